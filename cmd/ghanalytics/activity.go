@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/report"
+)
+
+// rankedRepoActivity is activity of a user in a repository and its place in the ranking, as it is printed in JSON.
+type rankedRepoActivity struct {
+	Rank                int    `json:"rank"`
+	ID                  string `json:"id"`
+	Name                string `json:"name"`
+	Activity            int    `json:"activity"`
+	PushedCommits       int    `json:"pushed_commits"`
+	CreatedPullRequests int    `json:"created_pull_requests"`
+}
+
+func printTopContributors(
+	ctx context.Context, l *loader, archivePath, repo string, n int, metric github.ActivityMetric, botsIncluded bool, format string,
+) error {
+	if err := checkFormat(format); err != nil {
+		return err
+	}
+
+	m, err := loadActivityMatrix(ctx, l, archivePath, "top-contributors")
+	if err != nil {
+		return err
+	}
+
+	stopTracking := l.timings.track("ranking")
+
+	// the repository is named by its real name, the matrix has pseudonyms
+	if l.pseudonymizer != nil {
+		repo = l.pseudonymizer.RepoName(repo)
+	}
+
+	topUsers, err := m.TopContributors(repo, n, metric, botsIncluded)
+	if err != nil {
+		return err
+	}
+
+	stopTracking()
+
+	if format == jsonFormat {
+		if err := writeJSON(os.Stdout, struct {
+			N      int                 `json:"n"`
+			Repo   string              `json:"repo"`
+			Metric string              `json:"metric"`
+			Users  []report.RankedUser `json:"users"`
+		}{N: n, Repo: repo, Metric: string(metric), Users: report.NewRankedUsers(topUsers)}); err != nil {
+			return err
+		}
+
+		return l.timings.print(os.Stderr)
+	}
+
+	fmt.Printf("top %d contributors of %s by %s:\n", n, repo, metric)
+
+	for i, u := range topUsers {
+		fmt.Printf(
+			"%3d. username: %30s| id: %10s| activity: %10d| pushed commits: %5d| created pull requests: %5d|\n",
+			i+1, u.Username, u.ID, u.Activity.Total(), u.Activity.PushedCommits, u.Activity.CreatedPullRequests,
+		)
+	}
+
+	return l.timings.print(os.Stderr)
+}
+
+func printTopReposOfUser(
+	ctx context.Context, l *loader, archivePath, username string, n int, metric github.ActivityMetric, format string,
+) error {
+	if err := checkFormat(format); err != nil {
+		return err
+	}
+
+	m, err := loadActivityMatrix(ctx, l, archivePath, "top-repos")
+	if err != nil {
+		return err
+	}
+
+	stopTracking := l.timings.track("ranking")
+
+	if l.pseudonymizer != nil {
+		username = l.pseudonymizer.Username(username)
+	}
+
+	topRepos, err := m.TopRepos(username, n, metric)
+	if err != nil {
+		return err
+	}
+
+	stopTracking()
+
+	if format == jsonFormat {
+		ranked := make([]rankedRepoActivity, len(topRepos))
+		for i, r := range topRepos {
+			ranked[i] = rankedRepoActivity{
+				Rank:                i + 1,
+				ID:                  r.ID,
+				Name:                r.Name,
+				Activity:            r.Activity.Total(),
+				PushedCommits:       r.Activity.PushedCommits,
+				CreatedPullRequests: r.Activity.CreatedPullRequests,
+			}
+		}
+
+		if err := writeJSON(os.Stdout, struct {
+			N      int                  `json:"n"`
+			User   string               `json:"user"`
+			Metric string               `json:"metric"`
+			Repos  []rankedRepoActivity `json:"repos"`
+		}{N: n, User: username, Metric: string(metric), Repos: ranked}); err != nil {
+			return err
+		}
+
+		return l.timings.print(os.Stderr)
+	}
+
+	fmt.Printf("top %d repositories of %s by %s:\n", n, username, metric)
+
+	for i, r := range topRepos {
+		fmt.Printf(
+			"%3d. name: %50s| id: %10s| activity: %10d| pushed commits: %5d| created pull requests: %5d|\n",
+			i+1, r.Name, r.ID, r.Activity.Total(), r.Activity.PushedCommits, r.Activity.CreatedPullRequests,
+		)
+	}
+
+	return l.timings.print(os.Stderr)
+}
+
+func loadActivityMatrix(ctx context.Context, l *loader, archivePath, command string) (*github.ActivityMatrix, error) {
+	ds, err := loadArchive(ctx, l, archivePath, command)
+	if err != nil {
+		return nil, err
+	}
+
+	defer l.timings.track("aggregation")()
+
+	return ds.ActivityMatrix(ctx)
+}
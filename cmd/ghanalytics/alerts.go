@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/alert"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+// alertStateSuffix is appended to the path of the rules file to get the default path of the state file.
+const alertStateSuffix = ".state.json"
+
+// alertOptions configure evaluating alert rules.
+type alertOptions struct {
+	rulesPath string
+	statePath string
+	// dryRun prints new alerts without delivering them or updating the state
+	dryRun bool
+	bots   bool
+	format string
+}
+
+// runAlerts evaluates the rules on the inputs, delivers alerts which started firing since the previous run
+// and prints them.
+func runAlerts(ctx context.Context, l *loader, inputs []string, opts alertOptions) error {
+	if err := checkFormat(opts.format); err != nil {
+		return err
+	}
+
+	rules, err := alert.Load(opts.rulesPath)
+	if err != nil {
+		return err
+	}
+
+	if opts.statePath == "" {
+		opts.statePath = opts.rulesPath + alertStateSuffix
+	}
+
+	state, err := alert.LoadState(opts.statePath)
+	if err != nil {
+		return err
+	}
+
+	users, repos, err := loadSamples(ctx, l, inputs, opts.bots)
+	if err != nil {
+		return err
+	}
+
+	stopTracking := l.timings.track("evaluation")
+
+	firing := rules.Evaluate(users, repos)
+	fresh := state.New(firing)
+
+	stopTracking()
+
+	if !opts.dryRun {
+		stopTracking = l.timings.track("delivery")
+
+		now := time.Now()
+		// failed deliveries stay pending, so they are retried by the next run, the state is saved anyway
+		// to keep the successful ones from being repeated
+		deliverErr := alert.NewSender().Deliver(ctx, rules.Deliveries, state, firing, now)
+
+		state.Update(firing, now)
+
+		if err := state.Save(opts.statePath); err != nil {
+			return err
+		}
+
+		if deliverErr != nil {
+			return deliverErr
+		}
+
+		stopTracking()
+	}
+
+	if opts.format == jsonFormat {
+		if err := writeJSON(os.Stdout, struct {
+			Firing int           `json:"firing"`
+			New    []alert.Alert `json:"new"`
+		}{Firing: len(firing), New: append([]alert.Alert{}, fresh...)}); err != nil {
+			return err
+		}
+
+		return l.timings.print(os.Stderr)
+	}
+
+	fmt.Printf("%d alerts firing, %d new:\n", len(firing), len(fresh))
+
+	for _, a := range fresh {
+		fmt.Println(a)
+	}
+
+	return l.timings.print(os.Stderr)
+}
+
+// loadSamples loads the inputs once and returns both the users and the repositories samples of them.
+func loadSamples(
+	ctx context.Context, l *loader, inputs []string, botsIncluded bool,
+) (*github.UsersSample, *github.ReposSample, error) {
+	ds, s, err := loadInputs(ctx, l, inputs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer l.timings.track("aggregation")()
+
+	if s != nil {
+		return s.UsersSample(botsIncluded), s.ReposSample(), nil
+	}
+
+	users, err := ds.UsersSample(ctx, botsIncluded)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	repos, err := ds.ReposSample(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return users, repos, nil
+}
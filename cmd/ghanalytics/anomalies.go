@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+// reportedAnomaly is an anomaly as it is printed in JSON.
+type reportedAnomaly struct {
+	Rank     int     `json:"rank"`
+	Period   string  `json:"period"`
+	Metric   string  `json:"metric"`
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	Value    int     `json:"value"`
+	Baseline float64 `json:"baseline"`
+	Scale    float64 `json:"scale"`
+	Z        float64 `json:"z"`
+	History  []int   `json:"history"`
+}
+
+func anomalyOptions(c *cli.Context) github.AnomalyOptions {
+	opts := github.AnomalyOptions{
+		Method:     github.AnomalyMethod(c.String("method")),
+		Threshold:  c.Float64("threshold"),
+		MinHistory: c.Int("min-history"),
+		MinValue:   c.Int("min-value"),
+		Alpha:      c.Float64("alpha"),
+	}
+
+	for _, m := range c.StringSlice("metric") {
+		opts.Metrics = append(opts.Metrics, github.AnomalyMetric(m))
+	}
+
+	return opts
+}
+
+func printAnomalies(
+	ctx context.Context, l *loader, inputs []string, n int, opts github.AnomalyOptions, botsIncluded bool, format string,
+) error {
+	if err := checkFormat(format); err != nil {
+		return err
+	}
+
+	if len(inputs) <= opts.MinHistory {
+		return errors.Wrapf(
+			github.ErrWrongParam, "anomalies need more than %d archives or snapshots, there are %d", opts.MinHistory, len(inputs),
+		)
+	}
+
+	periods := make([]github.Period, len(inputs))
+
+	for i, path := range inputs {
+		s, err := loadSnapshot(ctx, l, path)
+		if err != nil {
+			return err
+		}
+
+		periods[i] = github.Period{Name: filepath.Base(path), Users: s.UsersSample(botsIncluded), Repos: s.ReposSample()}
+	}
+
+	stopTracking := l.timings.track("detection")
+
+	anomalies, err := github.DetectAnomalies(ctx, periods, opts)
+	if err != nil {
+		return err
+	}
+
+	stopTracking()
+
+	if len(anomalies) > n {
+		anomalies = anomalies[:n]
+	}
+
+	if format == jsonFormat {
+		reported := make([]reportedAnomaly, len(anomalies))
+		for i, a := range anomalies {
+			reported[i] = reportedAnomaly{
+				Rank:     i + 1,
+				Period:   a.PeriodName,
+				Metric:   string(a.Metric),
+				ID:       a.ID,
+				Name:     a.Name,
+				Value:    a.Value,
+				Baseline: a.Baseline,
+				Scale:    a.Scale,
+				Z:        a.Z,
+				History:  a.History,
+			}
+		}
+
+		if err := writeJSON(os.Stdout, struct {
+			N         int               `json:"n"`
+			Method    string            `json:"method"`
+			Threshold float64           `json:"threshold"`
+			Anomalies []reportedAnomaly `json:"anomalies"`
+		}{N: n, Method: string(opts.Method), Threshold: opts.Threshold, Anomalies: reported}); err != nil {
+			return err
+		}
+
+		return l.timings.print(os.Stderr)
+	}
+
+	fmt.Printf("top %d anomalies by %s with |z| at least %.1f:\n", n, opts.Method, opts.Threshold)
+
+	for i, a := range anomalies {
+		history := make([]string, len(a.History))
+		for j, v := range a.History {
+			history[j] = fmt.Sprint(v)
+		}
+
+		fmt.Printf(
+			"%3d. period: %30s| metric: %18s| name: %50s| id: %10s| value: %6d| baseline: %9.1f ± %7.1f| z: %+8.1f| history: %s|\n",
+			i+1, a.PeriodName, a.Metric, a.Name, a.ID, a.Value, a.Baseline, a.Scale, a.Z, strings.Join(history, " "),
+		)
+	}
+
+	return l.timings.print(os.Stderr)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/snapshot"
+)
+
+// approxRanking describes an approximate ranking printed by a command.
+type approxRanking struct {
+	// title is printed above the text ranking, e.g. "repositories by pushed commits"
+	title string
+	// key is the key of the ranked items in JSON output, e.g. "repos"
+	key string
+	// countName names the ranked count in text output, e.g. "commits pushed"
+	countName string
+	// users is set if users are ranked, otherwise repositories are
+	users bool
+	rank  func(s *github.ArchiveStream, ctx context.Context, n int, opts github.ApproxOptions) ([]github.ApproxItem, error)
+}
+
+// rankedApproxItem is an approximately ranked item, as it is printed in JSON.
+type rankedApproxItem struct {
+	Rank  int    `json:"rank"`
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+	Error int    `json:"error"`
+}
+
+func approxOptions(c *cli.Context) github.ApproxOptions {
+	opts := github.DefaultApproxOptions()
+	opts.Capacity = c.Int("approx-capacity")
+
+	return opts
+}
+
+func printApproxTopN(
+	ctx context.Context, l *loader, inputs []string, n int, format string, opts github.ApproxOptions, r approxRanking,
+) error {
+	if err := checkFormat(format); err != nil {
+		return err
+	}
+
+	// sketches are built while events are streamed, snapshots have no events
+	errNotArchive := errors.Wrap(github.ErrWrongParam, "approximate rankings need a single archive, snapshots are ranked exactly")
+
+	if len(inputs) != 1 {
+		return errNotArchive
+	}
+
+	isSnapshot, err := snapshot.IsSnapshot(inputs[0])
+	if err != nil {
+		return err
+	}
+
+	if isSnapshot {
+		return errNotArchive
+	}
+
+	s, finish, err := l.stream(ctx, inputs[0])
+	if err != nil {
+		return err
+	}
+
+	stopTracking := l.timings.track("aggregation")
+
+	items, err := r.rank(s, ctx, n, opts)
+
+	finish()
+
+	if err != nil {
+		return err
+	}
+
+	stopTracking()
+
+	if p := l.pseudonymizer; p != nil {
+		for i := range items {
+			if r.users {
+				items[i].ID, items[i].Name = p.ActorID(items[i].ID), p.Username(items[i].Name)
+			} else {
+				items[i].ID, items[i].Name = p.RepoID(items[i].ID), p.RepoName(items[i].Name)
+			}
+		}
+	}
+
+	if format == jsonFormat {
+		ranked := make([]rankedApproxItem, len(items))
+		for i, item := range items {
+			ranked[i] = rankedApproxItem{Rank: i + 1, ID: item.ID, Name: item.Name, Count: item.Count, Error: item.Error}
+		}
+
+		if err := writeJSON(os.Stdout, map[string]interface{}{"n": n, "approximate": true, r.key: ranked}); err != nil {
+			return err
+		}
+
+		return l.timings.print(os.Stderr)
+	}
+
+	fmt.Printf("approximate top %d %s (true count is between count - error and count):\n", n, r.title)
+
+	for i, item := range items {
+		fmt.Printf(
+			"%3d. name: %50s| id: %10s| %s: %5d| error: %5d|\n",
+			i+1, item.Name, item.ID, r.countName, item.Count, item.Error,
+		)
+	}
+
+	return l.timings.print(os.Stderr)
+}
@@ -0,0 +1,511 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/config"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+// envPrefix prefixes environment variables of flags, e.g. GHANALYTICS_ARCHIVE for --archive.
+const envPrefix = "GHANALYTICS_"
+
+// Sources of effective settings, from the most to the least important.
+const (
+	flagSource    = "flag"
+	envSource     = "env"
+	profileSource = "profile"
+	fileSource    = "file"
+	defaultSource = "default"
+)
+
+// secretKeys are keys of settings which config show never prints.
+var secretKeys = map[string]bool{"pseudonymize-key": true}
+
+// settings are loaded from the configuration file before any command runs.
+type settings struct {
+	file    *config.File
+	profile string
+	// profileValues are settings of the profile, which override fileValues, the top-level settings of the file
+	profileValues config.Settings
+	fileValues    config.Settings
+	// globalFlags are values of global flags set on the command line by key
+	globalFlags map[string]string
+	// keys are keys of command flags
+	keys map[cli.Flag]flagKeys
+	// ambiguous are commands by keys without a definition shared by most commands, so they have to be scoped
+	ambiguous map[string][]string
+}
+
+// flagKeys are keys of a command flag in configuration files. The scoped key is the command followed by the key
+// of the flag, e.g. top-repos.by, and it is always used first. The shared key, e.g. archive, sets flags of all
+// commands which define them the way most commands do, it is empty for the others, e.g. --by of repo-health.
+type flagKeys struct {
+	scoped string
+	shared string
+}
+
+// configKey returns the key of the flag in configuration files, which is its longest name.
+func configKey(f cli.Flag) string {
+	var key string
+
+	for _, name := range f.Names() {
+		if len(name) > len(key) {
+			key = name
+		}
+	}
+
+	return key
+}
+
+// envVar returns the environment variable of the key, e.g. GHANALYTICS_TOP_REPOS_BY for top-repos.by.
+func envVar(key string) string {
+	return envPrefix + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(key))
+}
+
+// flagEnvVarsAndDefault returns environment variables field and default value of the flag.
+func flagEnvVarsAndDefault(f cli.Flag) (*[]string, string) {
+	switch f := f.(type) {
+	case *cli.StringFlag:
+		return &f.EnvVars, f.Value
+	case *cli.IntFlag:
+		return &f.EnvVars, fmt.Sprint(f.Value)
+	case *cli.Int64Flag:
+		return &f.EnvVars, fmt.Sprint(f.Value)
+	case *cli.UintFlag:
+		return &f.EnvVars, fmt.Sprint(f.Value)
+	case *cli.Float64Flag:
+		return &f.EnvVars, fmt.Sprint(f.Value)
+	case *cli.BoolFlag:
+		return &f.EnvVars, fmt.Sprint(f.Value)
+	case *cli.DurationFlag:
+		return &f.EnvVars, f.Value.String()
+	case *cli.StringSliceFlag:
+		if f.Value == nil {
+			return &f.EnvVars, ""
+		}
+
+		return &f.EnvVars, strings.Join(f.Value.Value(), ",")
+	default:
+		return nil, ""
+	}
+}
+
+// configure makes every flag of the app configurable with an environment variable and the configuration file,
+// with precedence flag > env > profile > file > default, and adds the config command. Flags of commands have
+// keys scoped by their commands, see flagKeys. The file is loaded before any command runs.
+func configure(app *cli.App) {
+	s := &settings{globalFlags: make(map[string]string)}
+
+	for _, f := range app.Flags {
+		bindEnv(f, configKey(f))
+	}
+
+	s.keys, s.ambiguous = commandFlagKeys(app.Commands)
+
+	before := app.Before
+	app.Before = func(c *cli.Context) error {
+		for _, name := range c.LocalFlagNames() {
+			if f := findFlag(app.Flags, name); f != nil {
+				s.globalFlags[configKey(f)] = fmt.Sprint(c.Value(name))
+			}
+		}
+
+		if err := s.load(c); err != nil {
+			return err
+		}
+
+		if err := s.apply(c, app.Flags); err != nil {
+			return err
+		}
+
+		if before != nil {
+			return before(c)
+		}
+
+		return nil
+	}
+
+	configureCommands(app.Commands, s)
+
+	app.Commands = append(app.Commands, &cli.Command{
+		Name:  "config",
+		Usage: "Inspects configuration from flags, " + envPrefix + "* environment variables and the configuration file",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "show",
+				Usage: "Prints effective configuration: every setting with its value, source and environment variable",
+				Action: func(c *cli.Context) error {
+					return showConfig(app, s)
+				},
+			},
+		},
+	})
+}
+
+// commandFlag is a flag of the command, which is a path of command names joined by dots.
+type commandFlag struct {
+	command string
+	flag    cli.Flag
+}
+
+// commandFlagKeys returns keys of flags of the commands and their subcommands, and commands by keys which have
+// no shared definition.
+func commandFlagKeys(commands []*cli.Command) (map[cli.Flag]flagKeys, map[string][]string) {
+	byKey := make(map[string][]commandFlag)
+
+	var walk func(prefix string, commands []*cli.Command)
+
+	walk = func(prefix string, commands []*cli.Command) {
+		for _, cmd := range commands {
+			for _, f := range cmd.Flags {
+				if isHidden(f) {
+					continue
+				}
+
+				byKey[configKey(f)] = append(byKey[configKey(f)], commandFlag{command: prefix + cmd.Name, flag: f})
+			}
+
+			walk(prefix+cmd.Name+".", cmd.Subcommands)
+		}
+	}
+
+	walk("", commands)
+
+	keys := make(map[cli.Flag]flagKeys)
+	ambiguous := make(map[string][]string)
+
+	for key, flags := range byKey {
+		shared := sharedDefinition(flags)
+
+		for _, cf := range flags {
+			keys[cf.flag] = flagKeys{scoped: cf.command + "." + key}
+
+			switch {
+			case shared == "":
+				ambiguous[key] = append(ambiguous[key], cf.command)
+			case flagDefinition(cf.flag) == shared:
+				keys[cf.flag] = flagKeys{scoped: cf.command + "." + key, shared: key}
+			}
+		}
+	}
+
+	return keys, ambiguous
+}
+
+// sharedDefinition returns the definition of the flags which most of them have, it is empty if there's no such one.
+func sharedDefinition(flags []commandFlag) string {
+	counts := make(map[string]int)
+
+	var shared string
+
+	for _, cf := range flags {
+		def := flagDefinition(cf.flag)
+		counts[def]++
+
+		if counts[def] > counts[shared] {
+			shared = def
+		}
+	}
+
+	for def, count := range counts {
+		if def != shared && count == counts[shared] {
+			return ""
+		}
+	}
+
+	return shared
+}
+
+// flagDefinition describes what the flag means, flags with the same definition can share a key.
+func flagDefinition(f cli.Flag) string {
+	_, def := flagEnvVarsAndDefault(f)
+
+	var usage string
+	if f, ok := f.(cli.DocGenerationFlag); ok {
+		usage = f.GetUsage()
+	}
+
+	return fmt.Sprintf("%T %q %q", f, def, usage)
+}
+
+// isHidden reports whether the flag is hidden, hidden flags have no settings.
+func isHidden(f cli.Flag) bool {
+	hidden := reflect.Indirect(reflect.ValueOf(f)).FieldByName("Hidden")
+
+	return hidden.IsValid() && hidden.Bool()
+}
+
+func findFlag(flags []cli.Flag, name string) cli.Flag {
+	for _, f := range flags {
+		for _, n := range f.Names() {
+			if n == name {
+				return f
+			}
+		}
+	}
+
+	return nil
+}
+
+func configureCommands(commands []*cli.Command, s *settings) {
+	for _, cmd := range commands {
+		for _, f := range cmd.Flags {
+			keys := s.keys[f]
+			bindEnv(f, keys.scoped, keys.shared)
+		}
+
+		if len(cmd.Subcommands) > 0 {
+			configureCommands(cmd.Subcommands, s)
+
+			continue
+		}
+
+		cmd := cmd
+		before := cmd.Before
+		cmd.Before = func(c *cli.Context) error {
+			if err := s.apply(c, cmd.Flags); err != nil {
+				return err
+			}
+
+			if before != nil {
+				return before(c)
+			}
+
+			return nil
+		}
+	}
+}
+
+// bindEnv makes the flag settable by environment variables of the keys, the first set one wins.
+func bindEnv(f cli.Flag, keys ...string) {
+	envVars, _ := flagEnvVarsAndDefault(f)
+	if envVars == nil {
+		return
+	}
+
+	for _, key := range keys {
+		if key != "" {
+			*envVars = append(*envVars, envVar(key))
+		}
+	}
+}
+
+// load reads the configuration file given by --config or the default one, if it exists, and selects the profile.
+// Keys which commands define differently can't be used without a command.
+func (s *settings) load(c *cli.Context) error {
+	path := c.String("config")
+
+	file, err := config.Load(path)
+	if !c.IsSet("config") && errors.Is(err, os.ErrNotExist) {
+		file, err = &config.File{}, nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	s.file = file
+	s.profile = c.String("profile")
+
+	// the file may choose the profile too, unless a flag or env already did
+	if values := file.Settings["profile"]; !c.IsSet("profile") && len(values) > 0 {
+		s.profile = values[0]
+	}
+
+	if _, err := file.Profile(s.profile); err != nil {
+		return err
+	}
+
+	s.profileValues, s.fileValues = file.Profiles[s.profile], file.Settings
+
+	for _, values := range []config.Settings{s.fileValues, s.profileValues} {
+		for key := range values {
+			if commands, ok := s.ambiguous[key]; ok {
+				return errors.Wrapf(github.ErrWrongParam, "setting %s of %s means different things in commands %s, "+
+					"scope it by a command, e.g. %s.%s", key, file.Path, strings.Join(commands, ", "), commands[0], key)
+			}
+		}
+	}
+
+	return nil
+}
+
+// lookup returns values of the first key set in the profile, or else in the top-level settings of the file.
+func (s *settings) lookup(keys ...string) (values []string, source string, ok bool) {
+	for _, layer := range []struct {
+		values config.Settings
+		source string
+	}{
+		{values: s.profileValues, source: profileSource},
+		{values: s.fileValues, source: fileSource},
+	} {
+		for _, key := range keys {
+			if values, ok := layer.values[key]; ok && key != "" {
+				return values, layer.source, true
+			}
+		}
+	}
+
+	return nil, "", false
+}
+
+// apply sets flags that are not set by command line or environment to values from the configuration file.
+func (s *settings) apply(c *cli.Context, flags []cli.Flag) error {
+	for _, f := range flags {
+		if isSet(c, f) || isHidden(f) {
+			continue
+		}
+
+		keys, ok := s.keys[f]
+		if !ok {
+			keys = flagKeys{shared: configKey(f)}
+		}
+
+		values, _, _ := s.lookup(keys.scoped, keys.shared)
+
+		for _, v := range values {
+			if err := c.Set(f.Names()[0], v); err != nil {
+				return errors.Wrapf(err, "bad value of %s in %s", configKey(f), s.file.Path)
+			}
+		}
+	}
+
+	return nil
+}
+
+func isSet(c *cli.Context, f cli.Flag) bool {
+	for _, name := range f.Names() {
+		if c.IsSet(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setting is an effective setting printed by config show.
+type setting struct {
+	key    string
+	value  string
+	env    string
+	source string
+}
+
+// showConfig prints global settings, shared settings of commands and scoped settings which are set or
+// which have no shared key.
+func showConfig(app *cli.App, s *settings) error {
+	byKey := make(map[string]setting)
+
+	// effective returns the value and the source of the flag, the env of the first key wins over the one of the second
+	effective := func(f cli.Flag, keys ...string) (string, string) {
+		for _, key := range keys {
+			if v, ok := os.LookupEnv(envVar(key)); ok && key != "" {
+				return v, envSource
+			}
+		}
+
+		if values, source, ok := s.lookup(keys...); ok {
+			return strings.Join(values, ","), source
+		}
+
+		_, def := flagEnvVarsAndDefault(f)
+
+		return def, defaultSource
+	}
+
+	// isScoped reports whether the scoped key is set by env or the file
+	isScoped := func(key string) bool {
+		_, env := os.LookupEnv(envVar(key))
+		_, _, file := s.lookup(key)
+
+		return env || file
+	}
+
+	for _, f := range app.Flags {
+		key := configKey(f)
+		if key == configKey(cli.HelpFlag) {
+			continue
+		}
+
+		// global flags can be given on the command line of config show too
+		if v, ok := s.globalFlags[key]; ok {
+			byKey[key] = setting{key: key, value: v, env: envVar(key), source: flagSource}
+
+			continue
+		}
+
+		value, source := effective(f, key)
+		byKey[key] = setting{key: key, value: value, env: envVar(key), source: source}
+	}
+
+	var walk func(commands []*cli.Command)
+
+	walk = func(commands []*cli.Command) {
+		for _, cmd := range commands {
+			for _, f := range cmd.Flags {
+				keys, ok := s.keys[f]
+				if !ok {
+					continue
+				}
+
+				if _, ok := byKey[keys.shared]; !ok && keys.shared != "" {
+					value, source := effective(f, keys.shared)
+					byKey[keys.shared] = setting{key: keys.shared, value: value, env: envVar(keys.shared), source: source}
+				}
+
+				if keys.shared == "" || isScoped(keys.scoped) {
+					value, source := effective(f, keys.scoped, keys.shared)
+					byKey[keys.scoped] = setting{key: keys.scoped, value: value, env: envVar(keys.scoped), source: source}
+				}
+			}
+
+			walk(cmd.Subcommands)
+		}
+	}
+
+	walk(app.Commands)
+
+	keys := make([]string, 0, len(byKey))
+	for key := range byKey {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	file := s.file.Path
+	if file == "" {
+		file = "none"
+	}
+
+	fmt.Printf("config file: %s\n", file)
+	fmt.Printf("profile: %s\n", s.profile)
+
+	if profiles := s.file.ProfileNames(); len(profiles) > 0 {
+		fmt.Printf("profiles: %s\n", strings.Join(profiles, ", "))
+	}
+
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "SETTING\tVALUE\tSOURCE\tENV")
+
+	for _, key := range keys {
+		st := byKey[key]
+		if secretKeys[key] && st.value != "" {
+			st.value = "(hidden)"
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", st.key, st.value, st.source, st.env)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+const testConfig = `top: 2
+top-repos-by-contributors.by: watchers
+by: commits
+profiles:
+  month:
+    top: 3
+`
+
+func TestConfigPrecedence(t *testing.T) {
+	path := writeConfig(t, testConfig)
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		// args are global flags, flags are flags of the command
+		args  []string
+		flags []string
+		want  int
+	}{
+		{name: "default", want: 10},
+		{name: "file", args: []string{"--config", path}, want: 2},
+		{name: "profile", args: []string{"--config", path, "--profile", "month"}, want: 3},
+		{name: "env", env: map[string]string{"GHANALYTICS_TOP": "4"}, args: []string{"--config", path, "--profile", "month"}, want: 4},
+		{
+			name: "command env",
+			env:  map[string]string{"GHANALYTICS_TOP": "4", "GHANALYTICS_TOP_USERS_TOP": "5"},
+			args: []string{"--config", path, "--profile", "month"},
+			want: 5,
+		},
+		{
+			name:  "flag",
+			env:   map[string]string{"GHANALYTICS_TOP_USERS_TOP": "5"},
+			args:  []string{"--config", path, "--profile", "month"},
+			flags: []string{"-n", "6"},
+			want:  6,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				setenv(t, key, value)
+			}
+
+			args := append(append(append([]string{}, tt.args...), "top-users"), tt.flags...)
+
+			out, err := run(t, append(args, "--format", "json", "--no-cache", "-p", sampleArchive)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var ranking struct {
+				N int `json:"n"`
+			}
+
+			if err := json.Unmarshal([]byte(out), &ranking); err != nil {
+				t.Fatal(err)
+			}
+
+			if ranking.N != tt.want {
+				t.Errorf("n = %d, want %d", ranking.N, tt.want)
+			}
+		})
+	}
+}
+
+// TestConfigScopes checks that a key doesn't set flags of commands which define it differently.
+func TestConfigScopes(t *testing.T) {
+	path := writeConfig(t, testConfig)
+
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"top-repos-by-contributors"}, want: string(github.WatchersMetric)},
+		{args: []string{"top-contributors", "--repo", "NixOS/nixpkgs"}, want: string(github.CommitsMetric)},
+	} {
+		args := append([]string{"--config", path}, tt.args...)
+
+		out, err := run(t, append(args, "--format", "json", "--no-cache", "-p", sampleArchive)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var ranking struct {
+			Metric string `json:"metric"`
+		}
+
+		if err := json.Unmarshal([]byte(out), &ranking); err != nil {
+			t.Fatal(err)
+		}
+
+		if ranking.Metric != tt.want {
+			t.Errorf("%s metric = %q, want %q", tt.args[0], ranking.Metric, tt.want)
+		}
+	}
+
+	ambiguous := writeConfig(t, "state: alerts.json\n")
+	if _, err := run(t, "--config", ambiguous, "stats", "--no-cache", "-p", sampleArchive); !errors.Is(err, github.ErrWrongParam) {
+		t.Errorf("error of an ambiguous setting = %v, want %v", err, github.ErrWrongParam)
+	}
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// TestRefusedEnrich checks that commands which don't join metadata refuse its flags, but not its settings,
+// which are meant for the commands joining it.
+func TestRefusedEnrich(t *testing.T) {
+	args := []string{"top-contributors", "--repo", "NixOS/nixpkgs", "--format", "json", "--no-cache", "-p", sampleArchive}
+
+	if _, err := run(t, append(args, "--where", "language=go")...); !errors.Is(err, github.ErrWrongParam) {
+		t.Errorf("error of --where = %v, want %v", err, github.ErrWrongParam)
+	}
+
+	path := writeConfig(t, "where: language=go\n")
+	if _, err := run(t, append([]string{"--config", path}, args...)...); err != nil {
+		t.Errorf("error of the where setting = %v, want none", err)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/sketch"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/snapshot"
+)
+
+// rankedRepoContributors is a repository with amounts of distinct actors and its place in the ranking,
+// as it is printed in JSON.
+type rankedRepoContributors struct {
+	Rank               int    `json:"rank"`
+	ID                 string `json:"id"`
+	Name               string `json:"name"`
+	Pushers            int    `json:"pushers"`
+	PullRequestAuthors int    `json:"pr_authors"`
+	Watchers           int    `json:"watchers"`
+	Contributors       int    `json:"contributors"`
+}
+
+// hllPrecision returns precision of HyperLogLog of the flags. The precision is checked before it is narrowed
+// to uint8, so big values are rejected instead of wrapping around.
+func hllPrecision(c *cli.Context) (uint8, error) {
+	precision := c.Uint("hll-precision")
+	if precision < sketch.MinPrecision || precision > sketch.MaxPrecision {
+		return 0, errors.Wrapf(github.ErrWrongParam, "--hll-precision should be between %d and %d",
+			sketch.MinPrecision, sketch.MaxPrecision)
+	}
+
+	return uint8(precision), nil
+}
+
+// loadContributors returns amounts of distinct actors of repositories of the inputs. With hll a single archive
+// is streamed, so its events are never held in memory. Snapshots keep sets of distinct actors, so they are
+// always counted exactly.
+func loadContributors(
+	ctx context.Context, l *loader, inputs []string, hll bool, precision uint8,
+) (*github.ContributorsSample, error) {
+	if hll && len(inputs) == 1 {
+		isSnapshot, err := snapshot.IsSnapshot(inputs[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if !isSnapshot {
+			return streamContributors(ctx, l, inputs[0], precision)
+		}
+	}
+
+	ds, s, err := loadInputs(ctx, l, inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	defer l.timings.track("aggregation")()
+
+	if s != nil {
+		return s.ContributorsSample(), nil
+	}
+
+	return ds.ContributorsSample(ctx)
+}
+
+// streamContributors estimates amounts of distinct actors of repositories of the archive while it is streamed.
+func streamContributors(ctx context.Context, l *loader, archivePath string, precision uint8) (*github.ContributorsSample, error) {
+	s, finish, err := l.stream(ctx, archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	stopTracking := l.timings.track("aggregation")
+
+	contributors, err := s.ContributorsSample(ctx, precision)
+
+	finish()
+
+	if err != nil {
+		return nil, err
+	}
+
+	stopTracking()
+
+	if l.pseudonymizer == nil {
+		return contributors, nil
+	}
+
+	pseudonymized := make(map[string]github.RepoContributors, len(contributors.M))
+
+	for _, rc := range contributors.M {
+		rc.ID, rc.Name = l.pseudonymizer.RepoID(rc.ID), l.pseudonymizer.RepoName(rc.Name)
+		pseudonymized[rc.ID] = rc
+	}
+
+	contributors.M = pseudonymized
+
+	return contributors, nil
+}
+
+func printTopNReposByContributors(
+	ctx context.Context, l *loader, inputs []string, n int, metric github.ContributorMetric, hll bool, precision uint8,
+	format string,
+) error {
+	if err := checkFormat(format); err != nil {
+		return err
+	}
+
+	contributors, err := loadContributors(ctx, l, inputs, hll, precision)
+	if err != nil {
+		return err
+	}
+
+	stopTracking := l.timings.track("ranking")
+
+	topRepos, err := contributors.TopNBy(n, metric)
+	if err != nil {
+		return err
+	}
+
+	stopTracking()
+
+	if format == jsonFormat {
+		ranked := make([]rankedRepoContributors, len(topRepos))
+		for i, r := range topRepos {
+			ranked[i] = rankedRepoContributors{
+				Rank:               i + 1,
+				ID:                 r.ID,
+				Name:               r.Name,
+				Pushers:            r.Pushers,
+				PullRequestAuthors: r.PullRequestAuthors,
+				Watchers:           r.Watchers,
+				Contributors:       r.Contributors,
+			}
+		}
+
+		if err := writeJSON(os.Stdout, struct {
+			N           int                      `json:"n"`
+			Metric      string                   `json:"metric"`
+			Approximate bool                     `json:"approximate"`
+			Repos       []rankedRepoContributors `json:"repos"`
+		}{N: n, Metric: string(metric), Approximate: contributors.Approximate, Repos: ranked}); err != nil {
+			return err
+		}
+
+		return l.timings.print(os.Stderr)
+	}
+
+	if contributors.Approximate {
+		fmt.Printf("top %d repositories by distinct %s (estimated):\n", n, metric)
+	} else {
+		fmt.Printf("top %d repositories by distinct %s:\n", n, metric)
+	}
+
+	for i, r := range topRepos {
+		fmt.Printf(
+			"%3d. name: %50s| id: %10s| contributors: %5d| pushers: %5d| PR authors: %5d| watchers: %5d|\n",
+			i+1, r.Name, r.ID, r.Contributors, r.Pushers, r.PullRequestAuthors, r.Watchers,
+		)
+	}
+
+	return l.timings.print(os.Stderr)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+// Entities metadata files are joined onto.
+const (
+	usersEntity = "users"
+	reposEntity = "repos"
+)
+
+// enrichment joins metadata files onto users and repositories, then filters and groups them by joined columns.
+type enrichment struct {
+	users []*github.MetadataTable
+	repos []*github.MetadataTable
+	// userColumns and repoColumns are joined columns in order of files, they are printed in text output
+	userColumns []string
+	repoColumns []string
+	where       []github.MetadataFilter
+	// groupBy is the column rankings are grouped by, rankings are not grouped if it is empty
+	groupBy string
+}
+
+func newEnrichment(c *cli.Context) (*enrichment, error) {
+	e := &enrichment{groupBy: c.String("group-by")}
+
+	for _, spec := range c.StringSlice("enrich") {
+		entity, table, err := readMetadataFile(spec)
+		if err != nil {
+			return nil, err
+		}
+
+		// metadata files are keyed by real IDs and names, samples by pseudonyms
+		if p := pseudonymizerOf(c); p != nil {
+			table = p.MetadataTable(table, entity == usersEntity)
+		}
+
+		if entity == usersEntity {
+			e.users = append(e.users, table)
+			e.userColumns = appendNew(e.userColumns, table.Columns)
+		} else {
+			e.repos = append(e.repos, table)
+			e.repoColumns = appendNew(e.repoColumns, table.Columns)
+		}
+	}
+
+	for _, s := range c.StringSlice("where") {
+		f, err := github.ParseMetadataFilter(s)
+		if err != nil {
+			return nil, err
+		}
+
+		e.where = append(e.where, f)
+	}
+
+	return e, nil
+}
+
+// refuseEnrich returns ErrWrongParam if refusedEnrichFlags of the command are set.
+func refuseEnrich(c *cli.Context) error {
+	for _, f := range refusedEnrichFlags() {
+		if name := f.Names()[0]; c.IsSet(name) {
+			return errors.Wrapf(github.ErrWrongParam, "%s doesn't join metadata, --%s works only with top-users, "+
+				"top-repos-by-commits and top-repos-by-watch-events", c.Command.Name, name)
+		}
+	}
+
+	return nil
+}
+
+// readMetadataFile reads the metadata file of spec, which is <users|repos>[:<key column>]=<path>.
+// The key column is id by default. Files with the .json extension are JSON, the others are CSV.
+func readMetadataFile(spec string) (string, *github.MetadataTable, error) {
+	idx := strings.Index(spec, "=")
+	if idx < 0 {
+		return "", nil, errors.Wrapf(github.ErrWrongParam, "--enrich %q should be <users|repos>[:<key column>]=<path>", spec)
+	}
+
+	target, path := spec[:idx], spec[idx+1:]
+	entity, key := target, github.IDColumn
+
+	if i := strings.Index(target, ":"); i >= 0 {
+		entity, key = target[:i], target[i+1:]
+	}
+
+	if entity != usersEntity && entity != reposEntity {
+		return "", nil, errors.Wrapf(github.ErrWrongParam, "--enrich %q joins onto %q, should be users or repos", spec, entity)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var table *github.MetadataTable
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		table, err = github.ReadMetadataJSON(f, key)
+	} else {
+		table, err = github.ReadMetadataCSV(f, key)
+	}
+
+	if err != nil {
+		return "", nil, errors.Wrap(err, path)
+	}
+
+	return entity, table, f.Close()
+}
+
+func appendNew(columns, more []string) []string {
+	for _, c := range more {
+		found := false
+
+		for _, known := range columns {
+			found = found || known == c
+		}
+
+		if !found {
+			columns = append(columns, c)
+		}
+	}
+
+	return columns
+}
+
+// userGroups joins metadata onto the users, filters them and returns their groups. There is one group
+// with an empty name if rankings are not grouped.
+func (e *enrichment) userGroups(users *github.UsersSample) map[string]*github.UsersSample {
+	users.Enrich(e.users...)
+
+	if len(e.where) > 0 {
+		users = users.Where(e.where...)
+	}
+
+	if e.groupBy == "" {
+		return map[string]*github.UsersSample{"": users}
+	}
+
+	return users.GroupBy(e.groupBy)
+}
+
+// repoGroups joins metadata onto the repositories, filters them and returns their groups. There is one group
+// with an empty name if rankings are not grouped.
+func (e *enrichment) repoGroups(repos *github.ReposSample) map[string]*github.ReposSample {
+	repos.Enrich(e.repos...)
+
+	if len(e.where) > 0 {
+		repos = repos.Where(e.where...)
+	}
+
+	if e.groupBy == "" {
+		return map[string]*github.ReposSample{"": repos}
+	}
+
+	return repos.GroupBy(e.groupBy)
+}
+
+// groupTitle returns the title of a ranking of the group.
+func (e *enrichment) groupTitle(title, group string) string {
+	if e.groupBy == "" {
+		return title
+	}
+
+	return fmt.Sprintf("%s, %s %s", title, e.groupBy, group)
+}
+
+// sortedGroups returns names of the groups sorted, with NoValue last.
+func sortedGroups(names []string) []string {
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == github.NoValue) != (names[j] == github.NoValue) {
+			return names[j] == github.NoValue
+		}
+
+		return names[i] < names[j]
+	})
+
+	return names
+}
+
+// metaColumns returns joined columns of text output.
+func metaColumns(meta github.Metadata, columns []string) string {
+	var b strings.Builder
+
+	for _, c := range columns {
+		fmt.Fprintf(&b, " %s: %s|", c, meta[c])
+	}
+
+	return b.String()
+}
+
+// active reports whether metadata is joined, filtered or grouped by.
+func (e *enrichment) active() bool {
+	return len(e.users) > 0 || len(e.repos) > 0 || len(e.where) > 0 || e.groupBy != ""
+}
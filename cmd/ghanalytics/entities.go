@@ -0,0 +1,133 @@
+package main
+
+import (
+	"github.com/urfave/cli/v2"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+// entityFilterKey is the key of the entity filter of the running command in metadata of the app.
+const entityFilterKey = "entity-filter"
+
+// patternsUsage describes values of entity filter flags.
+const patternsUsage = "a glob, a /regular expression/ or @file with one pattern per line. Can be repeated"
+
+// entityFilterFlags returns flags of the entity filter, except flags with the names, which commands use otherwise.
+func entityFilterFlags(except ...string) []cli.Flag {
+	all := []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "repo",
+			Usage: "Selects repositories by full name, e.g. kubernetes/*; " + patternsUsage,
+		},
+		&cli.StringSliceFlag{
+			Name:  "owner",
+			Usage: "Selects repositories by owner, e.g. golang; " + patternsUsage,
+		},
+		&cli.StringSliceFlag{
+			Name:  "user",
+			Usage: "Selects users by username, e.g. torvalds; " + patternsUsage,
+		},
+		&cli.StringSliceFlag{
+			Name:  "exclude-repo",
+			Usage: "Excludes repositories by full name; " + patternsUsage,
+		},
+		&cli.StringSliceFlag{
+			Name:  "exclude-owner",
+			Usage: "Excludes repositories by owner, e.g. our own organization; " + patternsUsage,
+		},
+		&cli.StringSliceFlag{
+			Name:  "exclude-user",
+			Usage: "Excludes users by username; " + patternsUsage,
+		},
+	}
+
+	flags := make([]cli.Flag, 0, len(all))
+
+	for _, f := range all {
+		if !contains(except, f.Names()[0]) {
+			flags = append(flags, f)
+		}
+	}
+
+	return flags
+}
+
+// filterEntities makes commands with entity filter flags parse them before they run, so bad patterns
+// are reported before anything is loaded. It must be called before configure, so flags are set from
+// the environment and the configuration file by then.
+func filterEntities(commands []*cli.Command) {
+	for _, cmd := range commands {
+		if len(cmd.Subcommands) > 0 {
+			filterEntities(cmd.Subcommands)
+
+			continue
+		}
+
+		// the exclude flags are never used otherwise, unlike include ones
+		if findFlag(cmd.Flags, "exclude-user") == nil {
+			continue
+		}
+
+		cmd := cmd
+		before := cmd.Before
+		cmd.Before = func(c *cli.Context) error {
+			if err := setupEntityFilter(c, cmd.Flags); err != nil {
+				return err
+			}
+
+			if before != nil {
+				return before(c)
+			}
+
+			return nil
+		}
+	}
+}
+
+// setupEntityFilter stores the entity filter of the flags in metadata of the app.
+func setupEntityFilter(c *cli.Context, flags []cli.Flag) error {
+	patterns := func(name string) []string {
+		if _, ok := findFlag(flags, name).(*cli.StringSliceFlag); !ok {
+			return nil
+		}
+
+		return c.StringSlice(name)
+	}
+
+	f, err := github.NewEntityFilter(github.EntityFilterOptions{
+		Repos:         patterns("repo"),
+		Owners:        patterns("owner"),
+		Users:         patterns("user"),
+		ExcludeRepos:  patterns("exclude-repo"),
+		ExcludeOwners: patterns("exclude-owner"),
+		ExcludeUsers:  patterns("exclude-user"),
+	})
+	if err != nil {
+		return err
+	}
+
+	if c.App.Metadata == nil {
+		c.App.Metadata = make(map[string]interface{})
+	}
+
+	c.App.Metadata[entityFilterKey] = f
+
+	return nil
+}
+
+// entityFilterOf returns the entity filter of the running command, or nil if it has none.
+func entityFilterOf(c *cli.Context) *github.EntityFilter {
+	f, _ := c.App.Metadata[entityFilterKey].(*github.EntityFilter)
+
+	return f
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+
+	return false
+}
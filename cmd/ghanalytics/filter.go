@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/pkg/csvtargz"
+)
+
+func filterArchive(ctx context.Context, l *loader, archivePath, outPath string, filter github.EventFilter) error {
+	archive, err := l.decodeArchive(ctx, archivePath)
+	if err != nil {
+		return err
+	}
+
+	stopTracking := l.timings.track("filtering")
+	sub := archive.Filter(filter)
+
+	if l.pseudonymizer != nil {
+		sub = l.pseudonymizer.Archive(sub)
+	}
+
+	stopTracking()
+	stopTracking = l.timings.track("encoding")
+
+	if err := csvtargz.EncodeToPath(outPath,
+		csvtargz.File{Name: github.ActorsCSVFilename, Src: sub.Actors},
+		csvtargz.File{Name: github.ReposCSVFilename, Src: sub.Repos},
+		csvtargz.File{Name: github.EventsCSVFilename, Src: sub.Events},
+		csvtargz.File{Name: github.CommitsCSVFilename, Src: sub.Commits},
+	); err != nil {
+		return err
+	}
+
+	stopTracking()
+
+	fmt.Printf(
+		"wrote %s: %d events, %d actors, %d repositories, %d commits\n",
+		outPath, len(sub.Events), len(sub.Actors), len(sub.Repos), len(sub.Commits),
+	)
+
+	return l.timings.print(os.Stderr)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/report"
+)
+
+func topNFlag() cli.Flag {
+	return &cli.IntFlag{
+		Name:    "n",
+		Aliases: []string{"top"},
+		Value:   10,
+		Usage:   "top N",
+	}
+}
+
+// topKFlag is amount of users and repositories in metrics, it bounds amount of label values.
+func topKFlag() cli.Flag {
+	return &cli.IntFlag{
+		Name:    "k",
+		Aliases: []string{"top-k"},
+		Value:   10,
+		Usage:   "Amount of top users and repositories in metrics, it bounds amount of label values",
+	}
+}
+
+func archivePathFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    "p",
+		Aliases: []string{"archive"},
+		Value:   "./samples/data.tar.gz",
+		Usage:   "Path to data.tar.gz",
+	}
+}
+
+func botsFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:  "bots",
+		Usage: "If flag is set, bots will be included in the report",
+	}
+}
+
+func noCacheFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:  "no-cache",
+		Usage: "If flag is set, archive is decoded without reading or writing cache",
+	}
+}
+
+func formatFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "format",
+		Value: textFormat,
+		Usage: "Output format: text or json",
+	}
+}
+
+func timingsFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:  "timings",
+		Usage: "If flag is set, time spent in each phase of the command is printed to stderr",
+	}
+}
+
+func approxFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name: "approx",
+		Usage: "If flag is set, ranking is approximated with streaming sketches in fixed memory, " +
+			"and every item is printed with its error bound",
+	}
+}
+
+func approxCapacityFlag() cli.Flag {
+	return &cli.IntFlag{
+		Name:  "approx-capacity",
+		Value: github.DefaultApproxOptions().Capacity,
+		Usage: "Amount of heavy hitters tracked in approximate mode, the bigger it is, the smaller errors are",
+	}
+}
+
+// snapshotFlag adds snapshots to inputs of a ranking command.
+func snapshotFlag() cli.Flag {
+	return &cli.StringSliceFlag{
+		Name: "snapshot",
+		Usage: "Path to a snapshot or an archive ranked together with the archive, can be repeated. " +
+			"If it is set, the archive is ranked only if -p is set too",
+	}
+}
+
+func snapshotOutputFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:     "o",
+		Aliases:  []string{"output"},
+		Required: true,
+		Usage:    "Path to the snapshot file to write",
+	}
+}
+
+func activityMetricFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "by",
+		Value: string(github.ActivityTotalMetric),
+		Usage: "Metric to sort by: activity (commits pushed and PRs created), commits or pull-requests",
+	}
+}
+
+// enrichFlags join metadata files onto users or repositories of a ranking command, filter and group them.
+func enrichFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name: "enrich",
+			Usage: "Metadata file joined onto users or repositories, <users|repos>[:<key column>]=<path>, can be repeated. " +
+				"Files with the .json extension are arrays of objects, the others are CSV with a header. " +
+				"The key column is id by default, any other key column is joined by name",
+		},
+		&cli.StringSliceFlag{
+			Name:  "where",
+			Usage: "Filter by a joined column, column=value or column!=value, can be repeated",
+		},
+		&cli.StringFlag{
+			Name:  "group-by",
+			Usage: "Joined column rankings are grouped by, e.g. language",
+		},
+	}
+}
+
+// refusedEnrichFlags are hidden enrichFlags of commands which don't join metadata, so refuseEnrich tells the user
+// instead of an unknown flag. Hidden flags have no settings, so settings of enrichFlags don't reach these commands.
+func refusedEnrichFlags() []cli.Flag {
+	flags := enrichFlags()
+
+	for _, f := range flags {
+		switch f := f.(type) {
+		case *cli.StringSliceFlag:
+			f.Hidden = true
+		case *cli.StringFlag:
+			f.Hidden = true
+		}
+	}
+
+	return flags
+}
+
+// starFlags configure detection of fake stars.
+func starFlags() []cli.Flag {
+	opts := github.DefaultStarOptions()
+
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:  "min-watchers",
+			Value: opts.MinWatchers,
+			Usage: "Repositories with less distinct watchers are not scored, their watchers say little",
+		},
+		&cli.IntFlag{
+			Name:  "min-ring-size",
+			Value: opts.MinRingSize,
+			Usage: "Least amount of accounts watching the same pair of repositories to make them a ring",
+		},
+		&cli.Float64Flag{
+			Name:  "new-account-share",
+			Value: opts.NewAccountShare,
+			Usage: "Share of actors with the biggest IDs which are new accounts, GitHub assigns IDs sequentially",
+		},
+	}
+}
+
+// anomalyFlags configure anomaly detection.
+func anomalyFlags() []cli.Flag {
+	opts := github.DefaultAnomalyOptions()
+
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "method",
+			Value: string(opts.Method),
+			Usage: "Baseline of previous periods: mad (median and median absolute deviation) or ewma",
+		},
+		&cli.StringSliceFlag{
+			Name:  "metric",
+			Usage: "Metric to check: repo-commits, repo-watch-events, user-commits or user-pull-requests, can be repeated. All by default",
+		},
+		&cli.Float64Flag{
+			Name:  "threshold",
+			Value: opts.Threshold,
+			Usage: "Least absolute z-score of an anomaly",
+		},
+		&cli.IntFlag{
+			Name:  "min-history",
+			Value: opts.MinHistory,
+			Usage: "Least amount of previous periods to compute a baseline",
+		},
+		&cli.IntFlag{
+			Name:  "min-value",
+			Value: opts.MinValue,
+			Usage: "Values are not anomalies if both they and their baselines are smaller",
+		},
+		&cli.Float64Flag{
+			Name:  "alpha",
+			Value: opts.Alpha,
+			Usage: "Weight of the most recent period in ewma",
+		},
+	}
+}
+
+func templateFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name: "template",
+		Usage: "Go template file the ranking is rendered with instead of --format, an HTML template if it ends with .html, " +
+			"or builtin:<name> of a built-in template: " + strings.Join(report.Builtins(), ", "),
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/generator"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/pkg/csvtargz"
+)
+
+// generatorConfig returns config of generated archive from command flags.
+// Amounts of actors and repositories that are not set are derived from amount of events.
+func generatorConfig(c *cli.Context) generator.Config {
+	cfg := generator.DefaultConfig(c.Int("events"))
+
+	if c.IsSet("actors") {
+		cfg.Actors = c.Int("actors")
+	}
+
+	if c.IsSet("repos") {
+		cfg.Repos = c.Int("repos")
+	}
+
+	cfg.RepoSkew = c.Float64("repo-skew")
+	cfg.ActorSkew = c.Float64("actor-skew")
+	cfg.Seed = c.Int64("seed")
+
+	return cfg
+}
+
+// generateArchive writes a synthetic archive. If samplePath is set, the activity profile is learned from it.
+func generateArchive(ctx context.Context, l *loader, cfg generator.Config, samplePath, outPath string) error {
+	if samplePath != "" {
+		sample, err := l.decodeArchive(ctx, samplePath)
+		if err != nil {
+			return err
+		}
+
+		cfg.Profile = generator.LearnProfile(sample)
+	}
+
+	stopTracking := l.timings.track("generation")
+
+	archive, err := generator.Generate(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	stopTracking()
+	stopTracking = l.timings.track("encoding")
+
+	if err := csvtargz.EncodeToPath(outPath,
+		csvtargz.File{Name: github.ActorsCSVFilename, Src: archive.Actors},
+		csvtargz.File{Name: github.ReposCSVFilename, Src: archive.Repos},
+		csvtargz.File{Name: github.EventsCSVFilename, Src: archive.Events},
+		csvtargz.File{Name: github.CommitsCSVFilename, Src: archive.Commits},
+	); err != nil {
+		return err
+	}
+
+	stopTracking()
+
+	fmt.Printf(
+		"wrote %s: %d events, %d actors, %d repositories, %d commits\n",
+		outPath, len(archive.Events), len(archive.Actors), len(archive.Repos), len(archive.Commits),
+	)
+
+	return l.timings.print(os.Stderr)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+// rankedRepoHealth is health of a repository and its place in the ranking, as it is printed in JSON.
+type rankedRepoHealth struct {
+	Rank                int     `json:"rank"`
+	ID                  string  `json:"id"`
+	Name                string  `json:"name"`
+	Contributors        int     `json:"contributors"`
+	Contributions       int     `json:"contributions"`
+	TopContributor      string  `json:"top_contributor"`
+	TopContributorShare float64 `json:"top_contributor_share"`
+	BusFactor           int     `json:"bus_factor"`
+	BusFactor80         int     `json:"bus_factor_80"`
+	Herfindahl          float64 `json:"herfindahl"`
+}
+
+func printTopNReposByHealth(
+	ctx context.Context, l *loader, archivePath string, n int, metric github.HealthMetric, minContributions int, format string,
+) error {
+	if err := checkFormat(format); err != nil {
+		return err
+	}
+
+	ds, err := loadArchive(ctx, l, archivePath, "repo-health")
+	if err != nil {
+		return err
+	}
+
+	stopTracking := l.timings.track("aggregation")
+
+	health, err := ds.RepoHealthSample(ctx)
+	if err != nil {
+		return err
+	}
+
+	stopTracking()
+	stopTracking = l.timings.track("ranking")
+
+	topRepos, err := health.TopNBy(n, metric, minContributions)
+	if err != nil {
+		return err
+	}
+
+	stopTracking()
+
+	if format == jsonFormat {
+		ranked := make([]rankedRepoHealth, len(topRepos))
+		for i, rh := range topRepos {
+			ranked[i] = rankedRepoHealth{
+				Rank:                i + 1,
+				ID:                  rh.ID,
+				Name:                rh.Name,
+				Contributors:        rh.Contributors(),
+				Contributions:       rh.Contributions,
+				TopContributor:      rh.Breakdown[0].Username,
+				TopContributorShare: rh.TopContributorShare,
+				BusFactor:           rh.BusFactor,
+				BusFactor80:         rh.BusFactor80,
+				Herfindahl:          rh.Herfindahl,
+			}
+		}
+
+		if err := writeJSON(os.Stdout, struct {
+			N                int                `json:"n"`
+			Metric           string             `json:"metric"`
+			MinContributions int                `json:"min_contributions"`
+			Repos            []rankedRepoHealth `json:"repos"`
+		}{N: n, Metric: string(metric), MinContributions: minContributions, Repos: ranked}); err != nil {
+			return err
+		}
+
+		return l.timings.print(os.Stderr)
+	}
+
+	fmt.Printf("top %d repositories with the most concentrated contributions by %s:\n", n, metric)
+
+	for i, rh := range topRepos {
+		fmt.Printf(
+			"%3d. name: %50s| id: %10s| contributors: %5d| contributions: %6d| top contributor: %30s| top share: %5.1f%%| "+
+				"bus factor: %3d| bus factor 80%%: %3d| herfindahl: %.3f|\n",
+			i+1, rh.Name, rh.ID, rh.Contributors(), rh.Contributions, rh.Breakdown[0].Username, rh.TopContributorShare*100,
+			rh.BusFactor, rh.BusFactor80, rh.Herfindahl,
+		)
+	}
+
+	return l.timings.print(os.Stderr)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/cache"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/pkg/csvtargz"
+)
+
+// loader loads datasets of archives the way command flags ask for.
+type loader struct {
+	cacheEnabled bool
+	// progressEnabled is set if progress of decoding should be reported to stderr.
+	progressEnabled bool
+	// timings is nil if they are not collected.
+	timings *timings
+	// stats is nil if health of loading is not collected.
+	stats *loaderStats
+	// filter selects users and repositories of datasets, it is nil if the command has no entity filter.
+	filter *github.EntityFilter
+	// pseudonymizer is nil if datasets are not pseudonymized.
+	pseudonymizer *github.Pseudonymizer
+	// ranked are entities the command aggregates, which decide whether the filter is exact on snapshot files.
+	ranked rankedEntities
+}
+
+func newLoader(c *cli.Context) *loader {
+	l := &loader{
+		cacheEnabled: !c.Bool("no-cache"),
+		// progress would only get in the way of a program reading JSON output
+		progressEnabled: !(c.String("format") == jsonFormat && !isTerminal(os.Stdout)),
+		filter:          entityFilterOf(c),
+		pseudonymizer:   pseudonymizerOf(c),
+		ranked:          rankedByCommand[c.Command.Name],
+	}
+
+	if c.Bool("timings") {
+		l.timings = newTimings()
+	}
+
+	return l
+}
+
+// load loads dataset of the archive with the users and repositories selected by the entity filter,
+// and pseudonymizes it if pseudonymization is enabled. Patterns of the filter match real names.
+func (l *loader) load(ctx context.Context, archivePath string) (*github.Dataset, error) {
+	ds, err := l.loadCached(ctx, archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !l.filter.Empty() {
+		stopTracking := l.timings.track("filtering")
+		ds = ds.Filter(l.filter)
+
+		stopTracking()
+	}
+
+	if l.pseudonymizer != nil {
+		defer l.timings.track("pseudonymizing")()
+
+		l.pseudonymizer.Dataset(ds)
+	}
+
+	return ds, nil
+}
+
+// loadCached loads dataset of the archive. If cache is enabled, dataset is read from the cache file
+// next to the archive, and the cache file is written on the first load. Cached datasets are never pseudonymized,
+// so the cache is shared by runs with any key.
+// Cache problems are never fatal: the archive is decoded as if there was no cache.
+func (l *loader) loadCached(ctx context.Context, archivePath string) (*github.Dataset, error) {
+	start := time.Now()
+
+	if !l.cacheEnabled {
+		ds, rows, err := l.decode(ctx, archivePath)
+		if err == nil {
+			l.stats.addLoad(csvSource, rows, time.Since(start))
+		}
+
+		return ds, err
+	}
+
+	stopTracking := l.timings.track("cache")
+
+	hash, err := cache.HashFile(ctx, archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	ds, rows, err := cache.Load(archivePath, hash)
+
+	stopTracking()
+
+	if err == nil {
+		l.stats.addLoad(cacheSource, rows, time.Since(start))
+
+		return ds, nil
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		log.Printf("ignoring cache of %s: %v", archivePath, err)
+	}
+
+	ds, rows, err = l.decode(ctx, archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	l.stats.addLoad(csvSource, rows, time.Since(start))
+
+	defer l.timings.track("cache")()
+
+	if err := cache.Store(archivePath, hash, ds, rows); err != nil {
+		log.Printf("can't write cache of %s: %v", archivePath, err)
+	}
+
+	return ds, nil
+}
+
+// decode decodes the archive and returns its dataset with amounts of its rows.
+func (l *loader) decode(ctx context.Context, archivePath string) (*github.Dataset, cache.Rows, error) {
+	archive, err := l.decodeArchive(ctx, archivePath)
+	if err != nil {
+		return nil, cache.Rows{}, err
+	}
+
+	rows := cache.Rows{Read: archive.Rows(), Rejected: archive.RejectedRows()}
+
+	defer l.timings.track("indexing")()
+
+	return archive.Dataset(), rows, nil
+}
+
+// stream returns stream of the archive for aggregations which don't hold the archive in memory. Streams bypass
+// the cache and can't be filtered by entities, which would need names of every user and repository of events.
+// The returned function should be called once the stream is aggregated, it clears the progress bar.
+func (l *loader) stream(ctx context.Context, archivePath string) (*github.ArchiveStream, func(), error) {
+	if !l.filter.Empty() {
+		return nil, nil, errors.Wrap(github.ErrWrongParam, "--approx and --hll stream the archive, so users and repositories can't be filtered")
+	}
+
+	onProgress, finish := l.progress(archivePath, []string{
+		github.ActorsCSVFilename, github.ReposCSVFilename, github.EventsCSVFilename, github.CommitsCSVFilename,
+	})
+
+	return github.NewArchiveStream(func(csvFilename string) (github.RowReader, error) {
+		r, err := csvtargz.OpenByPath(ctx, archivePath, csvFilename, csvtargz.WithProgress(onProgress))
+		if err != nil {
+			return nil, err
+		}
+
+		return r, nil
+	}), finish, nil
+}
+
+// progress returns the function receiving progress of decoding CSV files of the archive, which reports it
+// if progress is enabled and collects timings. finish clears the progress bar.
+func (l *loader) progress(archivePath string, filenames []string) (onProgress csvtargz.ProgressFunc, finish func()) {
+	var reporter *progressReporter
+
+	finish = func() {}
+
+	if l.progressEnabled {
+		reporter = newProgressReporter(os.Stderr, archivePath, filenames)
+		finish = reporter.finish
+	}
+
+	return func(p csvtargz.Progress) {
+		if reporter != nil {
+			reporter.report(p)
+		}
+
+		if p.Done {
+			l.timings.add("decompression", p.Decompression)
+			l.timings.add("decoding", p.Elapsed-p.Decompression)
+		}
+	}, finish
+}
+
+// decodeArchive decodes all CSV files of the archive, bypassing cache.
+func (l *loader) decodeArchive(ctx context.Context, archivePath string) (*github.Archive, error) {
+	var archive github.Archive
+
+	members := []struct {
+		filename string
+		dst      interface{}
+	}{
+		{filename: github.ActorsCSVFilename, dst: &archive.Actors},
+		{filename: github.ReposCSVFilename, dst: &archive.Repos},
+		{filename: github.EventsCSVFilename, dst: &archive.Events},
+		{filename: github.CommitsCSVFilename, dst: &archive.Commits},
+	}
+
+	filenames := make([]string, len(members))
+	for i := range members {
+		filenames[i] = members[i].filename
+	}
+
+	onProgress, finish := l.progress(archivePath, filenames)
+	defer finish()
+
+	// the first failed decode cancels the rest of them
+	g, ctx := errgroup.WithContext(ctx)
+
+	for _, m := range members {
+		m := m
+
+		g.Go(func() error {
+			return csvtargz.DecodeByPath(ctx, archivePath, m.filename, m.dst, csvtargz.WithProgress(onProgress))
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	return &archive, nil
+}
@@ -5,12 +5,18 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/urfave/cli/v2"
-	"golang.org/x/sync/errgroup"
 
+	"github.com/levakin/analytics-software-engineer-assignment/internal/cache"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/config"
 	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
-	"github.com/levakin/analytics-software-engineer-assignment/pkg/csvtargz"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/report"
 )
 
 const (
@@ -18,207 +24,875 @@ const (
 )
 
 func main() {
+	// the first SIGINT or SIGTERM cancels the context, so commands stop gracefully,
+	// the second one kills the application right away
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := newApp().RunContext(ctx, os.Args)
+
+	stop()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// newApp returns the application with all its commands, configurable by the environment and the configuration file.
+func newApp() *cli.App {
+	cancelTimeout := context.CancelFunc(func() {})
+
 	app := &cli.App{
 		Name:        projectName,
 		Description: "A cli application that can be used to get analytics information out of archive with csv files with GitHub data",
+		Flags: append([]cli.Flag{
+			&cli.DurationFlag{
+				Name:  "timeout",
+				Usage: "Stops a command if it runs longer than the timeout, e.g. 30s. No timeout if not set",
+			},
+			&cli.StringFlag{
+				Name:  "config",
+				Value: config.DefaultPath(),
+				Usage: "Path to the configuration file, it is optional unless set explicitly",
+			},
+			&cli.StringFlag{
+				Name:  "profile",
+				Usage: "Name of the profile of the configuration file to use",
+			},
+		}, pseudonymizeFlags()...),
+		Before: func(ctx *cli.Context) error {
+			if timeout := ctx.Duration("timeout"); timeout > 0 {
+				ctx.Context, cancelTimeout = context.WithTimeout(ctx.Context, timeout)
+			}
+
+			return setupPseudonymizer(ctx)
+		},
+		After: func(ctx *cli.Context) error {
+			defer cancelTimeout()
+
+			return writeLookup(ctx)
+		},
 		Commands: []*cli.Command{
 			{
 				Name:  "top-users",
 				Usage: "Prints top N active users sorted by amount of PRs created and commits pushed",
 
 				Action: func(ctx *cli.Context) error {
-					return printTopNUsersByPRsCreatedAndCommitsPushed(ctx.Context, ctx.String("p"), ctx.Int("n"), ctx.Bool("bots"))
+					e, err := newEnrichment(ctx)
+					if err != nil {
+						return err
+					}
+
+					tmpl, err := loadTemplate(ctx)
+					if err != nil {
+						return err
+					}
+
+					if ctx.Bool("approx") {
+						if e.active() || tmpl != nil {
+							return errors.Wrap(github.ErrWrongParam, "--approx can't be used with --enrich, --where, --group-by or --template")
+						}
+
+						return printApproxTopN(
+							ctx.Context, newLoader(ctx), rankingInputs(ctx), ctx.Int("n"), ctx.String("format"), approxOptions(ctx),
+							approxRanking{
+								title: "active users", key: "users", countName: "activity", users: true,
+								rank: func(s *github.ArchiveStream, c context.Context, n int, opts github.ApproxOptions) ([]github.ApproxItem, error) {
+									return s.ApproxTopNActiveUsers(c, n, ctx.Bool("bots"), opts)
+								},
+							},
+						)
+					}
+
+					return printTopNUsersByPRsCreatedAndCommitsPushed(
+						ctx.Context, newLoader(ctx), rankingInputs(ctx), ctx.Int("n"), ctx.Bool("bots"), ctx.String("format"), tmpl, e,
+					)
 				},
-				Flags: []cli.Flag{
-					&cli.IntFlag{
-						Name:  "n",
-						Value: 10,
-						Usage: "top N",
+				Flags: append(append([]cli.Flag{
+					topNFlag(), archivePathFlag(), snapshotFlag(), botsFlag(), noCacheFlag(), formatFlag(), timingsFlag(),
+					approxFlag(), approxCapacityFlag(),
+					templateFlag(),
+				}, enrichFlags()...), entityFilterFlags()...),
+			},
+			{
+				Name:  "top-repos-by-commits",
+				Usage: "Prints top N repositories sorted by amount of commits pushed",
+				Action: func(ctx *cli.Context) error {
+					e, err := newEnrichment(ctx)
+					if err != nil {
+						return err
+					}
+
+					tmpl, err := loadTemplate(ctx)
+					if err != nil {
+						return err
+					}
+
+					if ctx.Bool("approx") {
+						if e.active() || tmpl != nil {
+							return errors.Wrap(github.ErrWrongParam, "--approx can't be used with --enrich, --where, --group-by or --template")
+						}
+
+						return printApproxTopN(
+							ctx.Context, newLoader(ctx), rankingInputs(ctx), ctx.Int("n"), ctx.String("format"), approxOptions(ctx),
+							approxRanking{
+								title: "repositories by pushed commits", key: "repos", countName: "commits pushed",
+								rank: (*github.ArchiveStream).ApproxTopNByCommitsPushed,
+							},
+						)
+					}
+
+					return printTopNRepos(
+						ctx.Context, newLoader(ctx), rankingInputs(ctx), ctx.Int("n"), ctx.String("format"), tmpl, e, reposByPushedCommits,
+					)
+				},
+				Flags: append(append([]cli.Flag{
+					topNFlag(), archivePathFlag(), snapshotFlag(), noCacheFlag(), formatFlag(), timingsFlag(),
+					approxFlag(), approxCapacityFlag(),
+					templateFlag(),
+				}, enrichFlags()...), entityFilterFlags()...),
+			},
+			{
+				Name:  "top-repos-by-watch-events",
+				Usage: "Prints top N repositories sorted by amount of watch events",
+				Action: func(ctx *cli.Context) error {
+					e, err := newEnrichment(ctx)
+					if err != nil {
+						return err
+					}
+
+					tmpl, err := loadTemplate(ctx)
+					if err != nil {
+						return err
+					}
+
+					if ctx.Bool("approx") {
+						if e.active() || tmpl != nil {
+							return errors.Wrap(github.ErrWrongParam, "--approx can't be used with --enrich, --where, --group-by or --template")
+						}
+
+						return printApproxTopN(
+							ctx.Context, newLoader(ctx), rankingInputs(ctx), ctx.Int("n"), ctx.String("format"), approxOptions(ctx),
+							approxRanking{
+								title: "repositories by watch events", key: "repos", countName: "watch events",
+								rank: (*github.ArchiveStream).ApproxTopNByWatchEvents,
+							},
+						)
+					}
+
+					r := reposByWatchEvents
+					if ctx.Bool("organic") {
+						r = organicWatchEvents(starOptions(ctx))
+					}
+
+					return printTopNRepos(ctx.Context, newLoader(ctx), rankingInputs(ctx), ctx.Int("n"), ctx.String("format"), tmpl, e, r)
+				},
+				Flags: append(append(append([]cli.Flag{
+					topNFlag(), archivePathFlag(), snapshotFlag(), noCacheFlag(), formatFlag(), timingsFlag(),
+					approxFlag(), approxCapacityFlag(),
+					&cli.BoolFlag{
+						Name:  "organic",
+						Usage: "If flag is set, only watch events of watchers which are not suspicious are counted, see fake-stars",
 					},
+					templateFlag(),
+				}, enrichFlags()...), starFlags()...), entityFilterFlags()...),
+			},
+			{
+				Name: "top-repos-by-contributors",
+				Usage: "Prints top N repositories sorted by amount of distinct pushers, PR authors, watchers or contributors, " +
+					"who are actors that pushed commits or created pull requests",
+				Before: refuseEnrich,
+				Action: func(ctx *cli.Context) error {
+					precision, err := hllPrecision(ctx)
+					if err != nil {
+						return err
+					}
+
+					return printTopNReposByContributors(
+						ctx.Context, newLoader(ctx), rankingInputs(ctx), ctx.Int("n"), github.ContributorMetric(ctx.String("by")),
+						ctx.Bool("hll"), precision, ctx.String("format"),
+					)
+				},
+				Flags: append(append([]cli.Flag{
+					topNFlag(), archivePathFlag(), snapshotFlag(), noCacheFlag(), formatFlag(), timingsFlag(),
 					&cli.StringFlag{
-						Name:  "p",
-						Value: "./samples/data.tar.gz",
-						Usage: "Path to data.tar.gz",
+						Name:  "by",
+						Value: string(github.ContributorsMetric),
+						Usage: "Metric to sort by: pushers, pr-authors, watchers or contributors",
 					},
 					&cli.BoolFlag{
-						Name:  "bots",
-						Usage: "If flag is set, bots will be included in the report",
+						Name: "hll",
+						Usage: "If flag is set, distinct actors are estimated with HyperLogLog while the archive is streamed, " +
+							"which needs less memory on large archives",
 					},
+					&cli.UintFlag{
+						Name:  "hll-precision",
+						Value: uint(github.DefaultHLLPrecision),
+						Usage: "Precision of HyperLogLog from 4 to 16, relative error is about 1.04/sqrt(2^precision)",
+					},
+				}, refusedEnrichFlags()...), entityFilterFlags()...),
+			},
+			{
+				Name:   "top-contributors",
+				Usage:  "Prints top N users of the repository sorted by commits pushed and PRs created in it",
+				Before: refuseEnrich,
+				Action: func(ctx *cli.Context) error {
+					return printTopContributors(
+						ctx.Context, newLoader(ctx), ctx.String("p"), ctx.String("repo"), ctx.Int("n"),
+						github.ActivityMetric(ctx.String("by")), ctx.Bool("bots"), ctx.String("format"),
+					)
 				},
+				Flags: append(append([]cli.Flag{
+					topNFlag(), archivePathFlag(), botsFlag(), noCacheFlag(), formatFlag(), timingsFlag(), activityMetricFlag(),
+					&cli.StringFlag{
+						Name:     "repo",
+						Required: true,
+						Usage:    "Name of the repository, e.g. owner/name",
+					},
+				}, refusedEnrichFlags()...), entityFilterFlags("repo")...),
 			},
 			{
-				Name:  "top-repos-by-commits",
-				Usage: "Prints top N repositories sorted by amount of commits pushed",
+				Name:   "top-repos",
+				Usage:  "Prints top N repositories of the user sorted by commits pushed and PRs created by the user",
+				Before: refuseEnrich,
 				Action: func(ctx *cli.Context) error {
-					return printTopNReposByPushedCommits(ctx.Context, ctx.String("p"), ctx.Int("n"))
+					return printTopReposOfUser(
+						ctx.Context, newLoader(ctx), ctx.String("p"), ctx.String("user"), ctx.Int("n"),
+						github.ActivityMetric(ctx.String("by")), ctx.String("format"),
+					)
 				},
-				Flags: []cli.Flag{
+				Flags: append(append([]cli.Flag{
+					topNFlag(), archivePathFlag(), noCacheFlag(), formatFlag(), timingsFlag(), activityMetricFlag(),
+					&cli.StringFlag{
+						Name:     "user",
+						Required: true,
+						Usage:    "Username of the user",
+					},
+				}, refusedEnrichFlags()...), entityFilterFlags("user")...),
+			},
+			{
+				Name: "repo-health",
+				Usage: "Prints top N repositories with the most concentrated contributions, which are commits pushed and " +
+					"pull requests created: share of the top contributor, bus factor (contributors covering 50% and 80% " +
+					"of contributions) and Herfindahl index",
+				Before: refuseEnrich,
+				Action: func(ctx *cli.Context) error {
+					return printTopNReposByHealth(
+						ctx.Context, newLoader(ctx), ctx.String("p"), ctx.Int("n"), github.HealthMetric(ctx.String("by")),
+						ctx.Int("min-contributions"), ctx.String("format"),
+					)
+				},
+				Flags: append(append([]cli.Flag{
+					topNFlag(), archivePathFlag(), noCacheFlag(), formatFlag(), timingsFlag(),
+					&cli.StringFlag{
+						Name:  "by",
+						Value: string(github.BusFactorMetric),
+						Usage: "Metric to sort by: bus-factor, bus-factor-80, top-share or herfindahl",
+					},
 					&cli.IntFlag{
-						Name:  "n",
+						Name:  "min-contributions",
 						Value: 10,
-						Usage: "top N",
+						Usage: "Repositories with less contributions are not ranked, their concentration says little",
 					},
+				}, refusedEnrichFlags()...), entityFilterFlags()...),
+			},
+			{
+				Name: "fake-stars",
+				Usage: "Prints top N repositories with the most suspicious watch events, scored by shares of watchers " +
+					"which did nothing else, watched the same repositories as a ring, or are new or bot-like accounts",
+				Before: refuseEnrich,
+				Action: func(ctx *cli.Context) error {
+					return printTopNSuspiciousRepos(
+						ctx.Context, newLoader(ctx), ctx.String("p"), ctx.Int("n"), starOptions(ctx), ctx.String("format"),
+					)
+				},
+				Flags: append(append(append([]cli.Flag{
+					topNFlag(), archivePathFlag(), noCacheFlag(), formatFlag(), timingsFlag(),
+				}, starFlags()...), refusedEnrichFlags()...), entityFilterFlags()...),
+			},
+			{
+				Name: "report",
+				Usage: "Writes a self-contained HTML report with top users, top repositories by commits and by watch events, " +
+					"events by type and distribution charts",
+				Before: refuseEnrich,
+				Action: func(ctx *cli.Context) error {
+					return writeHTMLReport(
+						ctx.Context, newLoader(ctx), rankingInputs(ctx), ctx.Int("n"), ctx.Bool("bots"), ctx.String("html"),
+						ctx.String("title"),
+					)
+				},
+				Flags: append(append([]cli.Flag{
+					topNFlag(), archivePathFlag(), snapshotFlag(), botsFlag(), noCacheFlag(), timingsFlag(),
 					&cli.StringFlag{
-						Name:  "p",
-						Value: "./samples/data.tar.gz",
-						Usage: "Path to data.tar.gz",
+						Name:     "html",
+						Required: true,
+						Usage:    "Directory the report.html file is written to, it is created if it doesn't exist",
 					},
+					&cli.StringFlag{
+						Name:  "title",
+						Value: "GitHub activity report",
+						Usage: "Title of the report",
+					},
+				}, refusedEnrichFlags()...), entityFilterFlags()...),
+			},
+			{
+				Name: "stats",
+				Usage: "Prints distributions of commits pushed, pull requests and watch events among users and repositories: " +
+					"mean, median, percentiles, Gini coefficient, share of the top 1% and log-scale histograms",
+				Action: func(ctx *cli.Context) error {
+					return printStats(ctx.Context, newLoader(ctx), rankingInputs(ctx), ctx.Bool("bots"), ctx.String("format"))
 				},
+				Flags: append([]cli.Flag{archivePathFlag(), snapshotFlag(), botsFlag(), noCacheFlag(), formatFlag(), timingsFlag()}, entityFilterFlags()...),
 			},
 			{
-				Name:  "top-repos-by-watch-events",
-				Usage: "Prints top N repositories sorted by amount of watch events",
+				Name: "snapshot",
+				Usage: "Writes a snapshot of the archive: counters of its actors and repositories, which ranking commands " +
+					"take with --snapshot and merge combines into rollups, so the archive itself needn't be kept",
+				Action: func(ctx *cli.Context) error {
+					return writeSnapshot(ctx.Context, newLoader(ctx), []string{ctx.String("p")}, ctx.String("o"))
+				},
+				Flags: append([]cli.Flag{archivePathFlag(), noCacheFlag(), timingsFlag(), snapshotOutputFlag()}, entityFilterFlags()...),
+			},
+			{
+				Name:      "merge",
+				Usage:     "Merges snapshots and archives into one snapshot, e.g. hourly snapshots into a daily rollup",
+				ArgsUsage: "<snapshot or archive>...",
+				Description: "Merged snapshot is the same as a snapshot of all archives decoded together, and merging is " +
+					"associative, so rollups can be merged further. Merging snapshots of the same archive fails",
+				Action: func(ctx *cli.Context) error {
+					return writeSnapshot(ctx.Context, newLoader(ctx), ctx.Args().Slice(), ctx.String("o"))
+				},
+				Flags: append([]cli.Flag{noCacheFlag(), timingsFlag(), snapshotOutputFlag()}, entityFilterFlags()...),
+			},
+			{
+				Name:      "anomalies",
+				Usage:     "Prints top N values of metrics of repositories and users deviating the most from their own baselines",
+				ArgsUsage: "<snapshot or archive>...",
+				Description: "Archives and snapshots are periods of a series, in order. Every period with at least " +
+					"--min-history previous periods is compared with the baseline of the previous ones, the median and " +
+					"median absolute deviation (--method mad) or the exponentially weighted moving average and standard " +
+					"deviation (--method ewma), and values with an absolute z-score of at least --threshold are anomalies",
+				Action: func(ctx *cli.Context) error {
+					return printAnomalies(
+						ctx.Context, newLoader(ctx), ctx.Args().Slice(), ctx.Int("n"), anomalyOptions(ctx), ctx.Bool("bots"),
+						ctx.String("format"),
+					)
+				},
+				Flags: append(append([]cli.Flag{topNFlag(), botsFlag(), noCacheFlag(), formatFlag(), timingsFlag()}, anomalyFlags()...), entityFilterFlags()...),
+			},
+			{
+				Name: "alerts",
+				Usage: "Evaluates threshold rules of the rules file on users and repositories and delivers alerts " +
+					"which started firing since the previous run to webhooks, Slack or files",
+				Action: func(ctx *cli.Context) error {
+					return runAlerts(ctx.Context, newLoader(ctx), rankingInputs(ctx), alertOptions{
+						rulesPath: ctx.String("rules"),
+						statePath: ctx.String("state"),
+						dryRun:    ctx.Bool("dry-run"),
+						bots:      ctx.Bool("bots"),
+						format:    ctx.String("format"),
+					})
+				},
+				Flags: append([]cli.Flag{
+					archivePathFlag(), snapshotFlag(), botsFlag(), noCacheFlag(), formatFlag(), timingsFlag(),
+					&cli.StringFlag{
+						Name:     "rules",
+						Required: true,
+						Usage:    "Path to the JSON rules file with rules and deliveries",
+					},
+					&cli.StringFlag{
+						Name:  "state",
+						Usage: "Path to the file with alerts which are firing, the rules file path with " + alertStateSuffix + " by default",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "If flag is set, new alerts are printed, but not delivered, and the state is not updated",
+					},
+				}, entityFilterFlags()...),
+			},
+			{
+				Name: "export-metrics",
+				Usage: "Writes an OpenMetrics text file with commits pushed and watch events of top K repositories, " +
+					"activity of top K users, amounts of events per event type and health of loading the archive",
+				Before: refuseEnrich,
+				Action: func(ctx *cli.Context) error {
+					return exportMetrics(ctx.Context, newLoader(ctx), rankingInputs(ctx), ctx.String("o"), ctx.Int("k"), ctx.Bool("bots"))
+				},
+				Flags: append(append([]cli.Flag{
+					archivePathFlag(), snapshotFlag(), botsFlag(), noCacheFlag(), timingsFlag(), topKFlag(),
+					&cli.StringFlag{
+						Name:     "o",
+						Aliases:  []string{"output"},
+						Required: true,
+						Usage:    "Path to the metrics file to write, e.g. for the textfile collector",
+					},
+				}, refusedEnrichFlags()...), entityFilterFlags()...),
+			},
+			{
+				Name:   "serve",
+				Usage:  "Serves the metrics of export-metrics at /metrics, --metrics should be set",
+				Before: refuseEnrich,
+				Action: func(ctx *cli.Context) error {
+					return runServer(
+						ctx.Context, newLoader(ctx), rankingInputs(ctx), ctx.String("addr"), ctx.Bool("metrics"), ctx.Int("k"), ctx.Bool("bots"),
+					)
+				},
+				Flags: append(append([]cli.Flag{
+					archivePathFlag(), snapshotFlag(), noCacheFlag(), topKFlag(),
+					&cli.StringFlag{
+						Name:  "addr",
+						Value: "localhost:8080",
+						Usage: "Address to listen on",
+					},
+					&cli.BoolFlag{
+						Name:  "metrics",
+						Usage: "Serves metrics at /metrics in the OpenMetrics text format, it should be set",
+					},
+					botsFlag(),
+				}, refusedEnrichFlags()...), entityFilterFlags()...),
+			},
+			{
+				Name:      "watch",
+				Usage:     "Watches a directory for new archives and ingests only them into running aggregates",
+				ArgsUsage: "<dir>",
+				Description: "New archives are found every --interval, rankings are printed after they are ingested, " +
+					"or served like by serve if --addr is set. Ingested archives and aggregates are persisted " +
+					"in the --state file, so a restarted watch doesn't count any archive twice",
+				Action: func(ctx *cli.Context) error {
+					if ctx.NArg() != 1 {
+						return errors.New("watch needs exactly one directory")
+					}
+
+					dir := ctx.Args().First()
+
+					statePath := ctx.String("state")
+					if statePath == "" {
+						statePath = filepath.Join(dir, watchStateFilename)
+					}
+
+					return runWatch(ctx.Context, newLoader(ctx), watchOptions{
+						dir:       dir,
+						pattern:   ctx.String("pattern"),
+						statePath: statePath,
+						interval:  ctx.Duration("interval"),
+						settle:    ctx.Duration("settle"),
+						n:         ctx.Int("n"),
+						bots:      ctx.Bool("bots"),
+						format:    ctx.String("format"),
+						addr:      ctx.String("addr"),
+						metrics:   ctx.Bool("metrics"),
+						topK:      ctx.Int("k"),
+					})
+				},
+				Flags: append([]cli.Flag{
+					topNFlag(), botsFlag(), formatFlag(), topKFlag(),
+					&cli.StringFlag{
+						Name:  "pattern",
+						Value: "*.tar.gz",
+						Usage: "Pattern of names of archives, files of subdirectories are watched too",
+					},
+					&cli.StringFlag{
+						Name:  "state",
+						Usage: "Path to the file with ingested archives and aggregates (default: " + watchStateFilename + " in the directory)",
+					},
+					&cli.DurationFlag{
+						Name:  "interval",
+						Value: 10 * time.Second,
+						Usage: "How often the directory is checked for new archives",
+					},
+					&cli.DurationFlag{
+						Name:  "settle",
+						Value: 5 * time.Second,
+						Usage: "How long an archive should stay unmodified before it is ingested, so it isn't read while being written",
+					},
+					&cli.StringFlag{
+						Name:  "addr",
+						Usage: "If it is set together with --metrics, metrics are served on the address instead of rankings printed, see serve",
+					},
+					&cli.BoolFlag{
+						Name:  "metrics",
+						Usage: "If flag is set together with --addr, metrics are served at /metrics, it is required by --addr",
+					},
+				}, entityFilterFlags()...),
+			},
+			{
+				Name: "tui",
+				Usage: "Explores rankings of users and repositories interactively: switch rankings, change N, toggle bots, " +
+					"sort by any column, search by name and open a user or a repository to see its events and counterparts",
+				Action: func(ctx *cli.Context) error {
+					return runTUI(ctx.Context, newLoader(ctx), rankingInputs(ctx))
+				},
+				Flags: append([]cli.Flag{archivePathFlag(), snapshotFlag(), noCacheFlag()}, entityFilterFlags()...),
+			},
+			{
+				Name: "shell",
+				Usage: "Loads archives once and answers queries interactively, e.g. top users 20 --bots, top repos by watch 5, " +
+					"user torvalds, repo golang/go or load <path> to add another archive. Type help in the shell to list commands",
+				Action: func(ctx *cli.Context) error {
+					return runShell(ctx.Context, newLoader(ctx), rankingInputs(ctx), ctx.String("history"))
+				},
+				Flags: append([]cli.Flag{
+					archivePathFlag(), snapshotFlag(), noCacheFlag(),
+					&cli.StringFlag{
+						Name:  "history",
+						Value: defaultHistoryPath(),
+						Usage: "Path to the file with history of commands, no history is kept if it is empty",
+					},
+				}, entityFilterFlags()...),
+			},
+			{
+				Name:    "filter",
+				Aliases: []string{"extract"},
+				Usage: "Writes a sub-archive with events selected by repository, owner, actor and event type, " +
+					"and only the actors, repositories and commits they refer to",
+				Action: func(ctx *cli.Context) error {
+					return filterArchive(ctx.Context, newLoader(ctx), ctx.String("p"), ctx.String("o"), github.EventFilter{
+						Usernames:  ctx.StringSlice("actor"),
+						EventTypes: ctx.StringSlice("type"),
+						Entities:   entityFilterOf(ctx),
+					})
+				},
+				Flags: append([]cli.Flag{
+					archivePathFlag(),
+					&cli.StringFlag{
+						Name:     "o",
+						Aliases:  []string{"output"},
+						Required: true,
+						Usage:    "Path to the sub-archive to write",
+					},
+					&cli.StringSliceFlag{
+						Name:  "actor",
+						Usage: "Selects events made by the user, e.g. torvalds. Can be repeated",
+					},
+					&cli.StringSliceFlag{
+						Name:  "type",
+						Usage: "Selects events of the type, e.g. PushEvent. Can be repeated",
+					},
+					timingsFlag(),
+				}, entityFilterFlags()...),
+			},
+			{
+				Name: "generate",
+				Usage: "Writes a synthetic archive with the same layout as real ones, for load testing and fixtures. " +
+					"The same flags always generate the same archive",
 				Action: func(ctx *cli.Context) error {
-					return printTopNReposByWatchEvents(ctx.Context, ctx.String("p"), ctx.Int("n"))
+					return generateArchive(ctx.Context, newLoader(ctx), generatorConfig(ctx), ctx.String("sample"), ctx.String("o"))
 				},
 				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "o",
+						Aliases:  []string{"output"},
+						Required: true,
+						Usage:    "Path to the archive to write",
+					},
 					&cli.IntFlag{
-						Name:  "n",
-						Value: 10,
-						Usage: "top N",
+						Name:  "events",
+						Value: 327200,
+						Usage: "Amount of events",
+					},
+					&cli.IntFlag{
+						Name:  "actors",
+						Usage: "Amount of actors that may make events (default: 3/10 of events)",
+					},
+					&cli.IntFlag{
+						Name:  "repos",
+						Usage: "Amount of repositories where events may happen (default: 2/5 of events)",
+					},
+					&cli.Float64Flag{
+						Name:  "repo-skew",
+						Value: 1.1,
+						Usage: "Exponent of power law popularity of repositories, greater than 1",
+					},
+					&cli.Float64Flag{
+						Name:  "actor-skew",
+						Value: 1.2,
+						Usage: "Exponent of power law activity of actors, greater than 1",
+					},
+					&cli.Int64Flag{
+						Name:  "seed",
+						Value: 1,
+						Usage: "Seed of random numbers",
 					},
 					&cli.StringFlag{
-						Name:  "p",
-						Value: "./samples/data.tar.gz",
-						Usage: "Path to data.tar.gz",
+						Name:  "sample",
+						Usage: "Path to an archive to learn event types, bot share and commits per push from (default: built-in profile of the sample archive)",
+					},
+					timingsFlag(),
+				},
+			},
+			{
+				Name:  "cache",
+				Usage: "Manages cache of decoded archives",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "clean",
+						Usage: "Removes cache files of the archive",
+						Action: func(ctx *cli.Context) error {
+							return cleanCache(ctx.String("p"))
+						},
+						Flags: []cli.Flag{archivePathFlag()},
 					},
 				},
 			},
 		},
 	}
 
-	if err := app.Run(os.Args); err != nil {
-		log.Fatal(err)
-	}
+	filterEntities(app.Commands)
+	configure(app)
+
+	return app
 }
 
-func printTopNUsersByPRsCreatedAndCommitsPushed(ctx context.Context, archivePath string, n int, botsIncluded bool) error {
-	var (
-		actors  []github.ActorCSV
-		commits []github.CommitCSV
-		events  []github.EventCSV
-	)
+func printTopNUsersByPRsCreatedAndCommitsPushed(
+	ctx context.Context, l *loader, inputs []string, n int, botsIncluded bool, format string, tmpl report.Template, e *enrichment,
+) error {
+	if err := checkFormat(format); err != nil {
+		return err
+	}
 
-	g, _ := errgroup.WithContext(ctx)
+	users, err := loadUsersSample(ctx, l, inputs, botsIncluded)
+	if err != nil {
+		return err
+	}
 
-	g.Go(func() error {
-		return csvtargz.DecodeByPath(archivePath, github.ActorsCSVFilename, &actors)
-	})
+	stopTracking := l.timings.track("ranking")
 
-	g.Go(func() error {
-		return csvtargz.DecodeByPath(archivePath, github.EventsCSVFilename, &events)
-	})
+	groups := e.userGroups(users)
+	names := make([]string, 0, len(groups))
+	topUsers := make(map[string][]github.User, len(groups))
 
-	g.Go(func() error {
-		return csvtargz.DecodeByPath(archivePath, github.CommitsCSVFilename, &commits)
-	})
+	for name, g := range groups {
+		names = append(names, name)
 
-	if err := g.Wait(); err != nil {
-		return err
+		if topUsers[name], err = g.TopNActiveUsers(n); err != nil {
+			return err
+		}
 	}
 
-	users := github.NewUsersSample(actors, commits, events, botsIncluded)
+	stopTracking()
 
-	topUsers, err := users.TopNActiveUsers(n)
-	if err != nil {
-		return err
+	data := e.reportData(
+		"top-users", fmt.Sprintf("top %d active users", n), inputs, report.Params{N: n, Metric: "activity", Bots: botsIncluded},
+	)
+
+	for _, name := range sortedGroups(names) {
+		ranked := report.NewRankedUsers(topUsers[name])
+		if e.groupBy == "" {
+			data.Users = ranked
+		} else {
+			data.Groups = append(data.Groups, report.Group{Value: name, Users: ranked})
+		}
+	}
+
+	if tmpl != nil {
+		return executeTemplate(l, tmpl, data)
+	}
+
+	if format == jsonFormat {
+		var v interface{} = struct {
+			N     int                 `json:"n"`
+			Users []report.RankedUser `json:"users"`
+		}{N: n, Users: data.Users}
+
+		if e.groupBy != "" {
+			v = struct {
+				N       int            `json:"n"`
+				GroupBy string         `json:"group_by"`
+				Groups  []report.Group `json:"groups"`
+			}{N: n, GroupBy: e.groupBy, Groups: data.Groups}
+		}
+
+		if err := writeJSON(os.Stdout, v); err != nil {
+			return err
+		}
+
+		return l.timings.print(os.Stderr)
+	}
+
+	for _, name := range sortedGroups(names) {
+		printUsersTable(e.groupTitle(fmt.Sprintf("top %d active users", n), name), topUsers[name], e.userColumns)
 	}
 
-	fmt.Printf("top %d active users:\n", n)
+	return l.timings.print(os.Stderr)
+}
+
+func printUsersTable(title string, topUsers []github.User, columns []string) {
+	fmt.Printf("%s:\n", title)
 
 	for i, u := range topUsers {
 		fmt.Printf(
-			"%3d. username: %30s| id: %10s| activity: %10d| pushed commits: %5d| created pull requests: %5d|\n",
+			"%3d. username: %30s| id: %10s| activity: %10d| pushed commits: %5d| created pull requests: %5d|%s\n",
 			i+1, u.Username, u.ID, u.Activity.Total(), u.Activity.PushedCommits, u.Activity.CreatedPullRequests,
+			metaColumns(u.Meta, columns),
 		)
 	}
+}
 
-	return nil
+// repoRanking is a ranking of repositories printed by a command.
+type repoRanking struct {
+	command string
+	// title is printed above the text ranking, e.g. "repositories by pushed commits"
+	title string
+	// metric is the metric repositories are sorted by, as templates get it
+	metric string
+	rank   func(rs *github.ReposSample, n int) ([]github.Repo, error)
+	print  func(title string, topRepos []github.Repo, columns []string)
+	// load loads repositories of inputs, loadReposSample is used if it is nil
+	load func(ctx context.Context, l *loader, inputs []string) (*github.ReposSample, error)
 }
 
-func printTopNReposByPushedCommits(ctx context.Context, archivePath string, n int) error {
-	var (
-		commits  []github.CommitCSV
-		events   []github.EventCSV
-		repoCSVs []github.RepoCSV
-	)
+var (
+	reposByPushedCommits = repoRanking{
+		command: "top-repos-by-commits", metric: "commits",
+		title: "repositories by pushed commits", rank: (*github.ReposSample).TopNByCommitsPushed, print: printReposByPushedCommitsTable,
+	}
+	reposByWatchEvents = repoRanking{
+		command: "top-repos-by-watch-events", metric: "watch-events",
+		title: "repositories by watch events", rank: (*github.ReposSample).TopNByWatchEvents, print: printReposByWatchEventsTable,
+	}
+)
 
-	g, _ := errgroup.WithContext(ctx)
+func printTopNRepos(
+	ctx context.Context, l *loader, inputs []string, n int, format string, tmpl report.Template, e *enrichment, r repoRanking,
+) error {
+	if err := checkFormat(format); err != nil {
+		return err
+	}
 
-	g.Go(func() error {
-		return csvtargz.DecodeByPath(archivePath, github.ReposCSVFilename, &repoCSVs)
-	})
+	load := r.load
+	if load == nil {
+		load = loadReposSample
+	}
 
-	g.Go(func() error {
-		return csvtargz.DecodeByPath(archivePath, github.EventsCSVFilename, &events)
-	})
+	repos, err := load(ctx, l, inputs)
+	if err != nil {
+		return err
+	}
 
-	g.Go(func() error {
-		return csvtargz.DecodeByPath(archivePath, github.CommitsCSVFilename, &commits)
-	})
+	stopTracking := l.timings.track("ranking")
 
-	if err := g.Wait(); err != nil {
-		return err
+	groups := e.repoGroups(repos)
+	names := make([]string, 0, len(groups))
+	topRepos := make(map[string][]github.Repo, len(groups))
+
+	for name, g := range groups {
+		names = append(names, name)
+
+		if topRepos[name], err = r.rank(g, n); err != nil {
+			return err
+		}
 	}
 
-	repos := github.NewReposSample(events, repoCSVs, commits)
+	stopTracking()
 
-	topReposByPushedCommits, err := repos.TopNByCommitsPushed(n)
-	if err != nil {
-		return err
+	title := fmt.Sprintf("top %d %s", n, r.title)
+	data := e.reportData(r.command, title, inputs, report.Params{N: n, Metric: r.metric})
+
+	for _, name := range sortedGroups(names) {
+		ranked := report.NewRankedRepos(topRepos[name])
+		if e.groupBy == "" {
+			data.Repos = ranked
+		} else {
+			data.Groups = append(data.Groups, report.Group{Value: name, Repos: ranked})
+		}
 	}
 
-	fmt.Printf("top %d repositories by pushed commits:\n", n)
+	if tmpl != nil {
+		return executeTemplate(l, tmpl, data)
+	}
+
+	if format == jsonFormat {
+		var v interface{} = struct {
+			N     int                 `json:"n"`
+			Repos []report.RankedRepo `json:"repos"`
+		}{N: n, Repos: data.Repos}
+
+		if e.groupBy != "" {
+			v = struct {
+				N       int            `json:"n"`
+				GroupBy string         `json:"group_by"`
+				Groups  []report.Group `json:"groups"`
+			}{N: n, GroupBy: e.groupBy, Groups: data.Groups}
+		}
+
+		if err := writeJSON(os.Stdout, v); err != nil {
+			return err
+		}
+
+		return l.timings.print(os.Stderr)
+	}
+
+	for _, name := range sortedGroups(names) {
+		r.print(e.groupTitle(title, name), topRepos[name], e.repoColumns)
+	}
+
+	return l.timings.print(os.Stderr)
+}
 
-	for i, repo := range topReposByPushedCommits {
+func printReposByPushedCommitsTable(title string, topRepos []github.Repo, columns []string) {
+	fmt.Printf("%s:\n", title)
+
+	for i, repo := range topRepos {
 		fmt.Printf(
-			"%3d. name: %50s| id: %10s| commits pushed: %5d|\n",
-			i+1, repo.Name, repo.ID, repo.CommitsPushed,
+			"%3d. name: %50s| id: %10s| commits pushed: %5d|%s\n",
+			i+1, repo.Name, repo.ID, repo.CommitsPushed, metaColumns(repo.Meta, columns),
 		)
 	}
+}
 
-	return nil
+func printReposByWatchEventsTable(title string, topRepos []github.Repo, columns []string) {
+	fmt.Printf("%s:\n", title)
+
+	for i, repo := range topRepos {
+		fmt.Printf(
+			"%3d. name: %50s| id: %10s| watch events: %5d|%s\n",
+			i+1, repo.Name, repo.ID, repo.WatchEvents, metaColumns(repo.Meta, columns),
+		)
+	}
 }
 
-func printTopNReposByWatchEvents(ctx context.Context, archivePath string, n int) error {
-	var (
-		commits  []github.CommitCSV
-		events   []github.EventCSV
-		repoCSVs []github.RepoCSV
-	)
+func loadUsersSample(ctx context.Context, l *loader, inputs []string, botsIncluded bool) (*github.UsersSample, error) {
+	ds, s, err := loadInputs(ctx, l, inputs)
+	if err != nil {
+		return nil, err
+	}
 
-	g, _ := errgroup.WithContext(ctx)
+	defer l.timings.track("aggregation")()
 
-	g.Go(func() error {
-		return csvtargz.DecodeByPath(archivePath, github.ReposCSVFilename, &repoCSVs)
-	})
+	if s != nil {
+		return s.UsersSample(botsIncluded), nil
+	}
 
-	g.Go(func() error {
-		return csvtargz.DecodeByPath(archivePath, github.EventsCSVFilename, &events)
-	})
+	return ds.UsersSample(ctx, botsIncluded)
+}
 
-	g.Go(func() error {
-		return csvtargz.DecodeByPath(archivePath, github.CommitsCSVFilename, &commits)
-	})
+func loadReposSample(ctx context.Context, l *loader, inputs []string) (*github.ReposSample, error) {
+	ds, s, err := loadInputs(ctx, l, inputs)
+	if err != nil {
+		return nil, err
+	}
 
-	if err := g.Wait(); err != nil {
-		return err
+	defer l.timings.track("aggregation")()
+
+	if s != nil {
+		return s.ReposSample(), nil
 	}
 
-	repos := github.NewReposSample(events, repoCSVs, commits)
+	return ds.ReposSample(ctx)
+}
 
-	topReposByWatchEvents, err := repos.TopNByWatchEvents(10)
+func cleanCache(archivePath string) error {
+	removed, err := cache.Clean(archivePath)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("top %d repositories by watch events:\n", n)
-
-	for i, repo := range topReposByWatchEvents {
-		fmt.Printf(
-			"%3d. name: %50s| id: %10s| watch events: %5d|\n",
-			i+1, repo.Name, repo.ID, repo.WatchEvents,
-		)
+	for _, path := range removed {
+		fmt.Printf("removed %s\n", path)
 	}
 
 	return nil
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+const sampleArchive = "../../samples/data.tar.gz"
+
+// run runs the application with the arguments and returns what it printed to stdout.
+// The configuration file and the environment of the user are not read.
+func run(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	setenv(t, "XDG_CONFIG_HOME", t.TempDir())
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		_ = out.Close()
+	}()
+
+	stdout := os.Stdout
+	os.Stdout = out
+
+	err = newApp().RunContext(context.Background(), append([]string{projectName}, args...))
+
+	os.Stdout = stdout
+
+	if _, seekErr := out.Seek(0, io.SeekStart); seekErr != nil {
+		t.Fatal(seekErr)
+	}
+
+	printed, readErr := io.ReadAll(out)
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+
+	return string(printed), err
+}
+
+// setenv sets the environment variable until the end of the test.
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+
+	old, ok := os.LookupEnv(key)
+
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if ok {
+			_ = os.Setenv(key, old)
+		} else {
+			_ = os.Unsetenv(key)
+		}
+	})
+}
+
+// TestSnapshotEntityFilters checks that rankings of a snapshot filtered by entities are the same as rankings
+// of its archive, or the filter is refused if the snapshot can't tell them.
+func TestSnapshotEntityFilters(t *testing.T) {
+	snapshotPath := filepath.Join(t.TempDir(), "data.snapshot")
+
+	if _, err := run(t, "snapshot", "--no-cache", "-p", sampleArchive, "-o", snapshotPath); err != nil {
+		t.Fatal(err)
+	}
+
+	// rankings break ties in any order, so N is small enough to keep ties out of them
+	tests := []struct {
+		command string
+		n       string
+		filter  []string
+		wantErr bool
+	}{
+		{command: "top-users", n: "3", filter: []string{"--exclude-user", "direwolf-github", "--user", "/^[a-m]/"}},
+		{command: "top-users", n: "3", filter: []string{"--exclude-repo", "direwolf-github/my-app"}, wantErr: true},
+		{command: "top-repos-by-commits", n: "3", filter: []string{"--exclude-owner", "direwolf-github"}},
+		{command: "top-repos-by-commits", n: "3", filter: []string{"--exclude-user", "direwolf-github"}, wantErr: true},
+		{command: "top-repos-by-watch-events", n: "3", filter: []string{"--owner", "/^[n-z]/"}},
+		{command: "top-repos-by-watch-events", n: "3", filter: []string{"--user", "a*"}, wantErr: true},
+		{command: "top-repos-by-contributors", n: "1", filter: []string{"--exclude-user", "direwolf-github", "--exclude-owner", "NixOS"}},
+		{command: "stats", filter: []string{"--exclude-user", "direwolf-github"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.command+" "+tt.filter[0], func(t *testing.T) {
+			args := append([]string{tt.command, "--format", "json"}, tt.filter...)
+			if tt.n != "" {
+				args = append(args, "-n", tt.n)
+			}
+
+			want, err := run(t, append(args, "--no-cache", "-p", sampleArchive)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := run(t, append(args, "--snapshot", snapshotPath)...)
+			if tt.wantErr {
+				if !errors.Is(err, github.ErrWrongParam) {
+					t.Errorf("error = %v, want %v", err, github.ErrWrongParam)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != want {
+				t.Errorf("snapshot ranking = %s, want ranking of the archive %s", got, want)
+			}
+		})
+	}
+}
+
+// TestSnapshotInputs checks that metrics and shell rankings of a snapshot are the same as of its archive.
+func TestSnapshotInputs(t *testing.T) {
+	dir := t.TempDir()
+	snapshotPath := filepath.Join(dir, "data.snapshot")
+
+	if _, err := run(t, "snapshot", "--no-cache", "-p", sampleArchive, "-o", snapshotPath); err != nil {
+		t.Fatal(err)
+	}
+
+	// metrics of the loader differ, snapshots have no rows; top repositories by watch events tie after the first one
+	metrics := func(inputs ...string) string {
+		out := filepath.Join(dir, "metrics.txt")
+
+		if _, err := run(t, append([]string{"export-metrics", "--no-cache", "-k", "1", "-o", out}, inputs...)...); err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var lines []string
+
+		for _, line := range strings.Split(string(b), "\n") {
+			if !strings.Contains(line, "ghanalytics_loader_") {
+				lines = append(lines, line)
+			}
+		}
+
+		return strings.Join(lines, "\n")
+	}
+
+	if got, want := metrics("--snapshot", snapshotPath), metrics("-p", sampleArchive); got != want {
+		t.Errorf("metrics of the snapshot = %s, want metrics of the archive %s", got, want)
+	}
+
+	// the shell reads commands from stdin, which isn't a terminal in tests
+	shell := func(inputs ...string) string {
+		script := filepath.Join(dir, "script")
+		if err := os.WriteFile(script, []byte("top users 3\ntop repos by watch 1\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		stdin, err := os.Open(script)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer func() {
+			_ = stdin.Close()
+		}()
+
+		old := os.Stdin
+		os.Stdin = stdin
+
+		defer func() {
+			os.Stdin = old
+		}()
+
+		out, err := run(t, append([]string{"shell", "--no-cache", "--history", ""}, inputs...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the first line tells the loaded path
+		return out[strings.Index(out, "\n")+1:]
+	}
+
+	if got, want := shell("--snapshot", snapshotPath), shell("-p", sampleArchive); got != want {
+		t.Errorf("shell of the snapshot printed %s, want %s of the archive", got, want)
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/cache"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/openmetrics"
+)
+
+const metricsPrefix = "ghanalytics_"
+
+// Sources of loaded datasets, used as label values of loader metrics.
+const (
+	cacheSource = "cache"
+	csvSource   = "csv"
+)
+
+// loaderStats collects health of loading archives. Methods of nil *loaderStats do nothing,
+// so the loader doesn't have to check whether stats are collected.
+type loaderStats struct {
+	mu           sync.Mutex
+	rowsRead     map[string]int
+	rowsRejected map[string]int
+	loads        map[string]int
+	duration     time.Duration
+}
+
+func newLoaderStats() *loaderStats {
+	return &loaderStats{rowsRead: make(map[string]int), rowsRejected: make(map[string]int), loads: make(map[string]int)}
+}
+
+// addLoad counts a dataset loaded from the source in d with the rows of its archive.
+func (s *loaderStats) addLoad(source string, rows cache.Rows, d time.Duration) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.loads[source]++
+	s.duration += d
+
+	for filename, n := range rows.Read {
+		s.rowsRead[filename] += n
+	}
+
+	for filename, n := range rows.Rejected {
+		s.rowsRejected[filename] += n
+	}
+}
+
+// families returns loader metrics. Files and sources are few and fixed, so are the labels.
+func (s *loaderStats) families() []openmetrics.Family {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return []openmetrics.Family{
+		{
+			Name:    metricsPrefix + "loader_rows_read",
+			Type:    openmetrics.Counter,
+			Help:    "Rows of CSV files of loaded archives, datasets loaded from cache count rows of their archives",
+			Samples: labeledSamples("file", s.rowsRead),
+		},
+		{
+			Name:    metricsPrefix + "loader_rows_rejected",
+			Type:    openmetrics.Counter,
+			Help:    "Rows of CSV files which didn't make it into datasets: conflicting duplicates and commits of unknown push events",
+			Samples: labeledSamples("file", s.rowsRejected),
+		},
+		{
+			Name:    metricsPrefix + "loader_loads",
+			Type:    openmetrics.Counter,
+			Help:    "Datasets loaded by source, which is csv or cache",
+			Samples: labeledSamples("source", s.loads),
+		},
+		{
+			Name:    metricsPrefix + "loader_load_duration_seconds",
+			Type:    openmetrics.Counter,
+			Unit:    "seconds",
+			Help:    "Time spent loading datasets",
+			Samples: []openmetrics.Sample{{Value: s.duration.Seconds()}},
+		},
+	}
+}
+
+// labeledSamples returns a sample for every key of values, sorted by keys, with the key as the label value.
+func labeledSamples(label string, values map[string]int) []openmetrics.Sample {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	samples := make([]openmetrics.Sample, len(keys))
+	for i, k := range keys {
+		samples[i] = openmetrics.Sample{Labels: []openmetrics.Label{{Name: label, Value: k}}, Value: float64(values[k])}
+	}
+
+	return samples
+}
+
+// aggregates are samples of a dataset which rankings and metrics are built from.
+type aggregates struct {
+	users        *github.UsersSample
+	repos        *github.ReposSample
+	eventsByType map[string]int
+}
+
+// loadAggregates aggregates the dataset if the only input is an archive, or the merged snapshot of the inputs.
+func loadAggregates(ctx context.Context, l *loader, inputs []string, botsIncluded bool) (*aggregates, error) {
+	ds, s, err := loadInputs(ctx, l, inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	defer l.timings.track("aggregation")()
+
+	if s != nil {
+		return &aggregates{users: s.UsersSample(botsIncluded), repos: s.ReposSample(), eventsByType: s.EventsByType}, nil
+	}
+
+	return aggregate(ctx, ds, botsIncluded)
+}
+
+func aggregate(ctx context.Context, ds *github.Dataset, botsIncluded bool) (*aggregates, error) {
+	users, err := ds.UsersSample(ctx, botsIncluded)
+	if err != nil {
+		return nil, err
+	}
+
+	repos, err := ds.ReposSample(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	eventsByType, err := ds.EventsByType(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &aggregates{users: users, repos: repos, eventsByType: eventsByType}, nil
+}
+
+// metricFamilies returns metrics of top K users and repositories, totals per event type and health of the loader.
+// Only top K users and repositories get their own samples, so amount of label values is bounded by K.
+func metricFamilies(a *aggregates, k int, stats *loaderStats) ([]openmetrics.Family, error) {
+	topUsers, err := a.users.TopNActiveUsers(k)
+	if err != nil {
+		return nil, err
+	}
+
+	topByCommits, err := a.repos.TopNByCommitsPushed(k)
+	if err != nil {
+		return nil, err
+	}
+
+	topByWatches, err := a.repos.TopNByWatchEvents(k)
+	if err != nil {
+		return nil, err
+	}
+
+	userFamily := func(name, help string, value func(u github.User) int) openmetrics.Family {
+		samples := make([]openmetrics.Sample, len(topUsers))
+		for i, u := range topUsers {
+			samples[i] = openmetrics.Sample{
+				Labels: []openmetrics.Label{{Name: "user", Value: u.Username}, {Name: "id", Value: u.ID}},
+				Value:  float64(value(u)),
+			}
+		}
+
+		return openmetrics.Family{Name: metricsPrefix + name, Type: openmetrics.Gauge, Help: help, Samples: samples}
+	}
+
+	repoFamily := func(name, help string, repos []github.Repo, value func(r github.Repo) int) openmetrics.Family {
+		samples := make([]openmetrics.Sample, len(repos))
+		for i, r := range repos {
+			samples[i] = openmetrics.Sample{
+				Labels: []openmetrics.Label{{Name: "repo", Value: r.Name}, {Name: "id", Value: r.ID}},
+				Value:  float64(value(r)),
+			}
+		}
+
+		return openmetrics.Family{Name: metricsPrefix + name, Type: openmetrics.Gauge, Help: help, Samples: samples}
+	}
+
+	families := []openmetrics.Family{
+		userFamily("user_activity", "Commits pushed and pull requests created by top K active users",
+			func(u github.User) int { return u.Activity.Total() }),
+		userFamily("user_pushed_commits", "Commits pushed by top K active users",
+			func(u github.User) int { return u.Activity.PushedCommits }),
+		userFamily("user_created_pull_requests", "Pull requests created by top K active users",
+			func(u github.User) int { return u.Activity.CreatedPullRequests }),
+		repoFamily("repo_commits_pushed", "Commits pushed to top K repositories by pushed commits", topByCommits,
+			func(r github.Repo) int { return r.CommitsPushed }),
+		repoFamily("repo_watch_events", "Watch events of top K repositories by watch events", topByWatches,
+			func(r github.Repo) int { return r.WatchEvents }),
+		{
+			Name:    metricsPrefix + "events",
+			Type:    openmetrics.Gauge,
+			Help:    "Events by event type",
+			Samples: labeledSamples("type", a.eventsByType),
+		},
+	}
+
+	if stats != nil {
+		families = append(families, stats.families()...)
+	}
+
+	return families, nil
+}
+
+// exportMetrics writes metrics of the archive, or the merged snapshot of the inputs, to the file.
+func exportMetrics(ctx context.Context, l *loader, inputs []string, outPath string, k int, botsIncluded bool) error {
+	l.stats = newLoaderStats()
+
+	a, err := loadAggregates(ctx, l, inputs, botsIncluded)
+	if err != nil {
+		return err
+	}
+
+	families, err := metricFamilies(a, k, l.stats)
+	if err != nil {
+		return err
+	}
+
+	stopTracking := l.timings.track("writing")
+
+	if err := writeMetricsFile(outPath, families); err != nil {
+		return err
+	}
+
+	stopTracking()
+
+	return l.timings.print(os.Stderr)
+}
+
+// writeMetricsFile writes the families to a temporary file first, so a collector reading the file
+// never sees it partially written.
+func writeMetricsFile(path string, families []openmetrics.Family) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if err := tmp.Chmod(0o644); err != nil {
+		return err
+	}
+
+	if err := openmetrics.Write(tmp, families); err != nil {
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
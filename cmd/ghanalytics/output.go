@@ -0,0 +1,30 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Output formats of commands.
+const (
+	textFormat = "text"
+	jsonFormat = "json"
+)
+
+func checkFormat(format string) error {
+	switch format {
+	case textFormat, jsonFormat:
+		return nil
+	default:
+		return errors.Errorf("unknown output format %q, should be %s or %s", format, textFormat, jsonFormat)
+	}
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/levakin/analytics-software-engineer-assignment/pkg/csvtargz"
+)
+
+const (
+	ttyProgressInterval = 100 * time.Millisecond
+	logProgressInterval = 5 * time.Second
+	progressBarWidth    = 30
+)
+
+// progressReporter renders progress of decoding an archive: a progress bar on terminals and
+// periodic log lines otherwise. It is safe for concurrent use by goroutines decoding CSV files.
+type progressReporter struct {
+	mu       sync.Mutex
+	w        io.Writer
+	tty      bool
+	interval time.Duration
+	archive  string
+	members  []string
+	progress map[string]csvtargz.Progress
+	last     time.Time
+	rendered bool
+}
+
+func newProgressReporter(w *os.File, archivePath string, members []string) *progressReporter {
+	p := &progressReporter{
+		w:        w,
+		tty:      isTerminal(w),
+		interval: logProgressInterval,
+		archive:  archivePath,
+		members:  members,
+		progress: make(map[string]csvtargz.Progress),
+		last:     time.Now(),
+	}
+
+	if p.tty {
+		p.interval = ttyProgressInterval
+	}
+
+	return p
+}
+
+func (p *progressReporter) report(pr csvtargz.Progress) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.progress[pr.Member] = pr
+
+	if time.Since(p.last) < p.interval {
+		return
+	}
+
+	p.last = time.Now()
+	p.rendered = true
+
+	if p.tty {
+		_, _ = fmt.Fprintf(p.w, "\r%s\033[K", p.line())
+	} else {
+		log.Printf("loading %s", p.line())
+	}
+}
+
+// finish clears the progress bar, so it doesn't mix with the output.
+func (p *progressReporter) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.tty && p.rendered {
+		_, _ = fmt.Fprint(p.w, "\r\033[K")
+	}
+}
+
+func (p *progressReporter) line() string {
+	var consumed, total int64
+
+	parts := make([]string, 0, len(p.members))
+
+	for _, m := range p.members {
+		pr, ok := p.progress[m]
+		if !ok || pr.CompressedSize <= 0 {
+			continue
+		}
+
+		total += pr.CompressedSize
+
+		// a member is decoded without reading the rest of the archive
+		if pr.Done {
+			consumed += pr.CompressedSize
+		} else {
+			consumed += pr.CompressedBytes
+		}
+
+		parts = append(parts, fmt.Sprintf("%s: %d rows", path.Base(m), pr.Rows))
+	}
+
+	var ratio float64
+	if total > 0 {
+		ratio = float64(consumed) / float64(total)
+	}
+
+	filled := int(ratio * progressBarWidth)
+
+	bar := ""
+	if p.tty {
+		bar = "[" + strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled) + "] "
+	}
+
+	return fmt.Sprintf("%s %s%3.0f%% | %s", p.archive, bar, ratio*100, strings.Join(parts, ", "))
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
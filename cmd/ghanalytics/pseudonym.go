@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+// pseudonymizerKey is the key of the pseudonymizer in metadata of the app, it is not set unless
+// pseudonymization is enabled.
+const pseudonymizerKey = "pseudonymizer"
+
+func pseudonymizeFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name: "pseudonymize-key",
+			Usage: "Replaces usernames, actor IDs, repository names and IDs with HMAC pseudonyms keyed by the key " +
+				"in every output. The same key gives the same pseudonyms in every run",
+		},
+		&cli.StringFlag{
+			Name:  "pseudonymize-key-file",
+			Usage: "Path to a file with the key of --pseudonymize-key, so the key is not seen in the process list",
+		},
+		&cli.BoolFlag{
+			Name:  "pseudonymize-keep-owner",
+			Usage: "If flag is set, owners of repository names are kept and only the rest of names is pseudonymized",
+		},
+		&cli.StringFlag{
+			Name: "pseudonymize-lookup",
+			Usage: "Path to a CSV file to write pseudonyms of the run with their original values to. " +
+				"Nothing is written if it is not set",
+		},
+	}
+}
+
+// setupPseudonymizer stores the pseudonymizer in metadata of the app if a key is set.
+func setupPseudonymizer(c *cli.Context) error {
+	key := c.String("pseudonymize-key")
+
+	if path := c.String("pseudonymize-key-file"); path != "" {
+		if key != "" {
+			return errors.Wrap(github.ErrWrongParam, "--pseudonymize-key and --pseudonymize-key-file can't be set together")
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return errors.Wrap(err, "can't read pseudonymization key")
+		}
+
+		key = strings.TrimRight(string(data), "\r\n")
+	}
+
+	if key == "" {
+		if c.IsSet("pseudonymize-key") || c.String("pseudonymize-key-file") != "" {
+			return errors.Wrap(github.ErrWrongParam, "pseudonymization key is empty")
+		}
+
+		if c.Bool("pseudonymize-keep-owner") || c.String("pseudonymize-lookup") != "" {
+			return errors.Wrap(github.ErrWrongParam, "pseudonymization needs --pseudonymize-key or --pseudonymize-key-file")
+		}
+
+		return nil
+	}
+
+	p, err := github.NewPseudonymizer([]byte(key), c.Bool("pseudonymize-keep-owner"), c.String("pseudonymize-lookup") != "")
+	if err != nil {
+		return err
+	}
+
+	if c.App.Metadata == nil {
+		c.App.Metadata = make(map[string]interface{})
+	}
+
+	c.App.Metadata[pseudonymizerKey] = p
+
+	return nil
+}
+
+// pseudonymizerOf returns the pseudonymizer of the app, or nil if pseudonymization is disabled.
+func pseudonymizerOf(c *cli.Context) *github.Pseudonymizer {
+	p, _ := c.App.Metadata[pseudonymizerKey].(*github.Pseudonymizer)
+
+	return p
+}
+
+// writeLookup writes pseudonyms of the run with their original values to the file of --pseudonymize-lookup,
+// if it is set. The file is written to a temporary file first, so a partially written lookup is never read.
+func writeLookup(c *cli.Context) error {
+	path := c.String("pseudonymize-lookup")
+
+	p := pseudonymizerOf(c)
+	if path == "" || p == nil {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	// the lookup reverses pseudonyms, so only the owner may read it
+	if err := tmp.Chmod(0o600); err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(tmp)
+
+	if err := p.WriteLookup(w); err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
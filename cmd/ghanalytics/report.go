@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/report"
+)
+
+// htmlReportFilename is the name of the HTML report in its output directory.
+const htmlReportFilename = "report.html"
+
+func writeHTMLReport(ctx context.Context, l *loader, inputs []string, n int, botsIncluded bool, outDir, title string) error {
+	ds, s, err := loadInputs(ctx, l, inputs)
+	if err != nil {
+		return err
+	}
+
+	stopTracking := l.timings.track("aggregation")
+
+	var (
+		users  *github.UsersSample
+		repos  *github.ReposSample
+		byType map[string]int
+		stats  *github.Stats
+	)
+
+	if s != nil {
+		users, repos, byType, stats = s.UsersSample(botsIncluded), s.ReposSample(), s.EventsByType, s.Stats(botsIncluded)
+	} else if users, repos, byType, stats, err = aggregateReport(ctx, ds, botsIncluded); err != nil {
+		return err
+	}
+
+	stopTracking()
+	stopTracking = l.timings.track("ranking")
+
+	r := report.HTMLReport{
+		Title: title, GeneratedAt: time.Now(), Inputs: reportInputs(inputs), N: n, Bots: botsIncluded,
+		EventsByType: byType, Stats: stats,
+	}
+
+	topUsers, err := users.TopNActiveUsers(n)
+	if err != nil {
+		return err
+	}
+
+	topByCommits, err := repos.TopNByCommitsPushed(n)
+	if err != nil {
+		return err
+	}
+
+	topByWatches, err := repos.TopNByWatchEvents(n)
+	if err != nil {
+		return err
+	}
+
+	r.Users, r.ReposByCommits, r.ReposByWatchEvents = report.NewRankedUsers(topUsers),
+		report.NewRankedRepos(topByCommits), report.NewRankedRepos(topByWatches)
+
+	stopTracking()
+	stopTracking = l.timings.track("writing")
+
+	path := filepath.Join(outDir, htmlReportFilename)
+	if err := writeHTMLFile(path, &r); err != nil {
+		return err
+	}
+
+	stopTracking()
+
+	fmt.Printf("report written to %s\n", path)
+
+	return l.timings.print(os.Stderr)
+}
+
+func aggregateReport(
+	ctx context.Context, ds *github.Dataset, botsIncluded bool,
+) (*github.UsersSample, *github.ReposSample, map[string]int, *github.Stats, error) {
+	users, err := ds.UsersSample(ctx, botsIncluded)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	repos, err := ds.ReposSample(ctx)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	byType, err := ds.EventsByType(ctx)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	stats, err := ds.Stats(ctx, botsIncluded)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	return users, repos, byType, stats, nil
+}
+
+// writeHTMLFile writes the report to a temporary file first, so a half-written report is never shared.
+func writeHTMLFile(path string, r *report.HTMLReport) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if err := tmp.Chmod(0o644); err != nil {
+		return err
+	}
+
+	if err := report.WriteHTML(tmp, r); err != nil {
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
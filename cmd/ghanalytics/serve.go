@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/openmetrics"
+)
+
+const (
+	// shutdownTimeout is how long requests in progress may take after the server is asked to stop.
+	shutdownTimeout   = 5 * time.Second
+	readHeaderTimeout = 10 * time.Second
+	metricsPath       = "/metrics"
+)
+
+// errNoMetrics is returned when the server is started without --metrics, which is all it serves.
+var errNoMetrics = errors.Wrap(github.ErrWrongParam, "only metrics are served, --metrics should be set")
+
+// server serves metrics of a dataset.
+type server struct {
+	// topK is amount of users and repositories in metrics
+	topK int
+	// botsIncluded is set if bots are ranked in metrics
+	botsIncluded bool
+	stats        *loaderStats
+
+	mu           sync.RWMutex
+	users        *github.UsersSample
+	repos        *github.ReposSample
+	eventsByType map[string]int
+}
+
+// set replaces the served aggregates, users of a should include bots.
+func (s *server) set(a *aggregates) {
+	users := a.users
+	if !s.botsIncluded {
+		users = users.WithoutBots()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users = users
+	s.repos = a.repos
+	s.eventsByType = a.eventsByType
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc(metricsPath, func(w http.ResponseWriter, r *http.Request) {
+		s.mu.RLock()
+		families, err := metricFamilies(&aggregates{users: s.users, repos: s.repos, eventsByType: s.eventsByType}, s.topK, s.stats)
+		s.mu.RUnlock()
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", openmetrics.ContentType)
+
+		if err := openmetrics.Write(w, families); err != nil {
+			log.Printf("can't write metrics: %v", err)
+		}
+	})
+
+	return mux
+}
+
+// runServer loads the archive, or the merged snapshot of the inputs, and serves its metrics until ctx is done.
+func runServer(ctx context.Context, l *loader, inputs []string, addr string, metrics bool, topK int, botsIncluded bool) error {
+	if !metrics {
+		return errNoMetrics
+	}
+
+	s := &server{topK: topK, botsIncluded: botsIncluded, stats: newLoaderStats()}
+	l.stats = s.stats
+
+	a, err := loadAggregates(ctx, l, inputs, true)
+	if err != nil {
+		return err
+	}
+
+	s.set(a)
+
+	return serve(ctx, addr, s.handler())
+}
+
+// serve serves the handler on the address until ctx is done, then waits for requests in progress.
+func serve(ctx context.Context, addr string, handler http.Handler) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: readHeaderTimeout}
+	served := make(chan error, 1)
+
+	go func() {
+		served <- srv.Serve(listener)
+	}()
+
+	log.Printf("serving on http://%s", listener.Addr())
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	return srv.Shutdown(shutdownCtx)
+}
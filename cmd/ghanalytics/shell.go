@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/shell"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/snapshot"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/term"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/tui"
+)
+
+const shellPrompt = "ghanalytics> "
+
+// defaultHistoryPath returns path of the shell history file in the user cache directory,
+// or an empty string, which disables history, if there is no such directory.
+func defaultHistoryPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "ghanalytics", "shell_history")
+}
+
+// runShell loads the inputs, if they are set, and executes commands of the user until exit. Unless the only input
+// is an archive, the shell merges snapshots of inputs and of archives loaded later.
+// If stdin is not a terminal, commands are read line by line, so the shell can run scripts.
+func runShell(ctx context.Context, l *loader, inputs []string, historyPath string) error {
+	s := shell.New(l.load)
+
+	if len(inputs) > 1 || inputs[0] != "" {
+		merges, err := mergesSnapshots(inputs)
+		if err != nil {
+			return err
+		}
+
+		if merges {
+			s = shell.NewOfSnapshots(func(ctx context.Context, path string) (*snapshot.Snapshot, error) {
+				return loadSnapshot(ctx, l, path)
+			})
+		}
+
+		for _, path := range inputs {
+			if err := s.Exec(ctx, os.Stdout, "load "+path); err != nil {
+				return err
+			}
+		}
+	}
+
+	stdin := int(os.Stdin.Fd())
+	if !term.IsTerminal(stdin) {
+		return runShellScript(ctx, s, os.Stdin)
+	}
+
+	e := shell.NewEditor(readHistory(historyPath), s.Complete)
+
+	defer func() {
+		if err := writeHistory(historyPath, e.History()); err != nil {
+			log.Printf("can't write shell history: %v", err)
+		}
+	}()
+
+	state, err := term.MakeRaw(stdin)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = term.Restore(stdin, state)
+	}()
+
+	keys := make(chan []tui.Key)
+
+	// the reader is left blocked on stdin after the shell exits, the process exits soon after anyway
+	go func() {
+		buf := make([]byte, 256)
+
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+
+				return
+			}
+
+			keys <- tui.ParseKeys(buf[:n])
+		}
+	}()
+
+	draw := func() {
+		line := e.Line()
+		out := "\r" + shellPrompt + line + clearLineEnd
+
+		if back := len([]rune(line)) - e.Cursor(); back > 0 {
+			out += fmt.Sprintf("\x1b[%dD", back)
+		}
+
+		_, _ = os.Stdout.WriteString(out)
+	}
+
+	fmt.Println("type help to list commands, tab completes commands and names")
+	draw()
+
+	for {
+		var (
+			pressed []tui.Key
+			ok      bool
+		)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case pressed, ok = <-keys:
+			if !ok {
+				return nil
+			}
+		}
+
+		for _, k := range pressed {
+			switch e.Update(k) {
+			case shell.Submitted:
+				// keys of the batch were not drawn yet
+				draw()
+				_, _ = os.Stdout.WriteString("\n")
+
+				if err := execInterruptibly(ctx, s, e.Submit(), keys); errors.Is(err, shell.ErrExit) {
+					return nil
+				} else if ctx.Err() != nil {
+					return ctx.Err()
+				} else if err != nil {
+					fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				}
+			case shell.Interrupted:
+				_, _ = os.Stdout.WriteString("^C\n")
+			case shell.EOF:
+				_, _ = os.Stdout.WriteString("\n")
+
+				return nil
+			case shell.Editing:
+			}
+
+			if len(e.Suggestions) > 0 {
+				draw()
+				fmt.Printf("\n%s\n", strings.Join(e.Suggestions, "  "))
+			}
+		}
+
+		draw()
+	}
+}
+
+// execInterruptibly executes the line, ctrl-c cancels the command instead of exiting the shell.
+// Other keys pressed while the command runs are dropped.
+func execInterruptibly(ctx context.Context, s *shell.Shell, line string, keys <-chan []tui.Key) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- s.Exec(ctx, os.Stdout, line)
+	}()
+
+	for {
+		select {
+		case err := <-done:
+			return err
+		case pressed, ok := <-keys:
+			if !ok {
+				// stdin is closed, receiving from nil channel blocks until the command is done
+				keys = nil
+			}
+
+			for _, k := range pressed {
+				if k == tui.KeyCtrlC {
+					cancel()
+				}
+			}
+		}
+	}
+}
+
+// runShellScript executes commands read from r line by line. Errors of commands are reported and
+// don't stop the script, like they don't stop the interactive shell.
+func runShellScript(ctx context.Context, s *shell.Shell, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		err := s.Exec(ctx, os.Stdout, scanner.Text())
+
+		switch {
+		case errors.Is(err, shell.ErrExit):
+			return nil
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// readHistory reads lines of the history file. History is a convenience, so a missing or unreadable file
+// is the same as an empty one.
+func readHistory(path string) []string {
+	if path == "" {
+		return nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	return strings.FieldsFunc(string(b), func(r rune) bool { return r == '\n' })
+}
+
+func writeHistory(path string, history []string) error {
+	if path == "" || len(history) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, []byte(strings.Join(history, "\n")+"\n"), 0o600)
+}
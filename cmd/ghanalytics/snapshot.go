@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/cache"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/snapshot"
+)
+
+// rankedEntities are entities a command aggregates from snapshots. Snapshots have no activity of actors
+// in repositories, so an entity filter is exact on them only for some entities, see snapshot.Snapshot.Filter.
+type rankedEntities int
+
+const (
+	// rankedAll are both users and repositories, it is the default of commands
+	rankedAll rankedEntities = iota
+	rankedUsers
+	rankedRepos
+	// rankedContributors are distinct actors of repositories, which snapshots keep
+	rankedContributors
+)
+
+// rankedByCommand are entities of commands which don't aggregate all of them.
+var rankedByCommand = map[string]rankedEntities{
+	"top-users":                 rankedUsers,
+	"top-repos-by-commits":      rankedRepos,
+	"top-repos-by-watch-events": rankedRepos,
+	"top-repos-by-contributors": rankedContributors,
+}
+
+// rankingInputs returns archives and snapshots a ranking command should rank together.
+// The archive is ranked with snapshots only if it is set explicitly, so snapshots can be ranked on their own.
+func rankingInputs(c *cli.Context) []string {
+	snapshots := c.StringSlice("snapshot")
+	if len(snapshots) > 0 && !c.IsSet("p") {
+		return snapshots
+	}
+
+	return append([]string{c.String("p")}, snapshots...)
+}
+
+// mergesSnapshots reports whether inputs are aggregated as their merged snapshot, which they are
+// unless the only input is an archive.
+func mergesSnapshots(inputs []string) (bool, error) {
+	if len(inputs) != 1 {
+		return true, nil
+	}
+
+	return snapshot.IsSnapshot(inputs[0])
+}
+
+// loadInputs loads the dataset if the only input is an archive, so it is aggregated the way it always was.
+// Otherwise it returns the merged snapshot of all inputs.
+func loadInputs(ctx context.Context, l *loader, inputs []string) (*github.Dataset, *snapshot.Snapshot, error) {
+	merges, err := mergesSnapshots(inputs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !merges {
+		ds, err := l.load(ctx, inputs[0])
+
+		return ds, nil, err
+	}
+
+	s, err := mergeInputs(ctx, l, inputs)
+
+	return nil, s, err
+}
+
+// loadArchive loads the dataset of the archive for commands which need activity of actors in repositories,
+// which snapshots don't keep.
+func loadArchive(ctx context.Context, l *loader, archivePath, command string) (*github.Dataset, error) {
+	ds, _, err := loadInputs(ctx, l, []string{archivePath})
+	if err != nil {
+		return nil, err
+	}
+
+	if ds == nil {
+		return nil, errors.Wrapf(github.ErrWrongParam, "%s needs an archive, snapshots have no activity of actors in repositories", command)
+	}
+
+	return ds, nil
+}
+
+// mergeInputs returns the merged snapshot of archives and snapshots.
+func mergeInputs(ctx context.Context, l *loader, inputs []string) (*snapshot.Snapshot, error) {
+	snapshots := make([]*snapshot.Snapshot, len(inputs))
+
+	for i, path := range inputs {
+		s, err := loadSnapshot(ctx, l, path)
+		if err != nil {
+			return nil, err
+		}
+
+		snapshots[i] = s
+	}
+
+	defer l.timings.track("merging")()
+
+	return snapshot.Merge(snapshots...)
+}
+
+// loadSnapshot reads the snapshot file, or loads the archive and takes its snapshot.
+func loadSnapshot(ctx context.Context, l *loader, path string) (*snapshot.Snapshot, error) {
+	isSnapshot, err := snapshot.IsSnapshot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if isSnapshot {
+		if err := l.checkSnapshotFilter(path); err != nil {
+			return nil, err
+		}
+
+		defer l.timings.track("snapshot")()
+
+		s, err := snapshot.Load(path)
+		if err != nil {
+			return nil, err
+		}
+
+		s = s.Filter(l.filter)
+
+		if l.pseudonymizer != nil {
+			s.Pseudonymize(l.pseudonymizer)
+		}
+
+		return s, nil
+	}
+
+	ds, err := l.load(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer l.timings.track("snapshot")()
+
+	hash, err := cache.HashFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := snapshot.New(ctx, ds, snapshot.Source{Path: path, SHA256: hash, Events: ds.Events.Len()})
+	if err != nil {
+		return nil, err
+	}
+
+	s.Pseudonymized = l.pseudonymizer != nil
+
+	return s, nil
+}
+
+// checkSnapshotFilter returns ErrWrongParam unless the entity filter gives the same results on the snapshot file
+// as on its archives: users can't be filtered by repositories and repositories can't be filtered by users.
+func (l *loader) checkSnapshotFilter(path string) error {
+	var exact bool
+
+	switch l.ranked {
+	case rankedUsers:
+		exact = !l.filter.SelectsRepos()
+	case rankedRepos:
+		exact = !l.filter.SelectsUsers()
+	case rankedContributors:
+		exact = true
+	default:
+		exact = l.filter.Empty()
+	}
+
+	if exact {
+		return nil
+	}
+
+	return errors.Wrapf(github.ErrWrongParam, "snapshot %s has no activity of users in repositories, so users can't be "+
+		"filtered by repositories and repositories can't be filtered by users, filter its archives instead", path)
+}
+
+// writeSnapshot merges archives and snapshots into one snapshot file.
+func writeSnapshot(ctx context.Context, l *loader, inputs []string, outPath string) error {
+	if len(inputs) == 0 {
+		return errors.Wrap(github.ErrWrongParam, "nothing to merge, pass archives or snapshots as arguments")
+	}
+
+	s, err := mergeInputs(ctx, l, inputs)
+	if err != nil {
+		return err
+	}
+
+	stopTracking := l.timings.track("writing")
+
+	if err := snapshot.Save(outPath, s); err != nil {
+		return err
+	}
+
+	stopTracking()
+
+	var events int
+	for _, src := range s.Sources {
+		events += src.Events
+	}
+
+	fmt.Printf("wrote %s: %d archives, %d events, %d actors, %d repositories\n",
+		outPath, len(s.Sources), events, len(s.Actors), len(s.Repos))
+
+	return l.timings.print(os.Stderr)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+// rankedStarSuspicion is suspicion of watch events of a repository and its place in the ranking, as it is printed in JSON.
+type rankedStarSuspicion struct {
+	Rank               int     `json:"rank"`
+	ID                 string  `json:"id"`
+	Name               string  `json:"name"`
+	Score              float64 `json:"score"`
+	WatchEvents        int     `json:"watch_events"`
+	OrganicWatchEvents int     `json:"organic_watch_events"`
+	Watchers           int     `json:"watchers"`
+	IdleShare          float64 `json:"idle_share"`
+	RingRepo           string  `json:"ring_repo,omitempty"`
+	RingShare          float64 `json:"ring_share"`
+	NewOrBotShare      float64 `json:"new_or_bot_share"`
+}
+
+func starOptions(c *cli.Context) github.StarOptions {
+	return github.StarOptions{
+		MinWatchers:     c.Int("min-watchers"),
+		MinRingSize:     c.Int("min-ring-size"),
+		NewAccountShare: c.Float64("new-account-share"),
+	}
+}
+
+// organicWatchEvents ranks repositories by watch events of watchers which are not suspicious.
+func organicWatchEvents(opts github.StarOptions) repoRanking {
+	r := reposByWatchEvents
+	r.title, r.metric = "repositories by organic watch events", "organic-watch-events"
+	r.load = func(ctx context.Context, l *loader, inputs []string) (*github.ReposSample, error) {
+		if len(inputs) != 1 {
+			return nil, errors.Wrap(github.ErrWrongParam, "--organic needs a single archive")
+		}
+
+		ds, err := loadArchive(ctx, l, inputs[0], "--organic")
+		if err != nil {
+			return nil, err
+		}
+
+		defer l.timings.track("aggregation")()
+
+		ss, err := ds.StarSuspicionSample(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		repos, err := ds.ReposSample(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return ss.Organic(repos), nil
+	}
+
+	return r
+}
+
+func printTopNSuspiciousRepos(
+	ctx context.Context, l *loader, archivePath string, n int, opts github.StarOptions, format string,
+) error {
+	if err := checkFormat(format); err != nil {
+		return err
+	}
+
+	ds, err := loadArchive(ctx, l, archivePath, "fake-stars")
+	if err != nil {
+		return err
+	}
+
+	stopTracking := l.timings.track("aggregation")
+
+	ss, err := ds.StarSuspicionSample(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	stopTracking()
+	stopTracking = l.timings.track("ranking")
+
+	topRepos, err := ss.TopNBySuspicion(n)
+	if err != nil {
+		return err
+	}
+
+	stopTracking()
+
+	if format == jsonFormat {
+		ranked := make([]rankedStarSuspicion, len(topRepos))
+		for i, s := range topRepos {
+			ranked[i] = rankedStarSuspicion{
+				Rank:               i + 1,
+				ID:                 s.ID,
+				Name:               s.Name,
+				Score:              s.Score,
+				WatchEvents:        s.WatchEvents,
+				OrganicWatchEvents: s.OrganicWatchEvents,
+				Watchers:           s.Watchers,
+				IdleShare:          s.IdleShare,
+				RingRepo:           s.RingRepo,
+				RingShare:          s.RingShare,
+				NewOrBotShare:      s.NewOrBotShare,
+			}
+		}
+
+		if err := writeJSON(os.Stdout, struct {
+			N     int                   `json:"n"`
+			Repos []rankedStarSuspicion `json:"repos"`
+		}{N: n, Repos: ranked}); err != nil {
+			return err
+		}
+
+		return l.timings.print(os.Stderr)
+	}
+
+	fmt.Printf("top %d repositories with the most suspicious watch events:\n", n)
+
+	for i, s := range topRepos {
+		fmt.Printf(
+			"%3d. name: %50s| id: %10s| score: %.3f| watch events: %5d| organic: %5d| watchers: %5d| idle: %5.1f%%| "+
+				"ring: %5.1f%% %s| new or bots: %5.1f%%|\n",
+			i+1, s.Name, s.ID, s.Score, s.WatchEvents, s.OrganicWatchEvents, s.Watchers, s.IdleShare*100,
+			s.RingShare*100, s.RingRepo, s.NewOrBotShare*100,
+		)
+	}
+
+	return l.timings.print(os.Stderr)
+}
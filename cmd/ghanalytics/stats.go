@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+// histogramWidth is width of the longest histogram bar in characters.
+const histogramWidth = 40
+
+func printStats(ctx context.Context, l *loader, inputs []string, botsIncluded bool, format string) error {
+	if err := checkFormat(format); err != nil {
+		return err
+	}
+
+	ds, s, err := loadInputs(ctx, l, inputs)
+	if err != nil {
+		return err
+	}
+
+	stopTracking := l.timings.track("aggregation")
+
+	var stats *github.Stats
+
+	if s != nil {
+		stats = s.Stats(botsIncluded)
+	} else if stats, err = ds.Stats(ctx, botsIncluded); err != nil {
+		return err
+	}
+
+	stopTracking()
+
+	if format == jsonFormat {
+		if err := writeJSON(os.Stdout, stats); err != nil {
+			return err
+		}
+
+		return l.timings.print(os.Stderr)
+	}
+
+	for _, entity := range []struct {
+		name  string
+		stats github.EntityStats
+	}{
+		{name: "users", stats: stats.Users},
+		{name: "repositories", stats: stats.Repos},
+	} {
+		fmt.Printf("%s: %d\n", entity.name, entity.stats.Count)
+
+		printDistribution(os.Stdout, "commits pushed", entity.stats.CommitsPushed)
+		printDistribution(os.Stdout, "pull requests", entity.stats.PullRequests)
+		printDistribution(os.Stdout, "watch events", entity.stats.WatchEvents)
+	}
+
+	return l.timings.print(os.Stderr)
+}
+
+func printDistribution(w io.Writer, name string, d github.Distribution) {
+	fmt.Fprintf(w,
+		"\n  %s: sum %d, mean %.2f, median %d, p90 %d, p99 %d, max %d, gini %.3f, top 1%% share %.1f%%\n",
+		name, d.Sum, d.Mean, d.Median, d.P90, d.P99, d.Max, d.Gini, d.Top1PercentShare*100,
+	)
+
+	var maxCount int
+
+	for _, b := range d.Histogram {
+		if b.Count > maxCount {
+			maxCount = b.Count
+		}
+	}
+
+	for _, b := range d.Histogram {
+		// bars are scaled to the fullest bucket, any non-empty bucket gets at least one character
+		bar := b.Count * histogramWidth / maxCount
+		if bar == 0 && b.Count > 0 {
+			bar = 1
+		}
+
+		label := fmt.Sprintf("%d", b.Min)
+		if b.Max > b.Min {
+			label = fmt.Sprintf("%d-%d", b.Min, b.Max)
+		}
+
+		fmt.Fprintf(w, "  %13s | %-*s %d\n", label, histogramWidth, strings.Repeat("#", bar), b.Count)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/report"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/snapshot"
+)
+
+// Kinds of inputs of report data.
+const (
+	archiveKind  = "archive"
+	snapshotKind = "snapshot"
+)
+
+// loadTemplate returns the template of --template, or nil if it is not set.
+func loadTemplate(c *cli.Context) (report.Template, error) {
+	path := c.String("template")
+	if path == "" {
+		return nil, nil
+	}
+
+	return report.Load(path)
+}
+
+// reportData returns report data of a ranking with enrichment parameters and inputs.
+func (e *enrichment) reportData(command, title string, inputs []string, params report.Params) report.Data {
+	params.GroupBy = e.groupBy
+
+	for _, f := range e.where {
+		params.Where = append(params.Where, f.String())
+	}
+
+	return report.Data{
+		Ranking: command, Title: title, Params: params, Inputs: reportInputs(inputs), GeneratedAt: time.Now(),
+	}
+}
+
+// reportInputs describes inputs of a report.
+func reportInputs(inputs []string) []report.Input {
+	described := make([]report.Input, len(inputs))
+
+	for i, path := range inputs {
+		described[i] = report.Input{Path: path, Kind: archiveKind}
+
+		if isSnapshot, err := snapshot.IsSnapshot(path); err == nil && isSnapshot {
+			described[i].Kind = snapshotKind
+		}
+
+		// inputs are already loaded, so they exist
+		if fi, err := os.Stat(path); err == nil {
+			described[i].Size, described[i].ModTime = fi.Size(), fi.ModTime()
+		}
+	}
+
+	return described
+}
+
+func executeTemplate(l *loader, tmpl report.Template, data report.Data) error {
+	if err := tmpl.Execute(os.Stdout, data); err != nil {
+		return err
+	}
+
+	return l.timings.print(os.Stderr)
+}
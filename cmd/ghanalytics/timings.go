@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// timings collects time spent in phases of a command. Methods of nil *timings do nothing,
+// so callers don't have to check whether timings are enabled.
+type timings struct {
+	mu        sync.Mutex
+	phases    []string
+	durations map[string]time.Duration
+}
+
+func newTimings() *timings {
+	return &timings{durations: make(map[string]time.Duration)}
+}
+
+// add adds d to the phase. Phases are printed in order of their first addition.
+func (t *timings) add(phase string, d time.Duration) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.durations[phase]; !ok {
+		t.phases = append(t.phases, phase)
+	}
+
+	t.durations[phase] += d
+}
+
+// track starts measuring the phase, the returned func stops it.
+func (t *timings) track(phase string) func() {
+	start := time.Now()
+
+	return func() {
+		t.add(phase, time.Since(start))
+	}
+}
+
+func (t *timings) print(w io.Writer) error {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, err := fmt.Fprintln(w, "timings (CSV files are decompressed and decoded in parallel, their times are summed):"); err != nil {
+		return err
+	}
+
+	for _, phase := range t.phases {
+		if _, err := fmt.Fprintf(w, "%15s: %v\n", phase, t.durations[phase].Round(time.Microsecond)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
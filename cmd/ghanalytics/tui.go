@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/term"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/tui"
+)
+
+// resizeCheckInterval is how often the terminal size is checked to redraw the UI after resizing.
+const resizeCheckInterval = 250 * time.Millisecond
+
+// ANSI escape sequences used to draw the UI.
+const (
+	enterAltScreen = "\x1b[?1049h\x1b[?25l"
+	exitAltScreen  = "\x1b[?25h\x1b[?1049l"
+	cursorHome     = "\x1b[H"
+	clearLineEnd   = "\x1b[K"
+	clearBelow     = "\x1b[J"
+)
+
+// runTUI explores the dataset of the archive, or samples of the merged snapshot of the inputs, which have no details.
+func runTUI(ctx context.Context, l *loader, inputs []string) error {
+	stdin, stdout := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(stdin) || !term.IsTerminal(stdout) {
+		return errors.New("tui needs a terminal, use the batch commands to read or write pipes")
+	}
+
+	ds, s, err := loadInputs(ctx, l, inputs)
+	if err != nil {
+		return err
+	}
+
+	var m *tui.Model
+
+	if s != nil {
+		m = tui.NewOfSamples(ctx, s.UsersSample(true), s.ReposSample())
+	} else if m, err = tui.New(ctx, ds); err != nil {
+		return err
+	}
+
+	state, err := term.MakeRaw(stdin)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = term.Restore(stdin, state)
+	}()
+
+	_, _ = os.Stdout.WriteString(enterAltScreen)
+
+	defer func() {
+		_, _ = os.Stdout.WriteString(exitAltScreen)
+	}()
+
+	keys := make(chan []tui.Key)
+
+	// the reader is left blocked on stdin after the UI quits, the process exits soon after anyway
+	go func() {
+		buf := make([]byte, 256)
+
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+
+				return
+			}
+
+			keys <- tui.ParseKeys(buf[:n])
+		}
+	}()
+
+	ticker := time.NewTicker(resizeCheckInterval)
+	defer ticker.Stop()
+
+	var width, height int
+
+	draw := func(force bool) {
+		w, h, err := term.Size(stdout)
+		if err != nil || w == 0 || h == 0 {
+			w, h = 80, 24
+		}
+
+		if !force && w == width && h == height {
+			return
+		}
+
+		width, height = w, h
+
+		var b strings.Builder
+
+		b.WriteString(cursorHome)
+
+		for i, line := range m.View(width, height) {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+
+			b.WriteString(line)
+			b.WriteString(clearLineEnd)
+		}
+
+		b.WriteString(clearBelow)
+
+		_, _ = os.Stdout.WriteString(b.String())
+	}
+
+	draw(true)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			draw(false)
+		case pressed, ok := <-keys:
+			if !ok {
+				return nil
+			}
+
+			for _, k := range pressed {
+				if m.Update(k) {
+					return nil
+				}
+			}
+
+			draw(true)
+		}
+	}
+}
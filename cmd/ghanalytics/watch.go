@@ -0,0 +1,418 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/cache"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/report"
+)
+
+// watchStateVersion is a version of the watch state file layout. It must be incremented on every change of the layout.
+const watchStateVersion = 2
+
+// watchStateFilename is the default name of the state file in the watched directory.
+const watchStateFilename = ".ghanalytics-watch.json"
+
+// watchOptions configure watching a directory.
+type watchOptions struct {
+	dir     string
+	pattern string
+	// statePath is path of the file with processed archives and running aggregates
+	statePath string
+	interval  time.Duration
+	// settle is how long an archive should stay unmodified before it is ingested, so archives which are still
+	// being written are not ingested
+	settle time.Duration
+
+	n      int
+	bots   bool
+	format string
+
+	// addr is set if metrics of aggregates should be served instead of printed, which needs metrics set
+	addr    string
+	metrics bool
+	topK    int
+}
+
+// watchState is persisted after every ingested archive, so a restarted watch continues with the same aggregates
+// and never ingests an archive twice.
+type watchState struct {
+	Version int `json:"version"`
+	// EntityFilter and Pseudonymization are fingerprints of the options the aggregates were made with,
+	// a watch with other options would mix differently filtered or pseudonymized archives
+	EntityFilter     string             `json:"entity_filter,omitempty"`
+	Pseudonymization string             `json:"pseudonymization,omitempty"`
+	Processed        []processedArchive `json:"processed"`
+	// Users include bots, they are filtered out when rankings are printed
+	Users        *github.UsersSample `json:"users"`
+	Repos        *github.ReposSample `json:"repos"`
+	EventsByType map[string]int      `json:"events_by_type"`
+}
+
+// processedArchive is an ingested archive. Archives are identified by SHA-256 of their content,
+// so a renamed archive is not ingested again and a new archive with the name of an old one is.
+type processedArchive struct {
+	Path    string    `json:"path"`
+	SHA256  string    `json:"sha256"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Events  int       `json:"events"`
+}
+
+// fileVersion identifies a version of a file without reading it.
+type fileVersion struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+type watcher struct {
+	opts  watchOptions
+	l     *loader
+	state *watchState
+	// hashes are hashes of processed archives
+	hashes map[string]bool
+	// known are versions of files which were processed or failed to be ingested, they are not read again
+	known map[fileVersion]bool
+}
+
+func newWatcher(l *loader, opts watchOptions) (*watcher, error) {
+	state, err := loadWatchState(opts.statePath, l.filter.Fingerprint(), l.pseudonymizer.Fingerprint())
+	if err != nil {
+		return nil, err
+	}
+
+	w := &watcher{opts: opts, l: l, state: state, hashes: make(map[string]bool), known: make(map[fileVersion]bool)}
+
+	for _, p := range state.Processed {
+		w.hashes[p.SHA256] = true
+		w.known[fileVersion{path: p.Path, size: p.Size, modTime: p.ModTime}] = true
+	}
+
+	return w, nil
+}
+
+// loadWatchState reads the state file, a missing file is the state of a watch which hasn't ingested anything yet.
+// ErrWrongParam is returned if the state was made with another entity filter or pseudonymization.
+func loadWatchState(path, entityFilter, pseudonymization string) (*watchState, error) {
+	state := &watchState{
+		Version:      watchStateVersion,
+		Users:        &github.UsersSample{M: make(map[string]github.User)},
+		Repos:        &github.ReposSample{M: make(map[string]github.Repo)},
+		EventsByType: make(map[string]int),
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		state.EntityFilter, state.Pseudonymization = entityFilter, pseudonymization
+
+		return state, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, errors.Wrapf(err, "can't decode watch state %s", path)
+	}
+
+	if state.Version != watchStateVersion {
+		return nil, errors.Errorf("watch state %s has version %d, want %d", path, state.Version, watchStateVersion)
+	}
+
+	if state.EntityFilter != entityFilter {
+		return nil, errors.Wrapf(github.ErrWrongParam, "watch state %s was made with other entity filters, "+
+			"use the same filters or another --state", path)
+	}
+
+	if state.Pseudonymization != pseudonymization {
+		return nil, errors.Wrapf(github.ErrWrongParam, "watch state %s was made with other pseudonymization, "+
+			"use the same key or another --state", path)
+	}
+
+	return state, nil
+}
+
+// saveState writes the state to a temporary file first, so a crash never leaves a partially written state.
+func (w *watcher) saveState() error {
+	tmp, err := os.CreateTemp(filepath.Dir(w.opts.statePath), filepath.Base(w.opts.statePath)+".tmp*")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if err := json.NewEncoder(tmp).Encode(w.state); err != nil {
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), w.opts.statePath)
+}
+
+// aggregates returns a copy of the running aggregates, so the server can read it while the watcher merges
+// new archives into the running ones.
+func (w *watcher) aggregates() *aggregates {
+	a := &aggregates{
+		users:        &github.UsersSample{},
+		repos:        &github.ReposSample{},
+		eventsByType: make(map[string]int, len(w.state.EventsByType)),
+	}
+
+	a.users.Merge(w.state.Users)
+	a.repos.Merge(w.state.Repos)
+
+	for eventType, count := range w.state.EventsByType {
+		a.eventsByType[eventType] = count
+	}
+
+	return a
+}
+
+// scan ingests new archives of the directory in order of their paths and returns them.
+// An archive which fails to be ingested is reported and retried after it is modified.
+func (w *watcher) scan(ctx context.Context) ([]processedArchive, error) {
+	versions, err := w.newFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var ingested []processedArchive
+
+	for _, v := range versions {
+		p, err := w.ingest(ctx, v)
+		if ctx.Err() != nil {
+			return ingested, ctx.Err()
+		}
+
+		w.known[v] = true
+
+		if err != nil {
+			log.Printf("can't ingest %s: %v", v.path, err)
+
+			continue
+		}
+
+		if p != nil {
+			ingested = append(ingested, *p)
+		}
+	}
+
+	return ingested, nil
+}
+
+// newFiles returns files matching the pattern which are not known yet and weren't modified for the settle time.
+func (w *watcher) newFiles() ([]fileVersion, error) {
+	settledBefore := time.Now().Add(-w.opts.settle)
+
+	var versions []fileVersion
+
+	err := filepath.WalkDir(w.opts.dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		if matched, err := filepath.Match(w.opts.pattern, d.Name()); err != nil || !matched {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		v := fileVersion{path: path, size: info.Size(), modTime: info.ModTime().UTC()}
+		if !w.known[v] && v.modTime.Before(settledBefore) {
+			versions = append(versions, v)
+		}
+
+		return nil
+	})
+
+	sort.Slice(versions, func(i, j int) bool { return versions[i].path < versions[j].path })
+
+	return versions, err
+}
+
+// ingest merges aggregates of the archive into the running ones and saves the state.
+// It returns nil if the archive was processed before under another path or version.
+func (w *watcher) ingest(ctx context.Context, v fileVersion) (*processedArchive, error) {
+	hash, err := cache.HashFile(ctx, v.path)
+	if err != nil {
+		return nil, err
+	}
+
+	if w.hashes[hash] {
+		return nil, nil
+	}
+
+	ds, err := w.l.load(ctx, v.path)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := aggregate(ctx, ds, true)
+	if err != nil {
+		return nil, err
+	}
+
+	p := processedArchive{Path: v.path, SHA256: hash, Size: v.size, ModTime: v.modTime, Events: ds.Events.Len()}
+
+	w.state.Users.Merge(a.users)
+	w.state.Repos.Merge(a.repos)
+
+	for eventType, count := range a.eventsByType {
+		w.state.EventsByType[eventType] += count
+	}
+
+	w.state.Processed = append(w.state.Processed, p)
+	w.hashes[hash] = true
+
+	return &p, w.saveState()
+}
+
+// runWatch ingests new archives of the directory every interval until ctx is done. After archives are ingested,
+// rankings are printed, or the served metrics are replaced if the address is set.
+func runWatch(ctx context.Context, l *loader, opts watchOptions) error {
+	if err := checkFormat(opts.format); err != nil {
+		return err
+	}
+
+	if opts.addr != "" && !opts.metrics {
+		return errNoMetrics
+	}
+
+	// every archive is loaded once, caching it would only waste disk
+	l.cacheEnabled = false
+
+	w, err := newWatcher(l, opts)
+	if err != nil {
+		return err
+	}
+
+	update := func(ingested []processedArchive) error {
+		return printWatchRankings(w.aggregates(), ingested, len(w.state.Processed), opts)
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+
+	if opts.addr != "" {
+		s := &server{topK: opts.topK, botsIncluded: opts.bots, stats: newLoaderStats()}
+		l.stats = s.stats
+
+		s.set(w.aggregates())
+
+		update = func(ingested []processedArchive) error {
+			for _, p := range ingested {
+				log.Printf("ingested %s: %d events", p.Path, p.Events)
+			}
+
+			s.set(w.aggregates())
+
+			return nil
+		}
+
+		g.Go(func() error {
+			return serve(ctx, opts.addr, s.handler())
+		})
+	} else if len(w.state.Processed) > 0 {
+		if err := update([]processedArchive{}); err != nil {
+			return err
+		}
+	}
+
+	g.Go(func() error {
+		ticker := time.NewTicker(opts.interval)
+		defer ticker.Stop()
+
+		for {
+			ingested, err := w.scan(ctx)
+			if len(ingested) > 0 {
+				if err := update(ingested); err != nil {
+					return err
+				}
+			}
+
+			if err != nil {
+				return err
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	})
+
+	return g.Wait()
+}
+
+func printWatchRankings(a *aggregates, ingested []processedArchive, archives int, opts watchOptions) error {
+	users := a.users
+	if !opts.bots {
+		users = users.WithoutBots()
+	}
+
+	topUsers, err := users.TopNActiveUsers(opts.n)
+	if err != nil {
+		return err
+	}
+
+	topByCommits, err := a.repos.TopNByCommitsPushed(opts.n)
+	if err != nil {
+		return err
+	}
+
+	topByWatches, err := a.repos.TopNByWatchEvents(opts.n)
+	if err != nil {
+		return err
+	}
+
+	if opts.format == jsonFormat {
+		return writeJSON(os.Stdout, struct {
+			Ingested           []processedArchive  `json:"ingested"`
+			Archives           int                 `json:"archives"`
+			N                  int                 `json:"n"`
+			Users              []report.RankedUser `json:"users"`
+			ReposByCommits     []report.RankedRepo `json:"repos_by_commits"`
+			ReposByWatchEvents []report.RankedRepo `json:"repos_by_watch_events"`
+		}{
+			Ingested: ingested, Archives: archives, N: opts.n, Users: report.NewRankedUsers(topUsers),
+			ReposByCommits: report.NewRankedRepos(topByCommits), ReposByWatchEvents: report.NewRankedRepos(topByWatches),
+		})
+	}
+
+	for _, p := range ingested {
+		fmt.Printf("ingested %s: %d events\n", p.Path, p.Events)
+	}
+
+	fmt.Printf("rankings of %d archives at %s:\n", archives, time.Now().Format(time.RFC3339))
+	printUsersTable(fmt.Sprintf("top %d active users", opts.n), topUsers, nil)
+	printReposByPushedCommitsTable(fmt.Sprintf("top %d %s", opts.n, reposByPushedCommits.title), topByCommits, nil)
+	printReposByWatchEventsTable(fmt.Sprintf("top %d %s", opts.n, reposByWatchEvents.title), topByWatches, nil)
+
+	return nil
+}
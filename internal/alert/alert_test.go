@@ -0,0 +1,258 @@
+package alert_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/alert"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+func sample() (*github.UsersSample, *github.ReposSample) {
+	users := &github.UsersSample{M: map[string]github.User{
+		"1": {ID: "1", Username: "pusher", Activity: github.ActorActivity{PushedCommits: 600}},
+		"2": {ID: "2", Username: "casual", Activity: github.ActorActivity{PushedCommits: 5, CreatedPullRequests: 1}},
+	}}
+	repos := &github.ReposSample{M: map[string]github.Repo{
+		"1": {ID: "1", Name: "other/popular", WatchEvents: 90},
+		"2": {ID: "2", Name: "ours/rising", WatchEvents: 40},
+		"3": {ID: "3", Name: "ours/quiet", WatchEvents: 1},
+	}}
+
+	return users, repos
+}
+
+func TestRules_Evaluate(t *testing.T) {
+	users, repos := sample()
+	rules := alert.Rules{Rules: []alert.Rule{
+		{Name: "trending", Metric: alert.RepoWatchEventsRankMetric, Comparator: "<=", Threshold: 2, Owner: "ours"},
+		{Name: "pusher", Metric: alert.UserCommitsMetric, Comparator: ">", Threshold: 500},
+		{Name: "glob", Metric: alert.RepoWatchEventsMetric, Comparator: ">=", Threshold: 1, Match: "ours/q*"},
+	}}
+
+	if err := rules.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, a := range rules.Evaluate(users, repos) {
+		got = append(got, fmt.Sprintf("%s=%d", a.Key(), a.Value))
+	}
+
+	want := []string{"trending/repo/2=2", "pusher/user/1=600", "glob/repo/3=1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Evaluate() = %v, want %v", got, want)
+	}
+
+	invalid := []alert.Rules{
+		{Rules: []alert.Rule{{Name: "a", Metric: "stars", Comparator: ">"}}},
+		{Rules: []alert.Rule{{Name: "a", Metric: alert.UserCommitsMetric, Comparator: "=>"}}},
+		{Rules: []alert.Rule{{Name: "a", Metric: alert.UserCommitsMetric, Comparator: ">", Owner: "ours"}}},
+		{Rules: []alert.Rule{{Name: "a", Metric: alert.UserCommitsMetric, Comparator: ">"}, {Name: "a", Metric: alert.UserCommitsMetric, Comparator: ">"}}},
+		{Deliveries: []alert.Delivery{{Type: alert.SlackDelivery}}},
+	}
+
+	for i, rs := range invalid {
+		if err := rs.Validate(); !errors.Is(err, github.ErrWrongParam) {
+			t.Errorf("Validate() of rules %d error = %v, want %v", i, err, github.ErrWrongParam)
+		}
+	}
+}
+
+func TestSender_Send(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []string
+		failures = 2
+	)
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		// the first attempts fail, so the sender retries
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+
+		if _, ok := body["text"]; ok {
+			received = append(received, r.URL.Path+" "+body["text"].(string))
+		} else {
+			received = append(received, r.URL.Path+" "+body["alerts"].([]interface{})[0].(map[string]interface{})["rule"].(string))
+		}
+	}))
+	defer receiver.Close()
+
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "alerts.jsonl")
+	deliveries := []alert.Delivery{
+		{Type: alert.WebhookDelivery, URL: receiver.URL + "/hook"},
+		{Type: alert.SlackDelivery, URL: receiver.URL + "/slack"},
+		{Type: alert.FileDelivery, Path: filePath},
+	}
+
+	users, repos := sample()
+	rules := alert.Rules{Rules: []alert.Rule{{Name: "pusher", Metric: alert.UserCommitsMetric, Comparator: ">", Threshold: 500}}}
+	sender := &alert.Sender{Client: receiver.Client(), Attempts: 3, Backoff: time.Millisecond}
+	statePath := filepath.Join(dir, "state.json")
+
+	// the second run finds the same alert firing, so it delivers nothing
+	for run := 0; run < 2; run++ {
+		state, err := alert.LoadState(statePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		firing := rules.Evaluate(users, repos)
+
+		if err := sender.Deliver(context.Background(), deliveries, state, firing, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+
+		state.Update(firing, time.Now())
+
+		if err := state.Save(statePath); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{"/hook pusher", "/slack *1 new alerts*\n• pusher: user pusher has user-commits 600 (> 500)"}
+	if !reflect.DeepEqual(received, want) {
+		t.Errorf("receiver got %q, want %q", received, want)
+	}
+
+	lines, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := strings.Count(string(lines), "\n"); n != 1 {
+		t.Errorf("file has %d alerts, want 1", n)
+	}
+
+	// once the alert stops firing, it is forgotten and can fire again
+	state, err := alert.LoadState(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state.Update(nil, time.Now())
+
+	if fresh := state.New(rules.Evaluate(users, repos)); len(fresh) != 1 {
+		t.Errorf("New() = %v, want the alert firing again", fresh)
+	}
+
+	if pending := state.Pending(deliveries[0], rules.Evaluate(users, repos)); len(pending) != 1 {
+		t.Errorf("Pending() = %v, want the alert delivered again", pending)
+	}
+}
+
+// TestSender_Deliver_Failed checks that only a failed delivery is retried by later runs.
+func TestSender_Deliver_Failed(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		received  = make(map[string]int)
+		rejecting = true
+	)
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		received[r.URL.Path]++
+
+		if r.URL.Path == "/failing" && rejecting {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer receiver.Close()
+
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "alerts.jsonl")
+	deliveries := []alert.Delivery{
+		{Type: alert.WebhookDelivery, URL: receiver.URL + "/failing"},
+		{Type: alert.WebhookDelivery, URL: receiver.URL + "/ok"},
+		{Type: alert.FileDelivery, Path: filePath},
+	}
+
+	users, repos := sample()
+	rules := alert.Rules{Rules: []alert.Rule{{Name: "pusher", Metric: alert.UserCommitsMetric, Comparator: ">", Threshold: 500}}}
+	sender := &alert.Sender{Client: receiver.Client(), Attempts: 3, Backoff: time.Millisecond}
+	statePath := filepath.Join(dir, "state.json")
+
+	// the first two runs fail to deliver to one receiver, the third one succeeds
+	for run := 0; run < 3; run++ {
+		state, err := alert.LoadState(statePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rejecting = run < 2
+		firing := rules.Evaluate(users, repos)
+
+		err = sender.Deliver(context.Background(), deliveries, state, firing, time.Now())
+		if rejecting != (err != nil) {
+			t.Errorf("run %d Deliver() error = %v, want an error only while the receiver rejects alerts", run, err)
+		}
+
+		state.Update(firing, time.Now())
+
+		if err := state.Save(statePath); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if want := map[string]int{"/failing": 3, "/ok": 1}; !reflect.DeepEqual(received, want) {
+		t.Errorf("receivers got %v alerts, want %v", received, want)
+	}
+
+	lines, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := strings.Count(string(lines), "\n"); n != 1 {
+		t.Errorf("file has %d alerts, want 1", n)
+	}
+}
+
+func TestSender_SendPermanentError(t *testing.T) {
+	var attempts int
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer receiver.Close()
+
+	sender := &alert.Sender{Client: receiver.Client(), Attempts: 3, Backoff: time.Millisecond}
+	alerts := []alert.Alert{{Rule: "pusher", Entity: alert.UserEntity, ID: "1"}}
+
+	if err := sender.Send(context.Background(), []alert.Delivery{{Type: alert.WebhookDelivery, URL: receiver.URL}}, alerts); err == nil {
+		t.Error("Send() error = nil, want the rejected payload")
+	}
+
+	if attempts != 1 {
+		t.Errorf("rejected payload was sent %d times, want 1", attempts)
+	}
+}
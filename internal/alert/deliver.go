@@ -0,0 +1,178 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Sender delivers alerts with retries.
+type Sender struct {
+	Client *http.Client
+	// Attempts is the most amount of attempts of every delivery.
+	Attempts int
+	// Backoff is the delay before the second attempt, it doubles before every next one.
+	Backoff time.Duration
+}
+
+// NewSender returns a sender which makes 3 attempts with a timeout of 10 seconds each.
+func NewSender() *Sender {
+	return &Sender{Client: &http.Client{Timeout: 10 * time.Second}, Attempts: 3, Backoff: time.Second}
+}
+
+// permanentError is an error retries can't fix, like a rejected payload.
+type permanentError struct {
+	error
+}
+
+// webhookPayload is the body of generic JSON webhooks.
+type webhookPayload struct {
+	Alerts []Alert `json:"alerts"`
+}
+
+// slackPayload is the body of Slack-compatible incoming webhooks.
+type slackPayload struct {
+	Text string `json:"text"`
+}
+
+// Send delivers the alerts to every delivery, nothing is sent if there are no alerts. It returns the first
+// error of deliveries which failed after all attempts, the other deliveries are still made.
+func (s *Sender) Send(ctx context.Context, deliveries []Delivery, alerts []Alert) error {
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	var firstErr error
+
+	for _, d := range deliveries {
+		if err := s.send(ctx, d, alerts); err != nil && firstErr == nil {
+			firstErr = errors.Wrapf(err, "delivery to %s", d.Type)
+		}
+	}
+
+	return firstErr
+}
+
+// Deliver delivers firing alerts to every delivery they are not delivered to yet and records successful
+// deliveries in the state, so a failed delivery is retried by the next run without repeating the others.
+// It returns the first error of deliveries which failed after all attempts, the other deliveries are still made.
+func (s *Sender) Deliver(ctx context.Context, deliveries []Delivery, state *State, firing []Alert, now time.Time) error {
+	var firstErr error
+
+	for _, d := range deliveries {
+		pending := state.Pending(d, firing)
+		if len(pending) == 0 {
+			continue
+		}
+
+		if err := s.send(ctx, d, pending); err != nil {
+			if firstErr == nil {
+				firstErr = errors.Wrapf(err, "delivery to %s", d.Type)
+			}
+
+			continue
+		}
+
+		state.MarkDelivered(d, pending, now)
+	}
+
+	return firstErr
+}
+
+func (s *Sender) send(ctx context.Context, d Delivery, alerts []Alert) error {
+	if d.Type == FileDelivery {
+		return appendAlerts(d.Path, alerts)
+	}
+
+	var payload interface{} = webhookPayload{Alerts: alerts}
+
+	if d.Type == SlackDelivery {
+		lines := make([]string, len(alerts))
+		for i, a := range alerts {
+			lines[i] = "• " + a.String()
+		}
+
+		payload = slackPayload{Text: fmt.Sprintf("*%d new alerts*\n%s", len(alerts), strings.Join(lines, "\n"))}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	backoff := s.Backoff
+
+	for attempt := 1; ; attempt++ {
+		err = s.post(ctx, d.URL, body)
+
+		var permanent permanentError
+		if err == nil || errors.As(err, &permanent) || attempt >= s.Attempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+// post posts the body to the URL. Responses other than 2xx fail, 4xx except 429 fail permanently.
+func (s *Sender) post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err}
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	_ = resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests:
+		return permanentError{errors.Errorf("%s responded %s", url, resp.Status)}
+	default:
+		return errors.Errorf("%s responded %s", url, resp.Status)
+	}
+}
+
+// appendAlerts appends a JSON line per alert to the file.
+func appendAlerts(path string, alerts []Alert) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	enc := json.NewEncoder(f)
+	// comparators stay readable in the file
+	enc.SetEscapeHTML(false)
+
+	for _, a := range alerts {
+		if err := enc.Encode(a); err != nil {
+			return err
+		}
+	}
+
+	return f.Close()
+}
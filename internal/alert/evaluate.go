@@ -0,0 +1,134 @@
+package alert
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+// Entities of alerts.
+const (
+	UserEntity = "user"
+	RepoEntity = "repo"
+)
+
+// Alert is a user or a repository matching a rule.
+type Alert struct {
+	Rule       string `json:"rule"`
+	Metric     Metric `json:"metric"`
+	Comparator string `json:"comparator"`
+	Threshold  int    `json:"threshold"`
+	Entity     string `json:"entity"`
+	ID         string `json:"id"`
+	// Name is the name of a repository or the username of a user.
+	Name  string `json:"name"`
+	Value int    `json:"value"`
+}
+
+// Key identifies the alert of the rule and the entity, it is the same in every run.
+func (a Alert) Key() string {
+	return a.Rule + "/" + a.Entity + "/" + a.ID
+}
+
+// String describes the alert in one line.
+func (a Alert) String() string {
+	return fmt.Sprintf("%s: %s %s has %s %d (%s %d)", a.Rule, a.Entity, a.Name, a.Metric, a.Value, a.Comparator, a.Threshold)
+}
+
+// entityValue is a value of a metric of a user or a repository.
+type entityValue struct {
+	id    string
+	name  string
+	value int
+}
+
+// Evaluate returns alerts of the rules on the users and the repositories, in order of rules and then
+// by name and ID.
+func (rs *Rules) Evaluate(users *github.UsersSample, repos *github.ReposSample) []Alert {
+	var alerts []Alert
+
+	for i := range rs.Rules {
+		r := &rs.Rules[i]
+		entity := UserEntity
+
+		if r.Metric.isRepoMetric() {
+			entity = RepoEntity
+		}
+
+		values := metricValues(r.Metric, users, repos)
+		sort.Slice(values, func(i, j int) bool {
+			if values[i].name != values[j].name {
+				return values[i].name < values[j].name
+			}
+
+			return values[i].id < values[j].id
+		})
+
+		for _, v := range values {
+			if r.matches(v.name) && comparators[r.Comparator](v.value, r.Threshold) {
+				alerts = append(alerts, Alert{
+					Rule: r.Name, Metric: r.Metric, Comparator: r.Comparator, Threshold: r.Threshold,
+					Entity: entity, ID: v.id, Name: v.name, Value: v.value,
+				})
+			}
+		}
+	}
+
+	return alerts
+}
+
+// metricValues returns values of the metric of every user or repository.
+func metricValues(m Metric, users *github.UsersSample, repos *github.ReposSample) []entityValue {
+	var values []entityValue
+
+	if m.isRepoMetric() {
+		for _, r := range repos.M {
+			v := entityValue{id: r.ID, name: r.Name, value: r.CommitsPushed}
+			if m == RepoWatchEventsMetric || m == RepoWatchEventsRankMetric {
+				v.value = r.WatchEvents
+			}
+
+			values = append(values, v)
+		}
+	} else {
+		for _, u := range users.M {
+			v := entityValue{id: u.ID, name: u.Username, value: u.Activity.Total()}
+
+			switch m {
+			case UserCommitsMetric:
+				v.value = u.Activity.PushedCommits
+			case UserPullRequestsMetric:
+				v.value = u.Activity.CreatedPullRequests
+			}
+
+			values = append(values, v)
+		}
+	}
+
+	if m == RepoCommitsRankMetric || m == RepoWatchEventsRankMetric || m == UserActivityRankMetric {
+		rank(values)
+	}
+
+	return values
+}
+
+// rank replaces values with their ranks, starting from 1 for the biggest value. Ties are ranked by name and ID,
+// entities without activity are ranked too.
+func rank(values []entityValue) {
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].value != values[j].value {
+			return values[i].value > values[j].value
+		}
+
+		if values[i].name != values[j].name {
+			return values[i].name < values[j].name
+		}
+
+		return values[i].id < values[j].id
+	})
+
+	for i := range values {
+		values[i].value = i + 1
+	}
+}
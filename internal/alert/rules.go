@@ -0,0 +1,188 @@
+// Package alert evaluates threshold rules on aggregated users and repositories and delivers alerts
+// to webhooks, Slack or files.
+package alert
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+// Metric is a metric of a user or a repository rules compare with thresholds.
+type Metric string
+
+// Metrics. Ranks start from 1 for the biggest value, ties are ranked by name and ID.
+const (
+	RepoCommitsMetric         Metric = "repo-commits"
+	RepoWatchEventsMetric     Metric = "repo-watch-events"
+	RepoCommitsRankMetric     Metric = "repo-commits-rank"
+	RepoWatchEventsRankMetric Metric = "repo-watch-events-rank"
+	UserActivityMetric        Metric = "user-activity"
+	UserCommitsMetric         Metric = "user-commits"
+	UserPullRequestsMetric    Metric = "user-pull-requests"
+	UserActivityRankMetric    Metric = "user-activity-rank"
+)
+
+// Metrics lists all metrics.
+var Metrics = []Metric{
+	RepoCommitsMetric, RepoWatchEventsMetric, RepoCommitsRankMetric, RepoWatchEventsRankMetric,
+	UserActivityMetric, UserCommitsMetric, UserPullRequestsMetric, UserActivityRankMetric,
+}
+
+// isRepoMetric reports whether the metric is a metric of repositories.
+func (m Metric) isRepoMetric() bool {
+	switch m {
+	case RepoCommitsMetric, RepoWatchEventsMetric, RepoCommitsRankMetric, RepoWatchEventsRankMetric:
+		return true
+	default:
+		return false
+	}
+}
+
+// Comparators of rules.
+var comparators = map[string]func(value, threshold int) bool{
+	">":  func(v, t int) bool { return v > t },
+	">=": func(v, t int) bool { return v >= t },
+	"<":  func(v, t int) bool { return v < t },
+	"<=": func(v, t int) bool { return v <= t },
+	"==": func(v, t int) bool { return v == t },
+	"!=": func(v, t int) bool { return v != t },
+}
+
+// Rule fires an alert for every user or repository whose metric compares with the threshold,
+// e.g. repo-watch-events-rank <= 10 for repositories entering the top 10 by watch events.
+type Rule struct {
+	// Name identifies alerts of the rule, it should be unique.
+	Name       string `json:"name"`
+	Metric     Metric `json:"metric"`
+	Comparator string `json:"comparator"`
+	Threshold  int    `json:"threshold"`
+	// Match is a glob of repository names or usernames, e.g. golang/*, any name matches if it is empty.
+	Match string `json:"match,omitempty"`
+	// Owner is the owner of matched repositories, e.g. golang, it is only valid for metrics of repositories.
+	Owner string `json:"owner,omitempty"`
+}
+
+// matches reports whether the rule applies to the user or the repository with the name.
+func (r *Rule) matches(name string) bool {
+	if r.Owner != "" && github.RepoOwner(name) != r.Owner {
+		return false
+	}
+
+	if r.Match == "" {
+		return true
+	}
+
+	matched, _ := path.Match(r.Match, name)
+
+	return matched
+}
+
+// Delivery types.
+const (
+	WebhookDelivery = "webhook"
+	SlackDelivery   = "slack"
+	FileDelivery    = "file"
+)
+
+// Delivery is where alerts are delivered: a generic JSON webhook, a Slack-compatible webhook or a local file,
+// which gets a JSON line per alert.
+type Delivery struct {
+	Type string `json:"type"`
+	// URL is the URL of webhooks.
+	URL string `json:"url,omitempty"`
+	// Path is the path of the file.
+	Path string `json:"path,omitempty"`
+}
+
+// Key identifies the delivery in the state.
+func (d Delivery) Key() string {
+	if d.Type == FileDelivery {
+		return d.Type + " " + d.Path
+	}
+
+	return d.Type + " " + d.URL
+}
+
+// Rules is a rules file.
+type Rules struct {
+	Rules      []Rule     `json:"rules"`
+	Deliveries []Delivery `json:"deliveries"`
+}
+
+// Load reads and validates the rules file at path.
+func Load(path string) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rs Rules
+	if err := json.Unmarshal(data, &rs); err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+
+	if err := rs.Validate(); err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+
+	return &rs, nil
+}
+
+// Validate returns github.ErrWrongParam if a rule or a delivery is invalid.
+func (rs *Rules) Validate() error {
+	names := make(map[string]bool, len(rs.Rules))
+
+	for i, r := range rs.Rules {
+		if r.Name == "" || names[r.Name] {
+			return errors.Wrapf(github.ErrWrongParam, "rule %d should have a unique name", i)
+		}
+
+		names[r.Name] = true
+
+		if !isMetric(r.Metric) {
+			return errors.Wrapf(github.ErrWrongParam, "rule %q has unknown metric %q", r.Name, r.Metric)
+		}
+
+		if comparators[r.Comparator] == nil {
+			return errors.Wrapf(github.ErrWrongParam, "rule %q has unknown comparator %q", r.Name, r.Comparator)
+		}
+
+		if r.Owner != "" && !r.Metric.isRepoMetric() {
+			return errors.Wrapf(github.ErrWrongParam, "rule %q has an owner, but users have no owners", r.Name)
+		}
+
+		if r.Match != "" {
+			if _, err := path.Match(r.Match, ""); err != nil {
+				return errors.Wrapf(github.ErrWrongParam, "rule %q has malformed match %q", r.Name, r.Match)
+			}
+		}
+	}
+
+	for i, d := range rs.Deliveries {
+		switch {
+		case (d.Type == WebhookDelivery || d.Type == SlackDelivery) && d.URL == "":
+			return errors.Wrapf(github.ErrWrongParam, "delivery %d to %s has no url", i, d.Type)
+		case d.Type == FileDelivery && d.Path == "":
+			return errors.Wrapf(github.ErrWrongParam, "delivery %d to a file has no path", i)
+		case d.Type != WebhookDelivery && d.Type != SlackDelivery && d.Type != FileDelivery:
+			return errors.Wrapf(github.ErrWrongParam, "delivery %d has unknown type %q", i, d.Type)
+		}
+	}
+
+	return nil
+}
+
+func isMetric(m Metric) bool {
+	for _, known := range Metrics {
+		if m == known {
+			return true
+		}
+	}
+
+	return false
+}
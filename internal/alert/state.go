@@ -0,0 +1,158 @@
+package alert
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// State is alerts which are still firing and deliveries they were made to, it is persisted between runs,
+// so an alert is delivered once when it starts firing and again only after it stopped. Deliveries are recorded
+// one by one, so an alert is retried only by the deliveries which failed.
+type State struct {
+	// Firing is time alerts started firing by their keys.
+	Firing map[string]time.Time `json:"firing"`
+	// Delivered is time alerts were delivered by keys of deliveries and then keys of alerts.
+	Delivered map[string]map[string]time.Time `json:"delivered"`
+}
+
+// LoadState reads the state file at path, a missing file is an empty state.
+func LoadState(path string) (*State, error) {
+	s := State{Firing: make(map[string]time.Time), Delivered: make(map[string]map[string]time.Time)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &s, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+
+	if s.Firing == nil {
+		s.Firing = make(map[string]time.Time)
+	}
+
+	if s.Delivered == nil {
+		s.Delivered = make(map[string]map[string]time.Time)
+	}
+
+	return &s, nil
+}
+
+// New returns alerts which are not firing yet.
+func (s *State) New(alerts []Alert) []Alert {
+	var fresh []Alert
+
+	for _, a := range alerts {
+		if _, ok := s.Firing[a.Key()]; !ok {
+			fresh = append(fresh, a)
+		}
+	}
+
+	return fresh
+}
+
+// Pending returns alerts which are not delivered to the delivery yet.
+func (s *State) Pending(d Delivery, alerts []Alert) []Alert {
+	var pending []Alert
+
+	delivered := s.Delivered[d.Key()]
+
+	for _, a := range alerts {
+		if _, ok := delivered[a.Key()]; !ok {
+			pending = append(pending, a)
+		}
+	}
+
+	return pending
+}
+
+// MarkDelivered records that the alerts were delivered to the delivery at now.
+func (s *State) MarkDelivered(d Delivery, alerts []Alert, now time.Time) {
+	if len(alerts) == 0 {
+		return
+	}
+
+	delivered, ok := s.Delivered[d.Key()]
+	if !ok {
+		delivered = make(map[string]time.Time, len(alerts))
+		s.Delivered[d.Key()] = delivered
+	}
+
+	for _, a := range alerts {
+		delivered[a.Key()] = now
+	}
+}
+
+// Update makes the alerts firing since now if they are new, and forgets alerts which stopped firing
+// with their deliveries.
+func (s *State) Update(firing []Alert, now time.Time) {
+	keys := make(map[string]bool, len(firing))
+	for _, a := range firing {
+		keys[a.Key()] = true
+	}
+
+	for key := range s.Firing {
+		if !keys[key] {
+			delete(s.Firing, key)
+		}
+	}
+
+	for d, delivered := range s.Delivered {
+		for key := range delivered {
+			if !keys[key] {
+				delete(delivered, key)
+			}
+		}
+
+		if len(delivered) == 0 {
+			delete(s.Delivered, d)
+		}
+	}
+
+	for _, a := range firing {
+		if _, ok := s.Firing[a.Key()]; !ok {
+			s.Firing[a.Key()] = now
+		}
+	}
+}
+
+// Save writes the state file to a temporary file first, so a crash never leaves it partially written.
+func (s *State) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if err := tmp.Chmod(0o644); err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
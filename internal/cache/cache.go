@@ -0,0 +1,192 @@
+// Package cache implements on-disk cache of GitHub data archives.
+// The first load of an archive writes a compact columnar snapshot of its dataset next to the archive,
+// subsequent loads read the snapshot instead of decompressing and parsing CSV files again.
+// Cache files are keyed by SHA-256 of the archive and by SchemaVersion, so a changed archive or
+// a new version of the tool never reads a stale snapshot.
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+// SchemaVersion is a version of cache file layout. It must be incremented on every change of the layout.
+const SchemaVersion = 3
+
+const (
+	fileExt = ".ghacache"
+	// hashLen is amount of hex digits of the archive hash used in the cache filename.
+	hashLen = 16
+)
+
+// nameRe matches the part of cache filenames after the archive filename, so caches of sibling archives
+// like data.tar.gz.bak are never taken for caches of data.tar.gz.
+var nameRe = regexp.MustCompile(`^\.[0-9a-f]{1,` + strconv.Itoa(hashLen) + `}\.v[0-9]+` + regexp.QuoteMeta(fileExt) + `$`)
+
+// Rows are amounts of rows of each CSV file of the archive the dataset was built from, so loads from cache
+// report the same loader health as loads of the archive.
+type Rows struct {
+	Read     map[string]int
+	Rejected map[string]int
+}
+
+// ErrCorrupted is returned when cache file can't be decoded.
+var ErrCorrupted = errors.New("corrupted cache file")
+
+// HashFile returns hex encoded SHA-256 of the file content.
+// Hashing stops with ctx.Err() as soon as ctx is done.
+func HashFile(ctx context.Context, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	h := sha256.New()
+	buf := make([]byte, 1<<20)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+
+		n, err := f.Read(buf)
+		h.Write(buf[:n])
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), f.Close()
+}
+
+// Path returns path of the cache file for the archive with given hash.
+func Path(archivePath, archiveHash string) string {
+	if len(archiveHash) > hashLen {
+		archiveHash = archiveHash[:hashLen]
+	}
+
+	return archivePath + "." + archiveHash + ".v" + strconv.Itoa(SchemaVersion) + fileExt
+}
+
+// Load reads dataset of the archive with given hash and rows of the archive from cache.
+// Error satisfying errors.Is(err, os.ErrNotExist) is returned if there's no cache yet.
+func Load(archivePath, archiveHash string) (*github.Dataset, Rows, error) {
+	data, err := os.ReadFile(Path(archivePath, archiveHash))
+	if err != nil {
+		return nil, Rows{}, err
+	}
+
+	return decodeDataset(data)
+}
+
+// Store writes dataset of the archive with given hash and rows of the archive to cache, and removes outdated cache
+// files of the archive. The file is written to a temporary file first, so concurrent loads never see a partially
+// written cache.
+func Store(archivePath, archiveHash string, ds *github.Dataset, rows Rows) error {
+	path := Path(archivePath, archiveHash)
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if err := tmp.Chmod(0o644); err != nil {
+		return err
+	}
+
+	if err := encodeDataset(tmp, ds, rows); err != nil {
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	files, err := List(archivePath)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if f == filepath.Clean(path) {
+			continue
+		}
+
+		if err := os.Remove(f); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// List returns paths of all cache files of the archive.
+func List(archivePath string) ([]string, error) {
+	dir, base := filepath.Split(archivePath)
+	if dir == "" {
+		dir = "."
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, base) || !nameRe.MatchString(name[len(base):]) {
+			continue
+		}
+
+		files = append(files, filepath.Join(dir, name))
+	}
+
+	return files, nil
+}
+
+// Clean removes all cache files of the archive and returns their paths.
+func Clean(archivePath string) ([]string, error) {
+	files, err := List(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		if err := os.Remove(f); err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
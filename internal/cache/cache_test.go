@@ -0,0 +1,190 @@
+package cache_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/cache"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+func newTestDataset() *github.Dataset {
+	return github.NewDataset(
+		[]github.ActorCSV{
+			{ID: "1", Username: "alice"},
+			{ID: "2", Username: "dependabot[bot]"},
+			{ID: "1", Username: "alice"},
+		},
+		[]github.RepoCSV{
+			{ID: "10", Name: "alice/repo"},
+			{ID: "11", Name: ""},
+		},
+		[]github.EventCSV{
+			{ID: "100", Type: github.PushEventType, ActorID: "1", RepoID: "10"},
+			{ID: "101", Type: github.WatchEventType, ActorID: "2", RepoID: "10"},
+			{ID: "102", Type: github.PushEventType, ActorID: "2", RepoID: "11"},
+		},
+		[]github.CommitCSV{
+			{SHA: "a", Message: "msg", EventID: "100"},
+			{SHA: "b", Message: "msg", EventID: "100"},
+			{SHA: "c", Message: "msg", EventID: "102"},
+		},
+	)
+}
+
+func TestStoreLoad(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "data.tar.gz")
+	ds := newTestDataset()
+
+	if _, _, err := cache.Load(archivePath, "abc"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Load() error = %v, want os.ErrNotExist", err)
+	}
+
+	rows := cache.Rows{
+		Read:     map[string]int{github.ActorsCSVFilename: 3, github.CommitsCSVFilename: 3},
+		Rejected: map[string]int{github.ActorsCSVFilename: 0, github.CommitsCSVFilename: 1},
+	}
+
+	if err := cache.Store(archivePath, "abc", ds, rows); err != nil {
+		t.Fatal(err)
+	}
+
+	got, gotRows, err := cache.Load(archivePath, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, ds) {
+		t.Errorf("Load() = %v, want %v", got, ds)
+	}
+
+	if !reflect.DeepEqual(gotRows, rows) {
+		t.Errorf("Load() rows = %+v, want %+v", gotRows, rows)
+	}
+}
+
+func TestStoreRemovesOutdatedFiles(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "data.tar.gz")
+
+	if err := cache.Store(archivePath, "0123456789abcdef", newTestDataset(), cache.Rows{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cache.Store(archivePath, "fedcba9876543210", newTestDataset(), cache.Rows{}); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := cache.List(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{cache.Path(archivePath, "fedcba9876543210")}; !reflect.DeepEqual(files, want) {
+		t.Errorf("List() = %v, want %v", files, want)
+	}
+
+	removed, err := cache.Clean(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(removed) != 1 {
+		t.Errorf("Clean() removed %v, want 1 file", removed)
+	}
+
+	if _, _, err := cache.Load(archivePath, "fedcba9876543210"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load() after Clean() error = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestListSkipsSiblingArchives(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "data.tar.gz")
+
+	for _, sibling := range []string{archivePath + ".bak", filepath.Join(dir, "data.tar")} {
+		if err := cache.Store(sibling, "0123456789abcdef", newTestDataset(), cache.Rows{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := cache.Store(archivePath, "fedcba9876543210", newTestDataset(), cache.Rows{}); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := cache.List(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{cache.Path(archivePath, "fedcba9876543210")}; !reflect.DeepEqual(files, want) {
+		t.Errorf("List() = %v, want %v", files, want)
+	}
+
+	if _, _, err := cache.Load(archivePath+".bak", "0123456789abcdef"); err != nil {
+		t.Errorf("Store() removed cache of a sibling archive: %v", err)
+	}
+}
+
+func TestLoadCorrupted(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "data.tar.gz")
+
+	if err := cache.Store(archivePath, "abc", newTestDataset(), cache.Rows{}); err != nil {
+		t.Fatal(err)
+	}
+
+	path := cache.Path(archivePath, "abc")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "truncated", data: data[:len(data)/2]},
+		{name: "flipped byte", data: append(append([]byte{}, data[:20]...), append([]byte{data[20] ^ 0xff}, data[21:]...)...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(path, tt.data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, _, err := cache.Load(archivePath, "abc"); !errors.Is(err, cache.ErrCorrupted) {
+				t.Errorf("Load() error = %v, want ErrCorrupted", err)
+			}
+		})
+	}
+}
+
+func TestHashFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.tar.gz")
+	if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := cache.HashFile(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7"; hash != want {
+		t.Errorf("HashFile() = %v, want %v", hash, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := cache.HashFile(ctx, path); !errors.Is(err, context.Canceled) {
+		t.Errorf("HashFile() error = %v, want context.Canceled", err)
+	}
+}
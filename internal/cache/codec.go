@@ -0,0 +1,293 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+// magic starts every cache file.
+const magic = "GHACACHE"
+
+// Cache file layout:
+//
+//	magic | schema version (uint32) | actors | repos | events | rows | CRC-32 of everything before (uint32)
+//
+// Rows are sorted names of CSV files followed by amounts of read rows and then of rejected rows of every file.
+// Every table of the dataset is stored column by column. A string column is an amount of values followed by their
+// lengths and then by their concatenated bytes. An integer column is an amount of values followed by the values.
+// All integers except the fixed-size ones are uvarints.
+
+type encoder struct {
+	w   *bufio.Writer
+	crc uint32
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func encodeDataset(w io.Writer, ds *github.Dataset, rows Rows) error {
+	e := &encoder{w: bufio.NewWriter(w)}
+
+	e.write([]byte(magic))
+	e.uint32(SchemaVersion)
+
+	e.strings(ds.Actors.IDs)
+	e.strings(ds.Actors.Usernames)
+	e.uvarint(uint64(ds.Actors.Listed))
+
+	e.strings(ds.Repos.IDs)
+	e.strings(ds.Repos.Names)
+	e.uvarint(uint64(ds.Repos.Listed))
+
+	et := &ds.Events
+	e.strings(et.TypeNames)
+	e.uvarint(uint64(len(et.Types)))
+
+	for _, v := range et.Types {
+		e.uvarint(uint64(v))
+	}
+
+	e.uint32s(et.Actors)
+	e.uint32s(et.Repos)
+	e.uint32s(et.Commits)
+
+	filenames := make([]string, 0, len(rows.Read))
+	for filename := range rows.Read {
+		filenames = append(filenames, filename)
+	}
+
+	sort.Strings(filenames)
+	e.strings(filenames)
+
+	for _, filename := range filenames {
+		e.uvarint(uint64(rows.Read[filename]))
+	}
+
+	for _, filename := range filenames {
+		e.uvarint(uint64(rows.Rejected[filename]))
+	}
+
+	if e.err != nil {
+		return e.err
+	}
+
+	var sum [4]byte
+
+	binary.LittleEndian.PutUint32(sum[:], e.crc)
+
+	if _, err := e.w.Write(sum[:]); err != nil {
+		return err
+	}
+
+	return e.w.Flush()
+}
+
+func (e *encoder) write(p []byte) {
+	if e.err != nil {
+		return
+	}
+
+	e.crc = crc32.Update(e.crc, crc32.IEEETable, p)
+	_, e.err = e.w.Write(p)
+}
+
+func (e *encoder) uint32(v uint32) {
+	binary.LittleEndian.PutUint32(e.buf[:4], v)
+	e.write(e.buf[:4])
+}
+
+func (e *encoder) uvarint(v uint64) {
+	n := binary.PutUvarint(e.buf[:], v)
+	e.write(e.buf[:n])
+}
+
+func (e *encoder) strings(col []string) {
+	e.uvarint(uint64(len(col)))
+
+	for _, s := range col {
+		e.uvarint(uint64(len(s)))
+	}
+
+	for _, s := range col {
+		e.write([]byte(s))
+	}
+}
+
+func (e *encoder) uint32s(col []uint32) {
+	e.uvarint(uint64(len(col)))
+
+	for _, v := range col {
+		e.uvarint(uint64(v))
+	}
+}
+
+type decoder struct {
+	data []byte
+	off  int
+	err  error
+}
+
+func decodeDataset(data []byte) (*github.Dataset, Rows, error) {
+	if len(data) < len(magic)+8 || string(data[:len(magic)]) != magic {
+		return nil, Rows{}, errors.Wrap(ErrCorrupted, "bad header")
+	}
+
+	payload := data[:len(data)-4]
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(data[len(data)-4:]) {
+		return nil, Rows{}, errors.Wrap(ErrCorrupted, "checksum mismatch")
+	}
+
+	d := &decoder{data: payload, off: len(magic)}
+
+	if v := d.uint32(); v != SchemaVersion {
+		return nil, Rows{}, errors.Wrapf(ErrCorrupted, "unsupported schema version %d", v)
+	}
+
+	ds := &github.Dataset{}
+
+	ds.Actors.IDs = d.strings()
+	ds.Actors.Usernames = d.strings()
+	ds.Actors.Listed = int(d.uvarint())
+
+	ds.Repos.IDs = d.strings()
+	ds.Repos.Names = d.strings()
+	ds.Repos.Listed = int(d.uvarint())
+
+	et := &ds.Events
+	et.TypeNames = d.strings()
+	et.Types = make([]uint16, d.length())
+
+	for i := range et.Types {
+		et.Types[i] = uint16(d.uvarint())
+	}
+
+	et.Actors = d.uint32s()
+	et.Repos = d.uint32s()
+	et.Commits = d.uint32s()
+
+	filenames := d.strings()
+	rows := Rows{Read: make(map[string]int, len(filenames)), Rejected: make(map[string]int, len(filenames))}
+
+	for _, filename := range filenames {
+		rows.Read[filename] = int(d.uvarint())
+	}
+
+	for _, filename := range filenames {
+		rows.Rejected[filename] = int(d.uvarint())
+	}
+
+	if d.err != nil {
+		return nil, Rows{}, d.err
+	}
+
+	if err := ds.Validate(); err != nil {
+		return nil, Rows{}, errors.Wrap(ErrCorrupted, err.Error())
+	}
+
+	return ds, rows, nil
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+
+	if n < 0 || n > len(d.data)-d.off {
+		d.err = errors.Wrap(ErrCorrupted, "unexpected end of data")
+
+		return nil
+	}
+
+	p := d.data[d.off : d.off+n]
+	d.off += n
+
+	return p
+}
+
+func (d *decoder) uint32() uint32 {
+	p := d.next(4)
+	if p == nil {
+		return 0
+	}
+
+	return binary.LittleEndian.Uint32(p)
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+
+	v, n := binary.Uvarint(d.data[d.off:])
+	if n <= 0 {
+		d.err = errors.Wrap(ErrCorrupted, "bad varint")
+
+		return 0
+	}
+
+	d.off += n
+
+	return v
+}
+
+// length reads amount of items and checks that there is at least one byte left per item,
+// so a corrupted length can't make us allocate a huge slice.
+func (d *decoder) length() int {
+	n := d.uvarint()
+	if d.err == nil && n > uint64(len(d.data)-d.off) {
+		d.err = errors.Wrap(ErrCorrupted, "bad length")
+	}
+
+	if d.err != nil {
+		return 0
+	}
+
+	return int(n)
+}
+
+func (d *decoder) strings() []string {
+	n := d.length()
+	lens := make([]int, n)
+
+	for i := range lens {
+		lens[i] = int(d.uvarint())
+	}
+
+	var total int
+	for _, l := range lens {
+		if l < 0 || l > len(d.data) {
+			d.err = errors.Wrap(ErrCorrupted, "bad string length")
+
+			return nil
+		}
+
+		total += l
+	}
+
+	// values share one copy of the column bytes, so a column takes one allocation instead of one per value
+	all := string(d.next(total))
+	col := make([]string, n)
+
+	for i, off := 0, 0; i < len(col) && d.err == nil; i++ {
+		col[i] = all[off : off+lens[i]]
+		off += lens[i]
+	}
+
+	return col
+}
+
+func (d *decoder) uint32s() []uint32 {
+	col := make([]uint32, d.length())
+
+	for i := range col {
+		col[i] = uint32(d.uvarint())
+	}
+
+	return col
+}
@@ -0,0 +1,383 @@
+// Package config reads configuration files of the application. A configuration file is a subset of YAML:
+// top-level settings, which are scalars or lists of scalars, and a `profiles` mapping of named profiles
+// with settings overriding the top-level ones, e.g.
+//
+//	archive: ./samples/data.tar.gz
+//	top: 20
+//	profiles:
+//	  month:
+//	    archive: /data/2020-01.tar.gz
+//	    type: [PushEvent, PullRequestEvent]
+package config
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ProfilesKey is the key of the mapping of named profiles.
+const ProfilesKey = "profiles"
+
+var (
+	// ErrSyntax is returned if a configuration file is not valid.
+	ErrSyntax = errors.New("syntax error")
+	// ErrNoSuchProfile is returned if a configuration file has no such profile.
+	ErrNoSuchProfile = errors.New("no such profile")
+)
+
+// Settings are values of settings by key. A scalar setting has one value.
+type Settings map[string][]string
+
+// File is a parsed configuration file.
+type File struct {
+	// Path is path of the file, empty if it wasn't read from a file.
+	Path     string
+	Settings Settings
+	Profiles map[string]Settings
+}
+
+// DefaultPath returns path of the configuration file that is read if no other is given:
+// $XDG_CONFIG_HOME/ghanalytics/config.yaml, or ~/.config/ghanalytics/config.yaml if XDG_CONFIG_HOME is not set.
+// It returns an empty string if the home directory is unknown.
+func DefaultPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+
+		dir = filepath.Join(home, ".config")
+	}
+
+	return filepath.Join(dir, "ghanalytics", "config.yaml")
+}
+
+// Load reads and parses the configuration file.
+func Load(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	file, err := Parse(f)
+	if err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+
+	file.Path = path
+
+	return file, nil
+}
+
+// Profile returns settings of the named profile merged over the top-level settings.
+// An empty name returns the top-level settings.
+func (f *File) Profile(name string) (Settings, error) {
+	merged := make(Settings, len(f.Settings))
+	for key, values := range f.Settings {
+		merged[key] = values
+	}
+
+	if name == "" {
+		return merged, nil
+	}
+
+	profile, ok := f.Profiles[name]
+	if !ok {
+		return nil, errors.Wrapf(ErrNoSuchProfile, "%q, known profiles: %s", name, strings.Join(f.ProfileNames(), ", "))
+	}
+
+	for key, values := range profile {
+		merged[key] = values
+	}
+
+	return merged, nil
+}
+
+// ProfileNames returns sorted names of profiles.
+func (f *File) ProfileNames() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// line is a meaningful line of a configuration file.
+type line struct {
+	num    int
+	indent int
+	text   string
+}
+
+// Parse parses a configuration file.
+func Parse(r io.Reader) (*File, error) {
+	var lines []line
+
+	scanner := bufio.NewScanner(r)
+	for num := 1; scanner.Scan(); num++ {
+		text := stripComment(scanner.Text())
+		trimmed := strings.TrimLeft(text, " ")
+
+		if strings.TrimSpace(trimmed) == "" {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, errors.Wrapf(ErrSyntax, "line %d: tabs can't be used for indentation", num)
+		}
+
+		lines = append(lines, line{num: num, indent: len(text) - len(trimmed), text: strings.TrimSpace(trimmed)})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	p := &parser{lines: lines}
+
+	root, err := p.mapping(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos < len(p.lines) {
+		return nil, errors.Wrapf(ErrSyntax, "line %d: unexpected indentation", p.lines[p.pos].num)
+	}
+
+	file := &File{Settings: make(Settings), Profiles: make(map[string]Settings)}
+
+	for key, n := range root {
+		if key != ProfilesKey {
+			if n.mapping != nil {
+				return nil, errors.Wrapf(ErrSyntax, "setting %q should be a scalar or a list", key)
+			}
+
+			file.Settings[key] = n.values
+
+			continue
+		}
+
+		if n.mapping == nil && len(n.values) > 0 {
+			return nil, errors.Wrap(ErrSyntax, "profiles should be a mapping of named profiles")
+		}
+
+		for name, profile := range n.mapping {
+			// a profile without settings is parsed as an empty list
+			if profile.mapping == nil && len(profile.values) > 0 {
+				return nil, errors.Wrapf(ErrSyntax, "profile %q should be a mapping of settings", name)
+			}
+
+			settings := make(Settings, len(profile.mapping))
+
+			for key, setting := range profile.mapping {
+				if setting.mapping != nil {
+					return nil, errors.Wrapf(ErrSyntax, "setting %q of profile %q should be a scalar or a list", key, name)
+				}
+
+				settings[key] = setting.values
+			}
+
+			file.Profiles[name] = settings
+		}
+	}
+
+	return file, nil
+}
+
+// node is a value in a configuration file: either a mapping or scalar values.
+type node struct {
+	values  []string
+	mapping map[string]node
+}
+
+type parser struct {
+	lines []line
+	pos   int
+}
+
+// mapping parses `key: value` lines with the indentation.
+func (p *parser) mapping(indent int) (map[string]node, error) {
+	m := make(map[string]node)
+
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent {
+		l := p.lines[p.pos]
+		p.pos++
+
+		colon := strings.Index(l.text, ":")
+		if colon <= 0 || strings.HasPrefix(l.text, "- ") {
+			return nil, errors.Wrapf(ErrSyntax, "line %d: expected `key: value`", l.num)
+		}
+
+		key := strings.TrimSpace(l.text[:colon])
+		if _, ok := m[key]; ok {
+			return nil, errors.Wrapf(ErrSyntax, "line %d: duplicate key %q", l.num, key)
+		}
+
+		n, err := p.value(l, strings.TrimSpace(l.text[colon+1:]))
+		if err != nil {
+			return nil, err
+		}
+
+		m[key] = n
+	}
+
+	return m, nil
+}
+
+// value parses the value of the key on line l, which is either on the same line or in an indented block.
+func (p *parser) value(l line, text string) (node, error) {
+	if text != "" {
+		values, err := parseScalars(text)
+		if err != nil {
+			return node{}, errors.Wrapf(err, "line %d", l.num)
+		}
+
+		return node{values: values}, nil
+	}
+
+	if p.pos == len(p.lines) || p.lines[p.pos].indent <= l.indent {
+		// a key without value is an empty list
+		return node{values: []string{}}, nil
+	}
+
+	indent := p.lines[p.pos].indent
+
+	if strings.HasPrefix(p.lines[p.pos].text, "- ") || p.lines[p.pos].text == "-" {
+		values := []string{}
+
+		for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && strings.HasPrefix(p.lines[p.pos].text, "-") {
+			item, err := parseScalar(strings.TrimSpace(strings.TrimPrefix(p.lines[p.pos].text, "-")))
+			if err != nil {
+				return node{}, errors.Wrapf(err, "line %d", p.lines[p.pos].num)
+			}
+
+			values = append(values, item)
+			p.pos++
+		}
+
+		return node{values: values}, nil
+	}
+
+	m, err := p.mapping(indent)
+	if err != nil {
+		return node{}, err
+	}
+
+	return node{mapping: m}, nil
+}
+
+// parseScalars parses a scalar or a flow list of scalars like `[a, "b c"]`.
+func parseScalars(text string) ([]string, error) {
+	if !strings.HasPrefix(text, "[") {
+		v, err := parseScalar(text)
+
+		return []string{v}, err
+	}
+
+	if !strings.HasSuffix(text, "]") {
+		return nil, errors.Wrap(ErrSyntax, "unterminated list")
+	}
+
+	values := []string{}
+
+	inner := strings.TrimSpace(text[1 : len(text)-1])
+	if inner == "" {
+		return values, nil
+	}
+
+	for _, item := range splitFlow(inner) {
+		v, err := parseScalar(strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, v)
+	}
+
+	return values, nil
+}
+
+// splitFlow splits items of a flow list by commas outside quotes, so `a, "b,c"` is two items.
+func splitFlow(inner string) []string {
+	var (
+		items   []string
+		quote   rune
+		escaped bool
+		start   int
+	)
+
+	for i, r := range inner {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == ',':
+			items = append(items, inner[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(items, inner[start:])
+}
+
+func parseScalar(text string) (string, error) {
+	switch {
+	case strings.HasPrefix(text, `"`):
+		v, err := strconv.Unquote(text)
+		if err != nil {
+			return "", errors.Wrapf(ErrSyntax, "bad double-quoted string %s", text)
+		}
+
+		return v, nil
+	case strings.HasPrefix(text, "'"):
+		if len(text) < 2 || !strings.HasSuffix(text, "'") {
+			return "", errors.Wrapf(ErrSyntax, "bad single-quoted string %s", text)
+		}
+
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	default:
+		return text, nil
+	}
+}
+
+// stripComment removes a comment that starts with # at the beginning of the line or after a space, outside quotes.
+func stripComment(text string) string {
+	var quote rune
+
+	for i, r := range text {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t'):
+			return text[:i]
+		}
+	}
+
+	return text
+}
@@ -0,0 +1,154 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/config"
+)
+
+const sampleConfig = `# defaults for every command
+archive: ./samples/data.tar.gz
+top: 20 # inline comment
+format: "json"
+type: [PushEvent, 'PullRequestEvent']
+
+profiles:
+  month:
+    archive: /data/2020-01.tar.gz
+    bots: true
+    owner:
+      - golang
+      - "kubernetes #1"
+  empty:
+`
+
+func TestParse(t *testing.T) {
+	file, err := config.Parse(strings.NewReader(sampleConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &config.File{
+		Settings: config.Settings{
+			"archive": {"./samples/data.tar.gz"},
+			"top":     {"20"},
+			"format":  {"json"},
+			"type":    {"PushEvent", "PullRequestEvent"},
+		},
+		Profiles: map[string]config.Settings{
+			"month": {
+				"archive": {"/data/2020-01.tar.gz"},
+				"bots":    {"true"},
+				"owner":   {"golang", "kubernetes #1"},
+			},
+			"empty": {},
+		},
+	}
+
+	if !reflect.DeepEqual(file, want) {
+		t.Errorf("Parse() = %+v, want %+v", file, want)
+	}
+
+	got, err := file.Profile("month")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantMonth := config.Settings{
+		"archive": {"/data/2020-01.tar.gz"},
+		"top":     {"20"},
+		"format":  {"json"},
+		"type":    {"PushEvent", "PullRequestEvent"},
+		"bots":    {"true"},
+		"owner":   {"golang", "kubernetes #1"},
+	}
+	if !reflect.DeepEqual(got, wantMonth) {
+		t.Errorf("Profile() = %v, want %v", got, wantMonth)
+	}
+
+	if _, err := file.Profile("year"); !errors.Is(err, config.ErrNoSuchProfile) {
+		t.Errorf("Profile() error = %v, want %v", err, config.ErrNoSuchProfile)
+	}
+}
+
+// TestParse_FlowList checks that commas in quoted items of flow lists don't split them.
+func TestParse_FlowList(t *testing.T) {
+	file, err := config.Parse(strings.NewReader(`where: [a, "b,c", 'd, ''e''', "f\"g,h", ""]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := file.Settings["where"], []string{"a", "b,c", "d, 'e'", `f"g,h`, ""}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %q, want %q", got, want)
+	}
+}
+
+func TestParse_SyntaxError(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{name: "no colon", text: "archive"},
+		{name: "duplicate key", text: "top: 1\ntop: 2"},
+		{name: "unexpected indentation", text: "  top: 1\ntop: 2"},
+		{name: "tab indentation", text: "profiles:\n\tmonth:"},
+		{name: "unterminated list", text: "type: [PushEvent"},
+		{name: "bad quotes", text: `format: "json`},
+		{name: "nested setting", text: "archive:\n  path: x"},
+		{name: "scalar profiles", text: "profiles: month"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := config.Parse(strings.NewReader(tt.text)); !errors.Is(err, config.ErrSyntax) {
+				t.Errorf("Parse() error = %v, want %v", err, config.ErrSyntax)
+			}
+		})
+	}
+}
+
+func TestDefaultPath(t *testing.T) {
+	dir := t.TempDir()
+
+	prev, wasSet := os.LookupEnv("XDG_CONFIG_HOME")
+	if err := os.Setenv("XDG_CONFIG_HOME", dir); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if wasSet {
+			_ = os.Setenv("XDG_CONFIG_HOME", prev)
+		} else {
+			_ = os.Unsetenv("XDG_CONFIG_HOME")
+		}
+	}()
+
+	path := config.DefaultPath()
+	if want := filepath.Join(dir, "ghanalytics", "config.yaml"); path != want {
+		t.Fatalf("DefaultPath() = %s, want %s", path, want)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte(sampleConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if file.Path != path || len(file.Profiles) != 2 {
+		t.Errorf("Load() = %+v", file)
+	}
+}
@@ -0,0 +1,347 @@
+// Package generator generates synthetic GitHub data archives for load and regression testing.
+// Generated archives have the same CSV files and columns as real ones and reproduce a Profile of activity:
+// power-law popularity of repositories and activity of users, share of bots, event type mix and commits per push.
+// The same Config always generates the same archive.
+package generator
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+const (
+	// repoZipfV and actorZipfV flatten the head of Zipf distributions, so the most popular repository and
+	// the most active user get about the same share of events as they do in the sample archive.
+	repoZipfV  = 20
+	actorZipfV = 5
+
+	firstEventID int64 = 11185376329
+	firstActorID       = 1000000
+	firstRepoID        = 100000000
+	// idStep is the maximum gap between consecutive actor and repository IDs.
+	idStep = 97
+
+	ctxCheckInterval = 1 << 14
+)
+
+// ErrWrongConfig is returned if config can't be used to generate an archive.
+var ErrWrongConfig = errors.New("wrong config")
+
+// Config configures generated archive.
+type Config struct {
+	// Events is amount of events.
+	Events int
+	// Actors is amount of actors that may make events, only the ones that made any are listed in the archive.
+	Actors int
+	// Repos is amount of repositories where events may happen, only the ones that got any are listed in the archive.
+	Repos int
+	// RepoSkew is exponent of power law distribution of events among repositories, it must be greater than 1.
+	// The bigger it is, the more events the most popular repositories get.
+	RepoSkew float64
+	// ActorSkew is exponent of power law distribution of events among actors, it must be greater than 1.
+	ActorSkew float64
+	// Seed seeds random numbers, the same config with the same seed generates the same archive.
+	Seed int64
+	// Profile is the shape of reproduced activity.
+	Profile Profile
+}
+
+// DefaultConfig returns config of archive with given amount of events that resembles the sample archive.
+func DefaultConfig(events int) Config {
+	return Config{
+		Events:    events,
+		Actors:    events * 3 / 10,
+		Repos:     events * 2 / 5,
+		RepoSkew:  1.1,
+		ActorSkew: 1.2,
+		Seed:      1,
+		Profile:   DefaultProfile(),
+	}
+}
+
+func (c *Config) validate() error {
+	switch {
+	case c.Events < 0:
+		return errors.Wrap(ErrWrongConfig, "amount of events can't be negative")
+	case c.Actors < 1 || c.Repos < 1:
+		return errors.Wrap(ErrWrongConfig, "there should be at least 1 actor and 1 repository")
+	case c.RepoSkew <= 1 || c.ActorSkew <= 1:
+		return errors.Wrap(ErrWrongConfig, "skew should be greater than 1")
+	case c.Profile.BotEventShare < 0 || c.Profile.BotEventShare > 1 || c.Profile.BotActorShare < 0 || c.Profile.BotActorShare > 1:
+		return errors.Wrap(ErrWrongConfig, "bot shares should be between 0 and 1")
+	}
+
+	return nil
+}
+
+// Generate generates an archive. Generation stops with ctx.Err() as soon as ctx is done.
+// The whole archive is kept in memory, so memory usage is proportional to amount of events.
+func Generate(ctx context.Context, cfg Config) (*github.Archive, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	g, err := newGenerator(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &github.Archive{
+		Events: make([]github.EventCSV, 0, cfg.Events),
+	}
+
+	actorListed := make([]bool, cfg.Actors)
+	repoListed := make([]bool, cfg.Repos)
+
+	for i := 0; i < cfg.Events; i++ {
+		if i%ctxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		actor, bot := g.actor()
+		repo := g.repo()
+
+		eventTypes := g.humanEventTypes
+		if bot {
+			eventTypes = g.botEventTypes
+		}
+
+		e := github.EventCSV{
+			ID:      strconv.FormatInt(firstEventID+int64(i), 10),
+			Type:    eventTypes.pick(g.r),
+			ActorID: g.actorIDs[actor],
+			RepoID:  g.repoIDs[repo],
+		}
+
+		a.Events = append(a.Events, e)
+
+		if !actorListed[actor] {
+			actorListed[actor] = true
+			a.Actors = append(a.Actors, github.ActorCSV{ID: g.actorIDs[actor], Username: g.usernames[actor]})
+		}
+
+		if !repoListed[repo] {
+			repoListed[repo] = true
+			a.Repos = append(a.Repos, github.RepoCSV{ID: g.repoIDs[repo], Name: g.repoNames[repo]})
+		}
+
+		if e.Type == github.PushEventType {
+			for n := g.commitsPerPush.pick(g.r); n > 0; n-- {
+				a.Commits = append(a.Commits, github.CommitCSV{SHA: g.sha(), Message: g.message(), EventID: e.ID})
+			}
+		}
+	}
+
+	return a, nil
+}
+
+type generator struct {
+	r *rand.Rand
+
+	actorIDs  []string
+	usernames []string
+	// humans and bots are indices of actors, ordered by activity rank
+	humans    []int
+	bots      []int
+	humanZipf *rand.Zipf
+	botZipf   *rand.Zipf
+
+	repoIDs   []string
+	repoNames []string
+	// repoByRank are indices of repositories ordered by popularity rank
+	repoByRank []int
+	repoZipf   *rand.Zipf
+
+	humanEventTypes typeChoice
+	botEventTypes   typeChoice
+	commitsPerPush  weightedChoice
+	botEventShare   float64
+}
+
+func newGenerator(cfg Config) (*generator, error) {
+	r := rand.New(rand.NewSource(cfg.Seed)) //nolint:gosec // reproducible pseudo-random data, not security
+
+	g := &generator{
+		r:               r,
+		humanEventTypes: newTypeChoice(cfg.Profile.EventTypeWeights),
+		botEventTypes:   newTypeChoice(cfg.Profile.BotEventTypeWeights),
+		commitsPerPush:  newWeightedChoice(cfg.Profile.CommitsPerPushWeights),
+		botEventShare:   cfg.Profile.BotEventShare,
+	}
+
+	if g.humanEventTypes.choice.empty() {
+		return nil, errors.Wrap(ErrWrongConfig, "profile has no event types")
+	}
+
+	// every push has one commit, if profile doesn't say otherwise
+	if g.commitsPerPush.empty() {
+		g.commitsPerPush = newWeightedChoice([]float64{0, 1})
+	}
+
+	numBots := int(math.Round(float64(cfg.Actors) * cfg.Profile.BotActorShare))
+	if numBots == 0 && cfg.Profile.BotEventShare > 0 && cfg.Actors > 1 {
+		numBots = 1
+	}
+
+	if numBots >= cfg.Actors {
+		numBots = cfg.Actors - 1
+	}
+
+	if g.botEventTypes.choice.empty() || numBots == 0 {
+		g.botEventShare = 0
+	}
+
+	g.actorIDs = newIDs(r, firstActorID, cfg.Actors)
+	g.usernames = make([]string, cfg.Actors)
+
+	for i, actor := range r.Perm(cfg.Actors) {
+		if i < numBots {
+			g.usernames[actor] = "bot-" + strconv.Itoa(actor) + "[bot]"
+			g.bots = append(g.bots, actor)
+		} else {
+			g.usernames[actor] = "user-" + strconv.Itoa(actor)
+			g.humans = append(g.humans, actor)
+		}
+	}
+
+	g.humanZipf = rand.NewZipf(r, cfg.ActorSkew, actorZipfV, uint64(len(g.humans)-1))
+	if len(g.bots) > 0 {
+		g.botZipf = rand.NewZipf(r, cfg.ActorSkew, actorZipfV, uint64(len(g.bots)-1))
+	}
+
+	g.repoIDs = newIDs(r, firstRepoID, cfg.Repos)
+	g.repoNames = make([]string, cfg.Repos)
+
+	for i := range g.repoNames {
+		owner := g.usernames[g.humans[r.Intn(len(g.humans))]]
+		g.repoNames[i] = owner + "/repo-" + strconv.Itoa(i)
+	}
+
+	g.repoByRank = r.Perm(cfg.Repos)
+	g.repoZipf = rand.NewZipf(r, cfg.RepoSkew, repoZipfV, uint64(cfg.Repos-1))
+
+	if g.humanZipf == nil || g.repoZipf == nil || (len(g.bots) > 0 && g.botZipf == nil) {
+		return nil, errors.Wrap(ErrWrongConfig, "can't build power law distributions")
+	}
+
+	return g, nil
+}
+
+// actor returns index of the actor of the next event and whether it is a bot.
+func (g *generator) actor() (int, bool) {
+	if g.botEventShare > 0 && g.r.Float64() < g.botEventShare {
+		return g.bots[g.botZipf.Uint64()], true
+	}
+
+	return g.humans[g.humanZipf.Uint64()], false
+}
+
+func (g *generator) repo() int {
+	return g.repoByRank[g.repoZipf.Uint64()]
+}
+
+func (g *generator) sha() string {
+	return fmt.Sprintf("%016x%016x%08x", g.r.Uint64(), g.r.Uint64(), g.r.Uint32())
+}
+
+var messageWords = []string{
+	"fix", "add", "update", "remove", "refactor", "docs", "tests", "bump", "version", "readme",
+	"config", "build", "bug", "feature", "cleanup", "typo", "deps", "release", "merge", "branch",
+}
+
+func (g *generator) message() string {
+	words := make([]string, 1+g.r.Intn(4))
+	for i := range words {
+		words[i] = messageWords[g.r.Intn(len(messageWords))]
+	}
+
+	return strings.Join(words, " ")
+}
+
+// newIDs returns n unique increasing IDs starting from first with random gaps, like real IDs have.
+func newIDs(r *rand.Rand, first, n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = strconv.Itoa(first + i*idStep + r.Intn(idStep))
+	}
+
+	return ids
+}
+
+// weightedChoice picks indices of weights with probability proportional to the weights.
+type weightedChoice struct {
+	// cumulative are cumulative sums of weights
+	cumulative []float64
+}
+
+func newWeightedChoice(weights []float64) weightedChoice {
+	wc := weightedChoice{cumulative: make([]float64, len(weights))}
+
+	var sum float64
+
+	for i, w := range weights {
+		if w > 0 {
+			sum += w
+		}
+
+		wc.cumulative[i] = sum
+	}
+
+	return wc
+}
+
+func (wc weightedChoice) empty() bool {
+	return len(wc.cumulative) == 0 || wc.cumulative[len(wc.cumulative)-1] <= 0
+}
+
+// pick returns a random index.
+func (wc weightedChoice) pick(r *rand.Rand) int {
+	x := r.Float64() * wc.cumulative[len(wc.cumulative)-1]
+
+	i := sort.Search(len(wc.cumulative), func(i int) bool { return wc.cumulative[i] > x })
+	if i == len(wc.cumulative) {
+		i--
+	}
+
+	return i
+}
+
+// typeChoice picks event types.
+type typeChoice struct {
+	types  []string
+	choice weightedChoice
+}
+
+func newTypeChoice(weights map[string]float64) typeChoice {
+	tc := typeChoice{types: make([]string, 0, len(weights))}
+	for t := range weights {
+		tc.types = append(tc.types, t)
+	}
+
+	// map iteration order is random, types are sorted, so the same seed picks the same types
+	sort.Strings(tc.types)
+
+	typeWeights := make([]float64, len(tc.types))
+	for i, t := range tc.types {
+		typeWeights[i] = weights[t]
+	}
+
+	tc.choice = newWeightedChoice(typeWeights)
+
+	return tc
+}
+
+func (tc typeChoice) pick(r *rand.Rand) string {
+	return tc.types[tc.choice.pick(r)]
+}
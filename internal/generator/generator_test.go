@@ -0,0 +1,154 @@
+package generator_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/generator"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/samples"
+)
+
+func TestGenerate(t *testing.T) {
+	cfg := generator.DefaultConfig(10000)
+
+	a, err := generator.Generate(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(a.Events) != cfg.Events {
+		t.Fatalf("Generate() events = %d, want %d", len(a.Events), cfg.Events)
+	}
+
+	actorIDs := make(map[string]bool, len(a.Actors))
+	for _, actor := range a.Actors {
+		if actorIDs[actor.ID] {
+			t.Fatalf("Generate() actor %s is listed twice", actor.ID)
+		}
+
+		actorIDs[actor.ID] = true
+	}
+
+	repoIDs := make(map[string]bool, len(a.Repos))
+	for _, repo := range a.Repos {
+		if repoIDs[repo.ID] {
+			t.Fatalf("Generate() repo %s is listed twice", repo.ID)
+		}
+
+		repoIDs[repo.ID] = true
+	}
+
+	pushEventIDs := make(map[string]bool)
+
+	for _, e := range a.Events {
+		if !actorIDs[e.ActorID] || !repoIDs[e.RepoID] {
+			t.Fatalf("Generate() event %s refers to unlisted actor or repo", e.ID)
+		}
+
+		if e.Type == github.PushEventType {
+			pushEventIDs[e.ID] = true
+		}
+	}
+
+	for _, c := range a.Commits {
+		if !pushEventIDs[c.EventID] || len(c.SHA) != 40 {
+			t.Fatalf("Generate() commit %+v is malformed or doesn't belong to a push event", c)
+		}
+	}
+
+	// generated archive should have about the same profile as the requested one
+	got := generator.LearnProfile(a)
+	if d := got.BotEventShare - cfg.Profile.BotEventShare; d < -0.02 || d > 0.02 {
+		t.Errorf("Generate() bot event share = %v, want %v", got.BotEventShare, cfg.Profile.BotEventShare)
+	}
+
+	pushShare := got.EventTypeWeights[github.PushEventType] / float64(len(a.Events))
+	if pushShare < 0.45 || pushShare > 0.65 {
+		t.Errorf("Generate() push event share = %v, want about 0.55", pushShare)
+	}
+}
+
+func TestGenerate_Reproducible(t *testing.T) {
+	cfg := generator.DefaultConfig(1000)
+
+	a1, err := generator.Generate(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a2, err := generator.Generate(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(a1, a2) {
+		t.Error("Generate() generated different archives with the same config")
+	}
+
+	cfg.Seed++
+
+	a3, err := generator.Generate(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.DeepEqual(a1, a3) {
+		t.Error("Generate() generated the same archives with different seeds")
+	}
+}
+
+func TestGenerate_WrongConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *generator.Config)
+	}{
+		{name: "no actors", modify: func(cfg *generator.Config) { cfg.Actors = 0 }},
+		{name: "no repos", modify: func(cfg *generator.Config) { cfg.Repos = 0 }},
+		{name: "flat skew", modify: func(cfg *generator.Config) { cfg.RepoSkew = 1 }},
+		{name: "bot share above 1", modify: func(cfg *generator.Config) { cfg.Profile.BotEventShare = 2 }},
+		{name: "no event types", modify: func(cfg *generator.Config) { cfg.Profile.EventTypeWeights = nil }},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := generator.DefaultConfig(100)
+			tt.modify(&cfg)
+
+			if _, err := generator.Generate(context.Background(), cfg); !errors.Is(err, generator.ErrWrongConfig) {
+				t.Errorf("Generate() error = %v, want %v", err, generator.ErrWrongConfig)
+			}
+		})
+	}
+}
+
+func TestGenerate_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := generator.Generate(ctx, generator.DefaultConfig(100)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Generate() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestLearnProfile(t *testing.T) {
+	a, err := samples.Archive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := generator.LearnProfile(a)
+	want := generator.DefaultProfile()
+
+	if !reflect.DeepEqual(got.EventTypeWeights, want.EventTypeWeights) {
+		t.Errorf("LearnProfile() event types = %v, want %v", got.EventTypeWeights, want.EventTypeWeights)
+	}
+
+	if !reflect.DeepEqual(got.CommitsPerPushWeights, want.CommitsPerPushWeights) {
+		t.Errorf("LearnProfile() commits per push = %v, want %v", got.CommitsPerPushWeights, want.CommitsPerPushWeights)
+	}
+}
@@ -0,0 +1,113 @@
+package generator
+
+import (
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+// maxCommitsPerPush is the most commits GitHub lists in a push event.
+const maxCommitsPerPush = 20
+
+// Profile describes the shape of activity that Generate reproduces.
+type Profile struct {
+	// EventTypeWeights is relative frequency of event types of humans.
+	EventTypeWeights map[string]float64
+	// BotEventTypeWeights is relative frequency of event types of bots.
+	BotEventTypeWeights map[string]float64
+	// CommitsPerPushWeights is relative frequency of push events by amount of their commits, which is the index.
+	CommitsPerPushWeights []float64
+	// BotActorShare is share of actors that are bots.
+	BotActorShare float64
+	// BotEventShare is share of events made by bots.
+	BotEventShare float64
+}
+
+// DefaultProfile returns the profile learned from the sample archive of one hour of GitHub activity.
+func DefaultProfile() Profile {
+	return Profile{
+		EventTypeWeights: map[string]float64{
+			github.PushEventType:            18025,
+			"CreateEvent":                   4240,
+			github.WatchEventType:           2166,
+			github.PullRequestEventType:     1532,
+			"IssueCommentEvent":             1354,
+			"IssuesEvent":                   837,
+			"ForkEvent":                     755,
+			"DeleteEvent":                   682,
+			"PullRequestReviewCommentEvent": 315,
+			"GollumEvent":                   129,
+			"ReleaseEvent":                  111,
+			"PublicEvent":                   73,
+			"MemberEvent":                   46,
+			"CommitCommentEvent":            33,
+		},
+		BotEventTypeWeights: map[string]float64{
+			github.PullRequestEventType:     828,
+			github.PushEventType:            631,
+			"CreateEvent":                   456,
+			"IssueCommentEvent":             212,
+			"DeleteEvent":                   202,
+			"IssuesEvent":                   48,
+			"CommitCommentEvent":            32,
+			"ReleaseEvent":                  8,
+			"PullRequestReviewCommentEvent": 4,
+		},
+		CommitsPerPushWeights: []float64{
+			2137, 14559, 1047, 283, 117, 112, 53, 46, 37, 27, 26, 18, 17, 11, 10, 8, 9, 8, 4, 8, 119,
+		},
+		BotActorShare: 0.0043,
+		BotEventShare: 0.074,
+	}
+}
+
+// LearnProfile returns the profile of activity in the archive.
+func LearnProfile(a *github.Archive) Profile {
+	p := Profile{
+		EventTypeWeights:      make(map[string]float64),
+		BotEventTypeWeights:   make(map[string]float64),
+		CommitsPerPushWeights: make([]float64, maxCommitsPerPush+1),
+	}
+
+	isBot := make(map[string]bool, len(a.Actors))
+	for _, actor := range a.Actors {
+		isBot[actor.ID] = github.IsBotUsername(actor.Username)
+	}
+
+	for _, bot := range isBot {
+		if bot {
+			p.BotActorShare++
+		}
+	}
+
+	if len(isBot) > 0 {
+		p.BotActorShare /= float64(len(isBot))
+	}
+
+	numCommitsByEventID := make(map[string]int)
+	for _, c := range a.Commits {
+		numCommitsByEventID[c.EventID]++
+	}
+
+	for _, e := range a.Events {
+		if isBot[e.ActorID] {
+			p.BotEventTypeWeights[e.Type]++
+			p.BotEventShare++
+		} else {
+			p.EventTypeWeights[e.Type]++
+		}
+
+		if e.Type == github.PushEventType {
+			n := numCommitsByEventID[e.ID]
+			if n > maxCommitsPerPush {
+				n = maxCommitsPerPush
+			}
+
+			p.CommitsPerPushWeights[n]++
+		}
+	}
+
+	if len(a.Events) > 0 {
+		p.BotEventShare /= float64(len(a.Events))
+	}
+
+	return p
+}
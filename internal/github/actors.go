@@ -16,33 +16,3 @@ type ActorActivity struct {
 func (a ActorActivity) Total() int {
 	return a.PushedCommits + a.CreatedPullRequests
 }
-
-func newActorActivityByActorID(numCommitsByPushEventID map[string]int, events []EventCSV) map[string]ActorActivity {
-	m := make(map[string]ActorActivity)
-
-	for _, e := range events {
-		switch e.Type {
-		case PullRequestEventType:
-			if a, ok := m[e.ActorID]; ok {
-				a.CreatedPullRequests++
-				m[e.ActorID] = a
-			} else {
-				m[e.ActorID] = ActorActivity{
-					CreatedPullRequests: 1,
-				}
-			}
-
-		case PushEventType:
-			if a, ok := m[e.ActorID]; ok {
-				a.PushedCommits += numCommitsByPushEventID[e.ID]
-				m[e.ActorID] = a
-			} else {
-				m[e.ActorID] = ActorActivity{
-					PushedCommits: numCommitsByPushEventID[e.ID],
-				}
-			}
-		}
-	}
-
-	return m
-}
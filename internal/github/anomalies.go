@@ -0,0 +1,313 @@
+package github
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// AnomalyMethod is a method of computing baselines of entities.
+type AnomalyMethod string
+
+// Anomaly methods.
+const (
+	// MADMethod takes the median of previous periods as the baseline and their median absolute deviation,
+	// scaled to be consistent with the standard deviation, as the scale. Spikes in history don't move it.
+	MADMethod AnomalyMethod = "mad"
+	// EWMAMethod takes the exponentially weighted moving average and standard deviation of previous periods,
+	// so recent periods weigh more.
+	EWMAMethod AnomalyMethod = "ewma"
+)
+
+// AnomalyMetric is a metric of an entity which is checked for anomalies.
+type AnomalyMetric string
+
+// Anomaly metrics.
+const (
+	RepoCommitsMetric      AnomalyMetric = "repo-commits"
+	RepoWatchEventsMetric  AnomalyMetric = "repo-watch-events"
+	UserCommitsMetric      AnomalyMetric = "user-commits"
+	UserPullRequestsMetric AnomalyMetric = "user-pull-requests"
+)
+
+// AnomalyMetrics lists all anomaly metrics.
+var AnomalyMetrics = []AnomalyMetric{RepoCommitsMetric, RepoWatchEventsMetric, UserCommitsMetric, UserPullRequestsMetric}
+
+// madScale makes median absolute deviation of normally distributed values equal to their standard deviation.
+const madScale = 1.4826
+
+// minAnomalyScale is the least scale of baselines, so entities with constant history don't make any change
+// an infinite deviation.
+const minAnomalyScale = 1
+
+// AnomalyOptions configures anomaly detection.
+type AnomalyOptions struct {
+	Method AnomalyMethod
+	// Metrics are checked metrics, all of them if it is empty.
+	Metrics []AnomalyMetric
+	// Threshold is the least absolute z-score of an anomaly.
+	Threshold float64
+	// MinHistory is the least amount of previous periods to compute a baseline, earlier periods are not checked.
+	MinHistory int
+	// MinValue is the least value of a period or its baseline, smaller changes are noise.
+	MinValue int
+	// Alpha is the smoothing factor of EWMAMethod, the weight of the most recent period.
+	Alpha float64
+}
+
+// DefaultAnomalyOptions returns options of anomaly detection with median absolute deviation.
+func DefaultAnomalyOptions() AnomalyOptions {
+	return AnomalyOptions{Method: MADMethod, Threshold: 3.5, MinHistory: 3, MinValue: 10, Alpha: 0.3}
+}
+
+// Period is users and repositories of one archive or snapshot of a series.
+type Period struct {
+	Name  string
+	Users *UsersSample
+	Repos *ReposSample
+}
+
+// Anomaly is a value of a metric of a user or a repository which deviates from its baseline.
+type Anomaly struct {
+	Metric AnomalyMetric
+	ID     string
+	// Name is the username of a user or the name of a repository.
+	Name string
+	// Period is the index of the period of the anomaly in the series.
+	Period     int
+	PeriodName string
+	Value      int
+	Baseline   float64
+	Scale      float64
+	// Z is the deviation from the baseline in scales, it is negative for drops.
+	Z float64
+	// History is values of the metric in previous periods.
+	History []int
+}
+
+// series is values of a metric of an entity in every period.
+type series struct {
+	name   string
+	values []int
+}
+
+// DetectAnomalies returns anomalies of every period which has enough previous periods for a baseline,
+// sorted by absolute z-score descending, then by period, metric, name and ID.
+// Entities missing from a period have zero values in it. Detection stops with ctx.Err() as soon as ctx is done.
+func DetectAnomalies(ctx context.Context, periods []Period, opts AnomalyOptions) ([]Anomaly, error) {
+	if err := checkAnomalyOptions(opts); err != nil {
+		return nil, err
+	}
+
+	metrics := opts.Metrics
+	if len(metrics) == 0 {
+		metrics = AnomalyMetrics
+	}
+
+	var anomalies []Anomaly
+
+	for _, m := range metrics {
+		all := metricSeries(periods, m)
+
+		for id, s := range all {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			for p := opts.MinHistory; p < len(periods); p++ {
+				a, ok := checkPeriod(s.values[:p], s.values[p], opts)
+				if !ok {
+					continue
+				}
+
+				a.Metric, a.ID, a.Name, a.Period, a.PeriodName = m, id, s.name, p, periods[p].Name
+				anomalies = append(anomalies, a)
+			}
+		}
+	}
+
+	sort.Slice(anomalies, func(i, j int) bool {
+		ai, aj := anomalies[i], anomalies[j]
+		if zi, zj := math.Abs(ai.Z), math.Abs(aj.Z); zi != zj {
+			return zi > zj
+		}
+
+		if ai.Period != aj.Period {
+			return ai.Period < aj.Period
+		}
+
+		if ai.Metric != aj.Metric {
+			return ai.Metric < aj.Metric
+		}
+
+		if ai.Name != aj.Name {
+			return ai.Name < aj.Name
+		}
+
+		return ai.ID < aj.ID
+	})
+
+	return anomalies, nil
+}
+
+func checkAnomalyOptions(opts AnomalyOptions) error {
+	if opts.Method != MADMethod && opts.Method != EWMAMethod {
+		return errors.Wrapf(ErrWrongParam, "unknown method %q", opts.Method)
+	}
+
+	for _, m := range opts.Metrics {
+		if !isAnomalyMetric(m) {
+			return errors.Wrapf(ErrWrongParam, "unknown metric %q", m)
+		}
+	}
+
+	if opts.Threshold <= 0 {
+		return errors.Wrap(ErrWrongParam, "threshold should be above 0")
+	}
+
+	if opts.MinHistory < 1 {
+		return errors.Wrap(ErrWrongParam, "history should be at least 1 period")
+	}
+
+	if opts.Method == EWMAMethod && (opts.Alpha <= 0 || opts.Alpha > 1) {
+		return errors.Wrap(ErrWrongParam, "alpha should be above 0 and at most 1")
+	}
+
+	return nil
+}
+
+func isAnomalyMetric(m AnomalyMetric) bool {
+	for _, known := range AnomalyMetrics {
+		if m == known {
+			return true
+		}
+	}
+
+	return false
+}
+
+// metricSeries returns series of the metric of entities by ID. The last non-empty name wins,
+// the same way as in Merge.
+func metricSeries(periods []Period, m AnomalyMetric) map[string]*series {
+	all := make(map[string]*series)
+
+	add := func(p int, id, name string, value int) {
+		s, ok := all[id]
+		if !ok {
+			s = &series{values: make([]int, len(periods))}
+			all[id] = s
+		}
+
+		if name != "" {
+			s.name = name
+		}
+
+		s.values[p] += value
+	}
+
+	for p, period := range periods {
+		switch m {
+		case RepoCommitsMetric, RepoWatchEventsMetric:
+			if period.Repos == nil {
+				continue
+			}
+
+			for id, r := range period.Repos.M {
+				if m == RepoCommitsMetric {
+					add(p, id, r.Name, r.CommitsPushed)
+				} else {
+					add(p, id, r.Name, r.WatchEvents)
+				}
+			}
+		case UserCommitsMetric, UserPullRequestsMetric:
+			if period.Users == nil {
+				continue
+			}
+
+			for id, u := range period.Users.M {
+				if m == UserCommitsMetric {
+					add(p, id, u.Username, u.Activity.PushedCommits)
+				} else {
+					add(p, id, u.Username, u.Activity.CreatedPullRequests)
+				}
+			}
+		}
+	}
+
+	return all
+}
+
+// checkPeriod returns the anomaly of the value if it deviates from the baseline of the history.
+func checkPeriod(history []int, value int, opts AnomalyOptions) (Anomaly, bool) {
+	var baseline, scale float64
+
+	if opts.Method == EWMAMethod {
+		baseline, scale = ewma(history, opts.Alpha)
+	} else {
+		baseline, scale = medianMAD(history)
+	}
+
+	if float64(value) < float64(opts.MinValue) && baseline < float64(opts.MinValue) {
+		return Anomaly{}, false
+	}
+
+	scale = math.Max(scale, minAnomalyScale)
+
+	z := (float64(value) - baseline) / scale
+	if math.Abs(z) < opts.Threshold {
+		return Anomaly{}, false
+	}
+
+	return Anomaly{
+		Value:    value,
+		Baseline: baseline,
+		Scale:    scale,
+		Z:        z,
+		History:  append([]int(nil), history...),
+	}, true
+}
+
+// medianMAD returns the median of the values and their median absolute deviation scaled by madScale.
+func medianMAD(values []int) (float64, float64) {
+	sorted := make([]float64, len(values))
+	for i, v := range values {
+		sorted[i] = float64(v)
+	}
+
+	median := medianOf(sorted)
+
+	for i, v := range sorted {
+		sorted[i] = math.Abs(v - median)
+	}
+
+	return median, madScale * medianOf(sorted)
+}
+
+// medianOf returns the median of the values, sorting them.
+func medianOf(values []float64) float64 {
+	sort.Float64s(values)
+
+	mid := len(values) / 2
+	if len(values)%2 == 1 {
+		return values[mid]
+	}
+
+	return (values[mid-1] + values[mid]) / 2
+}
+
+// ewma returns the exponentially weighted moving average of the values and their exponentially weighted
+// standard deviation, starting from the first value.
+func ewma(values []int, alpha float64) (float64, float64) {
+	mean, variance := float64(values[0]), 0.0
+
+	for _, v := range values[1:] {
+		diff := float64(v) - mean
+		incr := alpha * diff
+		mean += incr
+		variance = (1 - alpha) * (variance + diff*incr)
+	}
+
+	return mean, math.Sqrt(variance)
+}
@@ -0,0 +1,91 @@
+package github_test
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+func TestDetectAnomalies(t *testing.T) {
+	commits := [][]int{
+		// a mass push in the last period
+		{20, 22, 19, 21, 400},
+		// steady, then quiet; the drop is big enough
+		{100, 104, 98, 101, 0},
+		// too small to matter
+		{0, 1, 0, 1, 9},
+	}
+	pullRequests := []int{0, 1, 0, 0, 1000}
+
+	periods := make([]github.Period, len(pullRequests))
+	for p := range periods {
+		periods[p] = github.Period{
+			Name: string(rune('a' + p)),
+			Repos: &github.ReposSample{M: map[string]github.Repo{
+				"1": {ID: "1", Name: "org/busy", CommitsPushed: commits[0][p]},
+				"2": {ID: "2", Name: "org/steady", CommitsPushed: commits[1][p]},
+				"3": {ID: "3", Name: "org/tiny", CommitsPushed: commits[2][p]},
+			}},
+			Users: &github.UsersSample{M: map[string]github.User{
+				"1": {ID: "1", Username: "spammer", Activity: github.ActorActivity{CreatedPullRequests: pullRequests[p]}},
+			}},
+		}
+	}
+
+	// the spammer appears in the second period only
+	delete(periods[0].Users.M, "1")
+
+	for _, method := range []github.AnomalyMethod{github.MADMethod, github.EWMAMethod} {
+		opts := github.DefaultAnomalyOptions()
+		opts.Method = method
+
+		anomalies, err := github.DetectAnomalies(context.Background(), periods, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := make([]string, len(anomalies))
+		for i, a := range anomalies {
+			got[i] = string(a.Metric) + " " + a.Name + " " + a.PeriodName
+		}
+
+		want := []string{"user-pull-requests spammer e", "repo-commits org/busy e", "repo-commits org/steady e"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("DetectAnomalies() with %s = %v, want %v", method, got, want)
+		}
+
+		for _, a := range anomalies {
+			if a.Name == "org/steady" && a.Z >= 0 {
+				t.Errorf("drop of org/steady has z-score %v, want negative", a.Z)
+			}
+		}
+	}
+
+	opts := github.DefaultAnomalyOptions()
+	opts.Metrics = []github.AnomalyMetric{github.RepoCommitsMetric}
+
+	anomalies, err := github.DetectAnomalies(context.Background(), periods, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	busy := anomalies[0]
+	if busy.Name != "org/busy" || busy.Baseline != 20.5 || !reflect.DeepEqual(busy.History, []int{20, 22, 19, 21}) {
+		t.Errorf("anomaly = %+v, want baseline 20.5 of org/busy", busy)
+	}
+
+	// median absolute deviation of 20, 22, 19, 21 is 1
+	if math.Abs(busy.Scale-1.4826) > 1e-9 {
+		t.Errorf("scale = %v, want 1.4826", busy.Scale)
+	}
+
+	opts.Metrics = []github.AnomalyMetric{"stars"}
+	if _, err := github.DetectAnomalies(context.Background(), periods, opts); !errors.Is(err, github.ErrWrongParam) {
+		t.Errorf("DetectAnomalies() error = %v, want %v", err, github.ErrWrongParam)
+	}
+}
@@ -0,0 +1,198 @@
+package github
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/sketch"
+)
+
+// ApproxOptions configures approximate aggregation.
+type ApproxOptions struct {
+	// Capacity is amount of heavy hitters tracked, the bigger it is, the smaller errors are.
+	Capacity int
+	// Epsilon is relative error of frequency estimates, which holds with probability 1-Delta.
+	Epsilon float64
+	Delta   float64
+}
+
+// DefaultApproxOptions returns options that keep errors of top 100 small on an hour of GitHub activity.
+func DefaultApproxOptions() ApproxOptions {
+	return ApproxOptions{
+		Capacity: 1000,
+		Epsilon:  0.0001,
+		Delta:    0.01,
+	}
+}
+
+func (o ApproxOptions) validate() error {
+	if o.Capacity < 1 || o.Epsilon <= 0 || o.Epsilon >= 1 || o.Delta <= 0 || o.Delta >= 1 {
+		return errors.Wrap(ErrWrongParam, "capacity should be positive, epsilon and delta should be between 0 and 1")
+	}
+
+	return nil
+}
+
+// ApproxItem is an approximately ranked user or repository. Its true count is between Count-Error and Count.
+type ApproxItem struct {
+	ID    string
+	Name  string
+	Count int
+	Error int
+}
+
+// ApproxTopNActiveUsers returns approximately top N users by amount of PRs created and commits pushed.
+// Memory used doesn't depend on amount of events or users, only IDs of bots are kept besides the sketch.
+// Users which are not listed in the actors CSV aren't ranked, like in UsersSample.
+func (s *ArchiveStream) ApproxTopNActiveUsers(ctx context.Context, n int, botsIncluded bool, opts ApproxOptions) ([]ApproxItem, error) {
+	if err := checkApprox(n, opts); err != nil {
+		return nil, err
+	}
+
+	bots := make(map[string]bool)
+
+	if !botsIncluded {
+		// the last row of an actor wins, the same way as in NewDataset
+		err := s.eachNamed(ctx, ActorsCSVFilename, func(id, username string) {
+			if IsBotUsername(username) {
+				bots[id] = true
+			} else {
+				delete(bots, id)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	topK := sketch.NewTopK(opts.Capacity, opts.Epsilon, opts.Delta)
+
+	err := s.eachEvent(ctx, true, func(e *EventCSV, commits int) {
+		if bots[e.ActorID] {
+			return
+		}
+
+		switch e.Type {
+		case PushEventType:
+			topK.Add(e.ActorID, uint64(commits))
+		case PullRequestEventType:
+			topK.Add(e.ActorID, 1)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.approxItems(ctx, topK.Top(opts.Capacity), n, ActorsCSVFilename, true)
+}
+
+// ApproxTopNByCommitsPushed returns approximately top N repositories by amount of commits pushed.
+// Memory used doesn't depend on amount of events or repositories.
+func (s *ArchiveStream) ApproxTopNByCommitsPushed(ctx context.Context, n int, opts ApproxOptions) ([]ApproxItem, error) {
+	if err := checkApprox(n, opts); err != nil {
+		return nil, err
+	}
+
+	topK := sketch.NewTopK(opts.Capacity, opts.Epsilon, opts.Delta)
+
+	err := s.eachEvent(ctx, true, func(e *EventCSV, commits int) {
+		if e.Type == PushEventType {
+			topK.Add(e.RepoID, uint64(commits))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.approxItems(ctx, topK.Top(n), n, ReposCSVFilename, false)
+}
+
+// ApproxTopNByWatchEvents returns approximately top N repositories by amount of watch events.
+// Memory used doesn't depend on amount of events or repositories.
+func (s *ArchiveStream) ApproxTopNByWatchEvents(ctx context.Context, n int, opts ApproxOptions) ([]ApproxItem, error) {
+	if err := checkApprox(n, opts); err != nil {
+		return nil, err
+	}
+
+	topK := sketch.NewTopK(opts.Capacity, opts.Epsilon, opts.Delta)
+
+	err := s.eachEvent(ctx, false, func(e *EventCSV, _ int) {
+		if e.Type == WatchEventType {
+			topK.Add(e.RepoID, 1)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.approxItems(ctx, topK.Top(n), n, ReposCSVFilename, false)
+}
+
+func checkApprox(n int, opts ApproxOptions) error {
+	if n < 1 {
+		return errors.Wrap(ErrWrongParam, "n should be positive")
+	}
+
+	return opts.validate()
+}
+
+// approxItems returns the first n counters of the sketch as items named by the actors or repos CSV.
+// Counters which are not listed in the CSV are left out if listedOnly is set.
+func (s *ArchiveStream) approxItems(
+	ctx context.Context, top []sketch.Counter, n int, csvFilename string, listedOnly bool,
+) ([]ApproxItem, error) {
+	// the sketch only knows IDs, names are looked up in one more pass over the CSV
+	names := make(map[string]string, len(top))
+	listed := make(map[string]bool, len(top))
+
+	for _, c := range top {
+		names[c.Key] = ""
+	}
+
+	err := s.eachNamed(ctx, csvFilename, func(id, name string) {
+		if _, ok := names[id]; ok {
+			names[id], listed[id] = name, true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]ApproxItem, 0, n)
+
+	for _, c := range top {
+		if len(items) == n {
+			break
+		}
+
+		if listedOnly && !listed[c.Key] {
+			continue
+		}
+
+		items = append(items, ApproxItem{ID: c.Key, Name: names[c.Key], Count: int(c.Count), Error: int(c.Error)})
+	}
+
+	return items, nil
+}
+
+// eachNamed calls f with ID and name of every row of the actors or repos CSV.
+func (s *ArchiveStream) eachNamed(ctx context.Context, csvFilename string, f func(id, name string)) error {
+	if csvFilename == ActorsCSVFilename {
+		var actor ActorCSV
+
+		return s.eachRow(ctx, csvFilename, &actor, func() error {
+			f(actor.ID, actor.Username)
+
+			return nil
+		})
+	}
+
+	var repo RepoCSV
+
+	return s.eachRow(ctx, csvFilename, &repo, func() error {
+		f(repo.ID, repo.Name)
+
+		return nil
+	})
+}
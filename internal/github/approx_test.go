@@ -0,0 +1,147 @@
+package github_test
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/samples"
+)
+
+// TestArchiveStream_ApproxTopN compares approximate rankings with exact ones on the sample archive.
+// Rankings have ties, so items may differ, but their true counts should be the same.
+func TestArchiveStream_ApproxTopN(t *testing.T) {
+	const n = 50
+
+	ctx := context.Background()
+
+	archive, err := samples.Archive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ds := archive.Dataset()
+	stream := sampleStream(t)
+	opts := github.DefaultApproxOptions()
+
+	users, err := ds.UsersSample(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repos, err := ds.ReposSample(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	topUsers, err := users.TopNActiveUsers(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	topByCommits, err := repos.TopNByCommitsPushed(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	topByWatches, err := repos.TopNByWatchEvents(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		approx func() ([]github.ApproxItem, error)
+		// exact returns true count of the user or repository with the ID
+		exact      func(id string) int
+		wantCounts []int
+	}{
+		{
+			name:   "active users",
+			approx: func() ([]github.ApproxItem, error) { return stream.ApproxTopNActiveUsers(ctx, n, false, opts) },
+			exact:  func(id string) int { return users.M[id].Activity.Total() },
+			wantCounts: func() []int {
+				counts := make([]int, len(topUsers))
+				for i, u := range topUsers {
+					counts[i] = u.Activity.Total()
+				}
+
+				return counts
+			}(),
+		},
+		{
+			name:   "repos by commits pushed",
+			approx: func() ([]github.ApproxItem, error) { return stream.ApproxTopNByCommitsPushed(ctx, n, opts) },
+			exact:  func(id string) int { return repos.M[id].CommitsPushed },
+			wantCounts: func() []int {
+				counts := make([]int, len(topByCommits))
+				for i, r := range topByCommits {
+					counts[i] = r.CommitsPushed
+				}
+
+				return counts
+			}(),
+		},
+		{
+			name:   "repos by watch events",
+			approx: func() ([]github.ApproxItem, error) { return stream.ApproxTopNByWatchEvents(ctx, n, opts) },
+			exact:  func(id string) int { return repos.M[id].WatchEvents },
+			wantCounts: func() []int {
+				counts := make([]int, len(topByWatches))
+				for i, r := range topByWatches {
+					counts[i] = r.WatchEvents
+				}
+
+				return counts
+			}(),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := tt.approx()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			counts := make([]int, len(items))
+
+			for i, item := range items {
+				counts[i] = tt.exact(item.ID)
+				if counts[i] > item.Count || counts[i] < item.Count-item.Error {
+					t.Errorf("item %+v doesn't bound true count %d", item, counts[i])
+				}
+
+				if item.Name == "" {
+					t.Errorf("item %+v has no name", item)
+				}
+			}
+
+			sort.Sort(sort.Reverse(sort.IntSlice(counts)))
+
+			if !reflect.DeepEqual(counts, tt.wantCounts) {
+				t.Errorf("true counts of approximate top = %v, want %v", counts, tt.wantCounts)
+			}
+		})
+	}
+}
+
+func TestArchiveStream_ApproxTopN_WrongParam(t *testing.T) {
+	stream := rowsStream(nil, nil, nil, nil)
+
+	if _, err := stream.ApproxTopNByWatchEvents(context.Background(), 0, github.DefaultApproxOptions()); !errors.Is(err, github.ErrWrongParam) {
+		t.Errorf("ApproxTopNByWatchEvents() error = %v, want %v", err, github.ErrWrongParam)
+	}
+
+	opts := github.DefaultApproxOptions()
+	opts.Epsilon = 0
+
+	if _, err := stream.ApproxTopNByWatchEvents(context.Background(), 10, opts); !errors.Is(err, github.ErrWrongParam) {
+		t.Errorf("ApproxTopNByWatchEvents() error = %v, want %v", err, github.ErrWrongParam)
+	}
+}
@@ -0,0 +1,207 @@
+package github
+
+import "strings"
+
+// Archive represents raw content of GitHub data archive: rows of all its CSV files.
+type Archive struct {
+	Actors  []ActorCSV
+	Repos   []RepoCSV
+	Events  []EventCSV
+	Commits []CommitCSV
+}
+
+// Dataset returns Dataset built from the archive.
+func (a *Archive) Dataset() *Dataset {
+	return NewDataset(a.Actors, a.Repos, a.Events, a.Commits)
+}
+
+// Rows returns amount of rows of each CSV file of the archive.
+func (a *Archive) Rows() map[string]int {
+	return map[string]int{
+		ActorsCSVFilename:  len(a.Actors),
+		ReposCSVFilename:   len(a.Repos),
+		EventsCSVFilename:  len(a.Events),
+		CommitsCSVFilename: len(a.Commits),
+	}
+}
+
+// RejectedRows returns amount of rows of each CSV file which don't make it into the dataset of the archive:
+// actors and repositories overridden by a later row with the same ID and a different name, and commits which
+// don't belong to any push event. Actors and repositories are listed once per event, so identical duplicates
+// are expected and not counted. Every CSV file has an entry, events are never rejected.
+func (a *Archive) RejectedRows() map[string]int {
+	rejected := map[string]int{
+		ActorsCSVFilename:  0,
+		ReposCSVFilename:   0,
+		EventsCSVFilename:  0,
+		CommitsCSVFilename: 0,
+	}
+
+	usernameByID := make(map[string]string, len(a.Actors))
+
+	for _, actor := range a.Actors {
+		if username, ok := usernameByID[actor.ID]; ok && username != actor.Username {
+			rejected[ActorsCSVFilename]++
+		}
+
+		usernameByID[actor.ID] = actor.Username
+	}
+
+	repoNameByID := make(map[string]string, len(a.Repos))
+
+	for _, r := range a.Repos {
+		if name, ok := repoNameByID[r.ID]; ok && name != r.Name {
+			rejected[ReposCSVFilename]++
+		}
+
+		repoNameByID[r.ID] = r.Name
+	}
+
+	pushEventIDs := make(map[string]bool)
+
+	for _, e := range a.Events {
+		if e.Type == PushEventType {
+			pushEventIDs[e.ID] = true
+		}
+	}
+
+	for _, c := range a.Commits {
+		if !pushEventIDs[c.EventID] {
+			rejected[CommitsCSVFilename]++
+		}
+	}
+
+	return rejected
+}
+
+// EventFilter selects events of an archive. An event is selected if it matches every non-empty criterion,
+// and it matches a criterion if it matches any of its values.
+type EventFilter struct {
+	// RepoNames are full names of repositories, like owner/name.
+	RepoNames []string
+	// Owners are owners of repositories, the part of repository name before slash.
+	Owners []string
+	// Usernames are logins of actors.
+	Usernames []string
+	// EventTypes are types of events, like PushEvent.
+	EventTypes []string
+	// Entities selects events of selected users in selected repositories, all of them if it is nil.
+	Entities *EntityFilter
+}
+
+// Filter returns a sub-archive with the events selected by the filter. The sub-archive is referentially consistent:
+// it has only actors and repositories referred to by the selected events and only commits pushed by them.
+// Actors and repositories are listed once, with their last occurrence in the archive.
+func (a *Archive) Filter(f EventFilter) *Archive {
+	repoNameByID := make(map[string]string, len(a.Repos))
+	for _, r := range a.Repos {
+		repoNameByID[r.ID] = r.Name
+	}
+
+	usernameByID := make(map[string]string, len(a.Actors))
+	for _, actor := range a.Actors {
+		usernameByID[actor.ID] = actor.Username
+	}
+
+	repoNames, owners, usernames, eventTypes := newSet(f.RepoNames), newSet(f.Owners), newSet(f.Usernames), newSet(f.EventTypes)
+
+	var sub Archive
+
+	actorIDs := make(map[string]bool)
+	repoIDs := make(map[string]bool)
+	eventIDs := make(map[string]bool)
+
+	for _, e := range a.Events {
+		repoName := repoNameByID[e.RepoID]
+
+		if !repoNames.matches(repoName) || !owners.matches(RepoOwner(repoName)) ||
+			!usernames.matches(usernameByID[e.ActorID]) || !eventTypes.matches(e.Type) ||
+			!f.Entities.MatchRepo(repoName) || !f.Entities.MatchUser(usernameByID[e.ActorID]) {
+			continue
+		}
+
+		sub.Events = append(sub.Events, e)
+		actorIDs[e.ActorID] = true
+		repoIDs[e.RepoID] = true
+		eventIDs[e.ID] = true
+	}
+
+	for _, actor := range uniqueActors(a.Actors) {
+		if actorIDs[actor.ID] {
+			sub.Actors = append(sub.Actors, actor)
+		}
+	}
+
+	for _, r := range uniqueRepos(a.Repos) {
+		if repoIDs[r.ID] {
+			sub.Repos = append(sub.Repos, r)
+		}
+	}
+
+	for _, c := range a.Commits {
+		if eventIDs[c.EventID] {
+			sub.Commits = append(sub.Commits, c)
+		}
+	}
+
+	return &sub
+}
+
+// RepoOwner returns owner of the repository, the part of its full name before slash.
+func RepoOwner(repoName string) string {
+	if i := strings.Index(repoName, "/"); i >= 0 {
+		return repoName[:i]
+	}
+
+	return repoName
+}
+
+// set is a set of strings, an empty set matches anything.
+type set map[string]bool
+
+func newSet(values []string) set {
+	s := make(set, len(values))
+	for _, v := range values {
+		s[v] = true
+	}
+
+	return s
+}
+
+func (s set) matches(v string) bool {
+	return len(s) == 0 || s[v]
+}
+
+func uniqueActors(actors []ActorCSV) []ActorCSV {
+	lastIdx := make(map[string]int, len(actors))
+	for i := range actors {
+		lastIdx[actors[i].ID] = i
+	}
+
+	unique := make([]ActorCSV, 0, len(lastIdx))
+
+	for i := range actors {
+		if lastIdx[actors[i].ID] == i {
+			unique = append(unique, actors[i])
+		}
+	}
+
+	return unique
+}
+
+func uniqueRepos(repoCSVs []RepoCSV) []RepoCSV {
+	lastIdx := make(map[string]int, len(repoCSVs))
+	for i := range repoCSVs {
+		lastIdx[repoCSVs[i].ID] = i
+	}
+
+	unique := make([]RepoCSV, 0, len(lastIdx))
+
+	for i := range repoCSVs {
+		if lastIdx[repoCSVs[i].ID] == i {
+			unique = append(unique, repoCSVs[i])
+		}
+	}
+
+	return unique
+}
@@ -0,0 +1,129 @@
+package github_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+func TestArchive_Filter(t *testing.T) {
+	archive := &github.Archive{
+		Actors: []github.ActorCSV{
+			{ID: "1", Username: "alice"},
+			{ID: "2", Username: "bob"},
+			{ID: "1", Username: "alice"},
+		},
+		Repos: []github.RepoCSV{
+			{ID: "10", Name: "golang/go"},
+			{ID: "11", Name: "golang/tools"},
+			{ID: "12", Name: "kubernetes/kubernetes"},
+		},
+		Events: []github.EventCSV{
+			{ID: "100", Type: github.PushEventType, ActorID: "1", RepoID: "10"},
+			{ID: "101", Type: github.WatchEventType, ActorID: "2", RepoID: "11"},
+			{ID: "102", Type: github.PushEventType, ActorID: "2", RepoID: "12"},
+		},
+		Commits: []github.CommitCSV{
+			{SHA: "a", Message: "msg", EventID: "100"},
+			{SHA: "b", Message: "msg", EventID: "102"},
+		},
+	}
+
+	entities, err := github.NewEntityFilter(github.EntityFilterOptions{Repos: []string{"golang/*"}, ExcludeUsers: []string{"alice"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter github.EventFilter
+		want   *github.Archive
+	}{
+		{
+			name:   "by owner",
+			filter: github.EventFilter{Owners: []string{"golang"}},
+			want: &github.Archive{
+				Actors:  []github.ActorCSV{{ID: "2", Username: "bob"}, {ID: "1", Username: "alice"}},
+				Repos:   []github.RepoCSV{{ID: "10", Name: "golang/go"}, {ID: "11", Name: "golang/tools"}},
+				Events:  archive.Events[:2],
+				Commits: archive.Commits[:1],
+			},
+		},
+		{
+			name:   "by repo, actor and event type",
+			filter: github.EventFilter{RepoNames: []string{"golang/tools", "kubernetes/kubernetes"}, Usernames: []string{"bob"}, EventTypes: []string{github.PushEventType}},
+			want: &github.Archive{
+				Actors:  []github.ActorCSV{{ID: "2", Username: "bob"}},
+				Repos:   []github.RepoCSV{{ID: "12", Name: "kubernetes/kubernetes"}},
+				Events:  archive.Events[2:],
+				Commits: archive.Commits[1:],
+			},
+		},
+		{
+			name:   "by entity patterns",
+			filter: github.EventFilter{Entities: entities},
+			want: &github.Archive{
+				Actors: []github.ActorCSV{{ID: "2", Username: "bob"}},
+				Repos:  []github.RepoCSV{{ID: "11", Name: "golang/tools"}},
+				Events: archive.Events[1:2],
+			},
+		},
+		{
+			name:   "nothing matches",
+			filter: github.EventFilter{Usernames: []string{"carol"}},
+			want:   &github.Archive{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := archive.Filter(tt.filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Filter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArchive_RejectedRows(t *testing.T) {
+	archive := &github.Archive{
+		Actors: []github.ActorCSV{
+			{ID: "1", Username: "alice"},
+			{ID: "1", Username: "alice"},
+			{ID: "2", Username: "bob"},
+			{ID: "2", Username: "bob-renamed"},
+		},
+		Repos: []github.RepoCSV{{ID: "10", Name: "golang/go"}},
+		Events: []github.EventCSV{
+			{ID: "100", Type: github.PushEventType, ActorID: "1", RepoID: "10"},
+			{ID: "101", Type: github.WatchEventType, ActorID: "2", RepoID: "10"},
+		},
+		Commits: []github.CommitCSV{
+			{SHA: "a", EventID: "100"},
+			{SHA: "b", EventID: "101"},
+			{SHA: "c", EventID: "999"},
+		},
+	}
+
+	want := map[string]int{
+		github.ActorsCSVFilename:  1,
+		github.ReposCSVFilename:   0,
+		github.EventsCSVFilename:  0,
+		github.CommitsCSVFilename: 2,
+	}
+
+	if got := archive.RejectedRows(); !reflect.DeepEqual(got, want) {
+		t.Errorf("RejectedRows() = %v, want %v", got, want)
+	}
+
+	want = map[string]int{
+		github.ActorsCSVFilename:  4,
+		github.ReposCSVFilename:   1,
+		github.EventsCSVFilename:  2,
+		github.CommitsCSVFilename: 3,
+	}
+
+	if got := archive.Rows(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Rows() = %v, want %v", got, want)
+	}
+}
@@ -6,13 +6,3 @@ type CommitCSV struct {
 	Message string `csv:"message"`
 	EventID string `csv:"event_id"`
 }
-
-func newNumCommitsByPushEventID(commits []CommitCSV) map[string]int {
-	numCommitsByPushEventID := make(map[string]int)
-
-	for _, c := range commits {
-		numCommitsByPushEventID[c.EventID]++
-	}
-
-	return numCommitsByPushEventID
-}
@@ -0,0 +1,257 @@
+package github
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/sketch"
+)
+
+// ContributorMetric is a metric of distinct actors of repositories.
+type ContributorMetric string
+
+// Contributor metrics. Contributors are actors who pushed commits or created pull requests.
+const (
+	PushersMetric            ContributorMetric = "pushers"
+	PullRequestAuthorsMetric ContributorMetric = "pr-authors"
+	WatchersMetric           ContributorMetric = "watchers"
+	ContributorsMetric       ContributorMetric = "contributors"
+)
+
+// ContributorMetrics lists all contributor metrics.
+var ContributorMetrics = []ContributorMetric{PushersMetric, PullRequestAuthorsMetric, WatchersMetric, ContributorsMetric}
+
+// RepoContributors represents GitHub repository with amounts of distinct actors
+type RepoContributors struct {
+	ID                 string
+	Name               string
+	Pushers            int
+	PullRequestAuthors int
+	Watchers           int
+	Contributors       int
+}
+
+// Count returns value of the metric.
+func (rc RepoContributors) Count(m ContributorMetric) int {
+	switch m {
+	case PushersMetric:
+		return rc.Pushers
+	case PullRequestAuthorsMetric:
+		return rc.PullRequestAuthors
+	case WatchersMetric:
+		return rc.Watchers
+	case ContributorsMetric:
+		return rc.Contributors
+	default:
+		return 0
+	}
+}
+
+// ContributorsSample is a collection of repositories with amounts of their distinct actors.
+type ContributorsSample struct {
+	M map[string]RepoContributors
+	// Approximate is set if amounts are estimated with HyperLogLog.
+	Approximate bool
+}
+
+// DefaultHLLPrecision is precision of HyperLogLog of about 1.6% error.
+const DefaultHLLPrecision uint8 = 12
+
+// distinctSetsByType are indices of sets an event of the type adds its actor to, in the order of ContributorMetrics.
+var distinctSetsByType = map[string][]int{
+	PushEventType:        {0, 3},
+	PullRequestEventType: {1, 3},
+	WatchEventType:       {2},
+}
+
+// ContributorsSample returns amounts of distinct pushers, PR authors, watchers and contributors of repositories.
+// Repositories which are not listed in the repos CSV are included only if they have any of those.
+// Aggregation stops with ctx.Err() as soon as ctx is done.
+func (d *Dataset) ContributorsSample(ctx context.Context) (*ContributorsSample, error) {
+	counts, err := d.countDistinctActors(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cs := ContributorsSample{M: make(map[string]RepoContributors, d.Repos.Listed)}
+
+	for i := range d.Repos.IDs {
+		rc := RepoContributors{
+			ID:                 d.Repos.IDs[i],
+			Name:               d.Repos.Names[i],
+			Pushers:            counts[0][i],
+			PullRequestAuthors: counts[1][i],
+			Watchers:           counts[2][i],
+			Contributors:       counts[3][i],
+		}
+
+		if i >= d.Repos.Listed && rc.Contributors == 0 && rc.Watchers == 0 {
+			continue
+		}
+
+		cs.M[rc.ID] = rc
+	}
+
+	return &cs, nil
+}
+
+// countDistinctActors counts distinct actors exactly by sorting repository and actor pairs.
+func (d *Dataset) countDistinctActors(ctx context.Context) ([4][]int, error) {
+	var (
+		counts [4][]int
+		pairs  [4][]uint64
+	)
+
+	et := &d.Events
+
+	setsByType := make([][]int, len(et.TypeNames))
+	for i, name := range et.TypeNames {
+		setsByType[i] = distinctSetsByType[name]
+	}
+
+	for i := 0; i < et.Len(); i++ {
+		if err := checkCtx(ctx, i); err != nil {
+			return counts, err
+		}
+
+		for _, set := range setsByType[et.Types[i]] {
+			pairs[set] = append(pairs[set], uint64(et.Repos[i])<<32|uint64(et.Actors[i]))
+		}
+	}
+
+	for set := range pairs {
+		if err := ctx.Err(); err != nil {
+			return counts, err
+		}
+
+		counts[set] = make([]int, len(d.Repos.IDs))
+		p := pairs[set]
+
+		sort.Slice(p, func(i, j int) bool { return p[i] < p[j] })
+
+		for i := range p {
+			if i == 0 || p[i] != p[i-1] {
+				counts[set][p[i]>>32]++
+			}
+		}
+	}
+
+	return counts, nil
+}
+
+// ContributorsSample estimates amounts of distinct pushers, PR authors, watchers and contributors of repositories
+// with HyperLogLog of the precision, relative error is about 1.04/sqrt(2^precision) and amounts up to
+// 2^precision/8 are exact. Memory used grows with amount of repositories, but not with amount of events or actors.
+// Repositories which are not listed in the repos CSV are included only if they have any of those.
+func (s *ArchiveStream) ContributorsSample(ctx context.Context, precision uint8) (*ContributorsSample, error) {
+	// fail early on wrong precision
+	if _, err := sketch.NewHyperLogLog(precision); err != nil {
+		return nil, errors.Wrap(ErrWrongParam, err.Error())
+	}
+
+	// sets of a repository are created on their first actor
+	hlls := make(map[string]*[4]*sketch.HyperLogLog)
+
+	err := s.eachEvent(ctx, false, func(e *EventCSV, _ int) {
+		sets := distinctSetsByType[e.Type]
+		if len(sets) == 0 {
+			return
+		}
+
+		repo, ok := hlls[e.RepoID]
+		if !ok {
+			repo = new([4]*sketch.HyperLogLog)
+			hlls[e.RepoID] = repo
+		}
+
+		h := sketch.Hash64(e.ActorID)
+
+		for _, set := range sets {
+			if repo[set] == nil {
+				repo[set], _ = sketch.NewHyperLogLog(precision)
+			}
+
+			repo[set].AddHash(h)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cs := ContributorsSample{M: make(map[string]RepoContributors, len(hlls)), Approximate: true}
+
+	for id, repo := range hlls {
+		var counts [4]int
+
+		for set, hll := range repo {
+			if hll != nil {
+				counts[set] = int(hll.Count())
+			}
+		}
+
+		cs.M[id] = RepoContributors{
+			ID:                 id,
+			Pushers:            counts[0],
+			PullRequestAuthors: counts[1],
+			Watchers:           counts[2],
+			Contributors:       counts[3],
+		}
+	}
+
+	err = s.eachNamed(ctx, ReposCSVFilename, func(id, name string) {
+		rc := cs.M[id]
+		rc.ID, rc.Name = id, name
+		cs.M[id] = rc
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &cs, nil
+}
+
+// TopNBy returns top N repositories sorted by the metric, ties are sorted by name and ID.
+func (cs *ContributorsSample) TopNBy(n int, m ContributorMetric) ([]RepoContributors, error) {
+	if n < 1 {
+		return nil, errors.Wrap(ErrWrongParam, "n should be at least 1")
+	}
+
+	if !isContributorMetric(m) {
+		return nil, errors.Wrapf(ErrWrongParam, "unknown metric %q", m)
+	}
+
+	repos := make([]RepoContributors, 0, len(cs.M))
+	for _, rc := range cs.M {
+		repos = append(repos, rc)
+	}
+
+	sort.Slice(repos, func(i, j int) bool {
+		if ci, cj := repos[i].Count(m), repos[j].Count(m); ci != cj {
+			return ci > cj
+		}
+
+		if repos[i].Name != repos[j].Name {
+			return repos[i].Name < repos[j].Name
+		}
+
+		return repos[i].ID < repos[j].ID
+	})
+
+	if len(repos) > n {
+		repos = repos[:n]
+	}
+
+	return repos, nil
+}
+
+func isContributorMetric(m ContributorMetric) bool {
+	for _, metric := range ContributorMetrics {
+		if m == metric {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,118 @@
+package github_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/samples"
+)
+
+func TestDataset_ContributorsSample(t *testing.T) {
+	repoCSVs := []github.RepoCSV{
+		{ID: "1", Name: "org/one"},
+		{ID: "2", Name: "org/two"},
+		{ID: "3", Name: "org/three"},
+	}
+	events := []github.EventCSV{
+		// one pusher pushes a lot
+		{ID: "1", Type: github.PushEventType, ActorID: "1", RepoID: "1"},
+		{ID: "2", Type: github.PushEventType, ActorID: "1", RepoID: "1"},
+		{ID: "3", Type: github.PushEventType, ActorID: "1", RepoID: "1"},
+		// pusher and PR authors of the second repo overlap
+		{ID: "4", Type: github.PushEventType, ActorID: "1", RepoID: "2"},
+		{ID: "5", Type: github.PushEventType, ActorID: "2", RepoID: "2"},
+		{ID: "6", Type: github.PullRequestEventType, ActorID: "2", RepoID: "2"},
+		{ID: "7", Type: github.PullRequestEventType, ActorID: "3", RepoID: "2"},
+		{ID: "8", Type: github.WatchEventType, ActorID: "4", RepoID: "2"},
+		{ID: "9", Type: github.WatchEventType, ActorID: "4", RepoID: "2"},
+		{ID: "10", Type: github.WatchEventType, ActorID: "1", RepoID: "2"},
+		// unlisted repository without contributors or watchers
+		{ID: "11", Type: "ForkEvent", ActorID: "1", RepoID: "4"},
+	}
+	want := map[string]github.RepoContributors{
+		"1": {ID: "1", Name: "org/one", Pushers: 1, Contributors: 1},
+		"2": {ID: "2", Name: "org/two", Pushers: 2, PullRequestAuthors: 2, Watchers: 2, Contributors: 3},
+		"3": {ID: "3", Name: "org/three"},
+	}
+
+	exact, err := github.NewDataset(nil, repoCSVs, events, nil).ContributorsSample(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	estimated, err := rowsStream(nil, repoCSVs, events, nil).ContributorsSample(context.Background(), github.DefaultHLLPrecision)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// small sets are counted exactly by HyperLogLog too
+	for _, got := range []*github.ContributorsSample{exact, estimated} {
+		if !reflect.DeepEqual(got.M, want) {
+			t.Errorf("ContributorsSample(approximate: %v) = %+v, want %v", got.Approximate, got, want)
+		}
+
+		top, err := got.TopNBy(2, github.ContributorsMetric)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if wantTop := []github.RepoContributors{want["2"], want["1"]}; !reflect.DeepEqual(top, wantTop) {
+			t.Errorf("TopNBy() = %v, want %v", top, wantTop)
+		}
+	}
+
+	if exact.Approximate || !estimated.Approximate {
+		t.Errorf("Approximate = %v and %v, want false and true", exact.Approximate, estimated.Approximate)
+	}
+}
+
+// TestArchiveStream_ContributorsSample compares estimated amounts with exact ones on the sample archive.
+func TestArchiveStream_ContributorsSample(t *testing.T) {
+	ctx := context.Background()
+
+	archive, err := samples.Archive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exact, err := archive.Dataset().ContributorsSample(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	estimated, err := sampleStream(t).ContributorsSample(ctx, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(estimated.M) != len(exact.M) {
+		t.Fatalf("ContributorsSample() has %d repos, want %d", len(estimated.M), len(exact.M))
+	}
+
+	for id, want := range exact.M {
+		got := estimated.M[id]
+
+		for _, m := range github.ContributorMetrics {
+			// relative error of precision 4 is 26%, three of them are allowed
+			if diff := float64(got.Count(m)-want.Count(m)) / float64(want.Count(m)); want.Count(m) > 0 && (diff > 0.78 || diff < -0.78) {
+				t.Errorf("ContributorsSample() %s of %s = %d, want about %d", m, id, got.Count(m), want.Count(m))
+			}
+		}
+	}
+}
+
+func TestContributorsSample_TopNBy_WrongParam(t *testing.T) {
+	cs := &github.ContributorsSample{}
+
+	if _, err := cs.TopNBy(0, github.PushersMetric); !errors.Is(err, github.ErrWrongParam) {
+		t.Errorf("TopNBy() error = %v, want %v", err, github.ErrWrongParam)
+	}
+
+	if _, err := cs.TopNBy(1, "stars"); !errors.Is(err, github.ErrWrongParam) {
+		t.Errorf("TopNBy() error = %v, want %v", err, github.ErrWrongParam)
+	}
+}
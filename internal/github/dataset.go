@@ -0,0 +1,314 @@
+package github
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// ctxCheckInterval is amount of rows processed between checks of context cancellation.
+const ctxCheckInterval = 1 << 14
+
+// Dataset represents GitHub data decoded from an archive and ready to be aggregated.
+// String IDs are interned into dense integer indices at load time and rows are stored as struct-of-arrays,
+// so aggregations index slices instead of hashing strings. Commits are kept only as amount of commits pushed
+// by each push event, since analytics doesn't need anything else.
+type Dataset struct {
+	Actors ActorTable
+	Repos  RepoTable
+	Events EventTable
+}
+
+// ActorTable holds interned actors. Actor with index i has ID IDs[i] and username Usernames[i].
+// The first Listed actors come from the actors CSV, the rest are only referenced by events and have no username.
+type ActorTable struct {
+	IDs       []string
+	Usernames []string
+	Listed    int
+}
+
+// RepoTable holds interned repositories. Repository with index i has ID IDs[i] and name Names[i].
+// The first Listed repositories come from the repos CSV, the rest are only referenced by events and have no name.
+type RepoTable struct {
+	IDs    []string
+	Names  []string
+	Listed int
+}
+
+// EventTable holds events as columns. Event i has type TypeNames[Types[i]], was made by actor Actors[i] in
+// repository Repos[i] and pushed Commits[i] commits.
+type EventTable struct {
+	TypeNames []string
+	Types     []uint16
+	Actors    []uint32
+	Repos     []uint32
+	Commits   []uint32
+}
+
+// Len returns amount of events.
+func (et *EventTable) Len() int {
+	return len(et.Types)
+}
+
+// TypeIndex returns index of the event type in TypeNames or -1 if there are no events of that type.
+func (et *EventTable) TypeIndex(eventType string) int {
+	for i, name := range et.TypeNames {
+		if name == eventType {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// NewDataset returns a new Dataset.
+// Actors and repositories are listed once per event in the archive, so duplicates are dropped.
+// The last occurrence of each ID wins, the same way as in NewUsersSample and NewReposSample.
+func NewDataset(actors []ActorCSV, repoCSVs []RepoCSV, events []EventCSV, commits []CommitCSV) *Dataset {
+	ds := Dataset{}
+
+	actorIdxByID := make(map[string]uint32)
+
+	for i := range actors {
+		if idx, ok := actorIdxByID[actors[i].ID]; ok {
+			ds.Actors.Usernames[idx] = actors[i].Username
+
+			continue
+		}
+
+		actorIdxByID[actors[i].ID] = uint32(len(ds.Actors.IDs))
+		ds.Actors.IDs = append(ds.Actors.IDs, actors[i].ID)
+		ds.Actors.Usernames = append(ds.Actors.Usernames, actors[i].Username)
+	}
+
+	ds.Actors.Listed = len(ds.Actors.IDs)
+
+	repoIdxByID := make(map[string]uint32)
+
+	for i := range repoCSVs {
+		if idx, ok := repoIdxByID[repoCSVs[i].ID]; ok {
+			ds.Repos.Names[idx] = repoCSVs[i].Name
+
+			continue
+		}
+
+		repoIdxByID[repoCSVs[i].ID] = uint32(len(ds.Repos.IDs))
+		ds.Repos.IDs = append(ds.Repos.IDs, repoCSVs[i].ID)
+		ds.Repos.Names = append(ds.Repos.Names, repoCSVs[i].Name)
+	}
+
+	ds.Repos.Listed = len(ds.Repos.IDs)
+
+	et := &ds.Events
+	et.Types = make([]uint16, len(events))
+	et.Actors = make([]uint32, len(events))
+	et.Repos = make([]uint32, len(events))
+	et.Commits = make([]uint32, len(events))
+
+	typeIdxByName := make(map[string]uint16)
+	pushEventIdxByID := make(map[string]int)
+	// push events which share ID with an earlier push event, they get the same commits as the earlier one
+	var duplicatePushEvents [][2]int
+
+	for i := range events {
+		e := &events[i]
+
+		typeIdx, ok := typeIdxByName[e.Type]
+		if !ok {
+			typeIdx = uint16(len(et.TypeNames))
+			typeIdxByName[e.Type] = typeIdx
+			et.TypeNames = append(et.TypeNames, e.Type)
+		}
+
+		et.Types[i] = typeIdx
+		et.Actors[i] = ds.Actors.intern(actorIdxByID, e.ActorID)
+		et.Repos[i] = ds.Repos.intern(repoIdxByID, e.RepoID)
+
+		if e.Type != PushEventType {
+			continue
+		}
+
+		if firstIdx, ok := pushEventIdxByID[e.ID]; ok {
+			duplicatePushEvents = append(duplicatePushEvents, [2]int{i, firstIdx})
+		} else {
+			pushEventIdxByID[e.ID] = i
+		}
+	}
+
+	for i := range commits {
+		if eventIdx, ok := pushEventIdxByID[commits[i].EventID]; ok {
+			et.Commits[eventIdx]++
+		}
+	}
+
+	for _, dup := range duplicatePushEvents {
+		et.Commits[dup[0]] = et.Commits[dup[1]]
+	}
+
+	return &ds
+}
+
+func (at *ActorTable) intern(idxByID map[string]uint32, id string) uint32 {
+	idx, ok := idxByID[id]
+	if !ok {
+		idx = uint32(len(at.IDs))
+		idxByID[id] = idx
+		at.IDs = append(at.IDs, id)
+		at.Usernames = append(at.Usernames, "")
+	}
+
+	return idx
+}
+
+func (rt *RepoTable) intern(idxByID map[string]uint32, id string) uint32 {
+	idx, ok := idxByID[id]
+	if !ok {
+		idx = uint32(len(rt.IDs))
+		idxByID[id] = idx
+		rt.IDs = append(rt.IDs, id)
+		rt.Names = append(rt.Names, "")
+	}
+
+	return idx
+}
+
+// Validate checks that all columns have matching lengths and all indices point into their tables.
+// It should be used for datasets that were not built by NewDataset, e.g. read from disk.
+func (d *Dataset) Validate() error {
+	if len(d.Actors.Usernames) != len(d.Actors.IDs) || d.Actors.Listed < 0 || d.Actors.Listed > len(d.Actors.IDs) {
+		return errors.Wrap(ErrWrongParam, "malformed actors table")
+	}
+
+	if len(d.Repos.Names) != len(d.Repos.IDs) || d.Repos.Listed < 0 || d.Repos.Listed > len(d.Repos.IDs) {
+		return errors.Wrap(ErrWrongParam, "malformed repos table")
+	}
+
+	et := &d.Events
+	if len(et.Actors) != et.Len() || len(et.Repos) != et.Len() || len(et.Commits) != et.Len() {
+		return errors.Wrap(ErrWrongParam, "malformed events table")
+	}
+
+	for i := 0; i < et.Len(); i++ {
+		if int(et.Types[i]) >= len(et.TypeNames) || int(et.Actors[i]) >= len(d.Actors.IDs) || int(et.Repos[i]) >= len(d.Repos.IDs) {
+			return errors.Wrapf(ErrWrongParam, "event %d refers to unknown type, actor or repo", i)
+		}
+	}
+
+	return nil
+}
+
+// UsersSample returns UsersSample built from the dataset.
+// Bots with `botname[bot]` are not humans and could be filtered out.
+// Aggregation stops with ctx.Err() as soon as ctx is done.
+func (d *Dataset) UsersSample(ctx context.Context, botsIncluded bool) (*UsersSample, error) {
+	activities := make([]ActorActivity, len(d.Actors.IDs))
+
+	et := &d.Events
+	pushType, prType := et.TypeIndex(PushEventType), et.TypeIndex(PullRequestEventType)
+
+	for i := 0; i < et.Len(); i++ {
+		if err := checkCtx(ctx, i); err != nil {
+			return nil, err
+		}
+
+		switch int(et.Types[i]) {
+		case pushType:
+			activities[et.Actors[i]].PushedCommits += int(et.Commits[i])
+		case prType:
+			activities[et.Actors[i]].CreatedPullRequests++
+		}
+	}
+
+	users := UsersSample{
+		M: make(map[string]User, d.Actors.Listed),
+	}
+
+	for i := 0; i < d.Actors.Listed; i++ {
+		// if username like dependabot[bot] skip
+		if !botsIncluded && IsBotUsername(d.Actors.Usernames[i]) {
+			continue
+		}
+
+		users.M[d.Actors.IDs[i]] = User{
+			ID:       d.Actors.IDs[i],
+			Username: d.Actors.Usernames[i],
+			Activity: activities[i],
+		}
+	}
+
+	return &users, nil
+}
+
+// ReposSample returns ReposSample built from the dataset.
+// Repositories which are not listed in the repos CSV are included only if they have commits or watch events.
+// Aggregation stops with ctx.Err() as soon as ctx is done.
+func (d *Dataset) ReposSample(ctx context.Context) (*ReposSample, error) {
+	commitsPushed := make([]int, len(d.Repos.IDs))
+	watchEvents := make([]int, len(d.Repos.IDs))
+
+	et := &d.Events
+	pushType, watchType := et.TypeIndex(PushEventType), et.TypeIndex(WatchEventType)
+
+	for i := 0; i < et.Len(); i++ {
+		if err := checkCtx(ctx, i); err != nil {
+			return nil, err
+		}
+
+		switch int(et.Types[i]) {
+		case pushType:
+			commitsPushed[et.Repos[i]] += int(et.Commits[i])
+		case watchType:
+			watchEvents[et.Repos[i]]++
+		}
+	}
+
+	repos := ReposSample{
+		M: make(map[string]Repo, d.Repos.Listed),
+	}
+
+	for i := range d.Repos.IDs {
+		if i >= d.Repos.Listed && commitsPushed[i] == 0 && watchEvents[i] == 0 {
+			continue
+		}
+
+		repos.M[d.Repos.IDs[i]] = Repo{
+			ID:            d.Repos.IDs[i],
+			Name:          d.Repos.Names[i],
+			CommitsPushed: commitsPushed[i],
+			WatchEvents:   watchEvents[i],
+		}
+	}
+
+	return &repos, nil
+}
+
+// EventsByType returns amount of events of each type.
+// Aggregation stops with ctx.Err() as soon as ctx is done.
+func (d *Dataset) EventsByType(ctx context.Context) (map[string]int, error) {
+	et := &d.Events
+	counts := make([]int, len(et.TypeNames))
+
+	for i := 0; i < et.Len(); i++ {
+		if err := checkCtx(ctx, i); err != nil {
+			return nil, err
+		}
+
+		counts[et.Types[i]]++
+	}
+
+	byType := make(map[string]int, len(counts))
+	for i, name := range et.TypeNames {
+		byType[name] = counts[i]
+	}
+
+	return byType, nil
+}
+
+// checkCtx returns ctx.Err() every ctxCheckInterval rows, checking it on every row would be too slow.
+func checkCtx(ctx context.Context, row int) error {
+	if row%ctxCheckInterval != 0 {
+		return nil
+	}
+
+	return ctx.Err()
+}
@@ -0,0 +1,247 @@
+package github_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/generator"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/samples"
+)
+
+func TestNewDataset(t *testing.T) {
+	actors := []github.ActorCSV{
+		{ID: "1", Username: "old"},
+		{ID: "2", Username: "2"},
+		{ID: "1", Username: "new"},
+	}
+	repoCSVs := []github.RepoCSV{
+		{ID: "1", Name: "1"},
+		{ID: "1", Name: "1"},
+	}
+	events := []github.EventCSV{
+		{ID: "1", Type: github.PushEventType, ActorID: "1", RepoID: "1"},
+		{ID: "2", Type: github.PullRequestEventType, ActorID: "2", RepoID: "1"},
+		{ID: "3", Type: github.WatchEventType, ActorID: "2", RepoID: "1"},
+	}
+	commits := []github.CommitCSV{
+		{SHA: "1", Message: "msg", EventID: "1"},
+		{SHA: "2", Message: "msg", EventID: "1"},
+	}
+
+	ds := github.NewDataset(actors, repoCSVs, events, commits)
+
+	wantActors := github.ActorTable{IDs: []string{"1", "2"}, Usernames: []string{"new", "2"}, Listed: 2}
+	if !reflect.DeepEqual(ds.Actors, wantActors) {
+		t.Errorf("NewDataset() actors = %v, want %v", ds.Actors, wantActors)
+	}
+
+	wantEvents := github.EventTable{
+		TypeNames: []string{github.PushEventType, github.PullRequestEventType, github.WatchEventType},
+		Types:     []uint16{0, 1, 2},
+		Actors:    []uint32{0, 1, 1},
+		Repos:     []uint32{0, 0, 0},
+		Commits:   []uint32{2, 0, 0},
+	}
+	if !reflect.DeepEqual(ds.Events, wantEvents) {
+		t.Errorf("NewDataset() events = %v, want %v", ds.Events, wantEvents)
+	}
+
+	if err := ds.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	want := &github.UsersSample{M: map[string]github.User{
+		"1": {ID: "1", Username: "new", Activity: github.ActorActivity{PushedCommits: 2}},
+		"2": {ID: "2", Username: "2", Activity: github.ActorActivity{CreatedPullRequests: 1}},
+	}}
+	got, err := ds.UsersSample(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UsersSample() = %v, want %v", got, want)
+	}
+}
+
+func TestDataset_ReposSample(t *testing.T) {
+	events := []github.EventCSV{
+		{ID: "1", Type: github.PushEventType, ActorID: "1", RepoID: "1"},
+		{ID: "2", Type: github.WatchEventType, ActorID: "1", RepoID: "2"},
+		{ID: "3", Type: github.PushEventType, ActorID: "1", RepoID: "3"},
+		{ID: "4", Type: "other", ActorID: "1", RepoID: "4"},
+	}
+	commits := []github.CommitCSV{
+		{SHA: "1", Message: "msg", EventID: "1"},
+	}
+
+	// repositories missing in repos CSV are reported only if they have any activity
+	want := &github.ReposSample{M: map[string]github.Repo{
+		"1": {ID: "1", Name: "1", CommitsPushed: 1},
+		"2": {ID: "2", WatchEvents: 1},
+	}}
+
+	got, err := github.NewDataset(nil, []github.RepoCSV{{ID: "1", Name: "1"}}, events, commits).ReposSample(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReposSample() = %v, want %v", got, want)
+	}
+}
+
+// TestDataset_SamplesOfRows checks that samples built from CSV rows are the samples of their dataset.
+func TestDataset_SamplesOfRows(t *testing.T) {
+	archive, err := samples.Archive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actors, repoCSVs, events, commits := archive.Actors, archive.Repos, archive.Events, archive.Commits
+	ds := github.NewDataset(actors, repoCSVs, events, commits)
+
+	for _, bots := range []bool{false, true} {
+		want, err := ds.UsersSample(context.Background(), bots)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := github.NewUsersSample(actors, commits, events, bots); !reflect.DeepEqual(got, want) {
+			t.Errorf("NewUsersSample(bots: %v) differs from UsersSample() of the dataset", bots)
+		}
+	}
+
+	want, err := ds.ReposSample(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := github.NewReposSample(events, repoCSVs, commits); !reflect.DeepEqual(got, want) {
+		t.Error("NewReposSample() differs from ReposSample() of the dataset")
+	}
+}
+
+func TestDataset_EventsByType(t *testing.T) {
+	events := []github.EventCSV{
+		{ID: "1", Type: github.PushEventType, ActorID: "1", RepoID: "1"},
+		{ID: "2", Type: github.WatchEventType, ActorID: "1", RepoID: "2"},
+		{ID: "3", Type: github.PushEventType, ActorID: "2", RepoID: "1"},
+	}
+	want := map[string]int{github.PushEventType: 2, github.WatchEventType: 1}
+
+	got, err := github.NewDataset(nil, nil, events, nil).EventsByType(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("EventsByType() = %v, want %v", got, want)
+	}
+}
+
+func TestDataset_Canceled(t *testing.T) {
+	ds := github.NewDataset(
+		[]github.ActorCSV{{ID: "1", Username: "1"}},
+		[]github.RepoCSV{{ID: "1", Name: "1"}},
+		[]github.EventCSV{{ID: "1", Type: github.PushEventType, ActorID: "1", RepoID: "1"}},
+		nil,
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := ds.UsersSample(ctx, true); !errors.Is(err, context.Canceled) {
+		t.Errorf("UsersSample() error = %v, want context.Canceled", err)
+	}
+
+	if _, err := ds.ReposSample(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("ReposSample() error = %v, want context.Canceled", err)
+	}
+}
+
+func BenchmarkNewDataset(b *testing.B) {
+	archive, err := samples.Archive(context.Background())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	actors, repoCSVs, events, commits := archive.Actors, archive.Repos, archive.Events, archive.Commits
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_ = github.NewDataset(actors, repoCSVs, events, commits)
+	}
+}
+
+func BenchmarkDataset_UsersSample(b *testing.B) {
+	archive, err := samples.Archive(context.Background())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	ds := archive.Dataset()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		users, err := ds.UsersSample(context.Background(), false)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if _, err := users.TopNActiveUsers(10); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDataset_ReposSample(b *testing.B) {
+	archive, err := samples.Archive(context.Background())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	ds := archive.Dataset()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		repos, err := ds.ReposSample(context.Background())
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if _, err := repos.TopNByCommitsPushed(10); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// sampleEvents is amount of events in the sample archive.
+const sampleEvents = 32720
+
+// generateArchive generates an archive scale times bigger than the sample one.
+// Big archives take a while to generate, so benchmarks using them are skipped in short mode.
+func generateArchive(b *testing.B, scale int) *github.Archive {
+	b.Helper()
+
+	if testing.Short() {
+		b.Skip("skipping benchmark of a generated archive in short mode")
+	}
+
+	a, err := generator.Generate(context.Background(), generator.DefaultConfig(sampleEvents*scale))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return a
+}
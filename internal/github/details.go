@@ -0,0 +1,115 @@
+package github
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotFound is returned if there is no such user or repository.
+var ErrNotFound = errors.New("not found")
+
+// Details describe activity of a user or in a repository.
+type Details struct {
+	ID   string
+	Name string
+	// EventsByType is amount of events by event type.
+	EventsByType map[string]int
+	// CommitsPushed is amount of commits pushed.
+	CommitsPushed int
+	// Counterparts are repositories the user made events in, or users who made events in the repository,
+	// sorted by amount of events descending and then by name.
+	Counterparts []Counterpart
+	// Health is concentration of contributions to the repository, it is nil for a user.
+	Health *RepoHealth
+}
+
+// Counterpart is a repository a user made events in, or a user who made events in a repository.
+type Counterpart struct {
+	ID            string
+	Name          string
+	Events        int
+	CommitsPushed int
+}
+
+// UserDetails returns details of activity of the listed user with the username.
+// Aggregation stops with ctx.Err() as soon as ctx is done.
+func (d *Dataset) UserDetails(ctx context.Context, username string) (*Details, error) {
+	for i := 0; i < d.Actors.Listed; i++ {
+		if d.Actors.Usernames[i] == username {
+			return d.details(ctx, d.Actors.IDs[i], username, d.Events.Actors, uint32(i), d.Events.Repos, d.Repos.IDs, d.Repos.Names)
+		}
+	}
+
+	return nil, errors.Wrapf(ErrNotFound, "user %q", username)
+}
+
+// RepoDetails returns details of activity in the listed repository with the name, including its health.
+// Aggregation stops with ctx.Err() as soon as ctx is done.
+func (d *Dataset) RepoDetails(ctx context.Context, name string) (*Details, error) {
+	for i := 0; i < d.Repos.Listed; i++ {
+		if d.Repos.Names[i] != name {
+			continue
+		}
+
+		details, err := d.details(ctx, d.Repos.IDs[i], name, d.Events.Repos, uint32(i), d.Events.Actors, d.Actors.IDs, d.Actors.Usernames)
+		if err != nil {
+			return nil, err
+		}
+
+		if details.Health, err = d.repoHealth(ctx, uint32(i)); err != nil {
+			return nil, err
+		}
+
+		return details, nil
+	}
+
+	return nil, errors.Wrapf(ErrNotFound, "repository %q", name)
+}
+
+// details aggregates events whose column value equals idx, counterparts are found in the other column.
+func (d *Dataset) details(
+	ctx context.Context, id, name string, column []uint32, idx uint32, otherColumn []uint32, otherIDs, otherNames []string,
+) (*Details, error) {
+	details := Details{ID: id, Name: name, EventsByType: make(map[string]int)}
+	counterpartIdxByOther := make(map[uint32]int)
+
+	et := &d.Events
+
+	for i := 0; i < et.Len(); i++ {
+		if err := checkCtx(ctx, i); err != nil {
+			return nil, err
+		}
+
+		if column[i] != idx {
+			continue
+		}
+
+		details.EventsByType[et.TypeNames[et.Types[i]]]++
+		details.CommitsPushed += int(et.Commits[i])
+
+		other := otherColumn[i]
+
+		j, ok := counterpartIdxByOther[other]
+		if !ok {
+			j = len(details.Counterparts)
+			counterpartIdxByOther[other] = j
+			details.Counterparts = append(details.Counterparts, Counterpart{ID: otherIDs[other], Name: otherNames[other]})
+		}
+
+		details.Counterparts[j].Events++
+		details.Counterparts[j].CommitsPushed += int(et.Commits[i])
+	}
+
+	sort.Slice(details.Counterparts, func(i, j int) bool {
+		ci, cj := details.Counterparts[i], details.Counterparts[j]
+		if ci.Events != cj.Events {
+			return ci.Events > cj.Events
+		}
+
+		return ci.Name < cj.Name
+	})
+
+	return &details, nil
+}
@@ -0,0 +1,82 @@
+package github_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+func TestDataset_Details(t *testing.T) {
+	actors := []github.ActorCSV{{ID: "1", Username: "alice"}, {ID: "2", Username: "bob"}}
+	repoCSVs := []github.RepoCSV{{ID: "1", Name: "org/one"}, {ID: "2", Name: "org/two"}}
+	events := []github.EventCSV{
+		{ID: "1", Type: github.PushEventType, ActorID: "1", RepoID: "1"},
+		{ID: "2", Type: github.PullRequestEventType, ActorID: "1", RepoID: "2"},
+		{ID: "3", Type: github.PullRequestEventType, ActorID: "1", RepoID: "2"},
+		{ID: "4", Type: github.WatchEventType, ActorID: "2", RepoID: "1"},
+	}
+	commits := []github.CommitCSV{{SHA: "1", EventID: "1"}, {SHA: "2", EventID: "1"}}
+
+	ds := github.NewDataset(actors, repoCSVs, events, commits)
+	ctx := context.Background()
+
+	user, err := ds.UserDetails(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantUser := &github.Details{
+		ID:            "1",
+		Name:          "alice",
+		EventsByType:  map[string]int{github.PushEventType: 1, github.PullRequestEventType: 2},
+		CommitsPushed: 2,
+		Counterparts: []github.Counterpart{
+			{ID: "2", Name: "org/two", Events: 2},
+			{ID: "1", Name: "org/one", Events: 1, CommitsPushed: 2},
+		},
+	}
+	if !reflect.DeepEqual(user, wantUser) {
+		t.Errorf("UserDetails() = %+v, want %+v", user, wantUser)
+	}
+
+	repo, err := ds.RepoDetails(ctx, "org/one")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantRepo := &github.Details{
+		ID:            "1",
+		Name:          "org/one",
+		EventsByType:  map[string]int{github.PushEventType: 1, github.WatchEventType: 1},
+		CommitsPushed: 2,
+		Counterparts: []github.Counterpart{
+			{ID: "1", Name: "alice", Events: 1, CommitsPushed: 2},
+			{ID: "2", Name: "bob", Events: 1},
+		},
+		Health: &github.RepoHealth{
+			ID:                  "1",
+			Name:                "org/one",
+			Contributions:       2,
+			Breakdown:           []github.Contribution{{ID: "1", Username: "alice", CommitsPushed: 2}},
+			TopContributorShare: 1,
+			BusFactor:           1,
+			BusFactor80:         1,
+			Herfindahl:          1,
+		},
+	}
+	if !reflect.DeepEqual(repo, wantRepo) {
+		t.Errorf("RepoDetails() = %+v, want %+v", repo, wantRepo)
+	}
+
+	if _, err := ds.UserDetails(ctx, "carol"); !errors.Is(err, github.ErrNotFound) {
+		t.Errorf("UserDetails() error = %v, want %v", err, github.ErrNotFound)
+	}
+
+	if _, err := ds.RepoDetails(ctx, "org/three"); !errors.Is(err, github.ErrNotFound) {
+		t.Errorf("RepoDetails() error = %v, want %v", err, github.ErrNotFound)
+	}
+}
@@ -0,0 +1,328 @@
+package github
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// EntityFilterOptions are patterns of repositories, owners and users to include or exclude.
+// A pattern is a glob like kubernetes/* (see path.Match), a regular expression between slashes like /^kube-.*$/,
+// or @path to a file with one pattern per line, where empty lines and lines starting with # are skipped.
+type EntityFilterOptions struct {
+	// Repos are patterns of full names of repositories, like owner/name.
+	Repos []string
+	// Owners are patterns of owners of repositories, the part of repository name before slash.
+	Owners []string
+	// Users are patterns of usernames.
+	Users []string
+
+	ExcludeRepos  []string
+	ExcludeOwners []string
+	ExcludeUsers  []string
+}
+
+// EntityFilter selects users and repositories. A repository is selected if it matches any pattern of every
+// non-empty include list and no exclude pattern, the same goes for users. A nil filter selects everything.
+type EntityFilter struct {
+	repos  patterns
+	owners patterns
+	users  patterns
+
+	excludeRepos  patterns
+	excludeOwners patterns
+	excludeUsers  patterns
+}
+
+// NewEntityFilter returns a filter with the patterns of the options. ErrWrongParam is returned for bad patterns.
+func NewEntityFilter(opts EntityFilterOptions) (*EntityFilter, error) {
+	var f EntityFilter
+
+	for _, p := range []struct {
+		dst   *patterns
+		specs []string
+	}{
+		{dst: &f.repos, specs: opts.Repos},
+		{dst: &f.owners, specs: opts.Owners},
+		{dst: &f.users, specs: opts.Users},
+		{dst: &f.excludeRepos, specs: opts.ExcludeRepos},
+		{dst: &f.excludeOwners, specs: opts.ExcludeOwners},
+		{dst: &f.excludeUsers, specs: opts.ExcludeUsers},
+	} {
+		ps, err := parsePatterns(p.specs)
+		if err != nil {
+			return nil, err
+		}
+
+		*p.dst = ps
+	}
+
+	return &f, nil
+}
+
+// Empty reports whether the filter selects everything.
+func (f *EntityFilter) Empty() bool {
+	return f == nil || (len(f.repos) == 0 && len(f.owners) == 0 && len(f.users) == 0 &&
+		len(f.excludeRepos) == 0 && len(f.excludeOwners) == 0 && len(f.excludeUsers) == 0)
+}
+
+// SelectsUsers reports whether the filter has patterns of users.
+func (f *EntityFilter) SelectsUsers() bool {
+	return f != nil && (len(f.users) > 0 || len(f.excludeUsers) > 0)
+}
+
+// SelectsRepos reports whether the filter has patterns of repositories or owners.
+func (f *EntityFilter) SelectsRepos() bool {
+	return f != nil && (len(f.repos) > 0 || len(f.owners) > 0 || len(f.excludeRepos) > 0 || len(f.excludeOwners) > 0)
+}
+
+// Fingerprint identifies the patterns of the filter, patterns of files included, so results of different
+// filters are told apart. It is empty for an empty filter.
+func (f *EntityFilter) Fingerprint() string {
+	if f.Empty() {
+		return ""
+	}
+
+	h := sha256.New()
+
+	for i, ps := range []patterns{f.repos, f.owners, f.users, f.excludeRepos, f.excludeOwners, f.excludeUsers} {
+		for _, p := range ps {
+			if p.re != nil {
+				fmt.Fprintf(h, "%d re %s\n", i, p.re)
+			} else {
+				fmt.Fprintf(h, "%d glob %s\n", i, p.glob)
+			}
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// MatchRepo reports whether the repository with the full name is selected.
+func (f *EntityFilter) MatchRepo(name string) bool {
+	if f == nil {
+		return true
+	}
+
+	owner := RepoOwner(name)
+
+	return f.repos.include(name) && f.owners.include(owner) && !f.excludeRepos.any(name) && !f.excludeOwners.any(owner)
+}
+
+// MatchUser reports whether the user with the username is selected.
+func (f *EntityFilter) MatchUser(username string) bool {
+	if f == nil {
+		return true
+	}
+
+	return f.users.include(username) && !f.excludeUsers.any(username)
+}
+
+// Filter returns a dataset with events of selected users in selected repositories, and only the actors
+// and repositories they refer to, the same as the dataset of the archive filtered by EventFilter.Entities.
+// Actors and repositories which are not listed have no names, so include patterns never select them.
+// The dataset itself is returned if the filter is empty.
+func (d *Dataset) Filter(f *EntityFilter) *Dataset {
+	if f.Empty() {
+		return d
+	}
+
+	selectedActors := make([]bool, len(d.Actors.IDs))
+	for i, username := range d.Actors.Usernames {
+		selectedActors[i] = f.MatchUser(username)
+	}
+
+	selectedRepos := make([]bool, len(d.Repos.IDs))
+	for i, name := range d.Repos.Names {
+		selectedRepos[i] = f.MatchRepo(name)
+	}
+
+	et := &d.Events
+	events := make([]int, 0, et.Len())
+	actorIdx := make([]uint32, len(d.Actors.IDs))
+	repoIdx := make([]uint32, len(d.Repos.IDs))
+
+	for i := range actorIdx {
+		actorIdx[i] = unreferenced
+	}
+
+	for i := range repoIdx {
+		repoIdx[i] = unreferenced
+	}
+
+	for i := 0; i < et.Len(); i++ {
+		if selectedActors[et.Actors[i]] && selectedRepos[et.Repos[i]] {
+			events = append(events, i)
+			actorIdx[et.Actors[i]], repoIdx[et.Repos[i]] = 0, 0
+		}
+	}
+
+	filtered := Dataset{Events: EventTable{
+		TypeNames: append([]string(nil), et.TypeNames...),
+		Types:     make([]uint16, len(events)),
+		Actors:    make([]uint32, len(events)),
+		Repos:     make([]uint32, len(events)),
+		Commits:   make([]uint32, len(events)),
+	}}
+
+	// listed entities keep coming first in the tables
+	for i, id := range d.Actors.IDs {
+		if actorIdx[i] != unreferenced {
+			actorIdx[i] = filtered.Actors.add(id, d.Actors.Usernames[i])
+		}
+
+		if i == d.Actors.Listed-1 {
+			filtered.Actors.Listed = len(filtered.Actors.IDs)
+		}
+	}
+
+	for i, id := range d.Repos.IDs {
+		if repoIdx[i] != unreferenced {
+			repoIdx[i] = filtered.Repos.add(id, d.Repos.Names[i])
+		}
+
+		if i == d.Repos.Listed-1 {
+			filtered.Repos.Listed = len(filtered.Repos.IDs)
+		}
+	}
+
+	fet := &filtered.Events
+
+	for j, i := range events {
+		fet.Types[j], fet.Commits[j] = et.Types[i], et.Commits[i]
+		fet.Actors[j], fet.Repos[j] = actorIdx[et.Actors[i]], repoIdx[et.Repos[i]]
+	}
+
+	return &filtered
+}
+
+// unreferenced is the index of actors and repositories without selected events, which are not in a filtered dataset.
+const unreferenced = ^uint32(0)
+
+func (at *ActorTable) add(id, username string) uint32 {
+	at.IDs = append(at.IDs, id)
+	at.Usernames = append(at.Usernames, username)
+
+	return uint32(len(at.IDs) - 1)
+}
+
+func (rt *RepoTable) add(id, name string) uint32 {
+	rt.IDs = append(rt.IDs, id)
+	rt.Names = append(rt.Names, name)
+
+	return uint32(len(rt.IDs) - 1)
+}
+
+// pattern is a parsed glob or regular expression.
+type pattern struct {
+	glob string
+	re   *regexp.Regexp
+}
+
+func (p pattern) match(s string) bool {
+	if p.re != nil {
+		return p.re.MatchString(s)
+	}
+
+	// the glob is checked by parsePattern, so it never fails
+	ok, _ := path.Match(p.glob, s)
+
+	return ok
+}
+
+type patterns []pattern
+
+// any reports whether any pattern matches the value.
+func (ps patterns) any(s string) bool {
+	for _, p := range ps {
+		if p.match(s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// include reports whether the value is included, empty patterns include anything.
+func (ps patterns) include(s string) bool {
+	return len(ps) == 0 || ps.any(s)
+}
+
+// parsePatterns parses the patterns, reading patterns of @path from files.
+func parsePatterns(specs []string) (patterns, error) {
+	var ps patterns
+
+	for _, spec := range specs {
+		if !strings.HasPrefix(spec, "@") {
+			p, err := parsePattern(spec)
+			if err != nil {
+				return nil, err
+			}
+
+			ps = append(ps, p)
+
+			continue
+		}
+
+		lines, err := readPatternsFile(spec[1:])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, line := range lines {
+			p, err := parsePattern(line)
+			if err != nil {
+				return nil, errors.Wrap(err, spec[1:])
+			}
+
+			ps = append(ps, p)
+		}
+	}
+
+	return ps, nil
+}
+
+func parsePattern(spec string) (pattern, error) {
+	if len(spec) >= 2 && strings.HasPrefix(spec, "/") && strings.HasSuffix(spec, "/") {
+		re, err := regexp.Compile(spec[1 : len(spec)-1])
+		if err != nil {
+			return pattern{}, errors.Wrapf(ErrWrongParam, "bad regular expression %q: %v", spec, err)
+		}
+
+		return pattern{re: re}, nil
+	}
+
+	if _, err := path.Match(spec, ""); err != nil {
+		return pattern{}, errors.Wrapf(ErrWrongParam, "bad glob %q: %v", spec, err)
+	}
+
+	return pattern{glob: spec}, nil
+}
+
+// readPatternsFile returns lines of the file which are neither empty nor comments, trimmed.
+func readPatternsFile(filename string) ([]string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, sc.Err()
+}
@@ -0,0 +1,192 @@
+package github_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+func TestEntityFilter(t *testing.T) {
+	list := filepath.Join(t.TempDir(), "org.txt")
+	if err := os.WriteFile(list, []byte("# our org\nacme\n\n/^acme-/\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		opts      github.EntityFilterOptions
+		repos     []string
+		usernames []string
+	}{
+		{
+			name:      "empty",
+			repos:     []string{"acme/api", "acme-labs/x", "kubernetes/kubernetes", ""},
+			usernames: []string{"alice", "ci[bot]", ""},
+		},
+		{
+			name:      "glob",
+			opts:      github.EntityFilterOptions{Repos: []string{"kubernetes/*"}, Users: []string{"a*"}},
+			repos:     []string{"kubernetes/kubernetes"},
+			usernames: []string{"alice"},
+		},
+		{
+			name:      "regular expression",
+			opts:      github.EntityFilterOptions{Owners: []string{"/^acme/"}, ExcludeUsers: []string{`/\[bot]$/`}},
+			repos:     []string{"acme/api", "acme-labs/x"},
+			usernames: []string{"alice", ""},
+		},
+		{
+			name:      "exclude list file",
+			opts:      github.EntityFilterOptions{ExcludeOwners: []string{"@" + list}, ExcludeRepos: []string{"kubernetes/kubernetes"}},
+			repos:     []string{""},
+			usernames: []string{"alice", "ci[bot]", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := github.NewEntityFilter(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var repos, usernames []string
+
+			for _, name := range []string{"acme/api", "acme-labs/x", "kubernetes/kubernetes", ""} {
+				if f.MatchRepo(name) {
+					repos = append(repos, name)
+				}
+			}
+
+			for _, username := range []string{"alice", "ci[bot]", ""} {
+				if f.MatchUser(username) {
+					usernames = append(usernames, username)
+				}
+			}
+
+			if !reflect.DeepEqual(repos, tt.repos) || !reflect.DeepEqual(usernames, tt.usernames) {
+				t.Errorf("selected %q and %q, want %q and %q", repos, usernames, tt.repos, tt.usernames)
+			}
+
+			if f.SelectsRepos() != (len(tt.repos) < 4) || f.SelectsUsers() != (len(tt.usernames) < 3) {
+				t.Errorf("SelectsRepos() = %v and SelectsUsers() = %v", f.SelectsRepos(), f.SelectsUsers())
+			}
+		})
+	}
+
+	for _, bad := range []string{"/(/", "[", "@" + filepath.Join(t.TempDir(), "missing.txt")} {
+		if _, err := github.NewEntityFilter(github.EntityFilterOptions{Users: []string{bad}}); err == nil {
+			t.Errorf("NewEntityFilter() with %q succeeded", bad)
+		}
+	}
+
+	if _, err := github.NewEntityFilter(github.EntityFilterOptions{Repos: []string{"["}}); !errors.Is(err, github.ErrWrongParam) {
+		t.Errorf("NewEntityFilter() error = %v, want %v", err, github.ErrWrongParam)
+	}
+}
+
+func TestEntityFilter_Fingerprint(t *testing.T) {
+	fingerprint := func(opts github.EntityFilterOptions) string {
+		f, err := github.NewEntityFilter(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return f.Fingerprint()
+	}
+
+	if fingerprint(github.EntityFilterOptions{}) != "" || (*github.EntityFilter)(nil).Fingerprint() != "" {
+		t.Error("Fingerprint() of an empty filter is not empty")
+	}
+
+	repos := fingerprint(github.EntityFilterOptions{Repos: []string{"acme/*"}})
+	if repos == "" || repos != fingerprint(github.EntityFilterOptions{Repos: []string{"acme/*"}}) {
+		t.Errorf("Fingerprint() = %q, want the same non-empty fingerprint for the same patterns", repos)
+	}
+
+	for _, other := range []github.EntityFilterOptions{
+		{ExcludeRepos: []string{"acme/*"}},
+		{Repos: []string{"/acme/*/"}},
+		{Repos: []string{"acme/*", "golang/*"}},
+	} {
+		if fingerprint(other) == repos {
+			t.Errorf("Fingerprint() of %+v is the same as of other patterns", other)
+		}
+	}
+}
+
+func TestDataset_Filter(t *testing.T) {
+	archive := &github.Archive{
+		Actors: []github.ActorCSV{{ID: "1", Username: "alice"}, {ID: "2", Username: "bob"}, {ID: "3", Username: "carol"}},
+		Repos:  []github.RepoCSV{{ID: "10", Name: "acme/api"}, {ID: "11", Name: "golang/go"}},
+		Events: []github.EventCSV{
+			{ID: "100", Type: github.PushEventType, ActorID: "1", RepoID: "10"},
+			{ID: "101", Type: github.PushEventType, ActorID: "1", RepoID: "11"},
+			{ID: "102", Type: github.PullRequestEventType, ActorID: "2", RepoID: "10"},
+			{ID: "103", Type: github.WatchEventType, ActorID: "4", RepoID: "11"},
+			{ID: "104", Type: github.WatchEventType, ActorID: "4", RepoID: "12"},
+		},
+		Commits: []github.CommitCSV{{SHA: "a", EventID: "100"}, {SHA: "b", EventID: "101"}, {SHA: "c", EventID: "101"}},
+	}
+
+	f, err := github.NewEntityFilter(github.EntityFilterOptions{ExcludeOwners: []string{"acme"}, ExcludeUsers: []string{"carol"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ds := archive.Dataset()
+	filtered := ds.Filter(f)
+
+	if ds.Events.Len() != 5 {
+		t.Error("Filter() modified the dataset")
+	}
+
+	if err := filtered.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	users, err := filtered.UsersSample(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	activity := make(map[string]github.ActorActivity)
+	for _, u := range users.M {
+		activity[u.Username] = u.Activity
+	}
+
+	// alice is ranked by commits outside of acme, bob has no events outside of it and carol is excluded
+	want := map[string]github.ActorActivity{"alice": {PushedCommits: 2}}
+	if !reflect.DeepEqual(activity, want) {
+		t.Errorf("users = %+v, want %+v", activity, want)
+	}
+
+	repos, err := filtered.ReposSample(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, r := range repos.M {
+		names = append(names, r.Name)
+	}
+
+	sort.Strings(names)
+
+	// the unlisted repository has no name, so only include patterns would drop it
+	if want := []string{"", "golang/go"}; !reflect.DeepEqual(names, want) || repos.M["11"].WatchEvents != 1 {
+		t.Errorf("repos = %q with %+v, want %q", names, repos.M["11"], want)
+	}
+
+	if ds.Filter(nil) != ds {
+		t.Error("Filter(nil) returned a copy")
+	}
+}
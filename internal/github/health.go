@@ -0,0 +1,214 @@
+package github
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// HealthMetric is a metric of concentration of contributions to a repository.
+type HealthMetric string
+
+// Health metrics. Repositories are ranked by them from the most concentrated contributions to the least.
+const (
+	BusFactorMetric           HealthMetric = "bus-factor"
+	BusFactor80Metric         HealthMetric = "bus-factor-80"
+	TopContributorShareMetric HealthMetric = "top-share"
+	HerfindahlMetric          HealthMetric = "herfindahl"
+)
+
+// HealthMetrics lists all health metrics.
+var HealthMetrics = []HealthMetric{BusFactorMetric, BusFactor80Metric, TopContributorShareMetric, HerfindahlMetric}
+
+// Contribution is commits pushed and pull requests created by an actor in a repository.
+type Contribution struct {
+	ID            string
+	Username      string
+	CommitsPushed int
+	PullRequests  int
+}
+
+// Total is the amount contributions are weighted by: every commit and every pull request counts as one.
+func (c Contribution) Total() int {
+	return c.CommitsPushed + c.PullRequests
+}
+
+// RepoHealth describes how concentrated contributions to a repository are among its contributors,
+// who are actors that pushed commits or created pull requests.
+type RepoHealth struct {
+	ID   string
+	Name string
+	// Contributions is the sum of commits pushed and pull requests created.
+	Contributions int
+	// Breakdown has contributions of every contributor, sorted by total descending and then by username and ID.
+	Breakdown []Contribution
+	// TopContributorShare is share of contributions of the top contributor.
+	TopContributorShare float64
+	// BusFactor is the least amount of contributors covering 50% of contributions.
+	BusFactor int
+	// BusFactor80 is the least amount of contributors covering 80% of contributions.
+	BusFactor80 int
+	// Herfindahl is the sum of squared shares of contributors: 1 if one contributor made everything,
+	// 1/N if N contributors contributed equally.
+	Herfindahl float64
+}
+
+// Contributors returns amount of contributors.
+func (rh *RepoHealth) Contributors() int {
+	return len(rh.Breakdown)
+}
+
+// Value returns value of the metric. The bigger the value, the more concentrated contributions are,
+// so bus factors are negated.
+func (rh *RepoHealth) Value(m HealthMetric) float64 {
+	switch m {
+	case BusFactorMetric:
+		return -float64(rh.BusFactor)
+	case BusFactor80Metric:
+		return -float64(rh.BusFactor80)
+	case TopContributorShareMetric:
+		return rh.TopContributorShare
+	case HerfindahlMetric:
+		return rh.Herfindahl
+	default:
+		return 0
+	}
+}
+
+// RepoHealthSample is a collection of repositories with concentration of their contributions.
+type RepoHealthSample struct {
+	M map[string]*RepoHealth
+}
+
+// RepoHealthSample returns health of repositories with contributors, listed in the repos CSV or not.
+// Commits are the ones joined to push events through CommitCSV.EventID.
+// Aggregation stops with ctx.Err() as soon as ctx is done.
+func (d *Dataset) RepoHealthSample(ctx context.Context) (*RepoHealthSample, error) {
+	m, err := d.ActivityMatrix(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	hs := RepoHealthSample{M: make(map[string]*RepoHealth)}
+
+	for repo := range d.Repos.IDs {
+		if m.repoCells[repo] == m.repoCells[repo+1] {
+			continue
+		}
+
+		rh := d.newRepoHealth(uint32(repo), m.repoContributions(uint32(repo)))
+		hs.M[rh.ID] = rh
+	}
+
+	return &hs, nil
+}
+
+// repoHealth returns health of the repository with the index, it has no breakdown if nobody contributed.
+func (d *Dataset) repoHealth(ctx context.Context, repo uint32) (*RepoHealth, error) {
+	m, err := d.activityMatrix(ctx, func(i int) bool { return d.Events.Repos[i] == repo })
+	if err != nil {
+		return nil, err
+	}
+
+	return d.newRepoHealth(repo, m.repoContributions(repo)), nil
+}
+
+func (d *Dataset) newRepoHealth(repo uint32, contributions []Contribution) *RepoHealth {
+	rh := RepoHealth{ID: d.Repos.IDs[repo], Name: d.Repos.Names[repo], Breakdown: contributions}
+
+	for _, c := range contributions {
+		rh.Contributions += c.Total()
+	}
+
+	sort.Slice(rh.Breakdown, func(i, j int) bool {
+		ci, cj := rh.Breakdown[i], rh.Breakdown[j]
+		if ci.Total() != cj.Total() {
+			return ci.Total() > cj.Total()
+		}
+
+		if ci.Username != cj.Username {
+			return ci.Username < cj.Username
+		}
+
+		return ci.ID < cj.ID
+	})
+
+	// contributors who pushed no commits still count, but they cover nothing
+	if rh.Contributions == 0 {
+		return &rh
+	}
+
+	var covered int
+
+	for i, c := range rh.Breakdown {
+		share := float64(c.Total()) / float64(rh.Contributions)
+		rh.Herfindahl += share * share
+
+		covered += c.Total()
+		if rh.BusFactor == 0 && covered*2 >= rh.Contributions {
+			rh.BusFactor = i + 1
+		}
+
+		if rh.BusFactor80 == 0 && covered*5 >= rh.Contributions*4 {
+			rh.BusFactor80 = i + 1
+		}
+	}
+
+	rh.TopContributorShare = float64(rh.Breakdown[0].Total()) / float64(rh.Contributions)
+
+	return &rh
+}
+
+// TopNBy returns top N repositories with the most concentrated contributions by the metric, among repositories
+// with at least minContributions. Ties are sorted by contributions descending, then by name and ID.
+func (hs *RepoHealthSample) TopNBy(n int, m HealthMetric, minContributions int) ([]*RepoHealth, error) {
+	if n < 1 {
+		return nil, errors.Wrap(ErrWrongParam, "n should be at least 1")
+	}
+
+	if !isHealthMetric(m) {
+		return nil, errors.Wrapf(ErrWrongParam, "unknown metric %q", m)
+	}
+
+	repos := make([]*RepoHealth, 0, len(hs.M))
+
+	for _, rh := range hs.M {
+		// repositories without contributions have no bus factor to rank
+		if rh.Contributions > 0 && rh.Contributions >= minContributions {
+			repos = append(repos, rh)
+		}
+	}
+
+	sort.Slice(repos, func(i, j int) bool {
+		if vi, vj := repos[i].Value(m), repos[j].Value(m); vi != vj {
+			return vi > vj
+		}
+
+		if repos[i].Contributions != repos[j].Contributions {
+			return repos[i].Contributions > repos[j].Contributions
+		}
+
+		if repos[i].Name != repos[j].Name {
+			return repos[i].Name < repos[j].Name
+		}
+
+		return repos[i].ID < repos[j].ID
+	})
+
+	if len(repos) > n {
+		repos = repos[:n]
+	}
+
+	return repos, nil
+}
+
+func isHealthMetric(m HealthMetric) bool {
+	for _, known := range HealthMetrics {
+		if m == known {
+			return true
+		}
+	}
+
+	return false
+}
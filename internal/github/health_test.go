@@ -0,0 +1,119 @@
+package github_test
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+func TestDataset_RepoHealthSample(t *testing.T) {
+	actors := []github.ActorCSV{
+		{ID: "1", Username: "alice"}, {ID: "2", Username: "bob"}, {ID: "3", Username: "carol"},
+		{ID: "4", Username: "dave"}, {ID: "5", Username: "eve"},
+	}
+	repoCSVs := []github.RepoCSV{{ID: "1", Name: "org/one"}, {ID: "2", Name: "org/two"}, {ID: "3", Name: "org/three"}}
+	events := []github.EventCSV{
+		// alice pushes 6 of 10 contributions to the first repo
+		{ID: "1", Type: github.PushEventType, ActorID: "1", RepoID: "1"},
+		{ID: "2", Type: github.PushEventType, ActorID: "2", RepoID: "1"},
+		{ID: "3", Type: github.PullRequestEventType, ActorID: "3", RepoID: "1"},
+		{ID: "4", Type: github.PullRequestEventType, ActorID: "4", RepoID: "1"},
+		// two equal contributors of the second repo
+		{ID: "5", Type: github.PushEventType, ActorID: "5", RepoID: "2"},
+		{ID: "6", Type: github.PullRequestEventType, ActorID: "1", RepoID: "2"},
+		// watchers are not contributors
+		{ID: "7", Type: github.WatchEventType, ActorID: "1", RepoID: "3"},
+	}
+	commits := []github.CommitCSV{
+		{SHA: "a", EventID: "1"}, {SHA: "b", EventID: "1"}, {SHA: "c", EventID: "1"},
+		{SHA: "d", EventID: "1"}, {SHA: "e", EventID: "1"}, {SHA: "f", EventID: "1"},
+		{SHA: "g", EventID: "2"}, {SHA: "h", EventID: "2"},
+		{SHA: "i", EventID: "5"},
+	}
+
+	hs, err := github.NewDataset(actors, repoCSVs, events, commits).RepoHealthSample(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]*github.RepoHealth{
+		"1": {
+			ID: "1", Name: "org/one", Contributions: 10,
+			Breakdown: []github.Contribution{
+				{ID: "1", Username: "alice", CommitsPushed: 6},
+				{ID: "2", Username: "bob", CommitsPushed: 2},
+				{ID: "3", Username: "carol", PullRequests: 1},
+				{ID: "4", Username: "dave", PullRequests: 1},
+			},
+			TopContributorShare: 0.6, BusFactor: 1, BusFactor80: 2, Herfindahl: 0.42,
+		},
+		"2": {
+			ID: "2", Name: "org/two", Contributions: 2,
+			Breakdown: []github.Contribution{
+				{ID: "1", Username: "alice", PullRequests: 1},
+				{ID: "5", Username: "eve", CommitsPushed: 1},
+			},
+			TopContributorShare: 0.5, BusFactor: 1, BusFactor80: 2, Herfindahl: 0.5,
+		},
+	}
+
+	if len(hs.M) != len(want) {
+		t.Fatalf("RepoHealthSample() has %d repos, want %d", len(hs.M), len(want))
+	}
+
+	for id, w := range want {
+		got := hs.M[id]
+		if got == nil {
+			t.Fatalf("RepoHealthSample() has no repo %s", id)
+		}
+
+		if math.Abs(got.Herfindahl-w.Herfindahl) > 1e-9 || math.Abs(got.TopContributorShare-w.TopContributorShare) > 1e-9 {
+			t.Errorf("repo %s has top share %v and Herfindahl %v, want %v and %v",
+				id, got.TopContributorShare, got.Herfindahl, w.TopContributorShare, w.Herfindahl)
+		}
+
+		g := *got
+		g.Herfindahl, g.TopContributorShare = w.Herfindahl, w.TopContributorShare
+
+		if !reflect.DeepEqual(&g, w) {
+			t.Errorf("repo %s = %+v, want %+v", id, got, w)
+		}
+	}
+
+	tests := []struct {
+		metric           github.HealthMetric
+		minContributions int
+		want             []string
+	}{
+		// ties are sorted by contributions
+		{metric: github.BusFactorMetric, want: []string{"org/one", "org/two"}},
+		{metric: github.TopContributorShareMetric, want: []string{"org/one", "org/two"}},
+		{metric: github.HerfindahlMetric, want: []string{"org/two", "org/one"}},
+		{metric: github.HerfindahlMetric, minContributions: 3, want: []string{"org/one"}},
+	}
+
+	for _, tt := range tests {
+		top, err := hs.TopNBy(10, tt.metric, tt.minContributions)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names := make([]string, len(top))
+		for i, rh := range top {
+			names[i] = rh.Name
+		}
+
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("TopNBy(%s, %d) = %v, want %v", tt.metric, tt.minContributions, names, tt.want)
+		}
+	}
+
+	if _, err := hs.TopNBy(1, "stars", 0); !errors.Is(err, github.ErrWrongParam) {
+		t.Errorf("TopNBy() error = %v, want %v", err, github.ErrWrongParam)
+	}
+}
@@ -0,0 +1,283 @@
+package github
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// ActivityMetric is a metric of activity of an actor.
+type ActivityMetric string
+
+// Activity metrics.
+const (
+	ActivityTotalMetric ActivityMetric = "activity"
+	CommitsMetric       ActivityMetric = "commits"
+	PullRequestsMetric  ActivityMetric = "pull-requests"
+)
+
+// ActivityMetrics lists all activity metrics.
+var ActivityMetrics = []ActivityMetric{ActivityTotalMetric, CommitsMetric, PullRequestsMetric}
+
+// Value returns value of the metric.
+func (a ActorActivity) Value(m ActivityMetric) int {
+	switch m {
+	case ActivityTotalMetric:
+		return a.Total()
+	case CommitsMetric:
+		return a.PushedCommits
+	case PullRequestsMetric:
+		return a.CreatedPullRequests
+	default:
+		return 0
+	}
+}
+
+// RepoActivity is activity of a user in a repository.
+type RepoActivity struct {
+	ID       string
+	Name     string
+	Activity ActorActivity
+}
+
+// activityCell is activity of an actor in a repository.
+type activityCell struct {
+	actor    uint32
+	repo     uint32
+	activity ActorActivity
+}
+
+// ActivityMatrix is a sparse actor×repository matrix of commits pushed and pull requests created.
+// Only actors who pushed or created pull requests in a repository have a cell, even if they pushed no commits.
+type ActivityMatrix struct {
+	d *Dataset
+	// cells are sorted by repository and then by actor
+	cells []activityCell
+	// repoCells are indices of the first cell of every repository and one past the last one
+	repoCells []int
+	// actorCells are indices of cells sorted by actor and then by repository
+	actorCells []int
+	// actorStarts are indices in actorCells of the first cell of every actor and one past the last one
+	actorStarts []int
+}
+
+// ActivityMatrix returns the activity matrix of all actors and repositories.
+// Aggregation stops with ctx.Err() as soon as ctx is done.
+func (d *Dataset) ActivityMatrix(ctx context.Context) (*ActivityMatrix, error) {
+	return d.activityMatrix(ctx, func(i int) bool { return true })
+}
+
+// activityMatrix returns the activity matrix of push and pull request events accepted by the filter.
+func (d *Dataset) activityMatrix(ctx context.Context, accept func(i int) bool) (*ActivityMatrix, error) {
+	et := &d.Events
+	pushType, prType := et.TypeIndex(PushEventType), et.TypeIndex(PullRequestEventType)
+	cellIdx := make(map[uint64]int)
+	m := ActivityMatrix{d: d}
+
+	for i := 0; i < et.Len(); i++ {
+		if err := checkCtx(ctx, i); err != nil {
+			return nil, err
+		}
+
+		typ := int(et.Types[i])
+		if (typ != pushType && typ != prType) || !accept(i) {
+			continue
+		}
+
+		key := uint64(et.Repos[i])<<32 | uint64(et.Actors[i])
+
+		j, ok := cellIdx[key]
+		if !ok {
+			j = len(m.cells)
+			cellIdx[key] = j
+			m.cells = append(m.cells, activityCell{actor: et.Actors[i], repo: et.Repos[i]})
+		}
+
+		if typ == pushType {
+			m.cells[j].activity.PushedCommits += int(et.Commits[i])
+		} else {
+			m.cells[j].activity.CreatedPullRequests++
+		}
+	}
+
+	sort.Slice(m.cells, func(i, j int) bool {
+		if m.cells[i].repo != m.cells[j].repo {
+			return m.cells[i].repo < m.cells[j].repo
+		}
+
+		return m.cells[i].actor < m.cells[j].actor
+	})
+
+	m.actorCells = make([]int, len(m.cells))
+	for i := range m.actorCells {
+		m.actorCells[i] = i
+	}
+
+	// cells of an actor stay sorted by repository, because the sort is stable
+	sort.SliceStable(m.actorCells, func(i, j int) bool { return m.cells[m.actorCells[i]].actor < m.cells[m.actorCells[j]].actor })
+
+	m.repoCells = starts(len(d.Repos.IDs), len(m.cells), func(i int) uint32 { return m.cells[i].repo })
+	m.actorStarts = starts(len(d.Actors.IDs), len(m.cells), func(i int) uint32 { return m.cells[m.actorCells[i]].actor })
+
+	return &m, nil
+}
+
+// starts returns indices of the first item of every key of sorted items and one past the last one.
+func starts(keys, items int, key func(i int) uint32) []int {
+	s := make([]int, keys+1)
+
+	for i := 0; i < items; i++ {
+		s[key(i)+1]++
+	}
+
+	for k := 1; k <= keys; k++ {
+		s[k] += s[k-1]
+	}
+
+	return s
+}
+
+// Len returns amount of non-empty cells.
+func (m *ActivityMatrix) Len() int {
+	return len(m.cells)
+}
+
+// TopContributors returns top N listed users of the listed repository with the name by the metric,
+// ties are sorted by total activity, then by username and ID. Bots with `botname[bot]` could be filtered out.
+func (m *ActivityMatrix) TopContributors(repoName string, n int, metric ActivityMetric, botsIncluded bool) ([]User, error) {
+	if err := checkTopN(n, metric); err != nil {
+		return nil, err
+	}
+
+	repo, ok := m.d.repoIndex(repoName)
+	if !ok {
+		return nil, errors.Wrapf(ErrNotFound, "repository %q", repoName)
+	}
+
+	var users []User
+
+	for _, c := range m.cells[m.repoCells[repo]:m.repoCells[repo+1]] {
+		username := m.d.Actors.Usernames[c.actor]
+		if int(c.actor) >= m.d.Actors.Listed || (!botsIncluded && IsBotUsername(username)) {
+			continue
+		}
+
+		users = append(users, User{ID: m.d.Actors.IDs[c.actor], Username: username, Activity: c.activity})
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return lessActivity(users[i].Activity, users[j].Activity, users[i].Username, users[j].Username, users[i].ID, users[j].ID, metric)
+	})
+
+	if len(users) > n {
+		users = users[:n]
+	}
+
+	return users, nil
+}
+
+// TopRepos returns top N listed repositories the listed user with the username is active in by the metric,
+// ties are sorted by total activity, then by name and ID.
+func (m *ActivityMatrix) TopRepos(username string, n int, metric ActivityMetric) ([]RepoActivity, error) {
+	if err := checkTopN(n, metric); err != nil {
+		return nil, err
+	}
+
+	actor, ok := m.d.actorIndex(username)
+	if !ok {
+		return nil, errors.Wrapf(ErrNotFound, "user %q", username)
+	}
+
+	var repos []RepoActivity
+
+	for _, i := range m.actorCells[m.actorStarts[actor]:m.actorStarts[actor+1]] {
+		c := m.cells[i]
+		if int(c.repo) >= m.d.Repos.Listed {
+			continue
+		}
+
+		repos = append(repos, RepoActivity{ID: m.d.Repos.IDs[c.repo], Name: m.d.Repos.Names[c.repo], Activity: c.activity})
+	}
+
+	sort.Slice(repos, func(i, j int) bool {
+		return lessActivity(repos[i].Activity, repos[j].Activity, repos[i].Name, repos[j].Name, repos[i].ID, repos[j].ID, metric)
+	})
+
+	if len(repos) > n {
+		repos = repos[:n]
+	}
+
+	return repos, nil
+}
+
+// repoContributions returns contributions of actors to the repository, in order of actors.
+func (m *ActivityMatrix) repoContributions(repo uint32) []Contribution {
+	cells := m.cells[m.repoCells[repo]:m.repoCells[repo+1]]
+	contributions := make([]Contribution, len(cells))
+
+	for i, c := range cells {
+		contributions[i] = Contribution{
+			ID:            m.d.Actors.IDs[c.actor],
+			Username:      m.d.Actors.Usernames[c.actor],
+			CommitsPushed: c.activity.PushedCommits,
+			PullRequests:  c.activity.CreatedPullRequests,
+		}
+	}
+
+	return contributions
+}
+
+// lessActivity reports whether the first of two ranked items goes first: by the metric descending,
+// by total activity descending, then by name and ID.
+func lessActivity(ai, aj ActorActivity, namei, namej, idi, idj string, metric ActivityMetric) bool {
+	if vi, vj := ai.Value(metric), aj.Value(metric); vi != vj {
+		return vi > vj
+	}
+
+	if ai.Total() != aj.Total() {
+		return ai.Total() > aj.Total()
+	}
+
+	if namei != namej {
+		return namei < namej
+	}
+
+	return idi < idj
+}
+
+func checkTopN(n int, metric ActivityMetric) error {
+	if n < 1 {
+		return errors.Wrap(ErrWrongParam, "n should be at least 1")
+	}
+
+	for _, known := range ActivityMetrics {
+		if metric == known {
+			return nil
+		}
+	}
+
+	return errors.Wrapf(ErrWrongParam, "unknown metric %q", metric)
+}
+
+// repoIndex returns index of the listed repository with the name.
+func (d *Dataset) repoIndex(name string) (uint32, bool) {
+	for i := 0; i < d.Repos.Listed; i++ {
+		if d.Repos.Names[i] == name {
+			return uint32(i), true
+		}
+	}
+
+	return 0, false
+}
+
+// actorIndex returns index of the listed actor with the username.
+func (d *Dataset) actorIndex(username string) (uint32, bool) {
+	for i := 0; i < d.Actors.Listed; i++ {
+		if d.Actors.Usernames[i] == username {
+			return uint32(i), true
+		}
+	}
+
+	return 0, false
+}
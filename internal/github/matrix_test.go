@@ -0,0 +1,92 @@
+package github_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+func TestActivityMatrix(t *testing.T) {
+	actors := []github.ActorCSV{{ID: "1", Username: "alice"}, {ID: "2", Username: "bob"}, {ID: "3", Username: "dependabot[bot]"}}
+	repoCSVs := []github.RepoCSV{{ID: "1", Name: "org/one"}, {ID: "2", Name: "org/two"}, {ID: "3", Name: "org/three"}}
+	events := []github.EventCSV{
+		{ID: "1", Type: github.PushEventType, ActorID: "1", RepoID: "1"},
+		{ID: "2", Type: github.PullRequestEventType, ActorID: "2", RepoID: "1"},
+		{ID: "3", Type: github.PullRequestEventType, ActorID: "2", RepoID: "1"},
+		{ID: "4", Type: github.PullRequestEventType, ActorID: "3", RepoID: "1"},
+		{ID: "5", Type: github.PullRequestEventType, ActorID: "3", RepoID: "1"},
+		{ID: "6", Type: github.PullRequestEventType, ActorID: "3", RepoID: "1"},
+		{ID: "7", Type: github.PullRequestEventType, ActorID: "1", RepoID: "2"},
+		{ID: "8", Type: github.WatchEventType, ActorID: "1", RepoID: "3"},
+		// unlisted actor and repository
+		{ID: "9", Type: github.PushEventType, ActorID: "4", RepoID: "1"},
+		{ID: "10", Type: github.PullRequestEventType, ActorID: "1", RepoID: "4"},
+	}
+	commits := []github.CommitCSV{{SHA: "a", EventID: "1"}, {SHA: "b", EventID: "1"}}
+
+	m, err := github.NewDataset(actors, repoCSVs, events, commits).ActivityMatrix(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Len() != 6 {
+		t.Errorf("Len() = %d, want 6", m.Len())
+	}
+
+	alice := github.User{ID: "1", Username: "alice", Activity: github.ActorActivity{PushedCommits: 2}}
+	bob := github.User{ID: "2", Username: "bob", Activity: github.ActorActivity{CreatedPullRequests: 2}}
+	bot := github.User{ID: "3", Username: "dependabot[bot]", Activity: github.ActorActivity{CreatedPullRequests: 3}}
+
+	contributorTests := []struct {
+		metric github.ActivityMetric
+		n      int
+		bots   bool
+		want   []github.User
+	}{
+		// ties are sorted by username
+		{metric: github.ActivityTotalMetric, n: 10, want: []github.User{alice, bob}},
+		{metric: github.ActivityTotalMetric, n: 1, bots: true, want: []github.User{bot}},
+		{metric: github.CommitsMetric, n: 10, bots: true, want: []github.User{alice, bot, bob}},
+		{metric: github.PullRequestsMetric, n: 10, want: []github.User{bob, alice}},
+	}
+
+	for _, tt := range contributorTests {
+		got, err := m.TopContributors("org/one", tt.n, tt.metric, tt.bots)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("TopContributors(%d, %s, %v) = %+v, want %+v", tt.n, tt.metric, tt.bots, got, tt.want)
+		}
+	}
+
+	repos, err := m.TopRepos("alice", 10, github.ActivityTotalMetric)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantRepos := []github.RepoActivity{
+		{ID: "1", Name: "org/one", Activity: github.ActorActivity{PushedCommits: 2}},
+		{ID: "2", Name: "org/two", Activity: github.ActorActivity{CreatedPullRequests: 1}},
+	}
+	if !reflect.DeepEqual(repos, wantRepos) {
+		t.Errorf("TopRepos() = %+v, want %+v", repos, wantRepos)
+	}
+
+	if _, err := m.TopContributors("org/four", 1, github.ActivityTotalMetric, false); !errors.Is(err, github.ErrNotFound) {
+		t.Errorf("TopContributors() error = %v, want %v", err, github.ErrNotFound)
+	}
+
+	if _, err := m.TopRepos("carol", 1, github.ActivityTotalMetric); !errors.Is(err, github.ErrNotFound) {
+		t.Errorf("TopRepos() error = %v, want %v", err, github.ErrNotFound)
+	}
+
+	if _, err := m.TopRepos("alice", 1, "stars"); !errors.Is(err, github.ErrWrongParam) {
+		t.Errorf("TopRepos() error = %v, want %v", err, github.ErrWrongParam)
+	}
+}
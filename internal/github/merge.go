@@ -0,0 +1,67 @@
+package github
+
+// MergeDatasets returns a dataset with events of all datasets, as if their archives were decoded together.
+// Actors, repositories and event types are interned again, so indices of the result don't match indices
+// of the datasets. An actor or a repository is listed if any dataset lists it, and the name from the last
+// dataset listing it wins, the same way as in NewDataset. Events are not deduplicated: merging a dataset
+// with itself counts its events twice.
+func MergeDatasets(datasets ...*Dataset) *Dataset {
+	merged := Dataset{}
+
+	actorIdxByID := make(map[string]uint32)
+	repoIdxByID := make(map[string]uint32)
+
+	// listed entities come first in the tables, so they are interned before events refer to unlisted ones
+	for _, d := range datasets {
+		for i := 0; i < d.Actors.Listed; i++ {
+			idx := merged.Actors.intern(actorIdxByID, d.Actors.IDs[i])
+			merged.Actors.Usernames[idx] = d.Actors.Usernames[i]
+		}
+
+		for i := 0; i < d.Repos.Listed; i++ {
+			idx := merged.Repos.intern(repoIdxByID, d.Repos.IDs[i])
+			merged.Repos.Names[idx] = d.Repos.Names[i]
+		}
+	}
+
+	merged.Actors.Listed = len(merged.Actors.IDs)
+	merged.Repos.Listed = len(merged.Repos.IDs)
+
+	typeIdxByName := make(map[string]uint16)
+	et := &merged.Events
+
+	for _, d := range datasets {
+		actorIdx := make([]uint32, len(d.Actors.IDs))
+		for i, id := range d.Actors.IDs {
+			actorIdx[i] = merged.Actors.intern(actorIdxByID, id)
+		}
+
+		repoIdx := make([]uint32, len(d.Repos.IDs))
+		for i, id := range d.Repos.IDs {
+			repoIdx[i] = merged.Repos.intern(repoIdxByID, id)
+		}
+
+		typeIdx := make([]uint16, len(d.Events.TypeNames))
+
+		for i, name := range d.Events.TypeNames {
+			idx, ok := typeIdxByName[name]
+			if !ok {
+				idx = uint16(len(et.TypeNames))
+				typeIdxByName[name] = idx
+				et.TypeNames = append(et.TypeNames, name)
+			}
+
+			typeIdx[i] = idx
+		}
+
+		for i := 0; i < d.Events.Len(); i++ {
+			et.Types = append(et.Types, typeIdx[d.Events.Types[i]])
+			et.Actors = append(et.Actors, actorIdx[d.Events.Actors[i]])
+			et.Repos = append(et.Repos, repoIdx[d.Events.Repos[i]])
+		}
+
+		et.Commits = append(et.Commits, d.Events.Commits...)
+	}
+
+	return &merged
+}
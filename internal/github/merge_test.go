@@ -0,0 +1,132 @@
+package github_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/samples"
+)
+
+// TestMergeDatasets splits events of the sample archive in two and checks that samples of merged halves
+// are the same as samples of the whole archive.
+func TestMergeDatasets(t *testing.T) {
+	ctx := context.Background()
+
+	archive, err := samples.Archive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actors, repoCSVs, events, commits := archive.Actors, archive.Repos, archive.Events, archive.Commits
+	half := len(events) / 2
+
+	whole := github.NewDataset(actors, repoCSVs, events, commits)
+	merged := github.MergeDatasets(
+		github.NewDataset(actors[:len(actors)/2], repoCSVs, events[:half], commits),
+		github.NewDataset(actors[len(actors)/2:], nil, events[half:], commits),
+	)
+
+	if err := merged.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if merged.Events.Len() != whole.Events.Len() || merged.Actors.Listed != whole.Actors.Listed || merged.Repos.Listed != whole.Repos.Listed {
+		t.Fatalf("MergeDatasets() has %d events, %d listed actors and %d listed repos, want %d, %d and %d",
+			merged.Events.Len(), merged.Actors.Listed, merged.Repos.Listed,
+			whole.Events.Len(), whole.Actors.Listed, whole.Repos.Listed)
+	}
+
+	for _, bots := range []bool{false, true} {
+		got, err := merged.UsersSample(ctx, bots)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want, err := whole.UsersSample(ctx, bots)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("UsersSample(%v) of merged datasets differs from the whole archive", bots)
+		}
+	}
+
+	got, err := merged.ReposSample(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := whole.ReposSample(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Error("ReposSample() of merged datasets differs from the whole archive")
+	}
+}
+
+// TestSamples_Merge checks that merged samples of two halves of the sample archive are the same as samples
+// of the whole archive.
+func TestSamples_Merge(t *testing.T) {
+	ctx := context.Background()
+
+	archive, err := samples.Archive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actors, repoCSVs, events, commits := archive.Actors, archive.Repos, archive.Events, archive.Commits
+	half := len(events) / 2
+	datasets := []*github.Dataset{
+		github.NewDataset(actors, repoCSVs, events[:half], commits),
+		github.NewDataset(actors, repoCSVs, events[half:], commits),
+	}
+
+	users, repos := &github.UsersSample{}, &github.ReposSample{}
+
+	for _, ds := range datasets {
+		u, err := ds.UsersSample(ctx, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := ds.ReposSample(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		users.Merge(u)
+		repos.Merge(r)
+	}
+
+	whole := github.NewDataset(actors, repoCSVs, events, commits)
+
+	for _, bots := range []bool{false, true} {
+		want, err := whole.UsersSample(ctx, bots)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := users
+		if !bots {
+			got = users.WithoutBots()
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("merged UsersSample(%v) differs from the whole archive", bots)
+		}
+	}
+
+	want, err := whole.ReposSample(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(repos, want) {
+		t.Error("merged ReposSample differs from the whole archive")
+	}
+}
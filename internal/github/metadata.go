@@ -0,0 +1,347 @@
+package github
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// IDColumn is the key column of metadata joined by ID, metadata with any other key column is joined by name.
+const IDColumn = "id"
+
+// NoValue is the group of users or repositories without a value in the grouped column.
+const NoValue = "(none)"
+
+// Metadata is columns of a user or a repository joined from a metadata file, like language or company.
+type Metadata map[string]string
+
+// MetadataTable is rows of a metadata file keyed by ID or by name of users or repositories.
+type MetadataTable struct {
+	// Key is the column rows are keyed by.
+	Key string
+	// Columns are columns of rows, without the key, in order of the file.
+	Columns []string
+	Rows    map[string]Metadata
+}
+
+// ReadMetadataCSV reads a metadata CSV file with a header. The key column is joined to IDs if it is IDColumn,
+// and to names or usernames otherwise. A key which repeats overrides the previous row.
+func ReadMetadataCSV(r io.Reader, key string) (*MetadataTable, error) {
+	cr := csv.NewReader(r)
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "can't read header")
+	}
+
+	keyIdx := -1
+
+	t := MetadataTable{Key: key, Rows: make(map[string]Metadata)}
+
+	for i, column := range header {
+		if column == key {
+			keyIdx = i
+
+			continue
+		}
+
+		t.Columns = append(t.Columns, column)
+	}
+
+	if keyIdx < 0 {
+		return nil, errors.Wrapf(ErrWrongParam, "no key column %q", key)
+	}
+
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return &t, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		m := make(Metadata, len(t.Columns))
+
+		for i, value := range record {
+			if i != keyIdx {
+				m[header[i]] = value
+			}
+		}
+
+		t.Rows[record[keyIdx]] = m
+	}
+}
+
+// ReadMetadataJSON reads a metadata JSON file, which is an array of objects. Numbers and booleans are joined
+// as they are written, arrays are joined with commas, like topics, and nulls are empty values.
+// The key is joined the same way as by ReadMetadataCSV.
+func ReadMetadataJSON(r io.Reader, key string) (*MetadataTable, error) {
+	var objects []map[string]json.RawMessage
+
+	if err := json.NewDecoder(r).Decode(&objects); err != nil {
+		return nil, errors.Wrap(err, "metadata should be an array of objects")
+	}
+
+	t := MetadataTable{Key: key, Rows: make(map[string]Metadata, len(objects))}
+	columns := make(map[string]bool)
+
+	for i, o := range objects {
+		raw, ok := o[key]
+		if !ok {
+			return nil, errors.Wrapf(ErrWrongParam, "object %d has no key %q", i, key)
+		}
+
+		keyValue, err := jsonValue(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		m := make(Metadata, len(o)-1)
+
+		for column, raw := range o {
+			if column == key {
+				continue
+			}
+
+			if m[column], err = jsonValue(raw); err != nil {
+				return nil, errors.Wrapf(err, "column %q of object %d", column, i)
+			}
+
+			columns[column] = true
+		}
+
+		t.Rows[keyValue] = m
+	}
+
+	// objects have no column order, so columns are sorted
+	for column := range columns {
+		t.Columns = append(t.Columns, column)
+	}
+
+	sort.Strings(t.Columns)
+
+	return &t, nil
+}
+
+// jsonValue returns a JSON value as a metadata value.
+func jsonValue(raw json.RawMessage) (string, error) {
+	var v interface{}
+
+	d := json.NewDecoder(strings.NewReader(string(raw)))
+	d.UseNumber()
+
+	if err := d.Decode(&v); err != nil {
+		return "", err
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []interface{}:
+		values := make([]string, len(v))
+
+		for i, item := range v {
+			b, err := json.Marshal(item)
+			if err != nil {
+				return "", err
+			}
+
+			if values[i], err = jsonValue(b); err != nil {
+				return "", err
+			}
+		}
+
+		return strings.Join(values, ","), nil
+	case map[string]interface{}:
+		return "", errors.Wrap(ErrWrongParam, "objects can't be metadata values")
+	default:
+		return strings.TrimSpace(string(raw)), nil
+	}
+}
+
+// lookup returns metadata of the user or the repository with the ID and the name.
+func (t *MetadataTable) lookup(id, name string) Metadata {
+	if t.Key == IDColumn {
+		return t.Rows[id]
+	}
+
+	return t.Rows[name]
+}
+
+// enrich returns a copy of meta with metadata of the tables joined, the last table wins for a column
+// in several tables. Users and repositories without rows keep their metadata.
+func enrich(meta Metadata, id, name string, tables []*MetadataTable) Metadata {
+	enriched, copied := meta, false
+
+	for _, t := range tables {
+		row := t.lookup(id, name)
+		if row == nil {
+			continue
+		}
+
+		// samples may share metadata maps, so they are never modified
+		if !copied {
+			enriched, copied = make(Metadata, len(meta)+len(row)), true
+			for column, value := range meta {
+				enriched[column] = value
+			}
+		}
+
+		for column, value := range row {
+			enriched[column] = value
+		}
+	}
+
+	return enriched
+}
+
+// MetadataFilter keeps users or repositories whose metadata column equals the value, or doesn't if it is negated.
+// A missing column has an empty value.
+type MetadataFilter struct {
+	Column  string
+	Value   string
+	Negated bool
+}
+
+// ParseMetadataFilter parses column=value or column!=value, the value may be empty.
+func ParseMetadataFilter(s string) (MetadataFilter, error) {
+	idx := strings.Index(s, "=")
+	if idx <= 0 {
+		return MetadataFilter{}, errors.Wrapf(ErrWrongParam, "filter %q should be column=value or column!=value", s)
+	}
+
+	f := MetadataFilter{Column: s[:idx], Value: s[idx+1:]}
+
+	if strings.HasSuffix(f.Column, "!") {
+		f.Column, f.Negated = strings.TrimSuffix(f.Column, "!"), true
+	}
+
+	if f.Column == "" {
+		return MetadataFilter{}, errors.Wrapf(ErrWrongParam, "filter %q has no column", s)
+	}
+
+	return f, nil
+}
+
+// String returns the filter as it is parsed by ParseMetadataFilter.
+func (f MetadataFilter) String() string {
+	if f.Negated {
+		return f.Column + "!=" + f.Value
+	}
+
+	return f.Column + "=" + f.Value
+}
+
+// Match reports whether the metadata passes the filter.
+func (f MetadataFilter) Match(m Metadata) bool {
+	return (m[f.Column] == f.Value) != f.Negated
+}
+
+func matchAll(m Metadata, filters []MetadataFilter) bool {
+	for _, f := range filters {
+		if !f.Match(m) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// groupValue returns the value users or repositories are grouped by.
+func groupValue(m Metadata, column string) string {
+	if v := m[column]; v != "" {
+		return v
+	}
+
+	return NoValue
+}
+
+// Enrich left-joins metadata of the tables onto the users.
+func (us *UsersSample) Enrich(tables ...*MetadataTable) {
+	for id, u := range us.M {
+		u.Meta = enrich(u.Meta, u.ID, u.Username, tables)
+		us.M[id] = u
+	}
+}
+
+// Where returns a sample of the users which pass all filters.
+func (us *UsersSample) Where(filters ...MetadataFilter) *UsersSample {
+	filtered := UsersSample{M: make(map[string]User)}
+
+	for id, u := range us.M {
+		if matchAll(u.Meta, filters) {
+			filtered.M[id] = u
+		}
+	}
+
+	return &filtered
+}
+
+// GroupBy returns samples of the users by values of the metadata column. Users without a value
+// are in the NoValue group.
+func (us *UsersSample) GroupBy(column string) map[string]*UsersSample {
+	groups := make(map[string]*UsersSample)
+
+	for id, u := range us.M {
+		v := groupValue(u.Meta, column)
+
+		g, ok := groups[v]
+		if !ok {
+			g = &UsersSample{M: make(map[string]User)}
+			groups[v] = g
+		}
+
+		g.M[id] = u
+	}
+
+	return groups
+}
+
+// Enrich left-joins metadata of the tables onto the repositories.
+func (rs *ReposSample) Enrich(tables ...*MetadataTable) {
+	for id, r := range rs.M {
+		r.Meta = enrich(r.Meta, r.ID, r.Name, tables)
+		rs.M[id] = r
+	}
+}
+
+// Where returns a sample of the repositories which pass all filters.
+func (rs *ReposSample) Where(filters ...MetadataFilter) *ReposSample {
+	filtered := ReposSample{M: make(map[string]Repo)}
+
+	for id, r := range rs.M {
+		if matchAll(r.Meta, filters) {
+			filtered.M[id] = r
+		}
+	}
+
+	return &filtered
+}
+
+// GroupBy returns samples of the repositories by values of the metadata column. Repositories without a value
+// are in the NoValue group.
+func (rs *ReposSample) GroupBy(column string) map[string]*ReposSample {
+	groups := make(map[string]*ReposSample)
+
+	for id, r := range rs.M {
+		v := groupValue(r.Meta, column)
+
+		g, ok := groups[v]
+		if !ok {
+			g = &ReposSample{M: make(map[string]Repo)}
+			groups[v] = g
+		}
+
+		g.M[id] = r
+	}
+
+	return groups
+}
@@ -0,0 +1,128 @@
+package github_test
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+func TestReadMetadata(t *testing.T) {
+	csvTable, err := github.ReadMetadataCSV(strings.NewReader("language,id,license\nGo,1,MIT\nC,2,\nRust,1,Apache-2.0\n"), "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantCSV := &github.MetadataTable{
+		Key:     "id",
+		Columns: []string{"language", "license"},
+		Rows: map[string]github.Metadata{
+			"1": {"language": "Rust", "license": "Apache-2.0"},
+			"2": {"language": "C", "license": ""},
+		},
+	}
+	if !reflect.DeepEqual(csvTable, wantCSV) {
+		t.Errorf("ReadMetadataCSV() = %+v, want %+v", csvTable, wantCSV)
+	}
+
+	jsonTable, err := github.ReadMetadataJSON(strings.NewReader(
+		`[{"name": "org/one", "topics": ["cli", "go"], "stars": 1200, "archived": false, "license": null}]`,
+	), "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantJSON := &github.MetadataTable{
+		Key:     "name",
+		Columns: []string{"archived", "license", "stars", "topics"},
+		Rows: map[string]github.Metadata{
+			"org/one": {"topics": "cli,go", "stars": "1200", "archived": "false", "license": ""},
+		},
+	}
+	if !reflect.DeepEqual(jsonTable, wantJSON) {
+		t.Errorf("ReadMetadataJSON() = %+v, want %+v", jsonTable, wantJSON)
+	}
+
+	if _, err := github.ReadMetadataCSV(strings.NewReader("language,license\nGo,MIT\n"), "id"); !errors.Is(err, github.ErrWrongParam) {
+		t.Errorf("ReadMetadataCSV() error = %v, want %v", err, github.ErrWrongParam)
+	}
+
+	if _, err := github.ReadMetadataJSON(strings.NewReader(`[{"language": "Go"}]`), "id"); !errors.Is(err, github.ErrWrongParam) {
+		t.Errorf("ReadMetadataJSON() error = %v, want %v", err, github.ErrWrongParam)
+	}
+}
+
+func TestReposSample_Enrich(t *testing.T) {
+	rs := &github.ReposSample{M: map[string]github.Repo{
+		"1": {ID: "1", Name: "org/one", CommitsPushed: 3},
+		"2": {ID: "2", Name: "org/two", CommitsPushed: 2},
+		"3": {ID: "3", Name: "org/three", CommitsPushed: 1},
+	}}
+	byID := &github.MetadataTable{Key: "id", Rows: map[string]github.Metadata{
+		"1": {"language": "Go"},
+		"2": {"language": "C", "license": "MIT"},
+	}}
+	byName := &github.MetadataTable{Key: "name", Rows: map[string]github.Metadata{
+		"org/two": {"language": "Go"},
+	}}
+
+	rs.Enrich(byID, byName)
+
+	// the last table wins, and the repository without rows is left as it is
+	want := map[string]github.Metadata{"1": {"language": "Go"}, "2": {"language": "Go", "license": "MIT"}, "3": nil}
+	for id, meta := range want {
+		if got := rs.M[id].Meta; !reflect.DeepEqual(got, meta) {
+			t.Errorf("Meta of %s = %v, want %v", id, got, meta)
+		}
+	}
+
+	if byID.Rows["2"]["language"] != "C" {
+		t.Error("Enrich() modified the metadata table")
+	}
+
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{filter: "language=Go", want: []string{"1", "2"}},
+		{filter: "license!=MIT", want: []string{"1", "3"}},
+		{filter: "language=", want: []string{"3"}},
+	}
+
+	for _, tt := range tests {
+		f, err := github.ParseMetadataFilter(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := sortedKeys(rs.Where(f).M); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Where(%s) = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+
+	groups := rs.GroupBy("license")
+	if len(groups) != 2 || len(groups["MIT"].M) != 1 || len(groups[github.NoValue].M) != 2 {
+		t.Errorf("GroupBy() = %+v", groups)
+	}
+
+	for _, s := range []string{"language", "=Go", "!=Go"} {
+		if _, err := github.ParseMetadataFilter(s); !errors.Is(err, github.ErrWrongParam) {
+			t.Errorf("ParseMetadataFilter(%q) error = %v, want %v", s, err, github.ErrWrongParam)
+		}
+	}
+}
+
+func sortedKeys(m map[string]github.Repo) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,260 @@
+package github
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// pseudonymBytes is length of HMAC digests in pseudonyms, 64 bits make collisions unlikely even among
+// hundreds of millions of users.
+const pseudonymBytes = 8
+
+// Kinds of pseudonymized values. They are hashed with the value, so equal IDs of a user and a repository
+// get different pseudonyms.
+const (
+	actorIDKind  = "actor-id"
+	usernameKind = "username"
+	repoIDKind   = "repo-id"
+	repoNameKind = "repo-name"
+	ownerKind    = "owner"
+	eventIDKind  = "event-id"
+	shaKind      = "sha"
+)
+
+// Pseudonymizer replaces actor IDs, usernames, repository IDs and names with keyed HMAC pseudonyms.
+// The same key always gives the same pseudonyms, so outputs of different runs can be compared,
+// while pseudonyms can't be reversed without the key or the lookup.
+// Bot usernames keep their [bot] suffix, so bots are still told apart from users.
+type Pseudonymizer struct {
+	key       []byte
+	keepOwner bool
+
+	mu sync.Mutex
+	// lookup has original values by pseudonyms, it is nil if lookup is not recorded
+	lookup map[string]lookupEntry
+}
+
+type lookupEntry struct {
+	kind     string
+	original string
+}
+
+// NewPseudonymizer returns a pseudonymizer with the key. If keepOwner is set, owners of repository names
+// are kept as they are and only the rest of the name is replaced. If recordLookup is set, every pseudonym
+// is recorded with its original value for WriteLookup.
+func NewPseudonymizer(key []byte, keepOwner, recordLookup bool) (*Pseudonymizer, error) {
+	if len(key) == 0 {
+		return nil, errors.Wrap(ErrWrongParam, "pseudonymization key is empty")
+	}
+
+	p := Pseudonymizer{key: key, keepOwner: keepOwner}
+
+	if recordLookup {
+		p.lookup = make(map[string]lookupEntry)
+	}
+
+	return &p, nil
+}
+
+// pseudonym returns the pseudonym of the value with the prefix and records it in the lookup.
+// Empty values stay empty.
+func (p *Pseudonymizer) pseudonym(kind, prefix, value string) string {
+	pseudonym := p.digest(kind, prefix, value)
+
+	if p.lookup != nil && pseudonym != "" {
+		p.mu.Lock()
+		p.lookup[kind+"\x00"+pseudonym] = lookupEntry{kind: kind, original: value}
+		p.mu.Unlock()
+	}
+
+	return pseudonym
+}
+
+// digest returns the pseudonym of the value with the prefix without recording it. Empty values stay empty.
+func (p *Pseudonymizer) digest(kind, prefix, value string) string {
+	if value == "" {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, p.key)
+	_, _ = mac.Write([]byte(kind + "\x00" + value))
+
+	return prefix + hex.EncodeToString(mac.Sum(nil)[:pseudonymBytes])
+}
+
+// Fingerprint identifies the key and options of the pseudonymizer without revealing the key, so outputs
+// pseudonymized differently are told apart. It is empty for a nil pseudonymizer.
+func (p *Pseudonymizer) Fingerprint() string {
+	if p == nil {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, p.key)
+	_, _ = mac.Write([]byte("fingerprint\x00" + strconv.FormatBool(p.keepOwner)))
+
+	return hex.EncodeToString(mac.Sum(nil)[:pseudonymBytes])
+}
+
+// ActorID returns the pseudonym of the actor ID.
+func (p *Pseudonymizer) ActorID(id string) string {
+	return p.pseudonym(actorIDKind, "a", id)
+}
+
+// Username returns the pseudonym of the username.
+func (p *Pseudonymizer) Username(username string) string {
+	pseudonym := p.pseudonym(usernameKind, "user-", username)
+	if IsBotUsername(username) {
+		pseudonym += "[bot]"
+	}
+
+	return pseudonym
+}
+
+// RepoID returns the pseudonym of the repository ID.
+func (p *Pseudonymizer) RepoID(id string) string {
+	return p.pseudonym(repoIDKind, "r", id)
+}
+
+// RepoName returns the pseudonym of the repository name, owner/repo-<digest>. The owner is a pseudonym too
+// unless owners are kept; repositories of one owner always get the same owner.
+func (p *Pseudonymizer) RepoName(name string) string {
+	if name == "" {
+		return ""
+	}
+
+	owner := RepoOwner(name)
+	if !p.keepOwner {
+		owner = p.pseudonym(ownerKind, "owner-", owner)
+	}
+
+	return owner + "/" + p.pseudonym(repoNameKind, "repo-", name)
+}
+
+// EventID returns the pseudonym of the event ID. Event IDs are public, so the original event, with its actor
+// and repository, could be looked up by them. They are not recorded in the lookup, which is meant for
+// users and repositories.
+func (p *Pseudonymizer) EventID(id string) string {
+	return p.digest(eventIDKind, "e", id)
+}
+
+// CommitSHA returns the pseudonym of the commit SHA, which could be looked up on GitHub as well as event IDs.
+// It is not recorded in the lookup either.
+func (p *Pseudonymizer) CommitSHA(sha string) string {
+	return p.digest(shaKind, "c", sha)
+}
+
+// Dataset replaces IDs, usernames and names of the dataset with pseudonyms in place.
+func (p *Pseudonymizer) Dataset(ds *Dataset) {
+	for i := range ds.Actors.IDs {
+		ds.Actors.IDs[i] = p.ActorID(ds.Actors.IDs[i])
+		ds.Actors.Usernames[i] = p.Username(ds.Actors.Usernames[i])
+	}
+
+	for i := range ds.Repos.IDs {
+		ds.Repos.IDs[i] = p.RepoID(ds.Repos.IDs[i])
+		ds.Repos.Names[i] = p.RepoName(ds.Repos.Names[i])
+	}
+}
+
+// Archive returns a copy of the archive with pseudonyms. Event IDs and commit SHAs are pseudonymized too,
+// commits keep pointing at their push events. Commit messages are dropped, since they often mention usernames
+// and repositories, e.g. in merges of pull requests.
+func (p *Pseudonymizer) Archive(a *Archive) *Archive {
+	pseudonymized := Archive{
+		Actors:  make([]ActorCSV, len(a.Actors)),
+		Repos:   make([]RepoCSV, len(a.Repos)),
+		Events:  make([]EventCSV, len(a.Events)),
+		Commits: make([]CommitCSV, len(a.Commits)),
+	}
+
+	for i, actor := range a.Actors {
+		pseudonymized.Actors[i] = ActorCSV{ID: p.ActorID(actor.ID), Username: p.Username(actor.Username)}
+	}
+
+	for i, r := range a.Repos {
+		pseudonymized.Repos[i] = RepoCSV{ID: p.RepoID(r.ID), Name: p.RepoName(r.Name)}
+	}
+
+	for i, e := range a.Events {
+		e.ID, e.ActorID, e.RepoID = p.EventID(e.ID), p.ActorID(e.ActorID), p.RepoID(e.RepoID)
+		pseudonymized.Events[i] = e
+	}
+
+	for i, c := range a.Commits {
+		pseudonymized.Commits[i] = CommitCSV{SHA: p.CommitSHA(c.SHA), EventID: p.EventID(c.EventID)}
+	}
+
+	return &pseudonymized
+}
+
+// MetadataTable returns a copy of the metadata table of users or repositories keyed by pseudonyms,
+// so it is joined onto pseudonymized samples.
+func (p *Pseudonymizer) MetadataTable(t *MetadataTable, users bool) *MetadataTable {
+	pseudonym := p.RepoName
+
+	switch {
+	case users && t.Key == IDColumn:
+		pseudonym = p.ActorID
+	case users:
+		pseudonym = p.Username
+	case t.Key == IDColumn:
+		pseudonym = p.RepoID
+	}
+
+	pseudonymized := MetadataTable{Key: t.Key, Columns: t.Columns, Rows: make(map[string]Metadata, len(t.Rows))}
+	for key, row := range t.Rows {
+		pseudonymized.Rows[pseudonym(key)] = row
+	}
+
+	return &pseudonymized
+}
+
+// WriteLookup writes the recorded pseudonyms with their original values as CSV with a header,
+// sorted by kind and pseudonym. It fails if lookup is not recorded.
+func (p *Pseudonymizer) WriteLookup(w io.Writer) error {
+	if p.lookup == nil {
+		return errors.Wrap(ErrWrongParam, "pseudonymizer doesn't record lookup")
+	}
+
+	p.mu.Lock()
+
+	keys := make([]string, 0, len(p.lookup))
+	for key := range p.lookup {
+		keys = append(keys, key)
+	}
+
+	entries := make([]lookupEntry, len(keys))
+
+	sort.Strings(keys)
+
+	for i, key := range keys {
+		entries[i] = p.lookup[key]
+	}
+
+	p.mu.Unlock()
+
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write([]string{"kind", "pseudonym", "original"}); err != nil {
+		return err
+	}
+
+	for i, e := range entries {
+		if err := cw.Write([]string{e.kind, keys[i][strings.IndexByte(keys[i], 0)+1:], e.original}); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
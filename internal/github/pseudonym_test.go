@@ -0,0 +1,169 @@
+package github_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/samples"
+)
+
+func TestPseudonymizer(t *testing.T) {
+	p := newPseudonymizer(t, "key", false, false)
+	again := newPseudonymizer(t, "key", false, false)
+	other := newPseudonymizer(t, "other key", false, false)
+
+	if p.Username("alice") != again.Username("alice") || p.RepoName("org/a") != again.RepoName("org/a") {
+		t.Error("pseudonyms of the same key differ")
+	}
+
+	if p.Username("alice") == other.Username("alice") || p.ActorID("1") == other.ActorID("1") {
+		t.Error("pseudonyms of different keys are the same")
+	}
+
+	if p.ActorID("1") == p.RepoID("1") {
+		t.Error("actor and repository with the same ID have the same pseudonym")
+	}
+
+	if got := p.Username("dependabot[bot]"); !github.IsBotUsername(got) || strings.Contains(got, "dependabot") {
+		t.Errorf("Username() of a bot = %q, want a pseudonym with [bot] suffix", got)
+	}
+
+	if p.Username("") != "" || p.RepoName("") != "" {
+		t.Error("pseudonyms of empty values are not empty")
+	}
+
+	a, b := p.RepoName("org/a"), p.RepoName("org/b")
+	if github.RepoOwner(a) != github.RepoOwner(b) || a == b || strings.Contains(a, "org") {
+		t.Errorf("RepoName() = %q and %q, want pseudonyms with the same owner", a, b)
+	}
+
+	kept := newPseudonymizer(t, "key", true, false).RepoName("org/a")
+	if github.RepoOwner(kept) != "org" || kept == "org/a" {
+		t.Errorf("RepoName() with owner kept = %q", kept)
+	}
+
+	if p.Fingerprint() != again.Fingerprint() || p.Fingerprint() == other.Fingerprint() ||
+		p.Fingerprint() == newPseudonymizer(t, "key", true, false).Fingerprint() {
+		t.Error("Fingerprint() doesn't tell keys and options apart")
+	}
+
+	if (*github.Pseudonymizer)(nil).Fingerprint() != "" {
+		t.Error("Fingerprint() of a nil pseudonymizer is not empty")
+	}
+
+	if _, err := github.NewPseudonymizer(nil, false, false); !errors.Is(err, github.ErrWrongParam) {
+		t.Errorf("NewPseudonymizer() error = %v, want %v", err, github.ErrWrongParam)
+	}
+}
+
+// TestPseudonymizer_Archive checks that a pseudonymized archive has the same dataset as the pseudonymized
+// dataset of the archive.
+func TestPseudonymizer_Archive(t *testing.T) {
+	archive := &github.Archive{
+		Actors: []github.ActorCSV{{ID: "1", Username: "alice"}, {ID: "2", Username: "ci[bot]"}},
+		Repos:  []github.RepoCSV{{ID: "1", Name: "org/a"}},
+		Events: []github.EventCSV{
+			{ID: "1", Type: github.PushEventType, ActorID: "1", RepoID: "1"},
+			{ID: "2", Type: github.PushEventType, ActorID: "2", RepoID: "1"},
+			{ID: "3", Type: github.WatchEventType, ActorID: "3", RepoID: "2"},
+		},
+		Commits: []github.CommitCSV{{SHA: "abc", Message: "Merge pull request #1 from alice/fix", EventID: "1"}},
+	}
+
+	p := newPseudonymizer(t, "key", false, true)
+	pseudonymized := p.Archive(archive)
+
+	if c := pseudonymized.Commits[0]; c.Message != "" || c.SHA != p.CommitSHA("abc") || c.EventID != pseudonymized.Events[0].ID {
+		t.Errorf("commit = %+v, want it pseudonymized without message", c)
+	}
+
+	if archive.Actors[0].Username != "alice" {
+		t.Error("Archive() modified the archive")
+	}
+
+	ds := archive.Dataset()
+	p.Dataset(ds)
+
+	for _, bots := range []bool{false, true} {
+		want, err := ds.UsersSample(context.Background(), bots)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := pseudonymized.Dataset().UsersSample(context.Background(), bots)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("UsersSample(%v) = %+v, want %+v", bots, got.M, want.M)
+		}
+	}
+
+	var lookup bytes.Buffer
+	if err := p.WriteLookup(&lookup); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "username," + p.Username("alice") + ",alice\n"; !strings.Contains(lookup.String(), want) {
+		t.Errorf("WriteLookup() = %q, want line %q", lookup.String(), want)
+	}
+
+	if err := newPseudonymizer(t, "key", false, false).WriteLookup(&lookup); !errors.Is(err, github.ErrWrongParam) {
+		t.Errorf("WriteLookup() error = %v, want %v", err, github.ErrWrongParam)
+	}
+}
+
+// TestPseudonymizer_Archive_PublicIDs checks that no event ID or commit SHA of the sample archive survives
+// pseudonymization, since they can be looked up in GH Archive or on GitHub.
+func TestPseudonymizer_Archive_PublicIDs(t *testing.T) {
+	archive, err := samples.Archive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pseudonymized := newPseudonymizer(t, "key", false, false).Archive(archive)
+
+	public := make(map[string]bool, len(archive.Events)+len(archive.Commits))
+
+	for _, e := range archive.Events {
+		public[e.ID] = true
+	}
+
+	for _, c := range archive.Commits {
+		public[c.SHA] = true
+	}
+
+	for _, e := range pseudonymized.Events {
+		if public[e.ID] {
+			t.Fatalf("event ID %s survived pseudonymization", e.ID)
+		}
+	}
+
+	for _, c := range pseudonymized.Commits {
+		if public[c.SHA] || public[c.EventID] {
+			t.Fatalf("commit %s of event %s survived pseudonymization", c.SHA, c.EventID)
+		}
+	}
+
+	if got, want := pseudonymized.RejectedRows(), archive.RejectedRows(); !reflect.DeepEqual(got, want) {
+		t.Errorf("RejectedRows() = %v, want %v of the archive, commits should keep their push events", got, want)
+	}
+}
+
+func newPseudonymizer(tb testing.TB, key string, keepOwner, recordLookup bool) *github.Pseudonymizer {
+	tb.Helper()
+
+	p, err := github.NewPseudonymizer([]byte(key), keepOwner, recordLookup)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return p
+}
@@ -18,6 +18,8 @@ type Repo struct {
 	Name          string
 	CommitsPushed int
 	WatchEvents   int
+	// Meta is joined from metadata files, it is nil if nothing is joined.
+	Meta Metadata `json:",omitempty"`
 }
 
 // ReposSample is GitHub repositories collection sample used for getting analytics reposts
@@ -25,25 +27,93 @@ type ReposSample struct {
 	M map[string]Repo
 }
 
-// NewReposSample returns a new ReposSample
+// NewReposSample returns a new ReposSample.
+// Rows are aggregated as they are, without building a Dataset, and the result is the same as ReposSample
+// of the dataset of the rows: repositories which are not listed in the repos CSV are included only if they have
+// commits or watch events.
 func NewReposSample(events []EventCSV, repoCSVs []RepoCSV, commits []CommitCSV) *ReposSample {
 	repos := ReposSample{
 		M: make(map[string]Repo),
 	}
 
-	// set data about repository
-	for _, repoCSV := range repoCSVs {
-		repos.M[repoCSV.ID] = Repo{
-			ID:   repoCSV.ID,
-			Name: repoCSV.Name,
-		}
+	// the last name of a repository wins, the same way as in NewDataset
+	for _, r := range repoCSVs {
+		repos.M[r.ID] = Repo{ID: r.ID, Name: r.Name}
 	}
 
-	repos.setAnalyticsData(events, commits)
+	commitsByPushEventID := newCommitsByPushEventID(events, commits)
+
+	for i := range events {
+		e := &events[i]
+
+		var commitsPushed, watchEvents int
+
+		switch e.Type {
+		case PushEventType:
+			commitsPushed = commitsByPushEventID[e.ID]
+		case WatchEventType:
+			watchEvents = 1
+		}
+
+		if commitsPushed == 0 && watchEvents == 0 {
+			continue
+		}
+
+		r := repos.M[e.RepoID]
+		r.ID = e.RepoID
+		r.CommitsPushed += commitsPushed
+		r.WatchEvents += watchEvents
+		repos.M[e.RepoID] = r
+	}
 
 	return &repos
 }
 
+// newCommitsByPushEventID returns amounts of commits of push events by their IDs. Commits which don't belong
+// to any push event are not counted, and push events sharing an ID get the same commits, like in NewDataset.
+func newCommitsByPushEventID(events []EventCSV, commits []CommitCSV) map[string]int {
+	commitsByPushEventID := make(map[string]int)
+
+	for i := range events {
+		if events[i].Type == PushEventType {
+			commitsByPushEventID[events[i].ID] = 0
+		}
+	}
+
+	for i := range commits {
+		if n, ok := commitsByPushEventID[commits[i].EventID]; ok {
+			commitsByPushEventID[commits[i].EventID] = n + 1
+		}
+	}
+
+	return commitsByPushEventID
+}
+
+// Merge adds counts of repositories of other to the repositories, as if both samples were built from one archive.
+// A non-empty name of other wins, the same way as the last listed name wins in an archive.
+func (rs *ReposSample) Merge(other *ReposSample) {
+	if rs.M == nil {
+		rs.M = make(map[string]Repo, len(other.M))
+	}
+
+	for id, r := range other.M {
+		merged, ok := rs.M[id]
+		if !ok {
+			rs.M[id] = r
+
+			continue
+		}
+
+		if r.Name != "" {
+			merged.Name = r.Name
+		}
+
+		merged.CommitsPushed += r.CommitsPushed
+		merged.WatchEvents += r.WatchEvents
+		rs.M[id] = merged
+	}
+}
+
 // TopNByCommitsPushed returns top N repositories sorted by amount of commits pushed.
 func (rs *ReposSample) TopNByCommitsPushed(n int) ([]Repo, error) {
 	if n < 1 {
@@ -113,26 +183,3 @@ func (rs *ReposSample) TopNByWatchEvents(n int) ([]Repo, error) {
 
 	return sortedRepos[0:last], nil
 }
-
-func (rs *ReposSample) setAnalyticsData(events []EventCSV, commits []CommitCSV) {
-	numCommitsByPushEventID := newNumCommitsByPushEventID(commits)
-
-	for _, e := range events {
-		switch e.Type {
-		case PushEventType:
-			count := numCommitsByPushEventID[e.ID]
-			if count <= 0 {
-				continue
-			}
-
-			r := rs.M[e.RepoID]
-			r.CommitsPushed += count
-			rs.M[e.RepoID] = r
-
-		case WatchEventType:
-			r := rs.M[e.RepoID]
-			r.WatchEvents++
-			rs.M[e.RepoID] = r
-		}
-	}
-}
@@ -1,6 +1,7 @@
 package github_test
 
 import (
+	"context"
 	"reflect"
 	"testing"
 
@@ -416,7 +417,7 @@ func BenchmarkReposSample_TopNByCommitsPushed(b *testing.B) {
 	}()
 
 	var events []github.EventCSV
-	if err := csvtargz.DecodeFromFile(gzFile, github.EventsCSVFilename, &events); err != nil {
+	if err := csvtargz.DecodeFromFile(context.Background(), gzFile, github.EventsCSVFilename, &events); err != nil {
 		b.Fatal(err)
 	}
 
@@ -428,7 +429,7 @@ func BenchmarkReposSample_TopNByCommitsPushed(b *testing.B) {
 	}
 
 	var commits []github.CommitCSV
-	if err := csvtargz.DecodeFromFile(gzFile, github.CommitsCSVFilename, &commits); err != nil {
+	if err := csvtargz.DecodeFromFile(context.Background(), gzFile, github.CommitsCSVFilename, &commits); err != nil {
 		b.Fatal(err)
 	}
 
@@ -440,7 +441,7 @@ func BenchmarkReposSample_TopNByCommitsPushed(b *testing.B) {
 	}
 
 	var repoCSVs []github.RepoCSV
-	if err := csvtargz.DecodeFromFile(gzFile, github.ReposCSVFilename, &repoCSVs); err != nil {
+	if err := csvtargz.DecodeFromFile(context.Background(), gzFile, github.ReposCSVFilename, &repoCSVs); err != nil {
 		b.Fatal(err)
 	}
 
@@ -448,6 +449,9 @@ func BenchmarkReposSample_TopNByCommitsPushed(b *testing.B) {
 
 	n := 10
 
+	b.ReportAllocs()
+	b.ResetTimer()
+
 	for i := 0; i < b.N; i++ {
 		repos := github.NewReposSample(events, repoCSVs, commits)
 
@@ -471,7 +475,7 @@ func BenchmarkReposSample_TopNByWatchEvents(b *testing.B) {
 	}()
 
 	var events []github.EventCSV
-	if err := csvtargz.DecodeFromFile(gzFile, github.EventsCSVFilename, &events); err != nil {
+	if err := csvtargz.DecodeFromFile(context.Background(), gzFile, github.EventsCSVFilename, &events); err != nil {
 		b.Fatal(err)
 	}
 
@@ -483,7 +487,7 @@ func BenchmarkReposSample_TopNByWatchEvents(b *testing.B) {
 	}
 
 	var commits []github.CommitCSV
-	if err := csvtargz.DecodeFromFile(gzFile, github.CommitsCSVFilename, &commits); err != nil {
+	if err := csvtargz.DecodeFromFile(context.Background(), gzFile, github.CommitsCSVFilename, &commits); err != nil {
 		b.Fatal(err)
 	}
 
@@ -495,7 +499,7 @@ func BenchmarkReposSample_TopNByWatchEvents(b *testing.B) {
 	}
 
 	var repoCSVs []github.RepoCSV
-	if err := csvtargz.DecodeFromFile(gzFile, github.ReposCSVFilename, &repoCSVs); err != nil {
+	if err := csvtargz.DecodeFromFile(context.Background(), gzFile, github.ReposCSVFilename, &repoCSVs); err != nil {
 		b.Fatal(err)
 	}
 
@@ -503,6 +507,9 @@ func BenchmarkReposSample_TopNByWatchEvents(b *testing.B) {
 
 	n := 10
 
+	b.ReportAllocs()
+	b.ResetTimer()
+
 	for i := 0; i < b.N; i++ {
 		repos := github.NewReposSample(events, repoCSVs, commits)
 
@@ -512,3 +519,14 @@ func BenchmarkReposSample_TopNByWatchEvents(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkNewReposSample_100x(b *testing.B) {
+	a := generateArchive(b, 100)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_ = github.NewReposSample(a.Events, a.Repos, a.Commits)
+	}
+}
@@ -0,0 +1,312 @@
+package github
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Weights of signals in the suspicion score, they sum up to 1.
+const (
+	idleWeight      = 0.4
+	ringWeight      = 0.35
+	newOrBotWeight  = 0.25
+	suspiciousFlags = 2
+)
+
+// StarOptions configures detection of fake stars.
+type StarOptions struct {
+	// MinWatchers is the least amount of distinct watchers of a repository to score it, smaller ones score 0.
+	MinWatchers int
+	// MinRingSize is the least amount of accounts watching the same pair of repositories to make them a ring.
+	MinRingSize int
+	// NewAccountShare is the share of actors of the archive with the biggest numeric IDs which are new accounts.
+	// GitHub assigns IDs sequentially, so the biggest IDs belong to the most recently created accounts.
+	NewAccountShare float64
+}
+
+// DefaultStarOptions returns options of fake star detection.
+func DefaultStarOptions() StarOptions {
+	return StarOptions{MinWatchers: 5, MinRingSize: 3, NewAccountShare: 0.1}
+}
+
+// StarSuspicion is how likely watch events of a repository are bought or farmed. A watcher is suspicious
+// if at least two of its signals are raised: it is idle, it is in a ring, or it is a new or bot-like account.
+type StarSuspicion struct {
+	ID          string
+	Name        string
+	WatchEvents int
+	// OrganicWatchEvents are watch events of watchers which are not suspicious.
+	OrganicWatchEvents int
+	// Watchers is amount of distinct watchers.
+	Watchers int
+	// IdleShare is share of watchers which did nothing but watch repositories in the archive.
+	IdleShare float64
+	// RingRepo is the name of the repository sharing the most watchers with this one, or empty if they are
+	// less than StarOptions.MinRingSize. RingShare is share of watchers which watched both.
+	RingRepo  string
+	RingShare float64
+	// NewOrBotShare is share of watchers which are new accounts or have bot-like usernames.
+	NewOrBotShare float64
+	// Score is the weighted sum of the shares, from 0 to 1. It is 0 for repositories with less than
+	// StarOptions.MinWatchers watchers.
+	Score float64
+}
+
+// StarSuspicionSample is a collection of repositories with watch events and suspicion of them.
+type StarSuspicionSample struct {
+	M map[string]*StarSuspicion
+}
+
+// watch is watch events of an actor in a repository.
+type watch struct {
+	repo   uint32
+	actor  uint32
+	events int
+}
+
+// StarSuspicionSample returns suspicion of watch events of repositories with watch events.
+// Aggregation stops with ctx.Err() as soon as ctx is done.
+func (d *Dataset) StarSuspicionSample(ctx context.Context, opts StarOptions) (*StarSuspicionSample, error) {
+	if opts.MinRingSize < 2 {
+		return nil, errors.Wrap(ErrWrongParam, "ring size should be at least 2")
+	}
+
+	if opts.NewAccountShare < 0 || opts.NewAccountShare > 1 {
+		return nil, errors.Wrap(ErrWrongParam, "share of new accounts should be between 0 and 1")
+	}
+
+	et := &d.Events
+	watchType := et.TypeIndex(WatchEventType)
+	active := make([]bool, len(d.Actors.IDs))
+	watchIdx := make(map[uint64]int)
+
+	var watches []watch
+
+	for i := 0; i < et.Len(); i++ {
+		if err := checkCtx(ctx, i); err != nil {
+			return nil, err
+		}
+
+		if int(et.Types[i]) != watchType {
+			active[et.Actors[i]] = true
+
+			continue
+		}
+
+		key := uint64(et.Repos[i])<<32 | uint64(et.Actors[i])
+
+		j, ok := watchIdx[key]
+		if !ok {
+			j = len(watches)
+			watchIdx[key] = j
+			watches = append(watches, watch{repo: et.Repos[i], actor: et.Actors[i]})
+		}
+
+		watches[j].events++
+	}
+
+	sort.Slice(watches, func(i, j int) bool {
+		if watches[i].repo != watches[j].repo {
+			return watches[i].repo < watches[j].repo
+		}
+
+		return watches[i].actor < watches[j].actor
+	})
+
+	repoWatches := starts(len(d.Repos.IDs), len(watches), func(i int) uint32 { return watches[i].repo })
+	watchedBy := make([][]uint32, len(d.Actors.IDs))
+
+	for _, w := range watches {
+		watchedBy[w.actor] = append(watchedBy[w.actor], w.repo)
+	}
+
+	newOrBot := d.newOrBotActors(opts.NewAccountShare)
+	ss := StarSuspicionSample{M: make(map[string]*StarSuspicion)}
+
+	for repo := range d.Repos.IDs {
+		if err := checkCtx(ctx, repo); err != nil {
+			return nil, err
+		}
+
+		ws := watches[repoWatches[repo]:repoWatches[repo+1]]
+		if len(ws) == 0 {
+			continue
+		}
+
+		s := d.starSuspicion(uint32(repo), ws, watchedBy, active, newOrBot, opts)
+		ss.M[s.ID] = s
+	}
+
+	return &ss, nil
+}
+
+func (d *Dataset) starSuspicion(
+	repo uint32, ws []watch, watchedBy [][]uint32, active, newOrBot []bool, opts StarOptions,
+) *StarSuspicion {
+	s := StarSuspicion{ID: d.Repos.IDs[repo], Name: d.Repos.Names[repo], Watchers: len(ws)}
+
+	ringRepo, ringSize := d.ringRepo(repo, ws, watchedBy)
+	inRing := ringSize >= opts.MinRingSize
+
+	if inRing {
+		s.RingRepo = d.Repos.Names[ringRepo]
+	}
+
+	var idle, ring, newOrBots int
+
+	for _, w := range ws {
+		s.WatchEvents += w.events
+		flags := 0
+
+		if !active[w.actor] {
+			idle++
+			flags++
+		}
+
+		if inRing && watched(watchedBy[w.actor], ringRepo) {
+			ring++
+			flags++
+		}
+
+		if newOrBot[w.actor] {
+			newOrBots++
+			flags++
+		}
+
+		if flags < suspiciousFlags {
+			s.OrganicWatchEvents += w.events
+		}
+	}
+
+	s.IdleShare = float64(idle) / float64(s.Watchers)
+	s.RingShare = float64(ring) / float64(s.Watchers)
+	s.NewOrBotShare = float64(newOrBots) / float64(s.Watchers)
+
+	if s.Watchers >= opts.MinWatchers {
+		s.Score = idleWeight*s.IdleShare + ringWeight*s.RingShare + newOrBotWeight*s.NewOrBotShare
+	}
+
+	return &s
+}
+
+// ringRepo returns the repository sharing the most watchers with the repository and amount of them.
+// Ties go to the repository with the smaller index, so results don't depend on map order.
+func (d *Dataset) ringRepo(repo uint32, ws []watch, watchedBy [][]uint32) (uint32, int) {
+	shared := make(map[uint32]int)
+
+	for _, w := range ws {
+		for _, other := range watchedBy[w.actor] {
+			if other != repo {
+				shared[other]++
+			}
+		}
+	}
+
+	var (
+		best     uint32
+		bestSize int
+	)
+
+	for other, size := range shared {
+		if size > bestSize || (size == bestSize && other < best) {
+			best, bestSize = other, size
+		}
+	}
+
+	return best, bestSize
+}
+
+// watched reports whether the sorted repositories contain the repository.
+func watched(repos []uint32, repo uint32) bool {
+	i := sort.Search(len(repos), func(i int) bool { return repos[i] >= repo })
+
+	return i < len(repos) && repos[i] == repo
+}
+
+// newOrBotActors returns which actors are new accounts, the share of actors with the biggest numeric IDs,
+// or have bot-like usernames. Actors with IDs which are not numbers are never new.
+func (d *Dataset) newOrBotActors(newAccountShare float64) []bool {
+	ids := make([]uint64, len(d.Actors.IDs))
+	numeric := make([]uint64, 0, len(d.Actors.IDs))
+
+	for i, id := range d.Actors.IDs {
+		if n, err := strconv.ParseUint(id, 10, 64); err == nil {
+			ids[i] = n
+			numeric = append(numeric, n)
+		}
+	}
+
+	sort.Slice(numeric, func(i, j int) bool { return numeric[i] < numeric[j] })
+
+	newCount := int(float64(len(numeric)) * newAccountShare)
+	// IDs are never 0, so nothing is new if the threshold is above all of them
+	threshold := ^uint64(0)
+
+	if newCount > 0 {
+		threshold = numeric[len(numeric)-newCount]
+	}
+
+	newOrBot := make([]bool, len(d.Actors.IDs))
+
+	for i := range d.Actors.IDs {
+		newOrBot[i] = (ids[i] != 0 && ids[i] >= threshold) || IsBotUsername(d.Actors.Usernames[i])
+	}
+
+	return newOrBot
+}
+
+// TopNBySuspicion returns top N repositories with the most suspicious watch events, among repositories with
+// a score above 0. Ties are sorted by watch events descending, then by name and ID.
+func (ss *StarSuspicionSample) TopNBySuspicion(n int) ([]*StarSuspicion, error) {
+	if n < 1 {
+		return nil, errors.Wrap(ErrWrongParam, "n should be at least 1")
+	}
+
+	repos := make([]*StarSuspicion, 0, len(ss.M))
+
+	for _, s := range ss.M {
+		if s.Score > 0 {
+			repos = append(repos, s)
+		}
+	}
+
+	sort.Slice(repos, func(i, j int) bool {
+		if repos[i].Score != repos[j].Score {
+			return repos[i].Score > repos[j].Score
+		}
+
+		if repos[i].WatchEvents != repos[j].WatchEvents {
+			return repos[i].WatchEvents > repos[j].WatchEvents
+		}
+
+		if repos[i].Name != repos[j].Name {
+			return repos[i].Name < repos[j].Name
+		}
+
+		return repos[i].ID < repos[j].ID
+	})
+
+	if len(repos) > n {
+		repos = repos[:n]
+	}
+
+	return repos, nil
+}
+
+// Organic returns a copy of the repositories with only organic watch events counted.
+func (ss *StarSuspicionSample) Organic(rs *ReposSample) *ReposSample {
+	organic := ReposSample{M: make(map[string]Repo, len(rs.M))}
+
+	for id, r := range rs.M {
+		if s, ok := ss.M[id]; ok {
+			r.WatchEvents = s.OrganicWatchEvents
+		}
+
+		organic.M[id] = r
+	}
+
+	return &organic
+}
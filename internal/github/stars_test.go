@@ -0,0 +1,96 @@
+package github_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+func TestDataset_StarSuspicionSample(t *testing.T) {
+	actors := []github.ActorCSV{
+		{ID: "1", Username: "alice"}, {ID: "2", Username: "bob"}, {ID: "3", Username: "carol"},
+		{ID: "4", Username: "dave"}, {ID: "5", Username: "eve"},
+		{ID: "1001", Username: "farm1"}, {ID: "1002", Username: "farm2"}, {ID: "1003", Username: "farm3"},
+		{ID: "1004", Username: "farm4"}, {ID: "6", Username: "star-bot"},
+	}
+	repoCSVs := []github.RepoCSV{
+		{ID: "1", Name: "org/popular"}, {ID: "2", Name: "junk/one"}, {ID: "3", Name: "junk/two"}, {ID: "4", Name: "org/small"},
+	}
+
+	var events []github.EventCSV
+
+	add := func(typ, actor, repo string) {
+		events = append(events, github.EventCSV{ID: actor + repo + typ, Type: typ, ActorID: actor, RepoID: repo})
+	}
+
+	// active users watch the popular repository, and alice watches it twice
+	for _, actor := range []string{"1", "2", "3", "4", "5"} {
+		add(github.PushEventType, actor, "4")
+		add(github.WatchEventType, actor, "1")
+	}
+
+	events = append(events, github.EventCSV{ID: "again", Type: github.WatchEventType, ActorID: "1", RepoID: "1"})
+
+	// idle accounts of a farm watch both junk repositories, with a bot and one real user
+	for _, actor := range []string{"1001", "1002", "1003", "1004", "6"} {
+		add(github.WatchEventType, actor, "2")
+		add(github.WatchEventType, actor, "3")
+	}
+
+	add(github.WatchEventType, "1", "2")
+
+	opts := github.DefaultStarOptions()
+	opts.NewAccountShare = 0.4
+
+	ss, err := github.NewDataset(actors, repoCSVs, events, nil).StarSuspicionSample(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	popular := ss.M["1"]
+	if popular.Watchers != 5 || popular.WatchEvents != 6 || popular.OrganicWatchEvents != 6 || popular.RingRepo != "" {
+		t.Errorf("popular repo = %+v, want 5 watchers and 6 organic watch events", popular)
+	}
+
+	// alice shares the popular repository with nobody, farm accounts are idle, new and in the ring
+	junk := ss.M["2"]
+	if junk.Watchers != 6 || junk.WatchEvents != 6 || junk.OrganicWatchEvents != 1 || junk.RingRepo != "junk/two" {
+		t.Errorf("junk repo = %+v, want 6 watchers, 1 organic watch event and ring with junk/two", junk)
+	}
+
+	if junk.Score <= popular.Score || ss.M["3"].Score <= popular.Score {
+		t.Errorf("junk repos scored %v and %v, the popular one %v", junk.Score, ss.M["3"].Score, popular.Score)
+	}
+
+	top, err := ss.TopNBySuspicion(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := []string{top[0].Name, top[1].Name}; !reflect.DeepEqual(got, []string{"junk/two", "junk/one"}) {
+		t.Errorf("TopNBySuspicion() = %v", got)
+	}
+
+	rs, err := github.NewDataset(actors, repoCSVs, events, nil).ReposSample(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	organic := ss.Organic(rs)
+	if organic.M["1"].WatchEvents != 6 || organic.M["2"].WatchEvents != 1 || organic.M["3"].WatchEvents != 0 {
+		t.Errorf("Organic() = %+v", organic.M)
+	}
+
+	if rs.M["2"].WatchEvents != 6 {
+		t.Error("Organic() modified the sample")
+	}
+
+	opts.MinRingSize = 1
+	if _, err := github.NewDataset(actors, repoCSVs, events, nil).StarSuspicionSample(context.Background(), opts); !errors.Is(err, github.ErrWrongParam) {
+		t.Errorf("StarSuspicionSample() error = %v, want %v", err, github.ErrWrongParam)
+	}
+}
@@ -0,0 +1,193 @@
+package github
+
+import (
+	"context"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// Stats describes distributions of activity among users and repositories.
+type Stats struct {
+	Users EntityStats `json:"users"`
+	Repos EntityStats `json:"repos"`
+}
+
+// EntityStats describes distributions of metrics among users or repositories.
+type EntityStats struct {
+	// Count is amount of users or repositories.
+	Count         int          `json:"count"`
+	CommitsPushed Distribution `json:"commits_pushed"`
+	PullRequests  Distribution `json:"pull_requests"`
+	WatchEvents   Distribution `json:"watch_events"`
+}
+
+// Distribution summarizes values of a metric, one value per user or repository.
+type Distribution struct {
+	Sum    int     `json:"sum"`
+	Mean   float64 `json:"mean"`
+	Median int     `json:"median"`
+	P90    int     `json:"p90"`
+	P99    int     `json:"p99"`
+	Max    int     `json:"max"`
+	// Gini is the Gini coefficient: 0 if everyone has the same value, close to 1 if one has everything.
+	Gini float64 `json:"gini"`
+	// Top1PercentShare is share of the sum that belongs to the top 1% of users or repositories.
+	Top1PercentShare float64 `json:"top_1_percent_share"`
+	// Histogram has log-scale buckets: the first one counts zeros and the k-th one counts values
+	// from 2^(k-1) to 2^k-1. Trailing empty buckets are omitted.
+	Histogram []Bucket `json:"histogram"`
+}
+
+// Bucket is a histogram bucket of values from Min to Max inclusive.
+type Bucket struct {
+	Min   int `json:"min"`
+	Max   int `json:"max"`
+	Count int `json:"count"`
+}
+
+// NewDistribution returns distribution of values. Values should not be negative.
+func NewDistribution(values []int) Distribution {
+	var dist Distribution
+
+	if len(values) == 0 {
+		return dist
+	}
+
+	sorted := make([]int, len(values))
+	copy(sorted, values)
+	sort.Ints(sorted)
+
+	// weighted sum for the Gini coefficient
+	var weighted float64
+
+	for i, v := range sorted {
+		dist.Sum += v
+		weighted += float64(i+1) * float64(v)
+
+		b := bucketIndex(v)
+		for len(dist.Histogram) <= b {
+			dist.Histogram = append(dist.Histogram, newBucket(len(dist.Histogram)))
+		}
+
+		dist.Histogram[b].Count++
+	}
+
+	n := len(sorted)
+	dist.Mean = float64(dist.Sum) / float64(n)
+	dist.Median = percentile(sorted, 50)
+	dist.P90 = percentile(sorted, 90)
+	dist.P99 = percentile(sorted, 99)
+	dist.Max = sorted[n-1]
+
+	if dist.Sum == 0 {
+		return dist
+	}
+
+	dist.Gini = 2*weighted/(float64(n)*float64(dist.Sum)) - float64(n+1)/float64(n)
+
+	var topSum int
+	for _, v := range sorted[n-int(math.Ceil(float64(n)/100)):] {
+		topSum += v
+	}
+
+	dist.Top1PercentShare = float64(topSum) / float64(dist.Sum)
+
+	return dist
+}
+
+// percentile returns the nearest-rank percentile p of sorted values.
+func percentile(sorted []int, p int) int {
+	rank := int(math.Ceil(float64(p) / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
+
+func bucketIndex(v int) int {
+	if v <= 0 {
+		return 0
+	}
+
+	return bits.Len(uint(v))
+}
+
+func newBucket(idx int) Bucket {
+	if idx == 0 {
+		return Bucket{}
+	}
+
+	return Bucket{Min: 1 << (idx - 1), Max: 1<<idx - 1}
+}
+
+// Stats returns distributions of commits pushed, pull requests created and watch events made by users and
+// happened in repositories. Users are the listed actors; bots with `botname[bot]` could be filtered out.
+// Repositories are all listed or referenced by events. Aggregation stops with ctx.Err() as soon as ctx is done.
+func (d *Dataset) Stats(ctx context.Context, botsIncluded bool) (*Stats, error) {
+	var actorCounts, repoCounts [3][]int
+
+	for m := range actorCounts {
+		actorCounts[m] = make([]int, len(d.Actors.IDs))
+		repoCounts[m] = make([]int, len(d.Repos.IDs))
+	}
+
+	et := &d.Events
+	pushType, prType, watchType := et.TypeIndex(PushEventType), et.TypeIndex(PullRequestEventType), et.TypeIndex(WatchEventType)
+
+	for i := 0; i < et.Len(); i++ {
+		if err := checkCtx(ctx, i); err != nil {
+			return nil, err
+		}
+
+		var m, v int
+
+		switch int(et.Types[i]) {
+		case pushType:
+			m, v = 0, int(et.Commits[i])
+		case prType:
+			m, v = 1, 1
+		case watchType:
+			m, v = 2, 1
+		default:
+			continue
+		}
+
+		actorCounts[m][et.Actors[i]] += v
+		repoCounts[m][et.Repos[i]] += v
+	}
+
+	// only listed actors that are not filtered out are users
+	users := make([]int, 0, d.Actors.Listed)
+
+	for i := 0; i < d.Actors.Listed; i++ {
+		if botsIncluded || !IsBotUsername(d.Actors.Usernames[i]) {
+			users = append(users, i)
+		}
+	}
+
+	for m := range actorCounts {
+		userCounts := make([]int, len(users))
+		for j, i := range users {
+			userCounts[j] = actorCounts[m][i]
+		}
+
+		actorCounts[m] = userCounts
+	}
+
+	return &Stats{
+		Users: NewEntityStats(actorCounts[0], actorCounts[1], actorCounts[2]),
+		Repos: NewEntityStats(repoCounts[0], repoCounts[1], repoCounts[2]),
+	}, nil
+}
+
+// NewEntityStats returns distributions of metrics, with one value of every metric per user or repository.
+func NewEntityStats(commitsPushed, pullRequests, watchEvents []int) EntityStats {
+	return EntityStats{
+		Count:         len(commitsPushed),
+		CommitsPushed: NewDistribution(commitsPushed),
+		PullRequests:  NewDistribution(pullRequests),
+		WatchEvents:   NewDistribution(watchEvents),
+	}
+}
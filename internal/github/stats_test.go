@@ -0,0 +1,100 @@
+package github_test
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/samples"
+)
+
+func TestNewDistribution(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []int
+		want   github.Distribution
+	}{
+		{
+			name:   "no values",
+			values: nil,
+			want:   github.Distribution{},
+		},
+		{
+			name:   "all zeros",
+			values: []int{0, 0},
+			want:   github.Distribution{Histogram: []github.Bucket{{Count: 2}}},
+		},
+		{
+			name:   "equal values",
+			values: []int{3, 3, 3, 3},
+			want: github.Distribution{
+				Sum: 12, Mean: 3, Median: 3, P90: 3, P99: 3, Max: 3, Top1PercentShare: 0.25,
+				Histogram: []github.Bucket{{}, {Min: 1, Max: 1}, {Min: 2, Max: 3, Count: 4}},
+			},
+		},
+		{
+			name:   "one has everything",
+			values: []int{0, 0, 0, 10},
+			want: github.Distribution{
+				Sum: 10, Mean: 2.5, Median: 0, P90: 10, P99: 10, Max: 10, Gini: 0.75, Top1PercentShare: 1,
+				Histogram: []github.Bucket{
+					{Count: 3}, {Min: 1, Max: 1}, {Min: 2, Max: 3}, {Min: 4, Max: 7}, {Min: 8, Max: 15, Count: 1},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := github.NewDistribution(tt.values); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewDistribution() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDataset_Stats(t *testing.T) {
+	archive, err := samples.Archive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ds := archive.Dataset()
+
+	stats, err := ds.Stats(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// totals are the same for users and repositories, since every event has both
+	if stats.Users.CommitsPushed.Sum != stats.Repos.CommitsPushed.Sum || stats.Users.WatchEvents.Sum != stats.Repos.WatchEvents.Sum {
+		t.Errorf("Stats() totals of users %+v and repos %+v differ", stats.Users, stats.Repos)
+	}
+
+	for _, dist := range []github.Distribution{stats.Users.CommitsPushed, stats.Repos.PullRequests, stats.Repos.WatchEvents} {
+		var count int
+		for _, b := range dist.Histogram {
+			count += b.Count
+		}
+
+		if dist.Median > dist.P90 || dist.P90 > dist.P99 || dist.P99 > dist.Max || math.IsNaN(dist.Gini) || dist.Gini < 0 || dist.Gini > 1 {
+			t.Errorf("Stats() distribution %+v is inconsistent", dist)
+		}
+
+		if count != stats.Repos.Count && count != stats.Users.Count {
+			t.Errorf("Stats() histogram counts %d values", count)
+		}
+	}
+
+	withoutBots, err := ds.Stats(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if withoutBots.Users.Count >= stats.Users.Count || withoutBots.Repos.Count != stats.Repos.Count {
+		t.Errorf("Stats() without bots counts %d users and %d repos", withoutBots.Users.Count, withoutBots.Repos.Count)
+	}
+}
@@ -0,0 +1,207 @@
+package github
+
+import (
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// RowReader reads rows of a CSV file one at a time. Read decodes the next row into dst, a pointer to a row
+// like EventCSV, and returns io.EOF after the last row.
+type RowReader interface {
+	Read(dst interface{}) error
+	Close() error
+}
+
+// OpenFunc opens a CSV file of an archive by its filename, e.g. EventsCSVFilename.
+type OpenFunc func(csvFilename string) (RowReader, error)
+
+// ArchiveStream aggregates an archive while its CSV files are read, without building a Dataset, so memory
+// doesn't grow with amount of events. Every aggregation reads the files it needs again.
+type ArchiveStream struct {
+	open            OpenFunc
+	rejectedCommits int
+}
+
+// NewArchiveStream returns a new ArchiveStream of the archive whose CSV files are opened by open.
+func NewArchiveStream(open OpenFunc) *ArchiveStream {
+	return &ArchiveStream{open: open}
+}
+
+// eachRow reads every row of the CSV file into dst and calls f after each of them.
+func (s *ArchiveStream) eachRow(ctx context.Context, csvFilename string, dst interface{}, f func() error) error {
+	r, err := s.open(csvFilename)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = r.Close()
+	}()
+
+	for i := 0; ; i++ {
+		if err := checkCtx(ctx, i); err != nil {
+			return err
+		}
+
+		err := r.Read(dst)
+		if errors.Is(err, io.EOF) {
+			return r.Close()
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := f(); err != nil {
+			return err
+		}
+	}
+}
+
+// commitLookahead is the most push events read ahead of commits while the push event of a commit is looked for.
+// Commits are listed in the order of their push events, so only a commit without a push event makes the join
+// read that far ahead.
+const commitLookahead = 1 << 14
+
+// pushEvent is a push event read ahead of commits, which may still get commits.
+type pushEvent struct {
+	e       EventCSV
+	commits int
+}
+
+// eachEvent calls f with every event and amount of its commits, which are counted only if withCommits is set.
+// Commits are joined to push events while both files are read, events may be passed to f out of order.
+// Commits should be listed in the order of their push events, the way GH Archive lists them; a commit whose
+// push event isn't found within commitLookahead push events is rejected, like a commit without a push event
+// in NewDataset.
+func (s *ArchiveStream) eachEvent(ctx context.Context, withCommits bool, f func(e *EventCSV, commits int)) error {
+	s.rejectedCommits = 0
+
+	if !withCommits {
+		var e EventCSV
+
+		return s.eachRow(ctx, EventsCSVFilename, &e, func() error {
+			f(&e, 0)
+
+			return nil
+		})
+	}
+
+	events, err := s.open(EventsCSVFilename)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = events.Close()
+	}()
+
+	var (
+		// pushes are push events read ahead of commits in the order of events, byID has the first one of an ID
+		pushes    []*pushEvent
+		byID      = make(map[string]*pushEvent)
+		eventsEOF bool
+		rows      int
+	)
+
+	// readEvent reads the next event, push events are queued and the others are passed to f at once
+	readEvent := func() error {
+		if err := checkCtx(ctx, rows); err != nil {
+			return err
+		}
+
+		rows++
+
+		var e EventCSV
+
+		err := events.Read(&e)
+		if errors.Is(err, io.EOF) {
+			eventsEOF = true
+
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if e.Type != PushEventType {
+			f(&e, 0)
+
+			return nil
+		}
+
+		p := &pushEvent{e: e}
+		pushes = append(pushes, p)
+
+		if _, ok := byID[e.ID]; !ok {
+			byID[e.ID] = p
+		}
+
+		return nil
+	}
+
+	// pop passes the first queued push event to f, it gets no more commits
+	pop := func() {
+		p := pushes[0]
+		pushes = pushes[1:]
+
+		if byID[p.e.ID] == p {
+			delete(byID, p.e.ID)
+		}
+
+		f(&p.e, p.commits)
+	}
+
+	var c CommitCSV
+
+	err = s.eachRow(ctx, CommitsCSVFilename, &c, func() error {
+		p, ok := byID[c.EventID]
+		for !ok && !eventsEOF && len(pushes) < commitLookahead {
+			if err := readEvent(); err != nil {
+				return err
+			}
+
+			p, ok = byID[c.EventID]
+		}
+
+		if !ok {
+			s.rejectedCommits++
+
+			return nil
+		}
+
+		// commits of earlier push events are over
+		for pushes[0] != p {
+			pop()
+		}
+
+		p.commits++
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for len(pushes) > 0 || !eventsEOF {
+		if len(pushes) > 0 {
+			pop()
+
+			continue
+		}
+
+		if err := readEvent(); err != nil {
+			return err
+		}
+	}
+
+	return events.Close()
+}
+
+// RejectedCommits returns amount of commits which were not joined to push events by the last aggregation.
+func (s *ArchiveStream) RejectedCommits() int {
+	return s.rejectedCommits
+}
@@ -0,0 +1,248 @@
+package github_test
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/pkg/csvtargz"
+	"github.com/levakin/analytics-software-engineer-assignment/samples"
+)
+
+// sampleStream returns stream of the sample archive.
+func sampleStream(tb testing.TB) *github.ArchiveStream {
+	tb.Helper()
+
+	return github.NewArchiveStream(func(csvFilename string) (github.RowReader, error) {
+		gzFile, err := samples.FS.Open(archivePath)
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := csvtargz.OpenFile(context.Background(), gzFile, csvFilename)
+		if err != nil {
+			return nil, err
+		}
+
+		return r, nil
+	})
+}
+
+// sliceReader reads rows of a slice.
+type sliceReader struct {
+	rows reflect.Value
+	i    int
+}
+
+func (r *sliceReader) Read(dst interface{}) error {
+	if r.i == r.rows.Len() {
+		return io.EOF
+	}
+
+	reflect.ValueOf(dst).Elem().Set(r.rows.Index(r.i))
+	r.i++
+
+	return nil
+}
+
+func (r *sliceReader) Close() error {
+	return nil
+}
+
+// rowsStream returns stream of an archive with the rows.
+func rowsStream(actors []github.ActorCSV, repoCSVs []github.RepoCSV, events []github.EventCSV, commits []github.CommitCSV) *github.ArchiveStream {
+	files := map[string]interface{}{
+		github.ActorsCSVFilename:  actors,
+		github.ReposCSVFilename:   repoCSVs,
+		github.EventsCSVFilename:  events,
+		github.CommitsCSVFilename: commits,
+	}
+
+	return github.NewArchiveStream(func(csvFilename string) (github.RowReader, error) {
+		return &sliceReader{rows: reflect.ValueOf(files[csvFilename])}, nil
+	})
+}
+
+func TestArchiveStream_Commits(t *testing.T) {
+	actors := []github.ActorCSV{{ID: "1", Username: "one"}, {ID: "2", Username: "two"}}
+	events := []github.EventCSV{
+		{ID: "1", Type: github.PushEventType, ActorID: "1", RepoID: "1"},
+		{ID: "2", Type: github.PullRequestEventType, ActorID: "2", RepoID: "1"},
+		{ID: "3", Type: github.PushEventType, ActorID: "2", RepoID: "1"},
+		{ID: "4", Type: github.PushEventType, ActorID: "1", RepoID: "1"},
+	}
+	commits := []github.CommitCSV{{SHA: "a", EventID: "1"}, {SHA: "b", EventID: "3"}, {SHA: "c", EventID: "3"}}
+	opts := github.DefaultApproxOptions()
+
+	stream := rowsStream(actors, nil, events, commits)
+
+	got, err := stream.ApproxTopNActiveUsers(context.Background(), 2, true, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []github.ApproxItem{{ID: "2", Name: "two", Count: 3}, {ID: "1", Name: "one", Count: 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ApproxTopNActiveUsers() = %+v, want %+v", got, want)
+	}
+
+	if got := stream.RejectedCommits(); got != 0 {
+		t.Errorf("RejectedCommits() = %d, want 0", got)
+	}
+
+	// commits of the first push event come after commits of a later one, so it is rejected
+	commits[0], commits[1] = commits[1], commits[0]
+	stream = rowsStream(actors, nil, events, commits)
+
+	got, err = stream.ApproxTopNActiveUsers(context.Background(), 2, true, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want = []github.ApproxItem{{ID: "2", Name: "two", Count: 3}}
+	if !reflect.DeepEqual(got, want) || stream.RejectedCommits() != 1 {
+		t.Errorf("ApproxTopNActiveUsers() of unordered commits = %+v with %d rejected commits, want %+v with 1",
+			got, stream.RejectedCommits(), want)
+	}
+}
+
+// TestArchiveStream_OrphanCommits checks that a commit without a push event is skipped the same way in exact and
+// approximate rankings.
+func TestArchiveStream_OrphanCommits(t *testing.T) {
+	actors := []github.ActorCSV{{ID: "1", Username: "one"}, {ID: "2", Username: "two"}}
+	repoCSVs := []github.RepoCSV{{ID: "1", Name: "one/repo"}, {ID: "2", Name: "two/repo"}}
+	events := []github.EventCSV{
+		{ID: "1", Type: github.PushEventType, ActorID: "1", RepoID: "1"},
+		{ID: "2", Type: github.PushEventType, ActorID: "2", RepoID: "2"},
+		{ID: "3", Type: github.PushEventType, ActorID: "2", RepoID: "2"},
+	}
+	commits := []github.CommitCSV{
+		{SHA: "a", EventID: "1"},
+		{SHA: "b", EventID: "nope"},
+		{SHA: "c", EventID: "2"},
+		{SHA: "d", EventID: "2"},
+		{SHA: "e", EventID: "3"},
+	}
+	stream := rowsStream(actors, repoCSVs, events, commits)
+
+	users, err := stream.ApproxTopNActiveUsers(context.Background(), 2, true, github.DefaultApproxOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := stream.RejectedCommits(); got != 1 {
+		t.Errorf("RejectedCommits() = %d, want 1", got)
+	}
+
+	repos, err := stream.ApproxTopNByCommitsPushed(context.Background(), 2, github.DefaultApproxOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exactUsers, err := github.NewUsersSample(actors, commits, events, true).TopNActiveUsers(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exactRepos, err := github.NewReposSample(events, repoCSVs, commits).TopNByCommitsPushed(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(users) != len(exactUsers) || len(repos) != len(exactRepos) {
+		t.Fatalf("approximate rankings = %+v and %+v, want %+v and %+v", users, repos, exactUsers, exactRepos)
+	}
+
+	for i, u := range exactUsers {
+		if users[i].ID != u.ID || users[i].Count != u.Activity.Total() {
+			t.Errorf("ApproxTopNActiveUsers()[%d] = %+v, want %+v", i, users[i], u)
+		}
+	}
+
+	for i, r := range exactRepos {
+		if repos[i].ID != r.ID || repos[i].Count != r.CommitsPushed {
+			t.Errorf("ApproxTopNByCommitsPushed()[%d] = %+v, want %+v", i, repos[i], r)
+		}
+	}
+}
+
+// generatedEvents reads watch events of a new repository each, with every tenth event in the same repository.
+// It records the largest heap seen while they are read.
+type generatedEvents struct {
+	n, i    int
+	maxHeap uint64
+}
+
+func (r *generatedEvents) Read(dst interface{}) error {
+	if r.i == r.n {
+		return io.EOF
+	}
+
+	if r.i%(1<<16) == 0 {
+		runtime.GC()
+
+		var m runtime.MemStats
+
+		runtime.ReadMemStats(&m)
+
+		if m.HeapAlloc > r.maxHeap {
+			r.maxHeap = m.HeapAlloc
+		}
+	}
+
+	repoID := strconv.Itoa(r.i)
+	if r.i%10 == 0 {
+		repoID = "heavy"
+	}
+
+	*dst.(*github.EventCSV) = github.EventCSV{ID: strconv.Itoa(r.i), Type: github.WatchEventType, ActorID: "1", RepoID: repoID}
+	r.i++
+
+	return nil
+}
+
+func (r *generatedEvents) Close() error {
+	return nil
+}
+
+// TestArchiveStream_ApproxTopN_Memory checks that memory of approximate rankings doesn't grow with amount
+// of repositories: a million of them would take more than the limit in a Dataset.
+func TestArchiveStream_ApproxTopN_Memory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping streaming of a million events in short mode")
+	}
+
+	const limit = 16 << 20
+
+	events := &generatedEvents{n: 1 << 20}
+	stream := github.NewArchiveStream(func(csvFilename string) (github.RowReader, error) {
+		if csvFilename == github.EventsCSVFilename {
+			return events, nil
+		}
+
+		return &sliceReader{rows: reflect.ValueOf([]github.RepoCSV(nil))}, nil
+	})
+
+	runtime.GC()
+
+	var before runtime.MemStats
+
+	runtime.ReadMemStats(&before)
+
+	top, err := stream.ApproxTopNByWatchEvents(context.Background(), 1, github.DefaultApproxOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(top) != 1 || top[0].ID != "heavy" {
+		t.Errorf("ApproxTopNByWatchEvents() = %+v, want the heavy repository", top)
+	}
+
+	if grown := int64(events.maxHeap) - int64(before.HeapAlloc); grown > limit {
+		t.Errorf("heap grew by %d bytes while streaming %d events, want at most %d", grown, events.n, limit)
+	}
+}
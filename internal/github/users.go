@@ -12,6 +12,8 @@ type User struct {
 	ID       string
 	Username string
 	Activity ActorActivity
+	// Meta is joined from metadata files, it is nil if nothing is joined.
+	Meta Metadata `json:",omitempty"`
 }
 
 // UsersSample represents users collection
@@ -21,24 +23,44 @@ type UsersSample struct {
 
 // NewUsersSample parses data and returns UsersSample collection.
 // Bots with `botname[bot]` are not humans and could be filtered out.
+// Rows are aggregated as they are, without building a Dataset, so only listed users and commits of push
+// events are kept. The result is the same as UsersSample of the dataset of the rows.
 func NewUsersSample(actors []ActorCSV, commits []CommitCSV, events []EventCSV, botsIncluded bool) *UsersSample {
 	users := UsersSample{
 		M: make(map[string]User),
 	}
 
-	numCommitsByPushEventID := newNumCommitsByPushEventID(commits)
-	actorActivityByActorID := newActorActivityByActorID(numCommitsByPushEventID, events)
+	// the last username of an actor wins, the same way as in NewDataset
+	for _, actor := range actors {
+		users.M[actor.ID] = User{ID: actor.ID, Username: actor.Username}
+	}
+
+	commitsByPushEventID := newCommitsByPushEventID(events, commits)
+
+	for i := range events {
+		user, ok := users.M[events[i].ActorID]
+		if !ok {
+			continue
+		}
 
-	for i := range actors {
-		// if username like dependabot[bot] skip
-		if !botsIncluded && isBotUsername(actors[i].Username) {
+		switch events[i].Type {
+		case PushEventType:
+			user.Activity.PushedCommits += commitsByPushEventID[events[i].ID]
+		case PullRequestEventType:
+			user.Activity.CreatedPullRequests++
+		default:
 			continue
 		}
 
-		users.M[actors[i].ID] = User{
-			ID:       actors[i].ID,
-			Username: actors[i].Username,
-			Activity: actorActivityByActorID[actors[i].ID],
+		users.M[user.ID] = user
+	}
+
+	if !botsIncluded {
+		for id, user := range users.M {
+			// if username like dependabot[bot] skip
+			if IsBotUsername(user.Username) {
+				delete(users.M, id)
+			}
 		}
 	}
 
@@ -81,8 +103,49 @@ func (us *UsersSample) TopNActiveUsers(n int) ([]User, error) {
 	return sortedUsers[0:last], nil
 }
 
+// Merge adds activity of users of other to the users, as if both samples were built from one archive.
+// A non-empty username of other wins, the same way as the last listed username wins in an archive.
+// Samples have only listed users, so merging is exact as long as archives list actors of all their events,
+// which they do.
+func (us *UsersSample) Merge(other *UsersSample) {
+	if us.M == nil {
+		us.M = make(map[string]User, len(other.M))
+	}
+
+	for id, u := range other.M {
+		merged, ok := us.M[id]
+		if !ok {
+			us.M[id] = u
+
+			continue
+		}
+
+		if u.Username != "" {
+			merged.Username = u.Username
+		}
+
+		merged.Activity.PushedCommits += u.Activity.PushedCommits
+		merged.Activity.CreatedPullRequests += u.Activity.CreatedPullRequests
+		us.M[id] = merged
+	}
+}
+
+// WithoutBots returns a sample of the users which are not bots.
+func (us *UsersSample) WithoutBots() *UsersSample {
+	humans := UsersSample{M: make(map[string]User, len(us.M))}
+
+	for id, u := range us.M {
+		if !IsBotUsername(u.Username) {
+			humans.M[id] = u
+		}
+	}
+
+	return &humans
+}
+
 var botUsernameRegex = regexp.MustCompile(`^.*\[bot]$`)
 
-func isBotUsername(username string) bool {
+// IsBotUsername reports whether the username belongs to a bot, like dependabot[bot].
+func IsBotUsername(username string) bool {
 	return botUsernameRegex.MatchString(username)
 }
@@ -1,6 +1,7 @@
 package github_test
 
 import (
+	"context"
 	"reflect"
 	"testing"
 
@@ -291,7 +292,7 @@ func BenchmarkUsersSample_TopNActiveUsers(b *testing.B) {
 	}()
 
 	var events []github.EventCSV
-	if err := csvtargz.DecodeFromFile(gzFile, github.EventsCSVFilename, &events); err != nil {
+	if err := csvtargz.DecodeFromFile(context.Background(), gzFile, github.EventsCSVFilename, &events); err != nil {
 		b.Fatal(err)
 	}
 
@@ -303,7 +304,7 @@ func BenchmarkUsersSample_TopNActiveUsers(b *testing.B) {
 	}
 
 	var commits []github.CommitCSV
-	if err := csvtargz.DecodeFromFile(gzFile, github.CommitsCSVFilename, &commits); err != nil {
+	if err := csvtargz.DecodeFromFile(context.Background(), gzFile, github.CommitsCSVFilename, &commits); err != nil {
 		b.Fatal(err)
 	}
 
@@ -315,7 +316,7 @@ func BenchmarkUsersSample_TopNActiveUsers(b *testing.B) {
 	}
 
 	var actors []github.ActorCSV
-	if err := csvtargz.DecodeFromFile(gzFile, github.ActorsCSVFilename, &actors); err != nil {
+	if err := csvtargz.DecodeFromFile(context.Background(), gzFile, github.ActorsCSVFilename, &actors); err != nil {
 		b.Fatal(err)
 	}
 
@@ -323,6 +324,9 @@ func BenchmarkUsersSample_TopNActiveUsers(b *testing.B) {
 
 	n := 10
 
+	b.ReportAllocs()
+	b.ResetTimer()
+
 	for i := 0; i < b.N; i++ {
 		users := github.NewUsersSample(actors, commits, events, false)
 
@@ -332,3 +336,14 @@ func BenchmarkUsersSample_TopNActiveUsers(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkNewUsersSample_100x(b *testing.B) {
+	a := generateArchive(b, 100)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_ = github.NewUsersSample(a.Actors, a.Commits, a.Events, false)
+	}
+}
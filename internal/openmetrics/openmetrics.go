@@ -0,0 +1,150 @@
+// Package openmetrics writes metrics in the OpenMetrics text format, which Prometheus scrapes and reads
+// from files. Only gauges and counters without timestamps are supported, that's all the exporter needs.
+package openmetrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ContentType is the HTTP content type of the OpenMetrics text format.
+const ContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// ErrWrongName is returned if a metric or label name is not valid.
+var ErrWrongName = errors.New("wrong metric or label name")
+
+// Type is a type of metric family.
+type Type string
+
+// Supported types of metric families.
+const (
+	Gauge   Type = "gauge"
+	Counter Type = "counter"
+)
+
+var (
+	metricNameRe = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRe  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// Family is a metric family: metrics with the same name and meaning, which differ by labels.
+type Family struct {
+	// Name of the family. Samples of counters get the _total suffix, so it shouldn't be a part of Name.
+	Name string
+	Type Type
+	// Unit is optional, if it is set, Name must end with _<unit>.
+	Unit    string
+	Help    string
+	Samples []Sample
+}
+
+// Sample is a value of the family with a set of labels.
+type Sample struct {
+	Labels []Label
+	Value  float64
+}
+
+// Label is a name-value pair identifying a sample.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Write writes the families followed by the terminating # EOF line.
+func Write(w io.Writer, families []Family) error {
+	bw := bufio.NewWriter(w)
+
+	for _, f := range families {
+		if err := writeFamily(bw, f); err != nil {
+			return err
+		}
+	}
+
+	if _, err := bw.WriteString("# EOF\n"); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+func writeFamily(w *bufio.Writer, f Family) error {
+	if !metricNameRe.MatchString(f.Name) || (f.Unit != "" && !strings.HasSuffix(f.Name, "_"+f.Unit)) {
+		return errors.Wrapf(ErrWrongName, "metric %q with unit %q", f.Name, f.Unit)
+	}
+
+	sampleName := f.Name
+	if f.Type == Counter {
+		sampleName += "_total"
+	}
+
+	var b strings.Builder
+
+	b.WriteString("# TYPE " + f.Name + " " + string(f.Type) + "\n")
+
+	if f.Unit != "" {
+		b.WriteString("# UNIT " + f.Name + " " + f.Unit + "\n")
+	}
+
+	if f.Help != "" {
+		b.WriteString("# HELP " + f.Name + " " + escape(f.Help, false) + "\n")
+	}
+
+	for _, s := range f.Samples {
+		b.WriteString(sampleName)
+
+		if len(s.Labels) > 0 {
+			b.WriteString("{")
+
+			for i, l := range s.Labels {
+				if !labelNameRe.MatchString(l.Name) {
+					return errors.Wrapf(ErrWrongName, "label %q of metric %q", l.Name, f.Name)
+				}
+
+				if i > 0 {
+					b.WriteString(",")
+				}
+
+				b.WriteString(l.Name + `="` + escape(l.Value, true) + `"`)
+			}
+
+			b.WriteString("}")
+		}
+
+		b.WriteString(" " + formatValue(s.Value) + "\n")
+	}
+
+	_, err := w.WriteString(b.String())
+
+	return err
+}
+
+// escape escapes backslashes and line feeds, and double quotes in label values.
+func escape(s string, quotes bool) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+
+	if quotes {
+		s = strings.ReplaceAll(s, `"`, `\"`)
+	}
+
+	return s
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
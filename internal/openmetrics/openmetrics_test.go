@@ -0,0 +1,84 @@
+package openmetrics_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/openmetrics"
+)
+
+func TestWrite(t *testing.T) {
+	families := []openmetrics.Family{
+		{
+			Name: "repo_watch_events",
+			Type: openmetrics.Gauge,
+			Help: "Watch events of a repository\nin the archive",
+			Samples: []openmetrics.Sample{
+				{Labels: []openmetrics.Label{{Name: "repo", Value: `org/"quoted\"`}, {Name: "id", Value: "1"}}, Value: 44},
+				{Labels: []openmetrics.Label{{Name: "repo", Value: "org/two"}, {Name: "id", Value: "2"}}, Value: 0.5},
+			},
+		},
+		{
+			Name:    "load_duration_seconds",
+			Type:    openmetrics.Gauge,
+			Unit:    "seconds",
+			Samples: []openmetrics.Sample{{Value: 1.25}},
+		},
+		{
+			Name:    "rows_read",
+			Type:    openmetrics.Counter,
+			Samples: []openmetrics.Sample{{Labels: []openmetrics.Label{{Name: "file", Value: "data/events.csv"}}, Value: 32720}},
+		},
+	}
+
+	want := `# TYPE repo_watch_events gauge
+# HELP repo_watch_events Watch events of a repository\nin the archive
+repo_watch_events{repo="org/\"quoted\\\"",id="1"} 44
+repo_watch_events{repo="org/two",id="2"} 0.5
+# TYPE load_duration_seconds gauge
+# UNIT load_duration_seconds seconds
+load_duration_seconds 1.25
+# TYPE rows_read counter
+rows_read_total{file="data/events.csv"} 32720
+# EOF
+`
+
+	var b bytes.Buffer
+	if err := openmetrics.Write(&b, families); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := b.String(); got != want {
+		t.Errorf("Write() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWrite_WrongName(t *testing.T) {
+	tests := []struct {
+		name   string
+		family openmetrics.Family
+	}{
+		{name: "metric name", family: openmetrics.Family{Name: "repo-watch-events", Type: openmetrics.Gauge}},
+		{name: "unit suffix", family: openmetrics.Family{Name: "load_duration", Type: openmetrics.Gauge, Unit: "seconds"}},
+		{
+			name: "label name",
+			family: openmetrics.Family{
+				Name:    "repo_watch_events",
+				Type:    openmetrics.Gauge,
+				Samples: []openmetrics.Sample{{Labels: []openmetrics.Label{{Name: "repo name", Value: "org/one"}}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := openmetrics.Write(&b, []openmetrics.Family{tt.family}); !errors.Is(err, openmetrics.ErrWrongName) {
+				t.Errorf("Write() error = %v, want %v", err, openmetrics.ErrWrongName)
+			}
+		})
+	}
+}
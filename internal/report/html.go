@@ -0,0 +1,144 @@
+package report
+
+import (
+	_ "embed" // the HTML report template is embedded
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+// Sizes of charts in pixels.
+const (
+	chartWidth     = 640
+	barHeight      = 22
+	labelWidth     = 180
+	histogramWidth = 320
+	histogramPlot  = 140
+)
+
+//go:embed html/report.html
+var htmlReportText string
+
+var htmlReport = htmltemplate.Must(htmltemplate.New("report.html").Funcs(htmltemplate.FuncMap{
+	"eventsChart":    eventsChart,
+	"histogramChart": histogramChart,
+	"distribution": func(name string, d github.Distribution) namedDistribution {
+		return namedDistribution{Name: name, D: d}
+	},
+	"percent": func(share float64) string { return fmt.Sprintf("%.1f%%", share*100) },
+}).Parse(htmlReportText))
+
+// namedDistribution is a distribution with its chart title.
+type namedDistribution struct {
+	Name string
+	D    github.Distribution
+}
+
+// HTMLReport is the data of a self-contained HTML report.
+type HTMLReport struct {
+	Title       string
+	GeneratedAt time.Time
+	Inputs      []Input
+	N           int
+	Bots        bool
+	Users       []RankedUser
+	// ReposByCommits and ReposByWatchEvents are repositories ranked by commits pushed and by watch events.
+	ReposByCommits     []RankedRepo
+	ReposByWatchEvents []RankedRepo
+	EventsByType       map[string]int
+	Stats              *github.Stats
+}
+
+// WriteHTML writes the report as one HTML file with inline SVG charts and scripts, which needs no network.
+func WriteHTML(w io.Writer, r *HTMLReport) error {
+	return htmlReport.Execute(w, r)
+}
+
+// eventsChart returns a horizontal bar chart of amounts of events by type, the most frequent types first.
+func eventsChart(byType map[string]int) htmltemplate.HTML {
+	types := make([]string, 0, len(byType))
+	for eventType := range byType {
+		types = append(types, eventType)
+	}
+
+	sort.Slice(types, func(i, j int) bool {
+		if byType[types[i]] != byType[types[j]] {
+			return byType[types[i]] > byType[types[j]]
+		}
+
+		return types[i] < types[j]
+	})
+
+	var highest int
+	for _, eventType := range types {
+		if byType[eventType] > highest {
+			highest = byType[eventType]
+		}
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" role="img">`, chartWidth, len(types)*barHeight)
+
+	for i, eventType := range types {
+		width := 0
+		if highest > 0 {
+			width = byType[eventType] * (chartWidth - labelWidth - 60) / highest
+		}
+
+		y := i * barHeight
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%s</text>`, labelWidth-6, y+15, htmltemplate.HTMLEscapeString(eventType))
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" class="bar"><title>%s: %d</title></rect>`,
+			labelWidth, y+3, width, barHeight-6, htmltemplate.HTMLEscapeString(eventType), byType[eventType])
+		fmt.Fprintf(&b, `<text x="%d" y="%d">%d</text>`, labelWidth+width+4, y+15, byType[eventType])
+	}
+
+	b.WriteString(`</svg>`)
+
+	return htmltemplate.HTML(b.String()) //nolint:gosec // labels are escaped
+}
+
+// histogramChart returns a column chart of the log-scale histogram of a distribution.
+func histogramChart(title string, d github.Distribution) htmltemplate.HTML {
+	var highest int
+	for _, bucket := range d.Histogram {
+		if bucket.Count > highest {
+			highest = bucket.Count
+		}
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" role="img">`, histogramWidth, histogramPlot+50)
+	fmt.Fprintf(&b, `<text x="0" y="14" class="title">%s</text>`, htmltemplate.HTMLEscapeString(title))
+
+	if len(d.Histogram) > 0 {
+		step := histogramWidth / len(d.Histogram)
+
+		for i, bucket := range d.Histogram {
+			height := 0
+			if highest > 0 {
+				height = bucket.Count * histogramPlot / highest
+			}
+
+			label := fmt.Sprint(bucket.Min)
+			if bucket.Max > bucket.Min {
+				label = fmt.Sprintf("%d-%d", bucket.Min, bucket.Max)
+			}
+
+			x, y := i*step, 24+histogramPlot-height
+			fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" class="bar"><title>%s: %d</title></rect>`,
+				x+1, y, step-2, height, label, bucket.Count)
+			fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle" class="axis">%d</text>`, x+step/2, histogramPlot+40, bucket.Min)
+		}
+	}
+
+	b.WriteString(`</svg>`)
+
+	return htmltemplate.HTML(b.String()) //nolint:gosec // the title is escaped
+}
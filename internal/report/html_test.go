@@ -0,0 +1,62 @@
+package report_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/report"
+)
+
+func TestWriteHTML(t *testing.T) {
+	users, repos := sampleRankings(t)
+	ds := sampleDataset(t)
+
+	byType, err := ds.EventsByType(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := ds.Stats(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	if err := report.WriteHTML(&b, &report.HTMLReport{
+		Title:              "weekly <report>",
+		GeneratedAt:        time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC),
+		Inputs:             []report.Input{{Path: archivePath, Kind: "archive"}},
+		N:                  3,
+		Users:              users,
+		ReposByCommits:     repos,
+		ReposByWatchEvents: repos,
+		EventsByType:       byType,
+		Stats:              stats,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	html := b.String()
+
+	for _, want := range []string{
+		"<title>weekly &lt;report&gt;</title>",
+		`<a href="https://github.com/` + users[0].Username + `">`,
+		`<a href="https://github.com/` + repos[0].Name + `">`,
+		`<table class="sortable">`,
+		"<svg", "PushEvent", "watch events", "<script>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("WriteHTML() has no %q", want)
+		}
+	}
+
+	// the report is self-contained
+	for _, external := range []string{"<link", " src=", "url(", "@import"} {
+		if strings.Contains(html, external) {
+			t.Errorf("WriteHTML() has external resource %q", external)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Package report renders rankings with user-defined and built-in templates.
+package report
+
+import (
+	"embed"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+// BuiltinPrefix selects a built-in template instead of a file, e.g. builtin:slack.
+const BuiltinPrefix = "builtin:"
+
+//go:embed templates
+var builtins embed.FS
+
+// Data is the data model templates are executed with.
+type Data struct {
+	// Ranking is the command of the ranking, e.g. top-users.
+	Ranking string
+	// Title is the title of the ranking, e.g. "top 10 active users".
+	Title       string
+	Params      Params
+	Inputs      []Input
+	GeneratedAt time.Time
+	// Users are ranked users of user rankings, if they are not grouped.
+	Users []RankedUser
+	// Repos are ranked repositories of repository rankings, if they are not grouped.
+	Repos []RankedRepo
+	// Groups are rankings of every value of Params.GroupBy.
+	Groups []Group
+}
+
+// Params are parameters of the ranking.
+type Params struct {
+	N int
+	// Metric is the metric items are sorted by: activity, commits or watch-events.
+	Metric  string
+	Bots    bool
+	Where   []string
+	GroupBy string
+}
+
+// Input is an archive or a snapshot the ranking is computed from.
+type Input struct {
+	Path string
+	// Kind is archive or snapshot.
+	Kind    string
+	Size    int64
+	ModTime time.Time
+}
+
+// Group is the ranking of users or repositories with one value of the grouped column.
+type Group struct {
+	Value string       `json:"value"`
+	Users []RankedUser `json:"users,omitempty"`
+	Repos []RankedRepo `json:"repos,omitempty"`
+}
+
+// RankedUser is a user with its place in the ranking.
+type RankedUser struct {
+	Rank                int    `json:"rank"`
+	ID                  string `json:"id"`
+	Username            string `json:"username"`
+	Activity            int    `json:"activity"`
+	PushedCommits       int    `json:"pushed_commits"`
+	CreatedPullRequests int    `json:"created_pull_requests"`
+	// Meta is metadata joined with --enrich.
+	Meta github.Metadata `json:"meta,omitempty"`
+}
+
+// NewRankedUsers returns users ranked in order.
+func NewRankedUsers(users []github.User) []RankedUser {
+	ranked := make([]RankedUser, len(users))
+
+	for i, u := range users {
+		ranked[i] = RankedUser{
+			Rank:                i + 1,
+			ID:                  u.ID,
+			Username:            u.Username,
+			Activity:            u.Activity.Total(),
+			PushedCommits:       u.Activity.PushedCommits,
+			CreatedPullRequests: u.Activity.CreatedPullRequests,
+			Meta:                u.Meta,
+		}
+	}
+
+	return ranked
+}
+
+// RankedRepo is a repository with its place in the ranking.
+type RankedRepo struct {
+	Rank          int    `json:"rank"`
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	CommitsPushed int    `json:"commits_pushed"`
+	WatchEvents   int    `json:"watch_events"`
+	// Meta is metadata joined with --enrich.
+	Meta github.Metadata `json:"meta,omitempty"`
+}
+
+// NewRankedRepos returns repositories ranked in order.
+func NewRankedRepos(repos []github.Repo) []RankedRepo {
+	ranked := make([]RankedRepo, len(repos))
+
+	for i, r := range repos {
+		ranked[i] = RankedRepo{
+			Rank:          i + 1,
+			ID:            r.ID,
+			Name:          r.Name,
+			CommitsPushed: r.CommitsPushed,
+			WatchEvents:   r.WatchEvents,
+			Meta:          r.Meta,
+		}
+	}
+
+	return ranked
+}
+
+// Template is a parsed text or HTML template.
+type Template interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+var funcs = map[string]interface{}{
+	"join": strings.Join,
+}
+
+// Load parses the template file at path, or the built-in template if path starts with BuiltinPrefix.
+// Files with the .html or .htm extension are HTML templates, which escape values, the others are text templates.
+func Load(path string) (Template, error) {
+	var (
+		text []byte
+		err  error
+	)
+
+	name := path
+
+	if strings.HasPrefix(path, BuiltinPrefix) {
+		name, err = builtinFile(strings.TrimPrefix(path, BuiltinPrefix))
+		if err != nil {
+			return nil, err
+		}
+
+		text, err = builtins.ReadFile("templates/" + name)
+	} else {
+		text, err = os.ReadFile(path)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if ext := strings.ToLower(filepath.Ext(name)); ext == ".html" || ext == ".htm" {
+		return htmltemplate.New(filepath.Base(name)).Funcs(funcs).Parse(string(text))
+	}
+
+	return texttemplate.New(filepath.Base(name)).Funcs(funcs).Parse(string(text))
+}
+
+// Builtins returns names of built-in templates.
+func Builtins() []string {
+	entries, _ := builtins.ReadDir("templates")
+
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// builtinFile returns the file of the built-in template.
+func builtinFile(name string) (string, error) {
+	entries, err := builtins.ReadDir("templates")
+	if err != nil {
+		return "", err
+	}
+
+	for _, e := range entries {
+		if strings.TrimSuffix(e.Name(), filepath.Ext(e.Name())) == name {
+			return e.Name(), nil
+		}
+	}
+
+	return "", errors.Wrapf(github.ErrWrongParam, "unknown built-in template %q, should be one of %s", name, strings.Join(Builtins(), ", "))
+}
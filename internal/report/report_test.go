@@ -0,0 +1,147 @@
+package report_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/report"
+	"github.com/levakin/analytics-software-engineer-assignment/samples"
+)
+
+const archivePath = "data.tar.gz"
+
+func TestBuiltins(t *testing.T) {
+	users, repos := sampleRankings(t)
+
+	if got := report.Builtins(); strings.Join(got, ",") != "email,markdown,slack" {
+		t.Fatalf("Builtins() = %v", got)
+	}
+
+	input := []report.Input{{Path: archivePath, Kind: "archive"}}
+	tests := []struct {
+		name string
+		data report.Data
+		want []string
+	}{
+		{
+			name: "users",
+			data: report.Data{Ranking: "top-users", Title: "top 3 active users", Params: report.Params{N: 3}, Users: users},
+			want: []string{"top 3 active users", users[0].Username, users[2].Username},
+		},
+		{
+			name: "repos",
+			data: report.Data{Ranking: "top-repos-by-commits", Title: "top 3 repositories", Params: report.Params{N: 3}, Repos: repos},
+			want: []string{"top 3 repositories", "https://github.com/" + repos[0].Name, repos[2].Name},
+		},
+		{
+			name: "groups",
+			data: report.Data{
+				Ranking: "top-repos-by-commits", Title: "top 3 repositories", Params: report.Params{N: 3, GroupBy: "language"},
+				Groups: []report.Group{{Value: "Go", Repos: repos[:1]}, {Value: github.NoValue, Repos: repos[1:]}},
+			},
+			want: []string{"language: Go", "language: " + github.NoValue, repos[0].Name, repos[2].Name},
+		},
+	}
+
+	for _, name := range report.Builtins() {
+		tmpl, err := report.Load(report.BuiltinPrefix + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tt := range tests {
+			tt.data.Inputs, tt.data.GeneratedAt = input, time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+			var b bytes.Buffer
+			if err := tmpl.Execute(&b, tt.data); err != nil {
+				t.Fatalf("%s: Execute(%s) error = %v", name, tt.name, err)
+			}
+
+			for _, want := range append(tt.want, archivePath) {
+				if !strings.Contains(b.String(), want) {
+					t.Errorf("%s: Execute(%s) = %s, want it to contain %q", name, tt.name, b.String(), want)
+				}
+			}
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	textPath, htmlPath := filepath.Join(dir, "users.tmpl"), filepath.Join(dir, "users.html")
+
+	for _, path := range []string{textPath, htmlPath} {
+		if err := os.WriteFile(path, []byte(`{{ range .Users }}{{ .Username }};{{ end }}`), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data := report.Data{Users: []report.RankedUser{{Rank: 1, Username: "<b>bob</b>"}}}
+
+	for path, want := range map[string]string{textPath: "<b>bob</b>;", htmlPath: "&lt;b&gt;bob&lt;/b&gt;;"} {
+		tmpl, err := report.Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, data); err != nil {
+			t.Fatal(err)
+		}
+
+		if b.String() != want {
+			t.Errorf("Execute() of %s = %q, want %q", filepath.Base(path), b.String(), want)
+		}
+	}
+
+	if _, err := report.Load(report.BuiltinPrefix + "wiki"); !errors.Is(err, github.ErrWrongParam) {
+		t.Errorf("Load() error = %v, want %v", err, github.ErrWrongParam)
+	}
+}
+
+func sampleRankings(t *testing.T) ([]report.RankedUser, []report.RankedRepo) {
+	t.Helper()
+
+	ds := sampleDataset(t)
+
+	us, err := ds.UsersSample(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	topUsers, err := us.TopNActiveUsers(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rs, err := ds.ReposSample(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	topRepos, err := rs.TopNByCommitsPushed(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return report.NewRankedUsers(topUsers), report.NewRankedRepos(topRepos)
+}
+
+func sampleDataset(t *testing.T) *github.Dataset {
+	t.Helper()
+
+	archive, err := samples.Archive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return archive.Dataset()
+}
@@ -0,0 +1,250 @@
+package shell
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/tui"
+)
+
+// maxHistory is the maximum amount of lines kept in history.
+const maxHistory = 1000
+
+// Result is the outcome of a key press in Editor.
+type Result int
+
+// Results of key presses.
+const (
+	// Editing means the line is still being edited.
+	Editing Result = iota
+	// Submitted means enter was pressed, the line is returned by Submit.
+	Submitted
+	// Interrupted means ctrl-c was pressed, the line is dropped.
+	Interrupted
+	// EOF means ctrl-d was pressed on an empty line.
+	EOF
+)
+
+// Editor edits a line of input with history and completion, like readline does. It doesn't depend on a real
+// terminal: keys are passed to Update and the caller draws Line with the cursor at Cursor.
+type Editor struct {
+	complete func(line string) []string
+
+	line   []rune
+	cursor int
+
+	history []string
+	// historyIdx is index of the history line being edited, len(history) is the new line
+	historyIdx int
+	// draft is the new line saved while history is browsed
+	draft []rune
+
+	// Suggestions are completions which should be shown to the user, they are set if tab can't choose
+	// one completion and cleared on the next key.
+	Suggestions []string
+}
+
+// NewEditor returns a new Editor with the history, oldest line first. Complete returns completions
+// of the last word of the line before the cursor, it may be nil.
+func NewEditor(history []string, complete func(line string) []string) *Editor {
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+
+	return &Editor{complete: complete, history: history, historyIdx: len(history)}
+}
+
+// Line returns the line being edited.
+func (e *Editor) Line() string {
+	return string(e.line)
+}
+
+// Cursor returns position of the cursor in runes of the line.
+func (e *Editor) Cursor() int {
+	return e.cursor
+}
+
+// History returns submitted lines, oldest first.
+func (e *Editor) History() []string {
+	return e.history
+}
+
+// Update changes the line on a key press.
+func (e *Editor) Update(k tui.Key) Result {
+	e.Suggestions = nil
+
+	switch k {
+	case tui.KeyEnter:
+		return Submitted
+	case tui.KeyCtrlC:
+		e.reset()
+
+		return Interrupted
+	case tui.KeyCtrlD:
+		if len(e.line) == 0 {
+			return EOF
+		}
+
+		e.delete(e.cursor, e.cursor+1)
+	case tui.KeyLeft:
+		if e.cursor > 0 {
+			e.cursor--
+		}
+	case tui.KeyRight:
+		if e.cursor < len(e.line) {
+			e.cursor++
+		}
+	case tui.KeyHome, tui.KeyCtrlA:
+		e.cursor = 0
+	case tui.KeyEnd, tui.KeyCtrlE:
+		e.cursor = len(e.line)
+	case tui.KeyBackspace:
+		e.delete(e.cursor-1, e.cursor)
+	case tui.KeyDelete:
+		e.delete(e.cursor, e.cursor+1)
+	case tui.KeyCtrlU:
+		e.delete(0, e.cursor)
+	case tui.KeyCtrlW:
+		start := e.cursor
+		for start > 0 && unicode.IsSpace(e.line[start-1]) {
+			start--
+		}
+
+		for start > 0 && !unicode.IsSpace(e.line[start-1]) {
+			start--
+		}
+
+		e.delete(start, e.cursor)
+	case tui.KeyUp:
+		e.browseHistory(e.historyIdx - 1)
+	case tui.KeyDown:
+		e.browseHistory(e.historyIdx + 1)
+	case tui.KeyTab:
+		e.completeWord()
+	default:
+		if r := []rune(string(k)); len(r) == 1 {
+			e.insert(r)
+		}
+	}
+
+	return Editing
+}
+
+// Submit returns the submitted line, adds it to history and starts a new line.
+func (e *Editor) Submit() string {
+	line := string(e.line)
+
+	// completion leaves a trailing space, which shouldn't make lines in history differ
+	if entry := strings.TrimRightFunc(line, unicode.IsSpace); entry != "" && (len(e.history) == 0 || e.history[len(e.history)-1] != entry) {
+		e.history = append(e.history, entry)
+		if len(e.history) > maxHistory {
+			e.history = e.history[len(e.history)-maxHistory:]
+		}
+	}
+
+	e.reset()
+
+	return line
+}
+
+func (e *Editor) reset() {
+	e.line, e.cursor, e.draft = nil, 0, nil
+	e.historyIdx = len(e.history)
+}
+
+func (e *Editor) insert(r []rune) {
+	line := make([]rune, 0, len(e.line)+len(r))
+	line = append(line, e.line[:e.cursor]...)
+	line = append(line, r...)
+	e.line = append(line, e.line[e.cursor:]...)
+	e.cursor += len(r)
+}
+
+// delete deletes runes from start to end, both are clamped to the line.
+func (e *Editor) delete(start, end int) {
+	if start < 0 {
+		start = 0
+	}
+
+	if end > len(e.line) {
+		end = len(e.line)
+	}
+
+	if start >= end {
+		return
+	}
+
+	e.line = append(e.line[:start:start], e.line[end:]...)
+	e.cursor = start
+}
+
+func (e *Editor) browseHistory(idx int) {
+	if idx < 0 || idx > len(e.history) || idx == e.historyIdx {
+		return
+	}
+
+	if e.historyIdx == len(e.history) {
+		e.draft = e.line
+	}
+
+	e.historyIdx = idx
+
+	if idx == len(e.history) {
+		e.line = e.draft
+	} else {
+		e.line = []rune(e.history[idx])
+	}
+
+	e.cursor = len(e.line)
+}
+
+// completeWord replaces the word before the cursor with its completion. If there are several completions,
+// the word is extended to their common prefix, and if it can't be extended, they become suggestions.
+func (e *Editor) completeWord() {
+	if e.complete == nil {
+		return
+	}
+
+	before := string(e.line[:e.cursor])
+
+	completions := e.complete(before)
+	if len(completions) == 0 {
+		return
+	}
+
+	start := e.cursor
+	for start > 0 && !unicode.IsSpace(e.line[start-1]) {
+		start--
+	}
+
+	word := string(e.line[start:e.cursor])
+
+	prefix := completions[0]
+	for _, c := range completions[1:] {
+		prefix = commonPrefix(prefix, c)
+	}
+
+	if len(completions) == 1 {
+		prefix += " "
+	}
+
+	if prefix == word {
+		e.Suggestions = completions
+
+		return
+	}
+
+	e.delete(start, e.cursor)
+	e.insert([]rune(prefix))
+}
+
+func commonPrefix(a, b string) string {
+	ra, rb := []rune(a), []rune(b)
+
+	i := 0
+	for i < len(ra) && i < len(rb) && ra[i] == rb[i] {
+		i++
+	}
+
+	return string(ra[:i])
+}
@@ -0,0 +1,547 @@
+// Package shell implements commands of the interactive query shell. Shell keeps datasets of loaded archives,
+// or their merged snapshot, and samples aggregated from them between commands, so every question after the first
+// one is answered without decoding archives again.
+package shell
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/snapshot"
+)
+
+const (
+	defaultN = 10
+	// maxCompletions is the maximum amount of completions returned for a word.
+	maxCompletions = 100
+)
+
+var (
+	// ErrExit is returned by Exec if the user asks to exit the shell.
+	ErrExit = errors.New("exit")
+	// ErrWrongCommand is returned by Exec if the command is unknown or has wrong arguments.
+	ErrWrongCommand = errors.New("wrong command")
+)
+
+// LoadFunc loads dataset of the archive.
+type LoadFunc func(ctx context.Context, path string) (*github.Dataset, error)
+
+// SnapshotLoadFunc loads the snapshot file, or snapshot of the archive.
+type SnapshotLoadFunc func(ctx context.Context, path string) (*snapshot.Snapshot, error)
+
+// Shell executes commands against datasets of loaded archives.
+type Shell struct {
+	load         LoadFunc
+	loadSnapshot SnapshotLoadFunc
+
+	ds *github.Dataset
+	// snap is the merged snapshot of loaded inputs, it is kept instead of ds by shells of snapshots
+	snap  *snapshot.Snapshot
+	paths []string
+	// timing is set if time of every command should be printed after its output
+	timing bool
+
+	// samples are aggregated on first use and dropped when another archive is loaded
+	users map[bool]*github.UsersSample
+	repos *github.ReposSample
+	// usernames and repoNames are sorted names of listed users and repositories, used for completion
+	usernames []string
+	repoNames []string
+}
+
+// New returns a new Shell without loaded archives.
+func New(load LoadFunc) *Shell {
+	return &Shell{load: load, ds: &github.Dataset{}, users: make(map[bool]*github.UsersSample)}
+}
+
+// NewOfSnapshots returns a new Shell which merges snapshots of loaded archives and snapshot files.
+// It ranks users and repositories, but has no events of them, so user and repo are refused.
+func NewOfSnapshots(load SnapshotLoadFunc) *Shell {
+	return &Shell{loadSnapshot: load, users: make(map[bool]*github.UsersSample)}
+}
+
+// commands are sorted names of commands, completed on the first word of a line.
+var commands = []string{`\timing`, "exit", "help", "load", "quit", "repo", "sources", "top", "user"}
+
+const help = `commands:
+  top users [N] [--bots]           top N active users, bots are excluded unless --bots is set
+  top repos [by commits|watch] [N] top N repositories by pushed commits or watch events
+  user <username> [N]              events of the user and N repositories the user is most active in
+  repo <name> [N]                  events in the repository and N users most active in it
+  load <path>                      adds events of another archive to the loaded ones
+  sources                          lists loaded archives
+  \timing [on|off]                 toggles printing of time spent by every command
+  help                             prints this help
+  exit, quit, \q                   exits the shell
+`
+
+// Exec executes the command line and writes its output to w.
+// It returns ErrExit if the line asks to exit and ErrWrongCommand wrapped with usage if the line is wrong.
+// Other errors are errors of the command, the shell stays usable after all of them.
+func (s *Shell) Exec(ctx context.Context, w io.Writer, line string) error {
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return nil
+	}
+
+	start := time.Now()
+
+	var err error
+
+	switch args[0] {
+	case "exit", "quit", `\q`:
+		return ErrExit
+	case "help", `\?`:
+		_, err = io.WriteString(w, help)
+	case `\timing`:
+		err = s.setTiming(w, args[1:])
+	case "load":
+		// paths may have spaces, so the rest of the line is the path
+		err = s.Load(ctx, strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "load")))
+		if err == nil {
+			_, err = fmt.Fprintf(w, "loaded %s, %d events in total\n", s.paths[len(s.paths)-1], s.events())
+		}
+	case "sources":
+		for _, path := range s.paths {
+			if _, err = fmt.Fprintln(w, path); err != nil {
+				break
+			}
+		}
+	case "top":
+		err = s.top(ctx, w, args[1:])
+	case "user":
+		err = s.details(ctx, w, args[1:], true)
+	case "repo":
+		err = s.details(ctx, w, args[1:], false)
+	default:
+		return errors.Wrapf(ErrWrongCommand, "unknown command %q, type help to list commands", args[0])
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if s.timing {
+		_, err = fmt.Fprintf(w, "Time: %v\n", time.Since(start).Round(time.Microsecond))
+	}
+
+	return err
+}
+
+// Load adds events of the archive to the loaded ones. An archive can't be loaded twice, since its events
+// would be counted twice.
+func (s *Shell) Load(ctx context.Context, path string) error {
+	if path == "" {
+		return errors.Wrap(ErrWrongCommand, "usage: load <path>")
+	}
+
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	for _, loaded := range s.paths {
+		if loaded == path {
+			return errors.Errorf("%s is already loaded", path)
+		}
+	}
+
+	if s.loadSnapshot != nil {
+		if err := s.mergeSnapshot(ctx, path); err != nil {
+			return err
+		}
+	} else {
+		ds, err := s.load(ctx, path)
+		if err != nil {
+			return err
+		}
+
+		if len(s.paths) == 0 {
+			s.ds = ds
+		} else {
+			s.ds = github.MergeDatasets(s.ds, ds)
+		}
+
+		s.usernames = sortedNames(s.ds.Actors.Usernames[:s.ds.Actors.Listed])
+		s.repoNames = sortedNames(s.ds.Repos.Names[:s.ds.Repos.Listed])
+	}
+
+	s.paths = append(s.paths, path)
+	s.users = make(map[bool]*github.UsersSample)
+	s.repos = nil
+
+	return nil
+}
+
+// mergeSnapshot merges snapshot of the path into the loaded one.
+func (s *Shell) mergeSnapshot(ctx context.Context, path string) error {
+	loaded, err := s.loadSnapshot(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	if s.snap != nil {
+		if loaded, err = snapshot.Merge(s.snap, loaded); err != nil {
+			return err
+		}
+	}
+
+	s.snap = loaded
+
+	var usernames, repoNames []string
+
+	for _, a := range s.snap.Actors {
+		if a.Listed {
+			usernames = append(usernames, a.Username)
+		}
+	}
+
+	for _, r := range s.snap.Repos {
+		if r.Listed {
+			repoNames = append(repoNames, r.Name)
+		}
+	}
+
+	s.usernames, s.repoNames = sortedNames(usernames), sortedNames(repoNames)
+
+	return nil
+}
+
+// events returns amount of loaded events.
+func (s *Shell) events() int {
+	if s.snap == nil {
+		return s.ds.Events.Len()
+	}
+
+	var n int
+	for _, count := range s.snap.EventsByType {
+		n += count
+	}
+
+	return n
+}
+
+func (s *Shell) setTiming(w io.Writer, args []string) error {
+	switch {
+	case len(args) == 0:
+		s.timing = !s.timing
+	case len(args) == 1 && (args[0] == "on" || args[0] == "off"):
+		s.timing = args[0] == "on"
+	default:
+		return errors.Wrap(ErrWrongCommand, `usage: \timing [on|off]`)
+	}
+
+	state := "off"
+	if s.timing {
+		state = "on"
+	}
+
+	_, err := fmt.Fprintf(w, "Timing is %s.\n", state)
+
+	return err
+}
+
+func (s *Shell) top(ctx context.Context, w io.Writer, args []string) error {
+	if len(args) == 0 {
+		return errors.Wrap(ErrWrongCommand, "usage: top users [N] [--bots] or top repos [by commits|watch] [N]")
+	}
+
+	switch args[0] {
+	case "users":
+		return s.topUsers(ctx, w, args[1:])
+	case "repos":
+		return s.topRepos(ctx, w, args[1:])
+	default:
+		return errors.Wrapf(ErrWrongCommand, "can't rank %q, only users or repos", args[0])
+	}
+}
+
+func (s *Shell) topUsers(ctx context.Context, w io.Writer, args []string) error {
+	n, bots := defaultN, false
+
+	for _, arg := range args {
+		if arg == "--bots" {
+			bots = true
+
+			continue
+		}
+
+		var err error
+		if n, err = parseN(arg); err != nil {
+			return err
+		}
+	}
+
+	if err := s.checkLoaded(); err != nil {
+		return err
+	}
+
+	users, ok := s.users[bots]
+	if !ok {
+		if s.snap != nil {
+			users = s.snap.UsersSample(bots)
+		} else {
+			var err error
+			if users, err = s.ds.UsersSample(ctx, bots); err != nil {
+				return err
+			}
+		}
+
+		s.users[bots] = users
+	}
+
+	top, err := users.TopNActiveUsers(n)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "top %d active users:\n", n); err != nil {
+		return err
+	}
+
+	for i, u := range top {
+		if _, err := fmt.Fprintf(w,
+			"%3d. username: %30s| id: %10s| activity: %10d| pushed commits: %5d| created pull requests: %5d|\n",
+			i+1, u.Username, u.ID, u.Activity.Total(), u.Activity.PushedCommits, u.Activity.CreatedPullRequests,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Shell) topRepos(ctx context.Context, w io.Writer, args []string) error {
+	n, byWatch := defaultN, false
+
+	for i := 0; i < len(args); i++ {
+		if args[i] != "by" {
+			var err error
+			if n, err = parseN(args[i]); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if i+1 == len(args) || (args[i+1] != "commits" && args[i+1] != "watch") {
+			return errors.Wrap(ErrWrongCommand, "repositories are ranked by commits or watch")
+		}
+
+		byWatch = args[i+1] == "watch"
+		i++
+	}
+
+	if err := s.checkLoaded(); err != nil {
+		return err
+	}
+
+	if s.repos == nil && s.snap != nil {
+		s.repos = s.snap.ReposSample()
+	}
+
+	if s.repos == nil {
+		repos, err := s.ds.ReposSample(ctx)
+		if err != nil {
+			return err
+		}
+
+		s.repos = repos
+	}
+
+	title, count := "pushed commits", func(r github.Repo) int { return r.CommitsPushed }
+	rank := s.repos.TopNByCommitsPushed
+
+	if byWatch {
+		title, count = "watch events", func(r github.Repo) int { return r.WatchEvents }
+		rank = s.repos.TopNByWatchEvents
+	}
+
+	top, err := rank(n)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "top %d repositories by %s:\n", n, title); err != nil {
+		return err
+	}
+
+	for i, r := range top {
+		if _, err := fmt.Fprintf(w, "%3d. name: %50s| id: %10s| %s: %5d|\n", i+1, r.Name, r.ID, title, count(r)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Shell) details(ctx context.Context, w io.Writer, args []string, user bool) error {
+	entity, counterparts := "repo <name>", "users"
+	if user {
+		entity, counterparts = "user <username>", "repositories"
+	}
+
+	if len(args) == 0 || len(args) > 2 {
+		return errors.Wrapf(ErrWrongCommand, "usage: %s [N]", entity)
+	}
+
+	n := defaultN
+
+	if len(args) == 2 {
+		var err error
+		if n, err = parseN(args[1]); err != nil {
+			return err
+		}
+	}
+
+	if err := s.checkLoaded(); err != nil {
+		return err
+	}
+
+	if s.snap != nil {
+		return errors.Wrap(github.ErrWrongParam, "user and repo need archives, snapshots have no events of users and repositories")
+	}
+
+	var (
+		d   *github.Details
+		err error
+	)
+
+	if user {
+		d, err = s.ds.UserDetails(ctx, args[0])
+	} else {
+		d, err = s.ds.RepoDetails(ctx, args[0])
+	}
+
+	if err != nil {
+		return err
+	}
+
+	types := make([]string, 0, len(d.EventsByType))
+	for t := range d.EventsByType {
+		types = append(types, t)
+	}
+
+	sort.Strings(types)
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s (id %s), commits pushed: %d\n", d.Name, d.ID, d.CommitsPushed)
+
+	if h := d.Health; h != nil && h.Contributions > 0 {
+		fmt.Fprintf(&b, "contributors: %d, top contributor share: %.1f%%, bus factor: %d (50%%), %d (80%%), herfindahl: %.3f\n",
+			h.Contributors(), h.TopContributorShare*100, h.BusFactor, h.BusFactor80, h.Herfindahl)
+	}
+
+	for _, t := range types {
+		fmt.Fprintf(&b, "%30s: %5d\n", t, d.EventsByType[t])
+	}
+
+	if len(d.Counterparts) < n {
+		n = len(d.Counterparts)
+	}
+
+	fmt.Fprintf(&b, "top %d of %d %s:\n", n, len(d.Counterparts), counterparts)
+
+	for i, c := range d.Counterparts[:n] {
+		fmt.Fprintf(&b, "%3d. name: %50s| id: %10s| events: %5d| commits pushed: %5d|\n", i+1, c.Name, c.ID, c.Events, c.CommitsPushed)
+	}
+
+	_, err = io.WriteString(w, b.String())
+
+	return err
+}
+
+func (s *Shell) checkLoaded() error {
+	if len(s.paths) == 0 {
+		return errors.New("no archives are loaded, use load <path>")
+	}
+
+	return nil
+}
+
+// Complete returns completions of the last word of the line: commands for the first word and names of users
+// or repositories for arguments of user and repo. Completions are sorted and at most maxCompletions are returned.
+func (s *Shell) Complete(line string) []string {
+	args := strings.Fields(line)
+	// a trailing space starts a new word
+	if len(args) == 0 || strings.HasSuffix(line, " ") {
+		args = append(args, "")
+	}
+
+	var words []string
+
+	switch {
+	case len(args) == 1:
+		words = commands
+	case len(args) == 2 && args[0] == "user":
+		words = s.usernames
+	case len(args) == 2 && args[0] == "repo":
+		words = s.repoNames
+	case len(args) == 2 && args[0] == "top":
+		words = []string{"repos", "users"}
+	case len(args) == 2 && args[0] == `\timing`:
+		words = []string{"off", "on"}
+	case len(args) == 3 && args[0] == "top" && args[1] == "repos":
+		words = []string{"by"}
+	case len(args) == 3 && args[0] == "top" && args[1] == "users":
+		words = []string{"--bots"}
+	case len(args) == 4 && args[0] == "top" && args[1] == "repos" && args[2] == "by":
+		words = []string{"commits", "watch"}
+	}
+
+	return completions(words, args[len(args)-1])
+}
+
+// completions returns the sorted words with the prefix.
+func completions(words []string, prefix string) []string {
+	i := sort.SearchStrings(words, prefix)
+
+	var matched []string
+
+	for ; i < len(words) && strings.HasPrefix(words[i], prefix) && len(matched) < maxCompletions; i++ {
+		matched = append(matched, words[i])
+	}
+
+	return matched
+}
+
+// sortedNames returns sorted distinct non-empty names.
+func sortedNames(names []string) []string {
+	sorted := make([]string, 0, len(names))
+
+	for _, name := range names {
+		if name != "" {
+			sorted = append(sorted, name)
+		}
+	}
+
+	sort.Strings(sorted)
+
+	distinct := sorted[:0]
+
+	for i, name := range sorted {
+		if i == 0 || name != sorted[i-1] {
+			distinct = append(distinct, name)
+		}
+	}
+
+	return distinct
+}
+
+func parseN(arg string) (int, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n <= 0 {
+		return 0, errors.Wrapf(ErrWrongCommand, "N should be a positive number, not %q", arg)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,310 @@
+package shell_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/shell"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/snapshot"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/tui"
+)
+
+// testDatasets returns datasets of two small archives by their base names.
+func testDatasets() map[string]*github.Dataset {
+	return map[string]*github.Dataset{
+		"first.tar.gz": github.NewDataset(
+			[]github.ActorCSV{{ID: "1", Username: "alice"}, {ID: "2", Username: "alex"}, {ID: "3", Username: "dependabot[bot]"}},
+			[]github.RepoCSV{{ID: "1", Name: "org/one"}, {ID: "2", Name: "org/two"}},
+			[]github.EventCSV{
+				{ID: "1", Type: github.PushEventType, ActorID: "1", RepoID: "1"},
+				{ID: "2", Type: github.PullRequestEventType, ActorID: "2", RepoID: "2"},
+				{ID: "3", Type: github.PullRequestEventType, ActorID: "3", RepoID: "2"},
+				{ID: "4", Type: github.PullRequestEventType, ActorID: "3", RepoID: "2"},
+				{ID: "5", Type: github.WatchEventType, ActorID: "2", RepoID: "1"},
+				{ID: "9", Type: github.PullRequestEventType, ActorID: "3", RepoID: "2"},
+			},
+			[]github.CommitCSV{{SHA: "1", EventID: "1"}, {SHA: "2", EventID: "1"}},
+		),
+		"second.tar.gz": github.NewDataset(
+			[]github.ActorCSV{{ID: "2", Username: "alex"}},
+			[]github.RepoCSV{{ID: "2", Name: "org/two"}},
+			[]github.EventCSV{
+				{ID: "6", Type: github.WatchEventType, ActorID: "2", RepoID: "2"},
+				{ID: "7", Type: github.PullRequestEventType, ActorID: "2", RepoID: "2"},
+				{ID: "8", Type: github.PullRequestEventType, ActorID: "2", RepoID: "2"},
+				{ID: "10", Type: github.WatchEventType, ActorID: "2", RepoID: "2"},
+			},
+			nil,
+		),
+	}
+}
+
+// newTestShell returns a shell which loads one of two small archives by their base names.
+func newTestShell(t *testing.T) *shell.Shell {
+	t.Helper()
+
+	datasets := testDatasets()
+
+	return shell.New(func(ctx context.Context, path string) (*github.Dataset, error) {
+		for name, ds := range datasets {
+			if strings.HasSuffix(path, name) {
+				return ds, nil
+			}
+		}
+
+		return nil, errors.Errorf("no archive %s", path)
+	})
+}
+
+func exec(t *testing.T, s *shell.Shell, line string) string {
+	t.Helper()
+
+	var out bytes.Buffer
+	if err := s.Exec(context.Background(), &out, line); err != nil {
+		t.Fatalf("Exec(%q) error = %v", line, err)
+	}
+
+	return out.String()
+}
+
+// names returns the name field of ranked lines of the output, e.g. alice of "1. username: alice| ...".
+func names(out string) []string {
+	var names []string
+
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 2 && strings.HasSuffix(fields[0], ".") && strings.HasSuffix(fields[1], ":") {
+			names = append(names, strings.TrimSuffix(fields[2], "|"))
+		}
+	}
+
+	return names
+}
+
+func TestShell_Exec(t *testing.T) {
+	s := newTestShell(t)
+
+	exec(t, s, "load first.tar.gz")
+
+	tests := []struct {
+		line      string
+		wantNames []string
+	}{
+		{line: "top users", wantNames: []string{"alice", "alex"}},
+		{line: "top users 1", wantNames: []string{"alice"}},
+		{line: "top users --bots 1", wantNames: []string{"dependabot[bot]"}},
+		{line: "top repos", wantNames: []string{"org/one", "org/two"}},
+		{line: "top repos by watch 1", wantNames: []string{"org/one"}},
+	}
+
+	for _, tt := range tests {
+		if got := names(exec(t, s, tt.line)); !reflect.DeepEqual(got, tt.wantNames) {
+			t.Errorf("Exec(%q) ranked %v, want %v", tt.line, got, tt.wantNames)
+		}
+	}
+
+	// the second archive is merged into the first one
+	exec(t, s, "load second.tar.gz")
+
+	if got, want := names(exec(t, s, "top users 1")), []string{"alex"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("top users after load ranked %v, want %v", got, want)
+	}
+
+	if got, want := names(exec(t, s, "top repos by watch")), []string{"org/two", "org/one"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("top repos by watch after load ranked %v, want %v", got, want)
+	}
+
+	out := exec(t, s, "repo org/two")
+	if !strings.Contains(out, "PullRequestEvent:     6") || !strings.Contains(out, "contributors: 2, top contributor share: 50.0%") || !reflect.DeepEqual(names(out), []string{"alex", "dependabot[bot]"}) {
+		t.Errorf("Exec(repo org/two) = %q", out)
+	}
+
+	exec(t, s, `\timing on`)
+
+	if out := exec(t, s, "user alice"); !strings.HasPrefix(out, "alice (id 1), commits pushed: 2\n") || !strings.Contains(out, "\nTime: ") {
+		t.Errorf("Exec(user alice) = %q", out)
+	}
+}
+
+func TestShell_Exec_Errors(t *testing.T) {
+	s := newTestShell(t)
+
+	var out bytes.Buffer
+
+	if err := s.Exec(context.Background(), &out, "top users"); err == nil {
+		t.Error("Exec() before load returned no error")
+	}
+
+	exec(t, s, "load first.tar.gz")
+
+	tests := []struct {
+		line    string
+		wantErr error
+	}{
+		{line: "exit", wantErr: shell.ErrExit},
+		{line: `\q`, wantErr: shell.ErrExit},
+		{line: "stars", wantErr: shell.ErrWrongCommand},
+		{line: "top users 0", wantErr: shell.ErrWrongCommand},
+		{line: "top repos by stars", wantErr: shell.ErrWrongCommand},
+		{line: "user", wantErr: shell.ErrWrongCommand},
+		{line: "user nobody", wantErr: github.ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		if err := s.Exec(context.Background(), &out, tt.line); !errors.Is(err, tt.wantErr) {
+			t.Errorf("Exec(%q) error = %v, want %v", tt.line, err, tt.wantErr)
+		}
+	}
+
+	if err := s.Exec(context.Background(), &out, "load first.tar.gz"); err == nil {
+		t.Error("Exec() loaded the same archive twice")
+	}
+}
+
+// TestShell_Snapshots checks that a shell of snapshots ranks the same way as a shell of datasets,
+// but refuses details.
+func TestShell_Snapshots(t *testing.T) {
+	snapshots := make(map[string]*snapshot.Snapshot)
+
+	for name, ds := range testDatasets() {
+		snap, err := snapshot.New(context.Background(), ds, snapshot.Source{Path: name, SHA256: name, Events: ds.Events.Len()})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		snapshots[name] = snap
+	}
+
+	s := shell.NewOfSnapshots(func(ctx context.Context, path string) (*snapshot.Snapshot, error) {
+		for name, snap := range snapshots {
+			if strings.HasSuffix(path, name) {
+				return snap, nil
+			}
+		}
+
+		return nil, errors.Errorf("no snapshot %s", path)
+	})
+	want := newTestShell(t)
+
+	for _, line := range []string{"load first.tar.gz", "load second.tar.gz", "top users 3 --bots", "top repos by watch 2"} {
+		if got, want := exec(t, s, line), exec(t, want, line); got != want {
+			t.Errorf("Exec(%q) of snapshots = %q, want %q", line, got, want)
+		}
+	}
+
+	if got, want := s.Complete("user al"), []string{"alex", "alice"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Complete() = %v, want %v", got, want)
+	}
+
+	var out bytes.Buffer
+	if err := s.Exec(context.Background(), &out, "user alice"); !errors.Is(err, github.ErrWrongParam) {
+		t.Errorf("Exec(user alice) of snapshots error = %v, want %v", err, github.ErrWrongParam)
+	}
+}
+
+func TestShell_Complete(t *testing.T) {
+	s := newTestShell(t)
+
+	exec(t, s, "load first.tar.gz")
+
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{line: "", want: []string{`\timing`, "exit", "help", "load", "quit", "repo", "sources", "top", "user"}},
+		{line: "t", want: []string{"top"}},
+		{line: "user al", want: []string{"alex", "alice"}},
+		{line: "user ", want: []string{"alex", "alice", "dependabot[bot]"}},
+		{line: "repo org/t", want: []string{"org/two"}},
+		{line: "top repos by ", want: []string{"commits", "watch"}},
+		{line: "user alice ", want: nil},
+	}
+
+	for _, tt := range tests {
+		if got := s.Complete(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Complete(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestEditor(t *testing.T) {
+	s := newTestShell(t)
+
+	exec(t, s, "load first.tar.gz")
+
+	e := shell.NewEditor([]string{"top users"}, s.Complete)
+
+	typeKeys := func(keys string) {
+		for _, k := range tui.ParseKeys([]byte(keys)) {
+			e.Update(k)
+		}
+	}
+
+	// a unique command is completed with a space, ambiguous names are completed to their common prefix
+	typeKeys("us\tal\t")
+
+	if got, want := e.Line(), "user al"; got != want {
+		t.Fatalf("Line() = %q, want %q", got, want)
+	}
+
+	// the next tab can't complete more, so it suggests
+	typeKeys("\t")
+
+	if want := []string{"alex", "alice"}; !reflect.DeepEqual(e.Suggestions, want) {
+		t.Errorf("Suggestions = %q, want %q", e.Suggestions, want)
+	}
+
+	typeKeys("i\t")
+
+	if e.Update(tui.KeyEnter) != shell.Submitted {
+		t.Fatal("enter didn't submit the line")
+	}
+
+	if got, want := e.Submit(), "user alice "; got != want {
+		t.Errorf("Submit() = %q, want %q", got, want)
+	}
+
+	// history is browsed from the newest line and the new line is restored after it
+	typeKeys("new\x1b[A\x1b[A")
+
+	if got, want := e.Line(), "top users"; got != want {
+		t.Errorf("Line() after up = %q, want %q", got, want)
+	}
+
+	typeKeys("\x1b[B\x1b[B")
+
+	if got, want := e.Line(), "new"; got != want {
+		t.Errorf("Line() after down = %q, want %q", got, want)
+	}
+
+	// editing in the middle of the line
+	typeKeys("\x1b[D\x1b[DX\x01>\x05\x7f")
+
+	if got, want := e.Line(), ">nXe"; got != want || e.Cursor() != 4 {
+		t.Errorf("Line() = %q with cursor %d, want %q with cursor 4", got, e.Cursor(), want)
+	}
+
+	typeKeys(" word\x17")
+
+	if got, want := e.Line(), ">nXe "; got != want {
+		t.Errorf("Line() after ctrl-w = %q, want %q", got, want)
+	}
+
+	if e.Update(tui.KeyCtrlC) != shell.Interrupted || e.Line() != "" {
+		t.Error("ctrl-c didn't drop the line")
+	}
+
+	if e.Update(tui.KeyCtrlD) != shell.EOF {
+		t.Error("ctrl-d on empty line isn't EOF")
+	}
+
+	if want := []string{"top users", "user alice"}; !reflect.DeepEqual(e.History(), want) {
+		t.Errorf("History() = %q, want %q", e.History(), want)
+	}
+}
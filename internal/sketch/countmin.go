@@ -0,0 +1,77 @@
+package sketch
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// CountMin estimates counts of keys with Count-Min Sketch. Estimates never underestimate, and with probability
+// 1-delta they overestimate by at most epsilon*Total().
+type CountMin struct {
+	width   uint32
+	depth   uint32
+	epsilon float64
+	total   uint64
+	counts  []uint64
+}
+
+// NewCountMin returns a new CountMin with given error bound epsilon that holds with probability 1-delta.
+// Both should be between 0 and 1; memory usage is proportional to ln(1/delta)/epsilon.
+func NewCountMin(epsilon, delta float64) *CountMin {
+	width := uint32(math.Ceil(math.E / epsilon))
+	depth := uint32(math.Ceil(math.Log(1 / delta)))
+
+	if depth < 1 {
+		depth = 1
+	}
+
+	return &CountMin{
+		width:   width,
+		depth:   depth,
+		epsilon: epsilon,
+		counts:  make([]uint64, width*depth),
+	}
+}
+
+// Add adds weight to the count of key.
+func (c *CountMin) Add(key string, weight uint64) {
+	c.total += weight
+
+	h1, h2 := hashKey(key)
+	for row := uint32(0); row < c.depth; row++ {
+		c.counts[row*c.width+(h1+row*h2)%c.width] += weight
+	}
+}
+
+// Estimate returns estimated count of key.
+func (c *CountMin) Estimate(key string) uint64 {
+	estimate := uint64(math.MaxUint64)
+
+	h1, h2 := hashKey(key)
+	for row := uint32(0); row < c.depth; row++ {
+		if count := c.counts[row*c.width+(h1+row*h2)%c.width]; count < estimate {
+			estimate = count
+		}
+	}
+
+	return estimate
+}
+
+// Total returns sum of all added weights.
+func (c *CountMin) Total() uint64 {
+	return c.total
+}
+
+// ErrorBound returns the most an estimate exceeds the true count with probability 1-delta.
+func (c *CountMin) ErrorBound() uint64 {
+	return uint64(math.Ceil(c.epsilon * float64(c.total)))
+}
+
+// hashKey returns two hashes of key, hashes of rows are derived from them as h1 + row*h2.
+func hashKey(key string) (uint32, uint32) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	sum := h.Sum64()
+
+	return uint32(sum), uint32(sum>>32) | 1
+}
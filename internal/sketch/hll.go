@@ -0,0 +1,139 @@
+package sketch
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+
+	"github.com/pkg/errors"
+)
+
+// Precision bounds of HyperLogLog.
+const (
+	MinPrecision = 4
+	MaxPrecision = 16
+)
+
+// ErrWrongPrecision is returned if precision of HyperLogLog is out of bounds.
+var ErrWrongPrecision = errors.New("wrong precision")
+
+// HyperLogLog estimates amount of distinct keys with relative error about 1.04/sqrt(2^precision).
+// Small sets are kept as exact lists of key hashes and are switched to registers once registers take less memory,
+// so the long tail of small sets costs little and is counted exactly.
+type HyperLogLog struct {
+	precision uint8
+	// hashes are distinct hashes of keys while the set is small, nil after switching to registers
+	hashes    []uint64
+	registers []uint8
+}
+
+// NewHyperLogLog returns a new HyperLogLog with 2^precision registers.
+func NewHyperLogLog(precision uint8) (*HyperLogLog, error) {
+	if precision < MinPrecision || precision > MaxPrecision {
+		return nil, errors.Wrapf(ErrWrongPrecision, "precision should be between %d and %d", MinPrecision, MaxPrecision)
+	}
+
+	return &HyperLogLog{precision: precision}, nil
+}
+
+// Add adds key to the set.
+func (h *HyperLogLog) Add(key string) {
+	h.AddHash(Hash64(key))
+}
+
+// AddHash adds a key by its hash returned by Hash64, so the same key can be hashed once for many sets.
+func (h *HyperLogLog) AddHash(x uint64) {
+	if h.registers != nil {
+		h.addToRegisters(x)
+
+		return
+	}
+
+	for _, hash := range h.hashes {
+		if hash == x {
+			return
+		}
+	}
+
+	h.hashes = append(h.hashes, x)
+
+	// a hash takes 8 bytes and a register takes 1
+	if len(h.hashes) > (1<<h.precision)/8 {
+		h.registers = make([]uint8, 1<<h.precision)
+		for _, hash := range h.hashes {
+			h.addToRegisters(hash)
+		}
+
+		h.hashes = nil
+	}
+}
+
+func (h *HyperLogLog) addToRegisters(x uint64) {
+	idx := x >> (64 - h.precision)
+	// the guard bit bounds rank when the rest of the hash is zero
+	rank := uint8(bits.LeadingZeros64(x<<h.precision|1<<(h.precision-1))) + 1
+
+	if rank > h.registers[idx] {
+		h.registers[idx] = rank
+	}
+}
+
+// Count returns estimated amount of distinct keys.
+func (h *HyperLogLog) Count() uint64 {
+	if h.registers == nil {
+		return uint64(len(h.hashes))
+	}
+
+	m := float64(len(h.registers))
+
+	var (
+		sum   float64
+		zeros int
+	)
+
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	estimate := alpha(len(h.registers)) * m * m / sum
+
+	// linear counting is more accurate for small cardinalities
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(math.Round(estimate))
+}
+
+func alpha(m int) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	default:
+		return 0.7213 / (1 + 1.079/float64(m))
+	}
+}
+
+// Hash64 returns a well mixed 64-bit hash of key.
+func Hash64(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+
+	// FNV hashes of similar short keys differ in few bits, the splitmix64 finalizer spreads them over all bits
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
@@ -0,0 +1,131 @@
+package sketch_test
+
+import (
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/sketch"
+)
+
+func TestSpaceSaving_Exact(t *testing.T) {
+	s := sketch.NewSpaceSaving(3)
+	s.Add("a", 1)
+	s.Add("b", 5)
+	s.Add("a", 2)
+	s.Add("c", 3)
+	s.Add("d", 0)
+
+	want := []sketch.Counter{
+		{Key: "b", Count: 5},
+		{Key: "a", Count: 3},
+		{Key: "c", Count: 3},
+	}
+	if got := s.Top(10); !reflect.DeepEqual(got, want) {
+		t.Errorf("Top() = %v, want %v", got, want)
+	}
+
+	if got := s.Top(1); !reflect.DeepEqual(got, want[:1]) {
+		t.Errorf("Top() = %v, want %v", got, want[:1])
+	}
+}
+
+func TestSpaceSaving_Eviction(t *testing.T) {
+	s := sketch.NewSpaceSaving(2)
+	s.Add("a", 10)
+	s.Add("b", 1)
+	s.Add("c", 2)
+
+	// c evicts b and inherits its count as error
+	want := []sketch.Counter{
+		{Key: "a", Count: 10},
+		{Key: "c", Count: 3, Error: 1},
+	}
+	if got := s.Top(2); !reflect.DeepEqual(got, want) {
+		t.Errorf("Top() = %v, want %v", got, want)
+	}
+}
+
+func TestTopK_Bounds(t *testing.T) {
+	// keys with power law counts, streamed in random order
+	r := rand.New(rand.NewSource(1)) //nolint:gosec // test data
+	zipf := rand.NewZipf(r, 1.2, 1, 9999)
+	exact := make(map[string]uint64)
+	topK := sketch.NewTopK(200, 0.001, 0.01)
+	cm := sketch.NewCountMin(0.001, 0.01)
+
+	for i := 0; i < 100000; i++ {
+		key := strconv.FormatUint(zipf.Uint64(), 10)
+		exact[key]++
+		topK.Add(key, 1)
+		cm.Add(key, 1)
+	}
+
+	for key, count := range exact {
+		if estimate := cm.Estimate(key); estimate < count {
+			t.Fatalf("CountMin.Estimate(%s) = %d, underestimates %d", key, estimate, count)
+		}
+	}
+
+	top := topK.Top(20)
+	if len(top) != 20 {
+		t.Fatalf("Top() returned %d counters, want 20", len(top))
+	}
+
+	for _, c := range top {
+		if count := exact[c.Key]; count > c.Count || count < c.Count-c.Error {
+			t.Errorf("Top() counter %+v doesn't bound true count %d", c, count)
+		}
+	}
+
+	// the heaviest keys of a skewed stream are found exactly
+	for i, key := range []string{"0", "1", "2"} {
+		if top[i].Key != key {
+			t.Errorf("Top()[%d] = %+v, want key %s", i, top[i], key)
+		}
+	}
+}
+
+func TestHyperLogLog(t *testing.T) {
+	tests := []struct {
+		name     string
+		distinct int
+		// maxError is relative error allowed, three standard errors of precision 12
+		maxError float64
+	}{
+		{name: "small sets are exact", distinct: 500},
+		{name: "registers", distinct: 10000, maxError: 0.05},
+		{name: "many keys", distinct: 200000, maxError: 0.05},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := sketch.NewHyperLogLog(12)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// every key is added twice
+			for i := 0; i < 2*tt.distinct; i++ {
+				h.Add("user-" + strconv.Itoa(i%tt.distinct))
+			}
+
+			got := float64(h.Count())
+			if diff := got/float64(tt.distinct) - 1; diff > tt.maxError || diff < -tt.maxError {
+				t.Errorf("Count() = %v, want %d with relative error up to %v", got, tt.distinct, tt.maxError)
+			}
+		})
+	}
+}
+
+func TestNewHyperLogLog_WrongPrecision(t *testing.T) {
+	for _, precision := range []uint8{sketch.MinPrecision - 1, sketch.MaxPrecision + 1} {
+		if _, err := sketch.NewHyperLogLog(precision); !errors.Is(err, sketch.ErrWrongPrecision) {
+			t.Errorf("NewHyperLogLog(%d) error = %v, want %v", precision, err, sketch.ErrWrongPrecision)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Package sketch implements streaming summaries that estimate frequencies of keys in fixed memory.
+// They are used for approximate aggregation of inputs too big to count every key exactly.
+package sketch
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// Counter is an estimated count of a key. True count of the key is between Count-Error and Count.
+type Counter struct {
+	Key   string
+	Count uint64
+	Error uint64
+}
+
+// SpaceSaving finds heavy hitters of a stream of weighted keys with the Space-Saving algorithm.
+// It keeps at most capacity counters. Any key with true count greater than Total()/capacity is guaranteed
+// to have a counter, and counts are overestimated by at most Total()/capacity.
+type SpaceSaving struct {
+	capacity int
+	total    uint64
+	counters counterHeap
+}
+
+// NewSpaceSaving returns a new SpaceSaving with given amount of counters. Capacity less than 1 is treated as 1.
+func NewSpaceSaving(capacity int) *SpaceSaving {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	return &SpaceSaving{
+		capacity: capacity,
+		counters: counterHeap{idxByKey: make(map[string]int, capacity)},
+	}
+}
+
+// Add adds weight to the count of key.
+func (s *SpaceSaving) Add(key string, weight uint64) {
+	if weight == 0 {
+		return
+	}
+
+	s.total += weight
+
+	if i, ok := s.counters.idxByKey[key]; ok {
+		s.counters.items[i].Count += weight
+		heap.Fix(&s.counters, i)
+
+		return
+	}
+
+	if len(s.counters.items) < s.capacity {
+		heap.Push(&s.counters, Counter{Key: key, Count: weight})
+
+		return
+	}
+
+	// the key replaces the least counted one and inherits its count as possible error
+	minCounter := &s.counters.items[0]
+	delete(s.counters.idxByKey, minCounter.Key)
+	s.counters.idxByKey[key] = 0
+	*minCounter = Counter{Key: key, Count: minCounter.Count + weight, Error: minCounter.Count}
+	heap.Fix(&s.counters, 0)
+}
+
+// Total returns sum of all added weights.
+func (s *SpaceSaving) Total() uint64 {
+	return s.total
+}
+
+// Top returns at most n counters with the biggest counts, sorted by count descending and then by key.
+func (s *SpaceSaving) Top(n int) []Counter {
+	top := make([]Counter, len(s.counters.items))
+	copy(top, s.counters.items)
+
+	sortCounters(top)
+
+	if n < len(top) {
+		top = top[:n]
+	}
+
+	return top
+}
+
+// sortCounters sorts counters by count descending and then by key, so rankings don't depend on order of the stream.
+func sortCounters(counters []Counter) {
+	sort.Slice(counters, func(i, j int) bool {
+		if counters[i].Count != counters[j].Count {
+			return counters[i].Count > counters[j].Count
+		}
+
+		return counters[i].Key < counters[j].Key
+	})
+}
+
+// counterHeap is a min-heap of counters by count that keeps track of counter indices by key.
+type counterHeap struct {
+	items    []Counter
+	idxByKey map[string]int
+}
+
+func (h *counterHeap) Len() int { return len(h.items) }
+
+func (h *counterHeap) Less(i, j int) bool { return h.items[i].Count < h.items[j].Count }
+
+func (h *counterHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.idxByKey[h.items[i].Key] = i
+	h.idxByKey[h.items[j].Key] = j
+}
+
+func (h *counterHeap) Push(x interface{}) {
+	c := x.(Counter) //nolint:forcetypeassert // only counters are pushed
+	h.idxByKey[c.Key] = len(h.items)
+	h.items = append(h.items, c)
+}
+
+func (h *counterHeap) Pop() interface{} {
+	c := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	delete(h.idxByKey, c.Key)
+
+	return c
+}
@@ -0,0 +1,52 @@
+package sketch
+
+// TopK finds keys with the biggest counts in a stream in fixed memory. Heavy hitters are tracked by SpaceSaving,
+// and their counts are refined by CountMin, which is usually tighter for keys that were evicted and came back.
+type TopK struct {
+	ss *SpaceSaving
+	cm *CountMin
+}
+
+// NewTopK returns a new TopK tracking capacity heavy hitters, whose Count-Min Sketch has error bound epsilon
+// with probability 1-delta.
+func NewTopK(capacity int, epsilon, delta float64) *TopK {
+	return &TopK{
+		ss: NewSpaceSaving(capacity),
+		cm: NewCountMin(epsilon, delta),
+	}
+}
+
+// Add adds weight to the count of key.
+func (t *TopK) Add(key string, weight uint64) {
+	if weight == 0 {
+		return
+	}
+
+	t.ss.Add(key, weight)
+	t.cm.Add(key, weight)
+}
+
+// Top returns at most n counters with the biggest counts, sorted by count descending and then by key.
+// True count of every key is between Count-Error and Count, the upper bound holds with high probability.
+func (t *TopK) Top(n int) []Counter {
+	top := t.ss.Top(t.ss.capacity)
+
+	for i := range top {
+		// both sketches only overestimate, so the smaller estimate is closer, and SpaceSaving bounds it from below
+		lower := top[i].Count - top[i].Error
+
+		if estimate := t.cm.Estimate(top[i].Key); estimate < top[i].Count {
+			top[i].Count = estimate
+		}
+
+		top[i].Error = top[i].Count - lower
+	}
+
+	sortCounters(top)
+
+	if n < len(top) {
+		top = top[:n]
+	}
+
+	return top
+}
@@ -0,0 +1,558 @@
+// Package snapshot implements aggregate snapshots of GitHub data archives.
+// A snapshot keeps per-actor and per-repository counters of an archive instead of its events, so it is much
+// smaller than the archive, and snapshots of several archives can be merged into a rollup which is the same
+// as a snapshot of the archives decoded together. Hourly snapshots can be merged into daily ones,
+// daily ones into weekly ones and so on, without keeping raw archives.
+package snapshot
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+// Version is a version of snapshot file layout. It must be incremented on every change of the layout.
+const Version = 1
+
+// magic starts every snapshot file, so snapshots are told apart from archives, which are gzip streams too.
+const magic = "ghanalytics-snapshot\n"
+
+var (
+	// ErrNotSnapshot is returned when a file is not a snapshot.
+	ErrNotSnapshot = errors.New("not a snapshot")
+	// ErrUnsupportedVersion is returned when a snapshot was written by another version of the layout.
+	ErrUnsupportedVersion = errors.New("unsupported snapshot version")
+	// ErrOverlap is returned when merged snapshots have the same archive, its events would be counted twice.
+	ErrOverlap = errors.New("snapshots overlap")
+	// ErrMixedPseudonyms is returned when pseudonymized snapshots are merged with plain ones,
+	// the same actors and repositories would be counted as different ones.
+	ErrMixedPseudonyms = errors.New("pseudonymized and plain snapshots are mixed")
+)
+
+// Snapshot is aggregates of one or more archives.
+type Snapshot struct {
+	Version int `json:"version"`
+	// Sources are archives the snapshot is built from.
+	Sources []Source `json:"sources"`
+	// Actors are keyed by ID, they include bots and actors which are not listed in actors CSV.
+	Actors map[string]Actor `json:"actors"`
+	// Repos are keyed by ID, they include repositories which are not listed in repos CSV.
+	Repos        map[string]Repo `json:"repos"`
+	EventsByType map[string]int  `json:"events_by_type"`
+	// Pseudonymized is set if IDs, usernames and names are pseudonyms, see github.Pseudonymizer.
+	Pseudonymized bool `json:"pseudonymized,omitempty"`
+}
+
+// Source is an archive a snapshot is built from.
+type Source struct {
+	Path string `json:"path"`
+	// SHA256 identifies the archive, snapshots of the same archive are not merged.
+	SHA256 string `json:"sha256"`
+	Events int    `json:"events"`
+}
+
+// Actor is counters of a GitHub actor.
+type Actor struct {
+	Username string `json:"username,omitempty"`
+	// Listed is set if the actor is listed in actors CSV of any archive, only listed actors are users.
+	Listed        bool `json:"listed,omitempty"`
+	PushedCommits int  `json:"pushed_commits,omitempty"`
+	PullRequests  int  `json:"pull_requests,omitempty"`
+	WatchEvents   int  `json:"watch_events,omitempty"`
+}
+
+// Repo is counters of a GitHub repository and IDs of its distinct actors, sorted, so they can be merged exactly.
+type Repo struct {
+	Name string `json:"name,omitempty"`
+	// Listed is set if the repository is listed in repos CSV of any archive.
+	Listed             bool     `json:"listed,omitempty"`
+	CommitsPushed      int      `json:"commits_pushed,omitempty"`
+	PullRequests       int      `json:"pull_requests,omitempty"`
+	WatchEvents        int      `json:"watch_events,omitempty"`
+	Pushers            []string `json:"pushers,omitempty"`
+	PullRequestAuthors []string `json:"pr_authors,omitempty"`
+	Watchers           []string `json:"watchers,omitempty"`
+}
+
+// New returns snapshot of the dataset decoded from the source archive.
+// Aggregation stops with ctx.Err() as soon as ctx is done.
+func New(ctx context.Context, ds *github.Dataset, source Source) (*Snapshot, error) {
+	actors := make([]Actor, len(ds.Actors.IDs))
+	for i := range actors {
+		actors[i] = Actor{Username: ds.Actors.Usernames[i], Listed: i < ds.Actors.Listed}
+	}
+
+	repos := make([]Repo, len(ds.Repos.IDs))
+	for i := range repos {
+		repos[i] = Repo{Name: ds.Repos.Names[i], Listed: i < ds.Repos.Listed}
+	}
+
+	et := &ds.Events
+	pushType, prType, watchType := et.TypeIndex(github.PushEventType), et.TypeIndex(github.PullRequestEventType),
+		et.TypeIndex(github.WatchEventType)
+
+	eventsByType := make(map[string]int, len(et.TypeNames))
+
+	for i := 0; i < et.Len(); i++ {
+		if i%(1<<16) == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		eventsByType[et.TypeNames[et.Types[i]]]++
+
+		a, r, actorID := &actors[et.Actors[i]], &repos[et.Repos[i]], ds.Actors.IDs[et.Actors[i]]
+
+		switch int(et.Types[i]) {
+		case pushType:
+			a.PushedCommits += int(et.Commits[i])
+			r.CommitsPushed += int(et.Commits[i])
+			r.Pushers = append(r.Pushers, actorID)
+		case prType:
+			a.PullRequests++
+			r.PullRequests++
+			r.PullRequestAuthors = append(r.PullRequestAuthors, actorID)
+		case watchType:
+			a.WatchEvents++
+			r.WatchEvents++
+			r.Watchers = append(r.Watchers, actorID)
+		}
+	}
+
+	s := &Snapshot{
+		Version:      Version,
+		Sources:      []Source{source},
+		Actors:       make(map[string]Actor, len(actors)),
+		Repos:        make(map[string]Repo, len(repos)),
+		EventsByType: eventsByType,
+	}
+
+	for i, a := range actors {
+		s.Actors[ds.Actors.IDs[i]] = a
+	}
+
+	for i, r := range repos {
+		r.Pushers, r.PullRequestAuthors, r.Watchers = distinct(r.Pushers), distinct(r.PullRequestAuthors), distinct(r.Watchers)
+		s.Repos[ds.Repos.IDs[i]] = r
+	}
+
+	return s, nil
+}
+
+// Merge returns a snapshot of all archives of the snapshots, which is the same as a snapshot of the archives
+// decoded together: counters are summed, sets of distinct actors are united, and the name from the last
+// snapshot listing an actor or a repository wins. Merging is associative, so rollups can be merged further.
+// The snapshots are not modified. ErrOverlap is returned if an archive is in more than one snapshot,
+// and ErrMixedPseudonyms if some of the snapshots are pseudonymized and others are not.
+func Merge(snapshots ...*Snapshot) (*Snapshot, error) {
+	merged := &Snapshot{
+		Version:      Version,
+		Sources:      []Source{},
+		Actors:       make(map[string]Actor),
+		Repos:        make(map[string]Repo),
+		EventsByType: make(map[string]int),
+	}
+
+	hashes := make(map[string]string)
+
+	for i, s := range snapshots {
+		if i > 0 && s.Pseudonymized != merged.Pseudonymized {
+			return nil, ErrMixedPseudonyms
+		}
+
+		merged.Pseudonymized = s.Pseudonymized
+
+		for _, src := range s.Sources {
+			if path, ok := hashes[src.SHA256]; ok && src.SHA256 != "" {
+				return nil, errors.Wrapf(ErrOverlap, "%s and %s are the same archive", path, src.Path)
+			}
+
+			hashes[src.SHA256] = src.Path
+			merged.Sources = append(merged.Sources, src)
+		}
+
+		for id, a := range s.Actors {
+			m, ok := merged.Actors[id]
+			if !ok || a.Listed {
+				m.Username = a.Username
+			}
+
+			m.Listed = m.Listed || a.Listed
+			m.PushedCommits += a.PushedCommits
+			m.PullRequests += a.PullRequests
+			m.WatchEvents += a.WatchEvents
+			merged.Actors[id] = m
+		}
+
+		for id, r := range s.Repos {
+			m, ok := merged.Repos[id]
+			if !ok || r.Listed {
+				m.Name = r.Name
+			}
+
+			m.Listed = m.Listed || r.Listed
+			m.CommitsPushed += r.CommitsPushed
+			m.PullRequests += r.PullRequests
+			m.WatchEvents += r.WatchEvents
+			m.Pushers = union(m.Pushers, r.Pushers)
+			m.PullRequestAuthors = union(m.PullRequestAuthors, r.PullRequestAuthors)
+			m.Watchers = union(m.Watchers, r.Watchers)
+			merged.Repos[id] = m
+		}
+
+		for eventType, count := range s.EventsByType {
+			merged.EventsByType[eventType] += count
+		}
+	}
+
+	return merged, nil
+}
+
+// Filter returns a copy of the snapshot with the selected actors which pushed commits, created pull requests
+// or watched selected repositories, and the selected repositories where selected actors did, the way
+// github.Dataset.Filter keeps only actors and repositories of selected events. Snapshots have no activity
+// of actors in repositories, so counters still count events of all actors in all repositories, and so do
+// events by type; only distinct actors of repositories are narrowed down. So counters of actors are the same
+// as of the filtered archive only if the filter doesn't select repositories, and counters of repositories
+// only if it doesn't select users. The snapshot itself is returned if the filter is empty.
+func (s *Snapshot) Filter(f *github.EntityFilter) *Snapshot {
+	if f.Empty() {
+		return s
+	}
+
+	selected := func(ids []string) []string {
+		var kept []string
+
+		for _, id := range ids {
+			if f.MatchUser(s.Actors[id].Username) {
+				kept = append(kept, id)
+			}
+		}
+
+		return kept
+	}
+
+	filtered := *s
+	filtered.Actors = make(map[string]Actor)
+	filtered.Repos = make(map[string]Repo)
+
+	for id, r := range s.Repos {
+		if !f.MatchRepo(r.Name) {
+			continue
+		}
+
+		r.Pushers, r.PullRequestAuthors, r.Watchers = selected(r.Pushers), selected(r.PullRequestAuthors), selected(r.Watchers)
+		if len(r.Pushers) == 0 && len(r.PullRequestAuthors) == 0 && len(r.Watchers) == 0 {
+			continue
+		}
+
+		filtered.Repos[id] = r
+
+		for _, ids := range [][]string{r.Pushers, r.PullRequestAuthors, r.Watchers} {
+			for _, actorID := range ids {
+				filtered.Actors[actorID] = s.Actors[actorID]
+			}
+		}
+	}
+
+	return &filtered
+}
+
+// Pseudonymize replaces IDs, usernames and names of the snapshot with pseudonyms in place,
+// the same way as github.Pseudonymizer.Dataset does. Pseudonymized snapshots are not modified.
+func (s *Snapshot) Pseudonymize(p *github.Pseudonymizer) {
+	if s.Pseudonymized {
+		return
+	}
+
+	actors := make(map[string]Actor, len(s.Actors))
+
+	for id, a := range s.Actors {
+		a.Username = p.Username(a.Username)
+		actors[p.ActorID(id)] = a
+	}
+
+	repos := make(map[string]Repo, len(s.Repos))
+
+	for id, r := range s.Repos {
+		r.Name = p.RepoName(r.Name)
+		r.Pushers = pseudonymizeIDs(p, r.Pushers)
+		r.PullRequestAuthors = pseudonymizeIDs(p, r.PullRequestAuthors)
+		r.Watchers = pseudonymizeIDs(p, r.Watchers)
+		repos[p.RepoID(id)] = r
+	}
+
+	s.Actors, s.Repos, s.Pseudonymized = actors, repos, true
+}
+
+// pseudonymizeIDs returns a new sorted set of pseudonyms of the actor IDs.
+func pseudonymizeIDs(p *github.Pseudonymizer, ids []string) []string {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	pseudonyms := make([]string, len(ids))
+	for i, id := range ids {
+		pseudonyms[i] = p.ActorID(id)
+	}
+
+	return distinct(pseudonyms)
+}
+
+// UsersSample returns the same sample as github.Dataset.UsersSample of the archives.
+// Bots with `botname[bot]` could be filtered out.
+func (s *Snapshot) UsersSample(botsIncluded bool) *github.UsersSample {
+	users := github.UsersSample{M: make(map[string]github.User, len(s.Actors))}
+
+	for id, a := range s.Actors {
+		if !a.Listed || (!botsIncluded && github.IsBotUsername(a.Username)) {
+			continue
+		}
+
+		users.M[id] = github.User{
+			ID:       id,
+			Username: a.Username,
+			Activity: github.ActorActivity{PushedCommits: a.PushedCommits, CreatedPullRequests: a.PullRequests},
+		}
+	}
+
+	return &users
+}
+
+// ReposSample returns the same sample as github.Dataset.ReposSample of the archives.
+func (s *Snapshot) ReposSample() *github.ReposSample {
+	repos := github.ReposSample{M: make(map[string]github.Repo, len(s.Repos))}
+
+	for id, r := range s.Repos {
+		if !r.Listed && r.CommitsPushed == 0 && r.WatchEvents == 0 {
+			continue
+		}
+
+		repos.M[id] = github.Repo{ID: id, Name: r.Name, CommitsPushed: r.CommitsPushed, WatchEvents: r.WatchEvents}
+	}
+
+	return &repos
+}
+
+// ContributorsSample returns the same sample as github.Dataset.ContributorsSample of the archives.
+func (s *Snapshot) ContributorsSample() *github.ContributorsSample {
+	contributors := github.ContributorsSample{M: make(map[string]github.RepoContributors, len(s.Repos))}
+
+	for id, r := range s.Repos {
+		rc := github.RepoContributors{
+			ID:                 id,
+			Name:               r.Name,
+			Pushers:            len(r.Pushers),
+			PullRequestAuthors: len(r.PullRequestAuthors),
+			Watchers:           len(r.Watchers),
+			Contributors:       len(union(r.Pushers, r.PullRequestAuthors)),
+		}
+
+		if !r.Listed && rc.Contributors == 0 && rc.Watchers == 0 {
+			continue
+		}
+
+		contributors.M[id] = rc
+	}
+
+	return &contributors
+}
+
+// Stats returns the same stats as github.Dataset.Stats of the archives.
+// Bots with `botname[bot]` could be filtered out.
+func (s *Snapshot) Stats(botsIncluded bool) *github.Stats {
+	var userCounts, repoCounts [3][]int
+
+	for _, a := range s.Actors {
+		if !a.Listed || (!botsIncluded && github.IsBotUsername(a.Username)) {
+			continue
+		}
+
+		userCounts[0] = append(userCounts[0], a.PushedCommits)
+		userCounts[1] = append(userCounts[1], a.PullRequests)
+		userCounts[2] = append(userCounts[2], a.WatchEvents)
+	}
+
+	for _, r := range s.Repos {
+		repoCounts[0] = append(repoCounts[0], r.CommitsPushed)
+		repoCounts[1] = append(repoCounts[1], r.PullRequests)
+		repoCounts[2] = append(repoCounts[2], r.WatchEvents)
+	}
+
+	return &github.Stats{
+		Users: github.NewEntityStats(userCounts[0], userCounts[1], userCounts[2]),
+		Repos: github.NewEntityStats(repoCounts[0], repoCounts[1], repoCounts[2]),
+	}
+}
+
+// Write writes the snapshot as gzipped JSON after the magic line.
+func Write(w io.Writer, s *Snapshot) error {
+	if _, err := io.WriteString(w, magic); err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(w)
+
+	if err := json.NewEncoder(zw).Encode(s); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// Read reads a snapshot written by Write. ErrNotSnapshot is returned if r is not a snapshot,
+// and ErrUnsupportedVersion if it has another version of the layout.
+func Read(r io.Reader) (*Snapshot, error) {
+	br := bufio.NewReader(r)
+
+	if !hasMagic(br) {
+		return nil, ErrNotSnapshot
+	}
+
+	if _, err := br.Discard(len(magic)); err != nil {
+		return nil, err
+	}
+
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't decompress snapshot")
+	}
+
+	var s Snapshot
+	if err := json.NewDecoder(zr).Decode(&s); err != nil {
+		return nil, errors.Wrap(err, "can't decode snapshot")
+	}
+
+	if s.Version != Version {
+		return nil, errors.Wrapf(ErrUnsupportedVersion, "version %d, want %d", s.Version, Version)
+	}
+
+	return &s, zr.Close()
+}
+
+// IsSnapshot reports whether the file is a snapshot, without reading more than the magic line.
+func IsSnapshot(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	return hasMagic(bufio.NewReaderSize(f, len(magic))), nil
+}
+
+func hasMagic(br *bufio.Reader) bool {
+	prefix, _ := br.Peek(len(magic))
+
+	return bytes.Equal(prefix, []byte(magic))
+}
+
+// Load reads the snapshot file.
+func Load(path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	s, err := Read(f)
+	if err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+
+	return s, f.Close()
+}
+
+// Save writes the snapshot to a temporary file first, so a partially written snapshot is never read.
+func Save(path string, s *Snapshot) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if err := tmp.Chmod(0o644); err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(tmp)
+
+	if err := Write(w, s); err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// distinct sorts the IDs and removes duplicates in place.
+func distinct(ids []string) []string {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	sort.Strings(ids)
+
+	unique := ids[:1]
+
+	for _, id := range ids[1:] {
+		if id != unique[len(unique)-1] {
+			unique = append(unique, id)
+		}
+	}
+
+	return unique
+}
+
+// union returns a new sorted set of IDs of both sorted sets.
+func union(a, b []string) []string {
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+
+	u := make([]string, 0, len(a)+len(b))
+
+	for len(a) > 0 || len(b) > 0 {
+		switch {
+		case len(b) == 0 || (len(a) > 0 && a[0] < b[0]):
+			u = append(u, a[0])
+			a = a[1:]
+		case len(a) == 0 || b[0] < a[0]:
+			u = append(u, b[0])
+			b = b[1:]
+		default:
+			u = append(u, a[0])
+			a, b = a[1:], b[1:]
+		}
+	}
+
+	return u
+}
@@ -0,0 +1,314 @@
+package snapshot_test
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/snapshot"
+	"github.com/levakin/analytics-software-engineer-assignment/samples"
+)
+
+const archivePath = "data.tar.gz"
+
+// TestMerge splits the sample archive in two and checks that merged snapshots of the halves are the same as
+// a snapshot of both halves decoded together, and that samples of the merged snapshot are the same as samples
+// of the whole archive.
+func TestMerge(t *testing.T) {
+	ctx := context.Background()
+
+	archive, err := samples.Archive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actors, repoCSVs, events, commits := archive.Actors, archive.Repos, archive.Events, archive.Commits
+	half := len(events) / 2
+	parts := []*github.Dataset{
+		github.NewDataset(actors[:len(actors)/2], repoCSVs, events[:half], commits),
+		github.NewDataset(actors[len(actors)/2:], nil, events[half:], commits),
+	}
+
+	merged, err := snapshot.Merge(newSnapshot(t, parts[0], "a"), newSnapshot(t, parts[1], "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	union := newSnapshot(t, github.MergeDatasets(parts...), "")
+	union.Sources = merged.Sources
+
+	if !reflect.DeepEqual(merged, union) {
+		t.Error("Merge() of snapshots of halves differs from snapshot of merged halves")
+	}
+
+	whole := github.NewDataset(actors, repoCSVs, events, commits)
+
+	for _, bots := range []bool{false, true} {
+		users, err := whole.UsersSample(ctx, bots)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(merged.UsersSample(bots), users) {
+			t.Errorf("UsersSample(%v) differs from the whole archive", bots)
+		}
+
+		stats, err := whole.Stats(ctx, bots)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(merged.Stats(bots), stats) {
+			t.Errorf("Stats(%v) differs from the whole archive", bots)
+		}
+	}
+
+	repos, err := whole.ReposSample(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(merged.ReposSample(), repos) {
+		t.Error("ReposSample() differs from the whole archive")
+	}
+
+	contributors, err := whole.ContributorsSample(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(merged.ContributorsSample(), contributors) {
+		t.Error("ContributorsSample() differs from the whole archive")
+	}
+}
+
+func TestMerge_Associative(t *testing.T) {
+	archive, err := samples.Archive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actors, repoCSVs, events, commits := archive.Actors, archive.Repos, archive.Events, archive.Commits
+	third := len(events) / 3
+	a := newSnapshot(t, github.NewDataset(actors, repoCSVs, events[:third], commits), "a")
+	b := newSnapshot(t, github.NewDataset(actors[:len(actors)/3], nil, events[third:2*third], commits), "b")
+	c := newSnapshot(t, github.NewDataset(actors[len(actors)/3:], repoCSVs[:len(repoCSVs)/2], events[2*third:], commits), "c")
+
+	ab, err := snapshot.Merge(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bc, err := snapshot.Merge(b, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	left, err := snapshot.Merge(ab, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	right, err := snapshot.Merge(a, bc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := snapshot.Merge(a, b, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(left, right) || !reflect.DeepEqual(left, all) {
+		t.Error("Merge(Merge(a, b), c), Merge(a, Merge(b, c)) and Merge(a, b, c) differ")
+	}
+}
+
+func TestMerge_Overlap(t *testing.T) {
+	archive, err := samples.Archive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newSnapshot(t, archive.Dataset(), "a")
+
+	if _, err := snapshot.Merge(s, s); !errors.Is(err, snapshot.ErrOverlap) {
+		t.Errorf("Merge() error = %v, want %v", err, snapshot.ErrOverlap)
+	}
+}
+
+// TestSnapshot_Pseudonymize checks that a pseudonymized snapshot of the archive is the same as a snapshot
+// of the pseudonymized archive, and that it is not merged with plain snapshots.
+func TestSnapshot_Pseudonymize(t *testing.T) {
+	archive, err := samples.Archive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actors, repoCSVs, events, commits := archive.Actors, archive.Repos, archive.Events, archive.Commits
+
+	p, err := github.NewPseudonymizer([]byte("key"), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := newSnapshot(t, github.NewDataset(actors, repoCSVs, events, commits), "a")
+	got.Pseudonymize(p)
+
+	ds := github.NewDataset(actors, repoCSVs, events, commits)
+	p.Dataset(ds)
+
+	want := newSnapshot(t, ds, "a")
+	want.Pseudonymized = true
+
+	if !reflect.DeepEqual(got, want) {
+		t.Error("pseudonymized snapshot differs from snapshot of the pseudonymized archive")
+	}
+
+	plain := newSnapshot(t, github.NewDataset(actors, repoCSVs, events, commits), "b")
+
+	if _, err := snapshot.Merge(got, plain); !errors.Is(err, snapshot.ErrMixedPseudonyms) {
+		t.Errorf("Merge() error = %v, want %v", err, snapshot.ErrMixedPseudonyms)
+	}
+}
+
+func TestSnapshot_Filter(t *testing.T) {
+	archive, err := samples.Archive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ds := archive.Dataset()
+
+	f, err := github.NewEntityFilter(github.EntityFilterOptions{Owners: []string{"/^[a-m]/"}, ExcludeUsers: []string{`/\[bot]$/`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// counters of a snapshot of the filtered archive differ, so only selected users and repositories are compared;
+	// actors with events of other types only are not in the snapshot
+	s := newSnapshot(t, ds, "a")
+	got, want := s.Filter(f), newSnapshot(t, ds.Filter(f), "a")
+
+	if len(got.Actors) == 0 || !subset(ids(got.Actors), ids(want.Actors)) {
+		t.Error("Filter() selected other actors than a snapshot of the filtered archive")
+	}
+
+	if len(got.Repos) == 0 || !subset(ids(got.Repos), ids(want.Repos)) {
+		t.Error("Filter() selected other repositories than a snapshot of the filtered archive")
+	}
+
+	for _, r := range got.Repos {
+		for _, pusher := range r.Pushers {
+			if github.IsBotUsername(s.Actors[pusher].Username) {
+				t.Errorf("repository %s has excluded pusher %s", r.Name, pusher)
+			}
+		}
+	}
+
+	if s.Filter(nil) != s {
+		t.Error("Filter(nil) returned a copy")
+	}
+}
+
+// subset reports whether the sorted set a is a subset of the sorted set b.
+func subset(a, b []string) bool {
+	for _, v := range a {
+		i := sort.SearchStrings(b, v)
+		if i == len(b) || b[i] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ids returns sorted keys of the map.
+func ids(m interface{}) []string {
+	v := reflect.ValueOf(m)
+	keys := make([]string, 0, v.Len())
+
+	for _, k := range v.MapKeys() {
+		keys = append(keys, k.String())
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func TestSaveLoad(t *testing.T) {
+	archive, err := samples.Archive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := newSnapshot(t, archive.Dataset(), "a")
+	path := filepath.Join(t.TempDir(), "hour.ghasnap")
+
+	if err := snapshot.Save(path, want); err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := snapshot.IsSnapshot(path)
+	if err != nil || !ok {
+		t.Fatalf("IsSnapshot() = %v, %v, want true", ok, err)
+	}
+
+	got, err := snapshot.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Error("Load() differs from the saved snapshot")
+	}
+}
+
+func TestRead_Errors(t *testing.T) {
+	archive, err := samples.FS.ReadFile(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var future bytes.Buffer
+	if err := snapshot.Write(&future, &snapshot.Snapshot{Version: snapshot.Version + 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{name: "archive", data: archive, want: snapshot.ErrNotSnapshot},
+		{name: "empty", data: nil, want: snapshot.ErrNotSnapshot},
+		{name: "future version", data: future.Bytes(), want: snapshot.ErrUnsupportedVersion},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := snapshot.Read(bytes.NewReader(tt.data)); !errors.Is(err, tt.want) {
+				t.Errorf("Read() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func newSnapshot(tb testing.TB, ds *github.Dataset, hash string) *snapshot.Snapshot {
+	tb.Helper()
+
+	s, err := snapshot.New(context.Background(), ds, snapshot.Source{Path: hash + ".tar.gz", SHA256: hash, Events: ds.Events.Len()})
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return s
+}
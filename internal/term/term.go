@@ -0,0 +1,13 @@
+// Package term switches terminals to raw mode and reads their size, for interactive commands.
+// Only Linux, macOS and BSDs are supported, elsewhere functions return ErrUnsupported.
+package term
+
+import "github.com/pkg/errors"
+
+// ErrUnsupported is returned on platforms where terminals can't be controlled.
+var ErrUnsupported = errors.New("terminal control is not supported on this platform")
+
+// State is a saved state of a terminal, to restore it after raw mode.
+type State struct {
+	state state
+}
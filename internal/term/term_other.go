@@ -0,0 +1,26 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd
+
+package term
+
+type state struct{}
+
+// IsTerminal reports whether fd is a terminal.
+func IsTerminal(fd int) bool {
+	return false
+}
+
+// MakeRaw switches the terminal to raw mode.
+func MakeRaw(fd int) (*State, error) {
+	return nil, ErrUnsupported
+}
+
+// Restore restores the terminal to the state saved by MakeRaw.
+func Restore(fd int, s *State) error {
+	return ErrUnsupported
+}
+
+// Size returns width and height of the terminal in characters.
+func Size(fd int) (int, int, error) {
+	return 0, 0, ErrUnsupported
+}
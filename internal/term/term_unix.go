@@ -0,0 +1,71 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+// +build linux darwin freebsd netbsd openbsd
+
+package term
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+type state struct {
+	termios syscall.Termios
+}
+
+// IsTerminal reports whether fd is a terminal.
+func IsTerminal(fd int) bool {
+	var termios syscall.Termios
+
+	return ioctl(fd, ioctlGetTermios, unsafe.Pointer(&termios)) == nil
+}
+
+// MakeRaw switches the terminal to raw mode: input is read byte by byte without echo, and Ctrl-C is read as a byte
+// instead of a signal. Output processing is kept, so "\n" still moves to the beginning of the next line.
+// The returned state restores the terminal with Restore.
+func MakeRaw(fd int) (*State, error) {
+	var old State
+	if err := ioctl(fd, ioctlGetTermios, unsafe.Pointer(&old.state.termios)); err != nil {
+		return nil, err
+	}
+
+	raw := old.state.termios
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR |
+		syscall.ICRNL | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+
+	if err := ioctl(fd, ioctlSetTermios, unsafe.Pointer(&raw)); err != nil {
+		return nil, err
+	}
+
+	return &old, nil
+}
+
+// Restore restores the terminal to the state saved by MakeRaw.
+func Restore(fd int, s *State) error {
+	return ioctl(fd, ioctlSetTermios, unsafe.Pointer(&s.state.termios))
+}
+
+// Size returns width and height of the terminal in characters.
+func Size(fd int) (int, int, error) {
+	var ws struct {
+		Row, Col, Xpixel, Ypixel uint16
+	}
+
+	if err := ioctl(fd, syscall.TIOCGWINSZ, unsafe.Pointer(&ws)); err != nil {
+		return 0, 0, err
+	}
+
+	return int(ws.Col), int(ws.Row), nil
+}
+
+func ioctl(fd int, req uint, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(req), uintptr(arg)); errno != 0 {
+		return errno
+	}
+
+	return nil
+}
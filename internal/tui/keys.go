@@ -0,0 +1,88 @@
+package tui
+
+import "unicode/utf8"
+
+// Key is a key pressed by the user: a printable character or one of the special keys.
+type Key string
+
+// Special keys.
+const (
+	KeyUp        Key = "up"
+	KeyDown      Key = "down"
+	KeyLeft      Key = "left"
+	KeyRight     Key = "right"
+	KeyPageUp    Key = "pgup"
+	KeyPageDown  Key = "pgdown"
+	KeyHome      Key = "home"
+	KeyEnd       Key = "end"
+	KeyEnter     Key = "enter"
+	KeyEsc       Key = "esc"
+	KeyBackspace Key = "backspace"
+	KeyDelete    Key = "delete"
+	KeyTab       Key = "tab"
+	KeyCtrlA     Key = "ctrl-a"
+	KeyCtrlC     Key = "ctrl-c"
+	KeyCtrlD     Key = "ctrl-d"
+	KeyCtrlE     Key = "ctrl-e"
+	KeyCtrlL     Key = "ctrl-l"
+	KeyCtrlU     Key = "ctrl-u"
+	KeyCtrlW     Key = "ctrl-w"
+)
+
+var escapeSequences = map[string]Key{
+	"[A": KeyUp, "[B": KeyDown, "[C": KeyRight, "[D": KeyLeft,
+	"OA": KeyUp, "OB": KeyDown, "OC": KeyRight, "OD": KeyLeft,
+	"[5~": KeyPageUp, "[6~": KeyPageDown, "[3~": KeyDelete,
+	"[H": KeyHome, "[F": KeyEnd, "[1~": KeyHome, "[4~": KeyEnd, "OH": KeyHome, "OF": KeyEnd,
+}
+
+var controlKeys = map[byte]Key{
+	1: KeyCtrlA, 3: KeyCtrlC, 4: KeyCtrlD, 5: KeyCtrlE, 9: KeyTab, 12: KeyCtrlL, 21: KeyCtrlU, 23: KeyCtrlW,
+	'\r': KeyEnter, '\n': KeyEnter, 127: KeyBackspace, 8: KeyBackspace,
+}
+
+// ParseKeys splits input read from a terminal in raw mode into keys. Unknown escape sequences and control
+// characters are dropped.
+func ParseKeys(b []byte) []Key {
+	var keys []Key
+
+	for len(b) > 0 {
+		switch {
+		case b[0] == 27:
+			key, n := parseEscape(b)
+			if key != "" {
+				keys = append(keys, key)
+			}
+
+			b = b[n:]
+		case b[0] < 32 || b[0] == 127:
+			if key, ok := controlKeys[b[0]]; ok {
+				keys = append(keys, key)
+			}
+
+			b = b[1:]
+		default:
+			r, n := utf8.DecodeRune(b)
+			keys = append(keys, Key(string(r)))
+			b = b[n:]
+		}
+	}
+
+	return keys
+}
+
+// parseEscape parses an escape sequence at the beginning of b and returns the key and length of the sequence.
+func parseEscape(b []byte) (Key, int) {
+	if len(b) == 1 || (b[1] != '[' && b[1] != 'O') {
+		return KeyEsc, 1
+	}
+
+	// CSI sequences end with a byte from @ to ~
+	for i := 2; i < len(b); i++ {
+		if b[i] >= '@' && b[i] <= '~' {
+			return escapeSequences[string(b[1:i+1])], i + 1
+		}
+	}
+
+	return "", len(b)
+}
@@ -0,0 +1,540 @@
+// Package tui implements an interactive terminal UI for exploring rankings of a loaded dataset.
+// Model holds the state of the UI, changes it on key presses and renders it into lines of text,
+// so it doesn't depend on a real terminal.
+package tui
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+)
+
+const (
+	defaultN = 20
+	// nStep is how much + and - change N.
+	nStep = 10
+)
+
+type view int
+
+const (
+	usersView view = iota
+	reposView
+	detailsView
+)
+
+// prompt is a line of text the user is typing.
+type prompt int
+
+const (
+	noPrompt prompt = iota
+	searchPrompt
+	nPrompt
+)
+
+// row is a ranked user or repository.
+type row struct {
+	id     string
+	name   string
+	bot    bool
+	values []int
+}
+
+// table is a ranking of users or repositories.
+type table struct {
+	// columns are titles of values of rows, the name column comes before them
+	columns []string
+	rows    []row
+	// sortBy is 0 to sort by name or index of the value column plus one
+	sortBy int
+}
+
+// Model is the state of the UI.
+type Model struct {
+	ctx context.Context
+	// ds is nil if the model shows samples of snapshots, which have no details
+	ds *github.Dataset
+
+	tables [2]*table
+	view   view
+	// listView is the ranking shown before details were opened
+	listView view
+	n        int
+	bots     bool
+	search   string
+	cursor   int
+
+	prompt prompt
+	input  string
+
+	// details are the opened user or repository, the last one is shown
+	details []*detailsPage
+	status  string
+}
+
+type detailsPage struct {
+	details *github.Details
+	// user is set if details are of a user, and counterparts are repositories
+	user   bool
+	cursor int
+}
+
+// New returns a new Model showing the ranking of users. Numbers come from UsersSample and ReposSample,
+// so they are the same as in the batch commands.
+func New(ctx context.Context, ds *github.Dataset) (*Model, error) {
+	users, err := ds.UsersSample(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	repos, err := ds.ReposSample(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m := NewOfSamples(ctx, users, repos)
+	m.ds = ds
+
+	return m, nil
+}
+
+// NewOfSamples returns a new Model showing the ranking of users of the samples, users should include bots.
+// It has no events of users and repositories, so their details can't be opened.
+func NewOfSamples(ctx context.Context, users *github.UsersSample, repos *github.ReposSample) *Model {
+	usersTable := &table{columns: []string{"activity", "pushed commits", "created PRs"}, sortBy: 1}
+	for _, u := range users.M {
+		usersTable.rows = append(usersTable.rows, row{
+			id:     u.ID,
+			name:   u.Username,
+			bot:    github.IsBotUsername(u.Username),
+			values: []int{u.Activity.Total(), u.Activity.PushedCommits, u.Activity.CreatedPullRequests},
+		})
+	}
+
+	reposTable := &table{columns: []string{"commits pushed", "watch events"}, sortBy: 1}
+	for _, r := range repos.M {
+		reposTable.rows = append(reposTable.rows, row{id: r.ID, name: r.Name, values: []int{r.CommitsPushed, r.WatchEvents}})
+	}
+
+	m := &Model{ctx: ctx, tables: [2]*table{usersTable, reposTable}, n: defaultN}
+	m.sortTables()
+
+	return m
+}
+
+func (m *Model) sortTables() {
+	for _, t := range m.tables {
+		t := t
+
+		sort.SliceStable(t.rows, func(i, j int) bool {
+			ri, rj := t.rows[i], t.rows[j]
+
+			if t.sortBy > 0 && ri.values[t.sortBy-1] != rj.values[t.sortBy-1] {
+				return ri.values[t.sortBy-1] > rj.values[t.sortBy-1]
+			}
+
+			if ri.name != rj.name {
+				return ri.name < rj.name
+			}
+
+			return ri.id < rj.id
+		})
+	}
+}
+
+// visibleRows returns the top N rows of the ranking shown, without bots unless they are toggled on
+// and matching the search.
+func (m *Model) visibleRows() []row {
+	t := m.tables[m.listView]
+	search := strings.ToLower(m.search)
+	rows := make([]row, 0, m.n)
+
+	for _, r := range t.rows {
+		if len(rows) == m.n {
+			break
+		}
+
+		if (r.bot && !m.bots) || (search != "" && !strings.Contains(strings.ToLower(r.name), search)) {
+			continue
+		}
+
+		rows = append(rows, r)
+	}
+
+	return rows
+}
+
+// Update changes the state on the key press and reports whether the user quit.
+func (m *Model) Update(k Key) bool {
+	m.status = ""
+
+	if k == KeyCtrlC {
+		return true
+	}
+
+	if m.prompt != noPrompt {
+		m.updatePrompt(k)
+
+		return false
+	}
+
+	if m.view == detailsView {
+		return m.updateDetails(k)
+	}
+
+	rows := m.visibleRows()
+
+	switch k {
+	case "q":
+		return true
+	case KeyTab, KeyLeft, KeyRight:
+		m.switchList(1 - m.listView)
+	case "u":
+		m.switchList(usersView)
+	case "r":
+		m.switchList(reposView)
+	case "b":
+		m.bots = !m.bots
+		m.cursor = 0
+	case "+", "=":
+		m.n += nStep
+	case "-":
+		if m.n -= nStep; m.n < 1 {
+			m.n = 1
+		}
+	case "n":
+		m.prompt, m.input = nPrompt, ""
+	case "/":
+		m.prompt, m.input = searchPrompt, m.search
+	case KeyEsc:
+		m.search, m.cursor = "", 0
+	case "s":
+		t := m.tables[m.listView]
+		t.sortBy = (t.sortBy + 1) % (len(t.columns) + 1)
+		m.sortTables()
+	case KeyEnter:
+		if m.cursor < len(rows) {
+			m.openDetails(rows[m.cursor].name, m.listView == usersView)
+		}
+	default:
+		if col, err := strconv.Atoi(string(k)); err == nil && col >= 0 && col <= len(m.tables[m.listView].columns) {
+			m.tables[m.listView].sortBy = col
+			m.sortTables()
+
+			break
+		}
+
+		m.cursor = moveCursor(m.cursor, len(rows), k)
+	}
+
+	if rows := m.visibleRows(); m.cursor >= len(rows) {
+		m.cursor = len(rows) - 1
+	}
+
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+
+	return false
+}
+
+func (m *Model) switchList(v view) {
+	m.view, m.listView, m.cursor, m.search = v, v, 0, ""
+}
+
+func (m *Model) updatePrompt(k Key) {
+	switch k {
+	case KeyEnter:
+		if m.prompt == searchPrompt {
+			m.search, m.cursor = m.input, 0
+		} else if n, err := strconv.Atoi(m.input); err == nil && n > 0 {
+			m.n = n
+		} else {
+			m.status = fmt.Sprintf("N should be a positive number, not %q", m.input)
+		}
+
+		m.prompt = noPrompt
+	case KeyEsc:
+		m.prompt = noPrompt
+	case KeyBackspace:
+		if r := []rune(m.input); len(r) > 0 {
+			m.input = string(r[:len(r)-1])
+		}
+	case KeyCtrlU:
+		m.input = ""
+	default:
+		if len([]rune(string(k))) == 1 {
+			m.input += string(k)
+		}
+	}
+}
+
+func (m *Model) updateDetails(k Key) bool {
+	page := m.details[len(m.details)-1]
+
+	switch k {
+	case "q":
+		return true
+	case KeyEsc, KeyBackspace, KeyLeft:
+		m.details = m.details[:len(m.details)-1]
+		if len(m.details) == 0 {
+			m.view = m.listView
+		}
+	case KeyEnter:
+		if page.cursor < len(page.details.Counterparts) {
+			m.openDetails(page.details.Counterparts[page.cursor].Name, !page.user)
+		}
+	default:
+		page.cursor = moveCursor(page.cursor, len(page.details.Counterparts), k)
+	}
+
+	return false
+}
+
+// openDetails opens details of the user or the repository with the name.
+func (m *Model) openDetails(name string, user bool) {
+	var (
+		details *github.Details
+		err     error
+	)
+
+	if m.ds == nil {
+		m.status = "details need archives, snapshots have no events of users and repositories"
+
+		return
+	}
+
+	if user {
+		details, err = m.ds.UserDetails(m.ctx, name)
+	} else {
+		details, err = m.ds.RepoDetails(m.ctx, name)
+	}
+
+	if err != nil {
+		m.status = err.Error()
+
+		return
+	}
+
+	m.details = append(m.details, &detailsPage{details: details, user: user})
+	m.view = detailsView
+}
+
+// pageSize is how far page up and page down move the cursor.
+const pageSize = 10
+
+func moveCursor(cursor, rows int, k Key) int {
+	switch k {
+	case KeyUp, "k":
+		cursor--
+	case KeyDown, "j":
+		cursor++
+	case KeyPageUp:
+		cursor -= pageSize
+	case KeyPageDown:
+		cursor += pageSize
+	case KeyHome, "g":
+		cursor = 0
+	case KeyEnd, "G":
+		cursor = rows - 1
+	}
+
+	if cursor >= rows {
+		cursor = rows - 1
+	}
+
+	if cursor < 0 {
+		cursor = 0
+	}
+
+	return cursor
+}
+
+// View renders the state into lines that fit into width and height.
+func (m *Model) View(width, height int) []string {
+	var header, body []string
+
+	cursor := m.cursor
+
+	if m.view == detailsView {
+		page := m.details[len(m.details)-1]
+		header, body = m.detailsLines(page)
+		cursor = page.cursor + len(body) - len(page.details.Counterparts)
+	} else {
+		header, body = m.listLines()
+	}
+
+	footer := m.footer()
+	lines := append([]string{}, header...)
+
+	// the body is scrolled so the cursor is visible
+	bodyHeight := height - len(header) - len(footer)
+	if bodyHeight < 1 {
+		bodyHeight = 1
+	}
+
+	offset := 0
+	if cursor >= bodyHeight {
+		offset = cursor - bodyHeight + 1
+	}
+
+	for i := offset; i < len(body) && i < offset+bodyHeight; i++ {
+		lines = append(lines, body[i])
+	}
+
+	for len(lines) < height-len(footer) {
+		lines = append(lines, "")
+	}
+
+	lines = append(lines, footer...)
+
+	for i := range lines {
+		lines[i] = fit(lines[i], width)
+	}
+
+	if len(lines) > height && height > 0 {
+		lines = lines[:height]
+	}
+
+	return lines
+}
+
+func (m *Model) listLines() ([]string, []string) {
+	t := m.tables[m.listView]
+	title := "users"
+
+	if m.listView == reposView {
+		title = "repositories"
+	}
+
+	bots := "off"
+	if m.bots {
+		bots = "on"
+	}
+
+	sortBy := "name"
+	if t.sortBy > 0 {
+		sortBy = t.columns[t.sortBy-1]
+	}
+
+	status := fmt.Sprintf("top %d %s | sorted by %s | bots: %s", m.n, title, sortBy, bots)
+	if m.listView == reposView {
+		status = fmt.Sprintf("top %d %s | sorted by %s", m.n, title, sortBy)
+	}
+
+	if m.search != "" {
+		status += fmt.Sprintf(" | search: %q", m.search)
+	}
+
+	columns := fmt.Sprintf("  %4s  %-40s", "rank", "[0] name")
+	for i, c := range t.columns {
+		columns += fmt.Sprintf(" %16s", fmt.Sprintf("[%d] %s", i+1, c))
+	}
+
+	header := []string{status, columns}
+
+	rows := m.visibleRows()
+	body := make([]string, len(rows))
+
+	for i, r := range rows {
+		marker := " "
+		if i == m.cursor {
+			marker = ">"
+		}
+
+		line := fmt.Sprintf("%s %4d. %-40s", marker, i+1, r.name)
+		for _, v := range r.values {
+			line += fmt.Sprintf(" %16d", v)
+		}
+
+		body[i] = line
+	}
+
+	if len(rows) == 0 {
+		body = append(body, "  nothing found")
+	}
+
+	return header, body
+}
+
+func (m *Model) detailsLines(page *detailsPage) ([]string, []string) {
+	d := page.details
+	kind, counterparts := "repository", "users"
+
+	if page.user {
+		kind, counterparts = "user", "repositories"
+	}
+
+	types := make([]string, 0, len(d.EventsByType))
+	for t := range d.EventsByType {
+		types = append(types, t)
+	}
+
+	sort.Slice(types, func(i, j int) bool {
+		if d.EventsByType[types[i]] != d.EventsByType[types[j]] {
+			return d.EventsByType[types[i]] > d.EventsByType[types[j]]
+		}
+
+		return types[i] < types[j]
+	})
+
+	events := make([]string, len(types))
+	for i, t := range types {
+		events[i] = fmt.Sprintf("%s: %d", t, d.EventsByType[t])
+	}
+
+	header := []string{
+		fmt.Sprintf("%s %s (id %s) | commits pushed: %d", kind, d.Name, d.ID, d.CommitsPushed),
+		"events: " + strings.Join(events, ", "),
+	}
+
+	if h := d.Health; h != nil && h.Contributions > 0 {
+		header = append(header, fmt.Sprintf(
+			"contributors: %d | top contributor share: %.1f%% | bus factor: %d (50%%), %d (80%%) | herfindahl: %.3f",
+			h.Contributors(), h.TopContributorShare*100, h.BusFactor, h.BusFactor80, h.Herfindahl,
+		))
+	}
+
+	body := []string{fmt.Sprintf("  %-50s %8s %16s", counterparts, "events", "commits pushed")}
+
+	for i, c := range d.Counterparts {
+		marker := " "
+		if i == page.cursor {
+			marker = ">"
+		}
+
+		body = append(body, fmt.Sprintf("%s %-50s %8d %16d", marker, c.Name, c.Events, c.CommitsPushed))
+	}
+
+	return header, body
+}
+
+func (m *Model) footer() []string {
+	var help string
+
+	switch {
+	case m.prompt == searchPrompt:
+		help = "search: " + m.input + "_"
+	case m.prompt == nPrompt:
+		help = "N: " + m.input + "_"
+	case m.view == detailsView:
+		help = "up/down: move | enter: open | esc: back | q: quit"
+	default:
+		help = "tab/u/r: users/repos | up/down: move | enter: details | 0-3/s: sort | b: bots | +/-/n: N | /: search | q: quit"
+	}
+
+	return []string{m.status, help}
+}
+
+// fit cuts the line to width characters.
+func fit(line string, width int) string {
+	if r := []rune(line); width > 0 && len(r) > width {
+		return string(r[:width])
+	}
+
+	return line
+}
@@ -0,0 +1,181 @@
+package tui_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/internal/tui"
+)
+
+func TestParseKeys(t *testing.T) {
+	got := tui.ParseKeys([]byte("a\x1b[A\x1b[6~\r\x7f\x1b\x03é\x1b[99x"))
+	want := []tui.Key{"a", tui.KeyUp, tui.KeyPageDown, tui.KeyEnter, tui.KeyBackspace, tui.KeyEsc, tui.KeyCtrlC, "é"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseKeys() = %q, want %q", got, want)
+	}
+}
+
+func testDataset() *github.Dataset {
+	actors := []github.ActorCSV{
+		{ID: "1", Username: "alice"},
+		{ID: "2", Username: "bob"},
+		{ID: "3", Username: "dependabot[bot]"},
+	}
+	repoCSVs := []github.RepoCSV{{ID: "1", Name: "org/one"}, {ID: "2", Name: "org/two"}}
+	events := []github.EventCSV{
+		{ID: "1", Type: github.PushEventType, ActorID: "1", RepoID: "1"},
+		{ID: "2", Type: github.PullRequestEventType, ActorID: "2", RepoID: "2"},
+		{ID: "3", Type: github.PullRequestEventType, ActorID: "3", RepoID: "2"},
+		{ID: "4", Type: github.PullRequestEventType, ActorID: "3", RepoID: "2"},
+		{ID: "5", Type: github.PullRequestEventType, ActorID: "3", RepoID: "2"},
+		{ID: "6", Type: github.WatchEventType, ActorID: "2", RepoID: "1"},
+	}
+	commits := []github.CommitCSV{{SHA: "1", EventID: "1"}, {SHA: "2", EventID: "1"}}
+
+	return github.NewDataset(actors, repoCSVs, events, commits)
+}
+
+func newTestModel(t *testing.T) *tui.Model {
+	t.Helper()
+
+	m, err := tui.New(context.Background(), testDataset())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+// names returns names of ranked rows in the rendered lines, the row under cursor is marked with >.
+func names(lines []string) []string {
+	var names []string
+
+	for _, line := range lines {
+		fields := strings.Fields(line)
+
+		marker := ""
+		if len(fields) > 0 && fields[0] == ">" {
+			marker, fields = ">", fields[1:]
+		}
+
+		// ranked rows start with the rank like "1."
+		if len(fields) > 1 && strings.HasSuffix(fields[0], ".") {
+			names = append(names, marker+fields[1])
+		}
+	}
+
+	return names
+}
+
+func TestModel_Update(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []tui.Key
+		want []string
+	}{
+		{name: "users by activity without bots", want: []string{">alice", "bob"}},
+		{name: "bots toggled", keys: []tui.Key{"b"}, want: []string{">dependabot[bot]", "alice", "bob"}},
+		{name: "sorted by name", keys: []tui.Key{"0", tui.KeyDown}, want: []string{"alice", ">bob"}},
+		{name: "sorted by created PRs", keys: []tui.Key{"3"}, want: []string{">bob", "alice"}},
+		{name: "N changed", keys: []tui.Key{"n", "1", tui.KeyEnter}, want: []string{">alice"}},
+		{name: "search", keys: []tui.Key{"/", "O", "b", tui.KeyEnter}, want: []string{">bob"}},
+		{name: "repos by commits", keys: []tui.Key{tui.KeyTab}, want: []string{">org/one", "org/two"}},
+		{name: "repos by watch events", keys: []tui.Key{"r", "s", "s", tui.KeyEnd}, want: []string{"org/one", ">org/two"}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel(t)
+
+			for _, k := range tt.keys {
+				if m.Update(k) {
+					t.Fatalf("Update(%q) quit", k)
+				}
+			}
+
+			if got := names(m.View(200, 30)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("View() rows = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModel_Details(t *testing.T) {
+	m := newTestModel(t)
+
+	// alice pushed to org/one, and bob watched it
+	for _, k := range []tui.Key{tui.KeyEnter, tui.KeyEnter} {
+		m.Update(k)
+	}
+
+	view := strings.Join(m.View(200, 30), "\n")
+	if !strings.Contains(view, "repository org/one (id 1) | commits pushed: 2") || !strings.Contains(view, "bob") ||
+		!strings.Contains(view, "bus factor: 1 (50%)") {
+		t.Errorf("View() of repository details = %s", view)
+	}
+
+	m.Update(tui.KeyEsc)
+
+	view = strings.Join(m.View(200, 30), "\n")
+	if !strings.Contains(view, "user alice (id 1)") || !strings.Contains(view, "PushEvent: 1") {
+		t.Errorf("View() of user details = %s", view)
+	}
+
+	m.Update(tui.KeyEsc)
+
+	if got := names(m.View(200, 30)); !reflect.DeepEqual(got, []string{">alice", "bob"}) {
+		t.Errorf("View() rows after details = %q", got)
+	}
+
+	if !m.Update("q") {
+		t.Error("Update(q) didn't quit")
+	}
+}
+
+func TestNewOfSamples(t *testing.T) {
+	ds := testDataset()
+
+	users, err := ds.UsersSample(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repos, err := ds.ReposSample(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := tui.NewOfSamples(context.Background(), users, repos)
+
+	if got := names(m.View(200, 30)); !reflect.DeepEqual(got, []string{">alice", "bob"}) {
+		t.Errorf("View() rows = %q", got)
+	}
+
+	// samples have no details, the ranking stays open
+	m.Update(tui.KeyEnter)
+
+	view := m.View(200, 30)
+	if got := names(view); !reflect.DeepEqual(got, []string{">alice", "bob"}) || !strings.Contains(strings.Join(view, "\n"), "details need archives") {
+		t.Errorf("View() after opening details of samples = %q", view)
+	}
+}
+
+func TestModel_View_Fits(t *testing.T) {
+	m := newTestModel(t)
+
+	lines := m.View(20, 5)
+	if len(lines) != 5 {
+		t.Errorf("View() has %d lines, want 5", len(lines))
+	}
+
+	for _, line := range lines {
+		if len([]rune(line)) > 20 {
+			t.Errorf("View() line %q is wider than 20", line)
+		}
+	}
+}
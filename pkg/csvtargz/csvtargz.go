@@ -3,10 +3,12 @@ package csvtargz
 import (
 	"archive/tar"
 	"compress/gzip"
+	"context"
 	"encoding/csv"
 	"io"
 	"io/fs"
 	"os"
+	"time"
 
 	"github.com/jszwec/csvutil"
 	"github.com/pkg/errors"
@@ -15,8 +17,47 @@ import (
 // ErrNoSuchFile is returned when no such file exists in archive.
 var ErrNoSuchFile = errors.New("no such file")
 
+// progressInterval is amount of decoded rows between progress reports.
+const progressInterval = 4096
+
+// Progress describes progress of decoding a CSV file from archive.
+type Progress struct {
+	// Member is the name of decoded CSV file in archive.
+	Member string
+	// CompressedBytes is amount of bytes of the archive consumed so far.
+	CompressedBytes int64
+	// CompressedSize is size of the archive in bytes, or -1 if it is unknown.
+	CompressedSize int64
+	// Rows is amount of CSV rows decoded so far.
+	Rows int
+	// Elapsed is time passed since decoding started.
+	Elapsed time.Duration
+	// Decompression is part of Elapsed spent reading and decompressing the archive, the rest is spent decoding CSV.
+	Decompression time.Duration
+	// Done is set in the last report, sent when the file is decoded successfully.
+	Done bool
+}
+
+// ProgressFunc receives progress reports. It is called from the decoding goroutine, so it should be fast.
+type ProgressFunc func(Progress)
+
+// Option configures decoding.
+type Option func(*options)
+
+type options struct {
+	progress ProgressFunc
+}
+
+// WithProgress makes decoding report progress to f periodically and once more when decoding is done.
+func WithProgress(f ProgressFunc) Option {
+	return func(o *options) {
+		o.progress = f
+	}
+}
+
 // DecodeByPath decodes a CSV file from .tar.gz archive by path into dst.
-func DecodeByPath(archivePath, csvFilename string, dst interface{}) error {
+// Decoding stops with ctx.Err() as soon as ctx is done.
+func DecodeByPath(ctx context.Context, archivePath, csvFilename string, dst interface{}, opts ...Option) error {
 	gzFile, err := os.Open(archivePath)
 	if err != nil {
 		return err
@@ -26,7 +67,7 @@ func DecodeByPath(archivePath, csvFilename string, dst interface{}) error {
 		_ = gzFile.Close()
 	}()
 
-	if err := DecodeFromFile(gzFile, csvFilename, dst); err != nil {
+	if err := DecodeFromFile(ctx, gzFile, csvFilename, dst, opts...); err != nil {
 		return err
 	}
 
@@ -34,18 +75,54 @@ func DecodeByPath(archivePath, csvFilename string, dst interface{}) error {
 }
 
 // DecodeFromFile decodes a CSV file from .tar.gz archive into dst.
-func DecodeFromFile(gzFile fs.File, csvFilename string, dst interface{}) error {
-	return withCSVReaderFromTarGz(gzFile, csvFilename, func(csvReader *csv.Reader) error {
-		csvDecoder, err := csvutil.NewDecoder(csvReader)
+// Decoding stops with ctx.Err() as soon as ctx is done.
+func DecodeFromFile(ctx context.Context, gzFile fs.File, csvFilename string, dst interface{}, opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	size := int64(-1)
+	if stat, err := gzFile.Stat(); err == nil {
+		size = stat.Size()
+	}
+
+	tracker := &progressTracker{
+		f:        o.progress,
+		start:    time.Now(),
+		progress: Progress{Member: csvFilename, CompressedSize: size},
+	}
+
+	src := &ctxReader{ctx: ctx, r: gzFile, n: &tracker.progress.CompressedBytes}
+
+	err := withCSVReaderFromTarGz(src, csvFilename, func(r io.Reader) error {
+		csvDecoder, err := csvutil.NewDecoder(&rowCounter{
+			r:       csv.NewReader(&timedReader{r: r, d: &tracker.progress.Decompression}),
+			tracker: tracker,
+		})
 		if err != nil {
 			return err
 		}
 
-		return csvDecoder.Decode(dst)
+		err = csvDecoder.Decode(dst)
+		// file with the header only has no rows to decode
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		return err
 	})
+	if err != nil {
+		return err
+	}
+
+	tracker.progress.Done = true
+	tracker.report()
+
+	return nil
 }
 
-func withCSVReaderFromTarGz(gzFile io.Reader, csvFilename string, f func(csvReader *csv.Reader) error) error {
+func withCSVReaderFromTarGz(gzFile io.Reader, csvFilename string, f func(r io.Reader) error) error {
 	gzReader, err := gzip.NewReader(gzFile)
 	if err != nil {
 		return err
@@ -55,20 +132,20 @@ func withCSVReaderFromTarGz(gzFile io.Reader, csvFilename string, f func(csvRead
 		_ = gzReader.Close()
 	}()
 
-	csvReader, err := newCSVReaderFromTar(tar.NewReader(gzReader), csvFilename)
+	r, err := findInTar(tar.NewReader(gzReader), csvFilename)
 	if err != nil {
 		return err
 	}
 
-	if err := f(csvReader); err != nil {
+	if err := f(r); err != nil {
 		return err
 	}
 
 	return gzReader.Close()
 }
 
-func newCSVReaderFromTar(tr *tar.Reader, csvFilename string) (*csv.Reader, error) {
-	// iterate through the files in the archive and return csv reader of searched file
+func findInTar(tr *tar.Reader, csvFilename string) (io.Reader, error) {
+	// iterate through the files in the archive and return reader of searched file
 	for {
 		hdr, err := tr.Next()
 		if errors.Is(err, io.EOF) {
@@ -80,9 +157,82 @@ func newCSVReaderFromTar(tr *tar.Reader, csvFilename string) (*csv.Reader, error
 		}
 
 		if hdr.Name == csvFilename {
-			break
+			return tr, nil
 		}
 	}
+}
+
+// ctxReader fails reads once ctx is done and counts bytes read. Everything is read through it,
+// so a long decode stops promptly.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+	n   *int64
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := r.r.Read(p)
+	*r.n += int64(n)
+
+	return n, err
+}
+
+// timedReader accumulates time spent in reads.
+type timedReader struct {
+	r io.Reader
+	d *time.Duration
+}
+
+func (r *timedReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := r.r.Read(p)
+	*r.d += time.Since(start)
+
+	return n, err
+}
+
+// rowCounter counts CSV rows read after the header and reports progress every progressInterval rows.
+type rowCounter struct {
+	r          *csv.Reader
+	tracker    *progressTracker
+	headerRead bool
+}
+
+func (rc *rowCounter) Read() ([]string, error) {
+	record, err := rc.r.Read()
+	if err != nil {
+		return record, err
+	}
+
+	if !rc.headerRead {
+		rc.headerRead = true
+
+		return record, nil
+	}
+
+	rc.tracker.progress.Rows++
+	if rc.tracker.progress.Rows%progressInterval == 0 {
+		rc.tracker.report()
+	}
+
+	return record, nil
+}
+
+type progressTracker struct {
+	f        ProgressFunc
+	start    time.Time
+	progress Progress
+}
+
+func (t *progressTracker) report() {
+	if t.f == nil {
+		return
+	}
 
-	return csv.NewReader(tr), nil
+	t.progress.Elapsed = time.Since(t.start)
+	t.f(t.progress)
 }
@@ -0,0 +1,143 @@
+package csvtargz_test
+
+import (
+	"context"
+	"io/fs"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/pkg/csvtargz"
+	"github.com/levakin/analytics-software-engineer-assignment/samples"
+)
+
+const archivePath = "data.tar.gz"
+
+func TestDecodeFromFile(t *testing.T) {
+	gzFile, err := samples.FS.Open(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		_ = gzFile.Close()
+	}()
+
+	var events []github.EventCSV
+	if err := csvtargz.DecodeFromFile(context.Background(), gzFile, github.EventsCSVFilename, &events); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) == 0 || events[0].ID == "" || events[0].Type == "" {
+		t.Errorf("DecodeFromFile() decoded %d events, first is %v", len(events), events[0])
+	}
+}
+
+func TestDecodeFromFile_Progress(t *testing.T) {
+	gzFile, err := samples.FS.Open(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		_ = gzFile.Close()
+	}()
+
+	var reports []csvtargz.Progress
+
+	var commits []github.CommitCSV
+	if err := csvtargz.DecodeFromFile(context.Background(), gzFile, github.CommitsCSVFilename, &commits, csvtargz.WithProgress(func(p csvtargz.Progress) {
+		reports = append(reports, p)
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reports) < 2 {
+		t.Fatalf("got %d progress reports, want periodic reports and the final one", len(reports))
+	}
+
+	for i := 1; i < len(reports); i++ {
+		if reports[i].Rows < reports[i-1].Rows || reports[i].CompressedBytes < reports[i-1].CompressedBytes {
+			t.Errorf("progress report %d = %+v goes back from %+v", i, reports[i], reports[i-1])
+		}
+	}
+
+	last := reports[len(reports)-1]
+	if !last.Done || last.Rows != len(commits) || last.Member != github.CommitsCSVFilename {
+		t.Errorf("last progress report = %+v, want done with %d rows of %s", last, len(commits), github.CommitsCSVFilename)
+	}
+
+	if last.CompressedBytes <= 0 || last.CompressedBytes > last.CompressedSize {
+		t.Errorf("last progress report consumed %d bytes of %d", last.CompressedBytes, last.CompressedSize)
+	}
+}
+
+func TestDecodeFromFile_NoSuchFile(t *testing.T) {
+	gzFile, err := samples.FS.Open(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		_ = gzFile.Close()
+	}()
+
+	var events []github.EventCSV
+	if err := csvtargz.DecodeFromFile(context.Background(), gzFile, "data/nope.csv", &events); !errors.Is(err, csvtargz.ErrNoSuchFile) {
+		t.Errorf("DecodeFromFile() error = %v, want ErrNoSuchFile", err)
+	}
+}
+
+// cancelingFile cancels the context after the first read.
+type cancelingFile struct {
+	fs.File
+	cancel    context.CancelFunc
+	bytesRead int
+}
+
+func (f *cancelingFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	f.bytesRead += n
+	f.cancel()
+
+	return n, err
+}
+
+func TestDecodeFromFile_Canceled(t *testing.T) {
+	gzFile, err := samples.FS.Open(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		_ = gzFile.Close()
+	}()
+
+	stat, err := gzFile.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f := &cancelingFile{File: gzFile, cancel: cancel}
+
+	var commits []github.CommitCSV
+	if err := csvtargz.DecodeFromFile(ctx, f, github.CommitsCSVFilename, &commits); !errors.Is(err, context.Canceled) {
+		t.Fatalf("DecodeFromFile() error = %v, want context.Canceled", err)
+	}
+
+	if f.bytesRead >= int(stat.Size()) {
+		t.Errorf("DecodeFromFile() read %d bytes of %d, want to stop early", f.bytesRead, stat.Size())
+	}
+}
+
+func TestDecodeByPath_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var actors []github.ActorCSV
+	if err := csvtargz.DecodeByPath(ctx, "../../samples/"+archivePath, github.ActorsCSVFilename, &actors); !errors.Is(err, context.Canceled) {
+		t.Errorf("DecodeByPath() error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,106 @@
+package csvtargz
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/jszwec/csvutil"
+	"github.com/pkg/errors"
+)
+
+// Reader reads rows of a CSV file from .tar.gz archive one at a time, so the file is never held in memory.
+type Reader struct {
+	gzFile   fs.File
+	gzReader *gzip.Reader
+	decoder  *csvutil.Decoder
+	tracker  *progressTracker
+}
+
+// OpenByPath opens a CSV file of .tar.gz archive by path for reading row by row.
+// Reading stops with ctx.Err() as soon as ctx is done.
+func OpenByPath(ctx context.Context, archivePath, csvFilename string, opts ...Option) (*Reader, error) {
+	gzFile, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return OpenFile(ctx, gzFile, csvFilename, opts...)
+}
+
+// OpenFile opens a CSV file of .tar.gz archive for reading row by row, Close of the reader closes gzFile too.
+// Reading stops with ctx.Err() as soon as ctx is done.
+func OpenFile(ctx context.Context, gzFile fs.File, csvFilename string, opts ...Option) (*Reader, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	size := int64(-1)
+	if stat, err := gzFile.Stat(); err == nil {
+		size = stat.Size()
+	}
+
+	r := &Reader{
+		gzFile: gzFile,
+		tracker: &progressTracker{
+			f:        o.progress,
+			start:    time.Now(),
+			progress: Progress{Member: csvFilename, CompressedSize: size},
+		},
+	}
+
+	if err := r.open(ctx, csvFilename); err != nil {
+		_ = r.Close()
+
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *Reader) open(ctx context.Context, csvFilename string) error {
+	gzReader, err := gzip.NewReader(&ctxReader{ctx: ctx, r: r.gzFile, n: &r.tracker.progress.CompressedBytes})
+	if err != nil {
+		return err
+	}
+
+	r.gzReader = gzReader
+
+	member, err := findInTar(tar.NewReader(gzReader), csvFilename)
+	if err != nil {
+		return err
+	}
+
+	r.decoder, err = csvutil.NewDecoder(&rowCounter{
+		r:       csv.NewReader(&timedReader{r: member, d: &r.tracker.progress.Decompression}),
+		tracker: r.tracker,
+	})
+
+	return err
+}
+
+// Read decodes the next row into dst, which should be a pointer to a struct. It returns io.EOF after the last row.
+func (r *Reader) Read(dst interface{}) error {
+	err := r.decoder.Decode(dst)
+	if errors.Is(err, io.EOF) && !r.tracker.progress.Done {
+		r.tracker.progress.Done = true
+		r.tracker.report()
+	}
+
+	return err
+}
+
+// Close closes the archive.
+func (r *Reader) Close() error {
+	if r.gzReader != nil {
+		_ = r.gzReader.Close()
+	}
+
+	return r.gzFile.Close()
+}
@@ -0,0 +1,81 @@
+package csvtargz_test
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/pkg/csvtargz"
+	"github.com/levakin/analytics-software-engineer-assignment/samples"
+)
+
+// TestReader checks that rows read one by one are the rows decoded at once.
+func TestReader(t *testing.T) {
+	gzFile, err := samples.FS.Open(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want []github.CommitCSV
+	if err := csvtargz.DecodeFromFile(context.Background(), gzFile, github.CommitsCSVFilename, &want); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = gzFile.Close()
+
+	var last csvtargz.Progress
+
+	r, err := csvtargz.OpenByPath(context.Background(), "../../samples/"+archivePath, github.CommitsCSVFilename,
+		csvtargz.WithProgress(func(p csvtargz.Progress) {
+			last = p
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		_ = r.Close()
+	}()
+
+	var got []github.CommitCSV
+
+	for {
+		var c github.CommitCSV
+
+		err := r.Read(&c)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got = append(got, c)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Read() read %d commits, want the %d decoded ones", len(got), len(want))
+	}
+
+	if !last.Done || last.Rows != len(want) {
+		t.Errorf("last progress report = %+v, want done with %d rows", last, len(want))
+	}
+}
+
+func TestOpenByPath_Errors(t *testing.T) {
+	if _, err := csvtargz.OpenByPath(context.Background(), "../../samples/"+archivePath, "data/nope.csv"); !errors.Is(err, csvtargz.ErrNoSuchFile) {
+		t.Errorf("OpenByPath() error = %v, want ErrNoSuchFile", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := csvtargz.OpenByPath(ctx, "../../samples/"+archivePath, github.ActorsCSVFilename); !errors.Is(err, context.Canceled) {
+		t.Errorf("OpenByPath() error = %v, want context.Canceled", err)
+	}
+}
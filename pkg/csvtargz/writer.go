@@ -0,0 +1,147 @@
+package csvtargz
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/jszwec/csvutil"
+	"github.com/pkg/errors"
+)
+
+// ErrWriterClosed is returned when a file is added to a closed Writer.
+var ErrWriterClosed = errors.New("writer is closed")
+
+// Writer writes CSV files into .tar.gz archive, so they can be read back with DecodeFromFile.
+type Writer struct {
+	gzWriter  *gzip.Writer
+	tarWriter *tar.Writer
+	dirs      map[string]bool
+	modTime   time.Time
+	closed    bool
+}
+
+// NewWriter returns a new Writer writing archive into w. Close must be called to flush the archive.
+func NewWriter(w io.Writer) *Writer {
+	gzWriter := gzip.NewWriter(w)
+
+	return &Writer{
+		gzWriter:  gzWriter,
+		tarWriter: tar.NewWriter(gzWriter),
+		dirs:      make(map[string]bool),
+		modTime:   time.Now().Truncate(time.Second),
+	}
+}
+
+// Encode encodes src, a slice of structs with csv tags, as CSV file with the header into the archive.
+// Parent directories of csvFilename are added to the archive the first time they are needed.
+func (w *Writer) Encode(csvFilename string, src interface{}) error {
+	if w.closed {
+		return ErrWriterClosed
+	}
+
+	data, err := csvutil.Marshal(src)
+	if err != nil {
+		return err
+	}
+
+	if err := w.addDirs(path.Dir(csvFilename)); err != nil {
+		return err
+	}
+
+	if err := w.tarWriter.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     csvFilename,
+		Size:     int64(len(data)),
+		Mode:     0o644,
+		ModTime:  w.modTime,
+	}); err != nil {
+		return err
+	}
+
+	_, err = w.tarWriter.Write(data)
+
+	return err
+}
+
+func (w *Writer) addDirs(dir string) error {
+	if dir == "." || dir == "/" || w.dirs[dir] {
+		return nil
+	}
+
+	if err := w.addDirs(path.Dir(dir)); err != nil {
+		return err
+	}
+
+	w.dirs[dir] = true
+
+	return w.tarWriter.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     dir + "/",
+		Mode:     0o755,
+		ModTime:  w.modTime,
+	})
+}
+
+// Close finishes the archive. It doesn't close the underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+
+	w.closed = true
+
+	if err := w.tarWriter.Close(); err != nil {
+		return err
+	}
+
+	return w.gzWriter.Close()
+}
+
+// File is a CSV file to be written into archive.
+type File struct {
+	// Name is the path of the file in archive.
+	Name string
+	// Src is a slice of structs with csv tags.
+	Src interface{}
+}
+
+// EncodeToPath writes the files into a new .tar.gz archive at archivePath.
+// The archive is written to a temporary file first, so it is never left partially written.
+func EncodeToPath(archivePath string, files ...File) error {
+	tmp, err := os.CreateTemp(filepath.Dir(archivePath), filepath.Base(archivePath)+".tmp*")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	w := NewWriter(tmp)
+
+	for _, f := range files {
+		if err := w.Encode(f.Name, f.Src); err != nil {
+			return err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	if err := tmp.Chmod(0o644); err != nil {
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), archivePath)
+}
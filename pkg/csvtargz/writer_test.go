@@ -0,0 +1,80 @@
+package csvtargz_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/pkg/csvtargz"
+)
+
+func TestEncodeToPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.tar.gz")
+
+	actors := []github.ActorCSV{
+		{ID: "1", Username: "alice"},
+		{ID: "2", Username: `quoted, "name"`},
+	}
+	commits := []github.CommitCSV{
+		{SHA: "sha", Message: "multi\nline", EventID: "1"},
+	}
+
+	if err := csvtargz.EncodeToPath(path,
+		csvtargz.File{Name: github.ActorsCSVFilename, Src: actors},
+		csvtargz.File{Name: github.CommitsCSVFilename, Src: commits},
+		csvtargz.File{Name: github.EventsCSVFilename, Src: []github.EventCSV{}},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	var gotActors []github.ActorCSV
+	if err := csvtargz.DecodeByPath(context.Background(), path, github.ActorsCSVFilename, &gotActors); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(gotActors, actors) {
+		t.Errorf("decoded actors = %v, want %v", gotActors, actors)
+	}
+
+	var gotCommits []github.CommitCSV
+	if err := csvtargz.DecodeByPath(context.Background(), path, github.CommitsCSVFilename, &gotCommits); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(gotCommits, commits) {
+		t.Errorf("decoded commits = %v, want %v", gotCommits, commits)
+	}
+
+	var gotEvents []github.EventCSV
+	if err := csvtargz.DecodeByPath(context.Background(), path, github.EventsCSVFilename, &gotEvents); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(gotEvents) != 0 {
+		t.Errorf("decoded events = %v, want none", gotEvents)
+	}
+
+	if entries, err := os.ReadDir(filepath.Dir(path)); err != nil || len(entries) != 1 {
+		t.Errorf("archive dir has %v, want only the archive, error = %v", entries, err)
+	}
+}
+
+func TestWriter_Closed(t *testing.T) {
+	w := csvtargz.NewWriter(&discard{})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Encode(github.ActorsCSVFilename, []github.ActorCSV{}); err == nil {
+		t.Error("Encode() after Close() should fail")
+	}
+}
+
+type discard struct{}
+
+func (discard) Write(p []byte) (int, error) {
+	return len(p), nil
+}
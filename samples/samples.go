@@ -1,6 +1,42 @@
 package samples
 
-import "embed"
+import (
+	"context"
+	"embed"
+
+	"github.com/levakin/analytics-software-engineer-assignment/internal/github"
+	"github.com/levakin/analytics-software-engineer-assignment/pkg/csvtargz"
+)
+
+// ArchivePath is path of the sample archive in FS.
+const ArchivePath = "data.tar.gz"
 
 //go:embed data.tar.gz
 var FS embed.FS
+
+// Archive decodes all CSV files of the sample archive.
+func Archive(ctx context.Context) (*github.Archive, error) {
+	var archive github.Archive
+
+	for filename, dst := range map[string]interface{}{
+		github.ActorsCSVFilename:  &archive.Actors,
+		github.ReposCSVFilename:   &archive.Repos,
+		github.EventsCSVFilename:  &archive.Events,
+		github.CommitsCSVFilename: &archive.Commits,
+	} {
+		gzFile, err := FS.Open(ArchivePath)
+		if err != nil {
+			return nil, err
+		}
+
+		err = csvtargz.DecodeFromFile(ctx, gzFile, filename, dst)
+
+		_ = gzFile.Close()
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &archive, nil
+}